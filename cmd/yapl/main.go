@@ -1,18 +1,82 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/trace"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"yapl/internal/app"
 	"yapl/internal/archive"
+	"yapl/internal/command"
 	"yapl/internal/config"
+	"yapl/internal/crash"
+	"yapl/internal/dependency"
+	"yapl/internal/downloads"
+	"yapl/internal/events"
+	"yapl/internal/fs"
+	"yapl/internal/importer"
+	"yapl/internal/journal"
+	"yapl/internal/library"
+	"yapl/internal/peer"
+	"yapl/internal/plugin"
+	"yapl/internal/policy"
+	"yapl/internal/purge"
+	"yapl/internal/repo"
+	"yapl/internal/saves"
+	"yapl/internal/startup"
+	"yapl/internal/store"
+	"yapl/internal/telemetry"
+	"yapl/internal/tui"
+	"yapl/internal/warn"
+	"yapl/pkg/yapl"
 )
 
+// commands are the built-in commands; any other command runs the plugin yapl-<command>.
+var commands = []string{
+	"setup", "package", "unpackage", "run", "winecfg", "regedit", "control", "kill", "clone",
+	"saves", "link-windows", "detect-exe", "logs", "compress", "shortcut", "steam", "sessions", "parental",
+	"library", "seed", "peers", "import", "downloads", "runtime", "proton", "licenses", "validate", "lint", "telemetry", "config", "known-issues", "store", "purge", "tui", "prefix", "repo", "install", "source", "search", "verify", "warm", "plugins",
+}
+
 func main() {
+	started := time.Now()
 	log.SetFlags(0)
+	invocationDir, _ = os.Getwd()
+
+	// Plugins parse their own flags, so they are started before yapl's are parsed.
+	// They may live in the root's plugins directory, so the root is entered to look.
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") && !slices.Contains(commands, os.Args[1]) {
+		if err := yapl.EnterRoot(""); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if _, ok := plugin.Path(os.Args[1]); ok {
+			code, err := plugin.Exec(os.Args[1], os.Args[2:])
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			os.Exit(code)
+		}
+		// Not a plugin; --root is yet to be applied, relative to where yapl started.
+		if err := os.Chdir(invocationDir); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+	}
 
 	// --- Flag Definition ---
 	gameName := flag.String("game", "", "The name of the game directory inside ./games/.")
@@ -21,23 +85,176 @@ func main() {
 	packageFormat := flag.String("format", "gz", "Compression format for packaging (gz, xz, zst).")
 	debugMode := flag.Bool("debug", false, "Enable verbose Proton logging for debugging.")
 	isSteamPrefix := flag.Bool("steam", false, "Run as a Steam client prefix, ignoring the configured executable.")
-	flag.Parse()
+	userName := flag.String("user", "", "Only show sessions of this user (sessions command).")
+	adminPIN := flag.String("pin", "", "Admin PIN to bypass parental controls.")
+	tarFormat := flag.String("tar-format", "", "Tar format for packaging (pax, gnu). Default: simplest format per entry.")
+	reproducible := flag.Bool("reproducible", false, "Create byte-identical packages for identical inputs.")
+	level := flag.Int("level", -1, "Compression level for packaging (gz 1-9, xz 0-9, zst 1-22). Default: packaging.levels or the format's default.")
+	splitSize := flag.String("split-size", "", "Split package bundles into volumes of this size (e.g. 4G, 700M).")
+	threads := flag.Int("threads", 0, "Compression threads for packaging (0 = all CPUs).")
+	listOnly := flag.Bool("list", false, "List the contents of the archives instead of extracting them (unpackage command).")
+	var include stringList
+	flag.Var(&include, "include", "Only extract paths matching this glob; may be repeated (unpackage command).")
+	jsonOutput := flag.Bool("json", false, "Write machine-readable JSON events to stdout; human-readable output goes to stderr.")
+	copyPrefix := flag.Bool("copy", false, "Copy the prefix instead of linking it (import prefix).")
+	follow := flag.Bool("tail", false, "Keep printing new lines of the log (logs command).")
+	full := flag.Bool("full", false, "Also bundle the Proton build, runtime and dependencies the game uses (package command).")
+	releaseVersion := flag.String("release-version", "", "Release version to record in the bundle (package command).")
+	notesFile := flag.String("notes-file", "", "File with release notes to record in the bundle (package command).")
+	diffAgainst := flag.String("diff-against", "", "Only package a patch from this earlier bundle of the game (package command).")
+	dest := flag.String("dest", "", "Directory name to unpackage a single archive to, instead of the archive's name (unpackage command).")
+	applyPatch := flag.Bool("apply-patch", false, "Apply patch bundles to the games they were made for (unpackage command).")
+	encrypt := flag.Bool("encrypt", false, "Encrypt the bundle with a passphrase from $YAPL_PASSPHRASE or the terminal (package command).")
+	all := flag.Bool("all", false, "Act on every game and app (saves backup).")
+	strict := flag.Bool("strict", false, "Fail the command if it logs any warning or error, e.g. for CI and packaging.")
+	repair := flag.Bool("repair", false, "Reinstall damaged components from where they were downloaded (verify command).")
+	force := flag.Bool("force", false, "Force the operation (kill: SIGKILL leftover processes; unpackage: extract over existing directories; others: ignore another yapl working on the game).")
+	profileStartup := flag.Bool("profile-startup", false, "Time each phase of the launch up to the game's first window and print a breakdown (run command).")
+	mangoHud := flag.Bool("mangohud", false, "Show the MangoHud overlay for this run, even if 'mangohud' is off in the config.")
+	background := flag.Bool("background", false, "Queue downloads behind downloads of other yapl commands (e.g. for scheduled updates).")
+	pprofAddr := flag.String("pprof", "", "Serve Go's pprof profiles on this address while the command runs, e.g. localhost:6060.")
+	traceFile := flag.String("trace", "", "Write a Go execution trace of the command to this file, for 'go tool trace'.")
+	gameOutput := flag.String("game-output", "", "Where launched programs write their output: '-' for stdout alone, with yapl's messages on stderr, or a file to append to.")
+	root := flag.String("root", "", "Directory that holds runner.json, games/, apps/, proton/ and the rest (default: $YAPL_HOME, runner.json's 'root', or the current directory).")
+	args := parseArgs()
+	if err := yapl.EnterRoot(*root); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	if len(args) == 0 {
+		log.Fatalf("❌ Error: No command provided. Use %s, or a plugin (yapl-<name> in plugins/ or on PATH).", quoteCommands())
+	}
+	command, args := args[0], args[1:]
 
-	if flag.NArg() == 0 {
-		log.Fatalf("❌ Error: No command provided. Use 'setup', 'package', 'unpackage', or 'run'.")
+	if *jsonOutput {
+		events.Enable(os.Stdout)
+		os.Stdout = os.Stderr
+		log.SetOutput(events.LogWriter(os.Stderr))
+	}
+	if *gameOutput != "" {
+		if err := routeGameOutput(*gameOutput, *jsonOutput); err != nil {
+			log.Fatalf("❌ --game-output: %v", err)
+		}
+	}
+	log.SetOutput(warn.Writer(log.Writer()))
+	warn.SetStrict(*strict)
+	// log.Fatalf exits without running deferred calls, so this only reports success.
+	defer events.Emit("result", map[string]interface{}{"command": command, "ok": true})
+	defer warn.Check()
+	defer warn.Summary()
+	stopProfiling, err := startProfiling(*pprofAddr, *traceFile)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	defer stopProfiling()
+	downloads.SetBackground(*background)
+	archive.SetPassphrase(readPassphrase)
+	if *profileStartup {
+		startup.Enable(started)
 	}
-	command := flag.Arg(0)
 
 	// --- Command Dispatching ---
-	if command == "unpackage" {
-		handleUnpackage()
+	switch command {
+	case "unpackage":
+		handleUnpackage(args, *listOnly, *applyPatch, include, *dest, *force)
+		return
+	case "sessions":
+		handleSessions(*gameName+*appName, *userName)
+		return
+	case "parental":
+		handleParental(args)
+		return
+	case "library":
+		handleLibrary(args)
+		return
+	case "repo":
+		handleRepo(args)
+		return
+	case "install":
+		handleInstall(args)
+		return
+	case "source":
+		handleSource(args)
+		return
+	case "search":
+		handleSearch(args)
+		return
+	case "seed", "peers":
+		handlePeers(command, args)
+		return
+	case "import":
+		handleImport(args, *gameName, *copyPrefix)
+		return
+	case "tui":
+		handleTUI(*upgradeProton, *debugMode, *isSteamPrefix)
+		return
+	case "downloads":
+		handleDownloads(args)
+		return
+	case "runtime":
+		handleRuntime(args)
+		return
+	case "proton":
+		handleProton(args)
 		return
+	case "licenses":
+		handleLicenses()
+		return
+	case "plugins":
+		handlePlugins()
+		return
+	case "verify":
+		handleVerify(*repair)
+		return
+	case "validate":
+		handleValidate()
+		return
+	case "lint":
+		handleLint()
+		return
+	case "telemetry":
+		handleTelemetry(args)
+		return
+	case "config":
+		handleConfig(args, *gameName, *appName)
+		return
+	case "known-issues":
+		handleKnownIssues(args, *gameName, *appName)
+		return
+	case "store":
+		handleStore(args)
+		return
+	case "purge":
+		handlePurge(args, *force)
+		return
+	case "saves":
+		if len(args) > 0 && args[0] == "update-db" {
+			handleSaveDatabaseUpdate(args)
+			return
+		}
+		if len(args) > 0 && args[0] == "backup" && *all {
+			handleBackupAll()
+			return
+		}
 	}
 
 	app, err := initializeApp(*gameName, *appName, *upgradeProton, *debugMode, *isSteamPrefix)
 	if err != nil {
 		log.Fatalf("❌ Error initializing application: %v", err)
 	}
+	app.AdminPIN = *adminPIN
+	startup.Phase("config load")
+	if *mangoHud {
+		app.AppConfig.MangoHud = true
+	}
+
+	if locks(command, args) {
+		unlock, err := app.Lock(command, *force)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		defer unlock()
+	}
 
 	switch command {
 	case "setup":
@@ -45,18 +262,192 @@ func main() {
 			log.Fatalf("❌ Setup failed: %v", err)
 		}
 	case "package":
-		if err := app.Package(*packageFormat); err != nil {
+		// Ctrl-C stops the walk and removes the partly written bundle.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		archive.SetContext(ctx)
+		archive.SetReproducible(*reproducible)
+		archive.SetEncrypt(*encrypt)
+		archive.SetThreads(*threads)
+		if *splitSize != "" {
+			size, err := archive.ParseSize(*splitSize)
+			if err != nil {
+				log.Fatalf("❌ --split-size: %v", err)
+			}
+			archive.SetSplitSize(size)
+		}
+		if *level != -1 {
+			if err := archive.SetLevel(*packageFormat, *level); err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+		}
+		if err := archive.SetTarFormat(*tarFormat); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if err := app.Package(*packageFormat, packageOptions(*full, *releaseVersion, *notesFile, *diffAgainst)); err != nil {
 			log.Fatalf("❌ Packaging failed: %v", err)
 		}
 	case "run":
 		if err := app.Run(); err != nil {
 			log.Fatalf("❌ Run failed: %v", err)
 		}
+	case "kill":
+		if err := app.Kill(*force); err != nil {
+			log.Fatalf("❌ Kill failed: %v", err)
+		}
+	case "warm":
+		if err := app.Warm(); err != nil {
+			log.Fatalf("❌ Warm failed: %v", err)
+		}
+	case "clone":
+		if len(args) == 0 {
+			log.Fatalf("❌ Usage: yapl --game <name> clone <new-name>")
+		}
+		if err := app.Clone(args[0]); err != nil {
+			log.Fatalf("❌ Clone failed: %v", err)
+		}
+	case "saves":
+		handleSaves(app, args)
+	case "prefix":
+		handlePrefix(app, args)
+	case "link-windows":
+		if len(args) == 0 || len(args) > 2 {
+			log.Fatalf("❌ Usage: yapl --game <name> link-windows <dir-on-windows-partition> [path-in-prefix]")
+		}
+		target := ""
+		if len(args) == 2 {
+			target = args[1]
+		}
+		if err := app.LinkWindowsGame(userPath(args[0]), target); err != nil {
+			log.Fatalf("❌ Linking failed: %v", err)
+		}
+	case "detect-exe":
+		if err := app.DetectExecutable(isInteractive()); err != nil {
+			log.Fatalf("❌ Executable detection failed: %v", err)
+		}
+	case "logs":
+		if err := app.ShowLogs(*follow); err != nil {
+			log.Fatalf("❌ Could not show logs: %v", err)
+		}
+	case "compress":
+		if err := app.Compress(); err != nil {
+			log.Fatalf("❌ Compression failed: %v", err)
+		}
+	case "shortcut":
+		if err := app.CreateShortcut(); err != nil {
+			log.Fatalf("❌ Creating shortcut failed: %v", err)
+		}
+	case "steam":
+		if len(args) == 0 || args[0] != "add" {
+			log.Fatalf("❌ Usage: yapl --game <name> steam add")
+		}
+		if err := app.AddToSteam(); err != nil {
+			log.Fatalf("❌ Adding to Steam failed: %v", err)
+		}
+	case "winecfg", "regedit", "control":
+		if err := app.RunTool(command); err != nil {
+			log.Fatalf("❌ %s failed: %v", command, err)
+		}
 	default:
 		log.Fatalf("❌ Error: Unknown command '%s'.", command)
 	}
 }
 
+// lockedCommands change a game's or app's prefix or directory, so only one of them may
+// work on it at a time (see app.LockFile).
+var lockedCommands = []string{"setup", "run", "package", "clone", "prefix", "compress", "link-windows", "winecfg", "regedit", "control", "warm"}
+
+// locks reports whether the command changes the game or app, so it takes its lock.
+func locks(command string, args []string) bool {
+	sub := ""
+	if len(args) > 0 {
+		sub = args[0]
+	}
+	switch command {
+	case "saves":
+		return sub == "restore"
+	case "prefix":
+		return sub != "programs" && sub != "diff"
+	}
+	return slices.Contains(lockedCommands, command)
+}
+
+// quoteCommands lists the built-in commands for usage messages.
+func quoteCommands() string {
+	quoted := make([]string, len(commands))
+	for i, c := range commands {
+		quoted[i] = "'" + c + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// stringList is a flag that can be given several times.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// routeGameOutput keeps the output of launched programs apart from yapl's messages. With
+// "-" it has stdout to itself and yapl's messages move to stderr, where the program's
+// stderr lines are marked with "[game]"; otherwise it is appended to the file dest, which
+// is relative to the directory yapl was started in.
+func routeGameOutput(dest string, jsonOutput bool) error {
+	if dest != "-" {
+		if !filepath.IsAbs(dest) && invocationDir != "" {
+			dest = filepath.Join(invocationDir, dest)
+		}
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		command.SetGameOutput(f, f)
+		return nil
+	}
+	if jsonOutput {
+		return errors.New("'-' cannot be used with --json, which writes its events to stdout")
+	}
+	command.SetGameOutput(os.Stdout, command.PrefixLines(os.Stderr, "[game] "))
+	os.Stdout = os.Stderr
+	return nil
+}
+
+// invocationDir is the directory yapl was started in, before it moved to its root.
+var invocationDir string
+
+// userPath resolves a path given on the command line against the directory yapl was
+// started in, if a file exists there; anything else (URLs, slugs, paths inside the root)
+// is returned unchanged.
+func userPath(p string) string {
+	if p == "" || filepath.IsAbs(p) || invocationDir == "" {
+		return p
+	}
+	candidate := filepath.Join(invocationDir, p)
+	if _, err := os.Stat(candidate); err != nil {
+		return p
+	}
+	return candidate
+}
+
+// parseArgs parses flags wherever they appear, so 'yapl --game foo run' and
+// 'yapl run --game foo' are equivalent, and returns the remaining arguments.
+func parseArgs() []string {
+	flag.Parse()
+	var positional []string
+	rest := flag.Args()
+	for len(rest) > 0 {
+		positional = append(positional, rest[0])
+		flag.CommandLine.Parse(rest[1:])
+		rest = flag.Args()
+	}
+	return positional
+}
+
 // initializeApp determines the target, loads configuration, and constructs the main App object.
 func initializeApp(gameName, appName string, force, debug, steam bool) (*app.App, error) {
 	if gameName == "" && appName == "" {
@@ -70,34 +461,1393 @@ func initializeApp(gameName, appName string, force, debug, steam bool) (*app.App
 		targetName = appName
 	}
 
-	globalCfg, err := config.LoadOrCreateGlobal("runner.json")
+	globalCfg, err := yapl.LoadGlobalConfig()
 	if err != nil {
 		return nil, fmt.Errorf("could not load global config: %w", err)
 	}
-
-	appCfg, err := config.LoadOrCreateApp(targetType, targetName, globalCfg)
+	appCfg, err := yapl.LoadAppConfig(targetType, targetName, &globalCfg)
 	if err != nil {
-		return nil, fmt.Errorf("could not load or create app config: %w", err)
+		return nil, err
 	}
 
 	return app.New(targetType, targetName, force, debug, steam, globalCfg, appCfg), nil
 }
 
 // handleUnpackage isolates the logic for the 'unpackage' command.
-func handleUnpackage() {
-	args := flag.Args()[1:]
+func handleUnpackage(args []string, listOnly, applyPatch bool, include []string, dest string, force bool) {
 	archiveType := "game" // Default type
 	if len(args) > 0 && (args[0] == "app" || args[0] == "game") {
 		archiveType = args[0]
 		args = args[1:]
 	}
+	// Split bundles are read from their first volume, so 'G.tar.zst.*' names each once.
+	var archives []string
+	for _, arg := range args {
+		if _, i, split := archive.SplitVolume(arg); split && i > 1 {
+			continue
+		}
+		archives = append(archives, userPath(arg))
+	}
+	args = archives
+
+	if listOnly {
+		for _, archivePath := range args {
+			if err := listArchive(archivePath, include); err != nil {
+				log.Fatalf("❌ Could not list '%s': %v", archivePath, err)
+			}
+		}
+		return
+	}
+
+	if _, err := yapl.LoadGlobalConfig(); err != nil {
+		log.Fatalf("❌ Could not load global config: %v", err)
+	}
 
 	targetDir := archiveType + "s" // 'games' or 'apps'
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
 		log.Fatalf("❌ Could not create directory %s: %v", targetDir, err)
 	}
+	if applyPatch {
+		for _, archivePath := range args {
+			fmt.Printf("-> Applying patch '%s'...\n", archivePath)
+			if err := archive.ApplyPatch(targetDir, archivePath); err != nil {
+				log.Fatalf("❌ Could not apply '%s': %v", archivePath, err)
+			}
+		}
+		return
+	}
 
-	if err := archive.Unpackage(targetDir, args); err != nil {
+	if len(args) > 1 {
+		args = skipDuplicateArchives(args, force)
+	}
+	if err := archive.UnpackageInto(targetDir, args, include, dest, force); err != nil {
 		log.Fatalf("❌ Unpackaging failed: %v", err)
 	}
+	if len(include) > 0 {
+		return
+	}
+	for _, archivePath := range args {
+		if name, ok := archive.TrimArchiveSuffix(filepath.Base(archivePath)); ok {
+			if dest != "" {
+				name = dest
+			}
+			if bundled, err := dependency.InstallBundled(filepath.Join(targetDir, name)); err != nil {
+				log.Printf("⚠️  Could not install the components bundled with '%s': %v", name, err)
+			} else if bundled {
+				fmt.Printf("✅ Installed the Proton build and dependencies bundled with '%s'.\n", name)
+			}
+			detectExecutable(targetDir, name)
+		}
+	}
+}
+
+// skipDuplicateArchives warns about archives that would produce the same install and,
+// unless forced, drops byte-identical copies from the list.
+func skipDuplicateArchives(archivePaths []string, force bool) []string {
+	fmt.Println("-> Checking for duplicate archives...")
+	duplicates, err := archive.FindDuplicates(archivePaths)
+	if err != nil {
+		log.Printf("⚠️  Could not check for duplicates: %v", err)
+		return archivePaths
+	}
+	skip := make(map[string]bool)
+	for _, d := range duplicates {
+		if skip[d.First] {
+			continue // Already reported against the copy that is kept
+		}
+		if d.Identical && !force {
+			log.Printf("⚠️  '%s' is identical to '%s'; skipping it (use --force to unpackage both).", d.Second, d.First)
+			skip[d.Second] = true
+		} else if d.Identical {
+			log.Printf("⚠️  '%s' is identical to '%s'.", d.Second, d.First)
+		} else {
+			log.Printf("⚠️  '%s' looks like the same install as '%s' (%.0f%% of sampled files match).", d.Second, d.First, d.Similarity*100)
+		}
+	}
+
+	var remaining []string
+	for _, path := range archivePaths {
+		if !skip[path] {
+			remaining = append(remaining, path)
+		}
+	}
+	return remaining
+}
+
+// detectExecutable offers to fix the executable of a freshly unpackaged or imported game
+// whose configured path does not exist on this copy.
+func detectExecutable(appType, name string) {
+	globalCfg, err := yapl.LoadGlobalConfig()
+	if err != nil {
+		return
+	}
+	appCfg, err := config.LoadApp(appType, name, globalCfg.Defaults)
+	if err != nil {
+		return // Not a yapl bundle, or it failed to extract
+	}
+	a := app.New(appType, name, false, false, false, globalCfg, appCfg)
+	if err := a.DetectExecutable(isInteractive()); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+}
+
+// isInteractive reports whether yapl can ask the user questions.
+func isInteractive() bool {
+	return tui.IsTerminal(os.Stdin) && !events.Enabled()
+}
+
+// packageOptions reads the package command's flags.
+func packageOptions(full bool, version, notesFile, diffAgainst string) app.PackageOptions {
+	opts := app.PackageOptions{Full: full, Version: version}
+	if diffAgainst != "" {
+		opts.DiffAgainst = userPath(diffAgainst)
+	}
+	if notesFile != "" {
+		notes, err := os.ReadFile(userPath(notesFile))
+		if err != nil {
+			log.Fatalf("❌ Could not read the release notes: %v", err)
+		}
+		opts.Notes = string(notes)
+	}
+	return opts
+}
+
+var passphrase string
+
+// startProfiling serves net/http/pprof on pprofAddr and records a runtime trace to
+// traceFile, for contributors looking into slow packaging, extraction or directory walks.
+// The returned function ends the trace; a command that fails leaves it incomplete.
+func startProfiling(pprofAddr, traceFile string) (func(), error) {
+	if pprofAddr != "" {
+		ln, err := net.Listen("tcp", pprofAddr)
+		if err != nil {
+			return nil, fmt.Errorf("--pprof: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		go http.Serve(ln, mux)
+		fmt.Printf("-> Serving pprof profiles on http://%s/debug/pprof/\n", ln.Addr())
+	}
+	if traceFile == "" {
+		return func() {}, nil
+	}
+	if !filepath.IsAbs(traceFile) {
+		traceFile = filepath.Join(invocationDir, traceFile)
+	}
+	f, err := os.Create(traceFile)
+	if err != nil {
+		return nil, fmt.Errorf("--trace: %w", err)
+	}
+	if err := trace.Start(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("--trace: %w", err)
+	}
+	return func() {
+		trace.Stop()
+		f.Close()
+		fmt.Printf("-> Wrote the execution trace to %s; view it with 'go tool trace'.\n", traceFile)
+	}, nil
+}
+
+// readPassphrase returns the passphrase of encrypted bundles: $YAPL_PASSPHRASE, or one
+// typed at the terminal, twice when confirm is set. It is asked for once per run.
+func readPassphrase(confirm bool) (string, error) {
+	if passphrase != "" {
+		return passphrase, nil
+	}
+	if p := os.Getenv("YAPL_PASSPHRASE"); p != "" {
+		passphrase = p
+		return p, nil
+	}
+	if !isInteractive() {
+		return "", errors.New("encrypted bundles need a passphrase; set YAPL_PASSPHRASE")
+	}
+	p, err := tui.ReadPassword("🔑 Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		again, err := tui.ReadPassword("🔑 Repeat the passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != p {
+			return "", errors.New("the passphrases do not match")
+		}
+	}
+	passphrase = p
+	return p, nil
+}
+
+func listArchive(archivePath string, include []string) error {
+	headers, err := archive.List(archivePath, include)
+	if err != nil {
+		return err
+	}
+	if manifest, err := archive.ReadManifest(archivePath); err != nil {
+		log.Printf("⚠️  %v", err)
+	} else if manifest != nil && (manifest.Version != "" || manifest.Notes != "") {
+		manifest.PrintRelease()
+		events.Emit("release", map[string]interface{}{"archive": archivePath, "version": manifest.Version, "notes": manifest.Notes, "created": manifest.Created})
+	}
+	if len(headers) == 0 {
+		fmt.Printf("-> No entries in '%s' match.\n", archivePath)
+		return nil
+	}
+
+	var total int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, hdr := range headers {
+		name := hdr.Name
+		if hdr.Linkname != "" {
+			name += " -> " + hdr.Linkname
+		}
+		fmt.Fprintf(w, "%s\t%s/%s\t%d\t%s\t%s\n", hdr.FileInfo().Mode(), hdr.Uname, hdr.Gname, hdr.Size, hdr.ModTime.Local().Format("2006-01-02 15:04"), name)
+		events.Emit("entry", map[string]interface{}{"archive": archivePath, "name": hdr.Name, "link": hdr.Linkname, "mode": hdr.FileInfo().Mode().String(), "size": hdr.Size, "mtime": hdr.ModTime.UTC()})
+		total += hdr.Size
+	}
+	w.Flush()
+	fmt.Printf("-> %d entries, %d bytes\n", len(headers), total)
+	return nil
+}
+
+// handleSaves implements 'saves backup', 'saves restore [archive]', 'saves list' and
+// 'saves paths'.
+func handleSaves(a *app.App, args []string) {
+	if len(args) == 0 {
+		log.Fatalf("❌ Error: No saves command provided. Use 'backup', 'restore', 'list', 'paths' or 'update-db'.")
+	}
+
+	var err error
+	switch args[0] {
+	case "backup":
+		err = a.BackupSaves()
+	case "restore":
+		archivePath := ""
+		if len(args) > 1 {
+			archivePath = userPath(args[1])
+		}
+		err = a.RestoreSaves(archivePath)
+	case "list":
+		err = a.ListSaves()
+	case "paths":
+		var paths []string
+		if paths, err = a.SavePaths(); err == nil && len(paths) == 0 {
+			fmt.Println("-> No save paths configured, and the game is not in the save location database.")
+		}
+		for _, p := range paths {
+			fmt.Println(p)
+		}
+	default:
+		log.Fatalf("❌ Error: Unknown saves command '%s'.", args[0])
+	}
+	if err != nil {
+		log.Fatalf("❌ Saves %s failed: %v", args[0], err)
+	}
+}
+
+// handleSaveDatabaseUpdate implements 'saves update-db <url-or-file>'.
+func handleSaveDatabaseUpdate(args []string) {
+	if len(args) != 2 {
+		log.Fatalf("❌ Usage: yapl saves update-db <url-or-file>")
+	}
+	n, err := saves.UpdateDatabase(userPath(args[1]))
+	if err != nil {
+		log.Fatalf("❌ Could not update the save location database: %v", err)
+	}
+	fmt.Printf("✅ Installed save locations for %d game(s) to %s.\n", n, saves.DatabasePath)
+}
+
+// handleBackupAll implements 'saves backup --all': it backs up the saves of every game and
+// app that has save paths, continuing past failures.
+func handleBackupAll() {
+	globalCfg, err := yapl.LoadGlobalConfig()
+	if err != nil {
+		log.Fatalf("❌ Could not load global config: %v", err)
+	}
+	installed, err := config.LoadAll(globalCfg.Defaults)
+	if err != nil {
+		log.Fatalf("❌ Could not read the library: %v", err)
+	}
+	backedUp, failed := 0, 0
+	for _, inst := range installed {
+		a := app.New(inst.Type, inst.Name, false, false, false, globalCfg, inst.Config)
+		err := a.BackupSaves()
+		if errors.Is(err, saves.ErrNoSavePaths) {
+			continue
+		}
+		if err != nil {
+			log.Printf("⚠️  Could not back up the saves of '%s': %v", inst.Name, err)
+			failed++
+			continue
+		}
+		backedUp++
+	}
+	if failed > 0 {
+		log.Fatalf("❌ Backed up %d game(s); %d failed.", backedUp, failed)
+	}
+	fmt.Printf("✅ Backed up the saves of %d game(s).\n", backedUp)
+}
+
+// handlePrefix implements 'prefix snapshot|diff|export <dir>|apply <dir>'.
+func handlePrefix(a *app.App, args []string) {
+	if len(args) == 0 {
+		log.Fatalf("❌ Error: No prefix command provided. Use 'snapshot', 'diff', 'export <dir>', 'apply <dir>', 'programs' or 'uninstall <name>'.")
+	}
+
+	var err error
+	switch args[0] {
+	case "snapshot":
+		err = a.SnapshotPrefix()
+	case "diff":
+		err = a.DiffPrefix()
+	case "export", "apply":
+		if len(args) < 2 {
+			log.Fatalf("❌ Usage: yapl --game <name> prefix %s <recipe-dir>", args[0])
+		}
+		if args[0] == "export" {
+			dest := args[1]
+			if !filepath.IsAbs(dest) && invocationDir != "" {
+				dest = filepath.Join(invocationDir, dest)
+			}
+			err = a.ExportRecipe(dest)
+		} else {
+			err = a.ApplyRecipe(userPath(args[1]))
+		}
+	case "programs":
+		err = a.ListPrograms()
+	case "uninstall":
+		if len(args) < 2 {
+			log.Fatalf("❌ Usage: yapl --game <name> prefix uninstall <program>")
+		}
+		err = a.UninstallProgram(strings.Join(args[1:], " "))
+	default:
+		log.Fatalf("❌ Error: Unknown prefix command '%s'.", args[0])
+	}
+	if err != nil {
+		log.Fatalf("❌ Prefix %s failed: %v", args[0], err)
+	}
+}
+
+// handleLibrary implements 'library list' and 'library sync [name...]' for a shared catalog.
+func handleLibrary(args []string) {
+	if len(args) == 0 {
+		log.Fatalf("❌ Error: No library command provided. Use 'list' or 'sync'.")
+	}
+
+	globalCfg, err := yapl.LoadGlobalConfig()
+	if err != nil {
+		log.Fatalf("❌ Could not load global config: %v", err)
+	}
+	if globalCfg.Library == nil || globalCfg.Library.Path == "" {
+		log.Fatalf("❌ No library configured. Set 'library.path' in runner.json.")
+	}
+	libPath := globalCfg.Library.Path
+
+	switch args[0] {
+	case "list":
+		entries, err := library.List(libPath)
+		if err != nil {
+			log.Fatalf("❌ Could not list library: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TYPE\tNAME\tINSTALLED\tARCHIVE")
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", e.Type, e.Name, e.Installed, e.Archive)
+			events.Emit("library_entry", map[string]interface{}{"type": e.Type, "name": e.Name, "installed": e.Installed, "archive": e.Archive})
+		}
+		w.Flush()
+	case "sync":
+		if err := library.Sync(libPath, args[1:]); err != nil {
+			log.Fatalf("❌ Library sync failed: %v", err)
+		}
+	default:
+		log.Fatalf("❌ Error: Unknown library command '%s'.", args[0])
+	}
+}
+
+// handleRepo implements 'repo index <dir>', which publishes a directory of bundles as a
+// signed index, and 'repo key', which prints the key clients verify it with.
+func handleRepo(args []string) {
+	if len(args) == 0 {
+		log.Fatalf("❌ Error: No repo command provided. Use 'index <dir>' or 'key'.")
+	}
+	switch args[0] {
+	case "index":
+		if len(args) != 2 {
+			log.Fatalf("❌ Usage: yapl repo index <dir>")
+		}
+		dir := userPath(args[1])
+		index, key, err := repo.Generate(dir)
+		if err != nil {
+			log.Fatalf("❌ Could not index '%s': %v", dir, err)
+		}
+		for _, b := range index.Bundles {
+			events.Emit("repo_bundle", map[string]interface{}{"name": b.Name, "type": b.Type, "version": b.Version, "size": b.Size, "sha256": b.SHA256, "url": b.URL})
+		}
+		fmt.Printf("✅ Indexed %d bundle(s) in %s.\n", len(index.Bundles), filepath.Join(dir, repo.IndexFile))
+		fmt.Printf("➡️ Publish the directory; clients add it as a bundle source with the public key %s\n", key)
+	case "key":
+		key, err := repo.PublicKey()
+		if err != nil {
+			log.Fatalf("❌ Could not read the signing key: %v", err)
+		}
+		fmt.Println(key)
+	default:
+		log.Fatalf("❌ Error: Unknown repo command '%s'.", args[0])
+	}
+}
+
+// handleInstall implements 'install [game|app] <name>', which installs a bundle from the
+// bundle sources in runner.json.
+func handleInstall(args []string) {
+	typ := ""
+	if len(args) > 1 && (args[0] == "app" || args[0] == "game") {
+		typ, args = args[0], args[1:]
+	}
+	if len(args) != 1 {
+		log.Fatalf("❌ Usage: yapl install [game|app] <name>")
+	}
+	globalCfg, err := yapl.LoadGlobalConfig()
+	if err != nil {
+		log.Fatalf("❌ Could not load global config: %v", err)
+	}
+	if len(globalCfg.BundleSources) == 0 {
+		log.Fatalf("❌ No bundle sources configured. Add one with 'yapl source add <name> <url> <public-key>'.")
+	}
+	bundle, source, err := repo.Find(globalCfg.BundleSources, typ, args[0])
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	targetDir := bundle.Type + "s"
+	fmt.Printf("📦 Installing '%s'...\n", bundle.Name)
+	if err := repo.Install(bundle, source, targetDir); err != nil {
+		log.Fatalf("❌ Could not install '%s': %v", bundle.Name, err)
+	}
+	if bundled, err := dependency.InstallBundled(filepath.Join(targetDir, bundle.Name)); err != nil {
+		log.Printf("⚠️  Could not install the components bundled with '%s': %v", bundle.Name, err)
+	} else if bundled {
+		fmt.Printf("✅ Installed the Proton build and dependencies bundled with '%s'.\n", bundle.Name)
+	}
+	fmt.Printf("✅ Installed '%s' to '%s'.\n", bundle.Name, filepath.Join(targetDir, bundle.Name))
+	detectExecutable(targetDir, bundle.Name)
+}
+
+// handleSource implements 'source add <name> <url> <public-key>', 'source list' and
+// 'source remove <name>', which manage the bundle sources in runner.json.
+func handleSource(args []string) {
+	if len(args) == 0 {
+		log.Fatalf("❌ Error: No source command provided. Use 'add', 'list' or 'remove'.")
+	}
+	switch args[0] {
+	case "add":
+		if len(args) != 4 {
+			log.Fatalf("❌ Usage: yapl source add <name> <url> <public-key>")
+		}
+		src := config.BundleSource{Name: args[1], URL: args[2], PublicKey: args[3]}
+		if !strings.HasPrefix(src.URL, "http://") && !strings.HasPrefix(src.URL, "https://") {
+			src.URL = fs.MustGetAbsolutePath(userPath(src.URL))
+		}
+		index, _, err := repo.Fetch(src)
+		if err != nil {
+			log.Fatalf("❌ Could not read the index of '%s': %v", src.URL, err)
+		}
+		exists := false
+		err = config.UpdateGlobal("runner.json", func(g *config.Global) {
+			for _, s := range g.BundleSources {
+				exists = exists || s.Name == src.Name
+			}
+			if !exists {
+				g.BundleSources = append(g.BundleSources, src)
+			}
+		})
+		if err != nil {
+			log.Fatalf("❌ Could not update runner.json: %v", err)
+		}
+		if exists {
+			log.Fatalf("❌ A source named '%s' already exists; remove it first.", src.Name)
+		}
+		fmt.Printf("✅ Added source '%s' with %d bundle(s).\n", src.Name, len(index.Bundles))
+	case "list":
+		globalCfg, err := yapl.LoadGlobalConfig()
+		if err != nil {
+			log.Fatalf("❌ Could not load global config: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tURL\tPUBLIC KEY")
+		for _, src := range globalCfg.BundleSources {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", src.Name, src.URL, src.PublicKey)
+			events.Emit("source", map[string]interface{}{"name": src.Name, "url": src.URL, "public_key": src.PublicKey})
+		}
+		w.Flush()
+	case "remove":
+		if len(args) != 2 {
+			log.Fatalf("❌ Usage: yapl source remove <name>")
+		}
+		found := false
+		err := config.UpdateGlobal("runner.json", func(g *config.Global) {
+			g.BundleSources = slices.DeleteFunc(g.BundleSources, func(s config.BundleSource) bool {
+				found = found || s.Name == args[1]
+				return s.Name == args[1]
+			})
+		})
+		if err != nil {
+			log.Fatalf("❌ Could not update runner.json: %v", err)
+		}
+		if !found {
+			log.Fatalf("❌ No source named '%s'.", args[1])
+		}
+		fmt.Printf("✅ Removed source '%s'.\n", args[1])
+	default:
+		log.Fatalf("❌ Error: Unknown source command '%s'.", args[0])
+	}
+}
+
+// handleSearch implements 'search [term]', which lists the bundles of every source whose
+// name contains term, or all of them.
+func handleSearch(args []string) {
+	if len(args) > 1 {
+		log.Fatalf("❌ Usage: yapl search [term]")
+	}
+	globalCfg, err := yapl.LoadGlobalConfig()
+	if err != nil {
+		log.Fatalf("❌ Could not load global config: %v", err)
+	}
+	if len(globalCfg.BundleSources) == 0 {
+		log.Fatalf("❌ No bundle sources configured. Add one with 'yapl source add <name> <url> <public-key>'.")
+	}
+	term := ""
+	if len(args) == 1 {
+		term = args[0]
+	}
+	results := repo.Search(globalCfg.BundleSources, term)
+	if len(results) == 0 {
+		fmt.Printf("-> No bundles match '%s'.\n", term)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tTYPE\tNAME\tVERSION\tSIZE")
+	for _, r := range results {
+		version := r.Version
+		if version == "" {
+			version = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d MB\n", r.Source, r.Type, r.Name, version, r.Size>>20)
+		events.Emit("search_result", map[string]interface{}{"source": r.Source, "type": r.Type, "name": r.Name, "version": r.Version, "size": r.Size, "sha256": r.SHA256, "url": r.URL})
+	}
+	w.Flush()
+}
+
+// handlePeers implements 'seed' (serve this machine's components to the LAN) as well as
+// 'peers list' and 'peers fetch <game|app> <name>'.
+func handlePeers(command string, args []string) {
+	globalCfg, err := yapl.LoadGlobalConfig()
+	if err != nil {
+		log.Fatalf("❌ Could not load global config: %v", err)
+	}
+	port := peer.Port(globalCfg.LANPeers)
+
+	if command == "seed" {
+		if err := peer.Serve(globalCfg); err != nil {
+			log.Fatalf("❌ Seeding failed: %v", err)
+		}
+		return
+	}
+
+	if len(args) == 0 {
+		log.Fatalf("❌ Error: No peers command provided. Use 'list' or 'fetch'.")
+	}
+	switch args[0] {
+	case "list":
+		peers := peer.Discover(port)
+		if len(peers) == 0 {
+			fmt.Println("-> No LAN peers found.")
+		}
+		for _, p := range peers {
+			fmt.Println(p)
+			events.Emit("peer", map[string]interface{}{"address": p})
+		}
+	case "fetch":
+		if len(args) != 3 || (args[1] != "game" && args[1] != "app") {
+			log.Fatalf("❌ Usage: yapl peers fetch <game|app> <name>")
+		}
+		relDir := filepath.Join(args[1]+"s", args[2])
+		if fs.DirExistsAndIsNotEmpty(relDir) {
+			log.Fatalf("❌ '%s' already exists.", relDir)
+		}
+		if !peer.FetchApp(port, relDir) {
+			log.Fatalf("❌ No LAN peer has '%s'.", relDir)
+		}
+		fmt.Printf("✅ Fetched '%s' from the LAN.\n", relDir)
+	default:
+		log.Fatalf("❌ Error: Unknown peers command '%s'.", args[0])
+	}
+}
+
+// handleImport implements 'import <source> <path-or-id>', creating a game from another launcher's setup.
+func handleImport(args []string, nameOverride string, copyPrefix bool) {
+	if len(args) == 0 {
+		log.Fatalf("❌ Usage: yapl [--game <name>] import <lutris <file-or-slug> | heroic [app-name...] | prefix <path>>")
+	}
+
+	var results []importer.Result
+	switch args[0] {
+	case "lutris":
+		if len(args) < 2 {
+			log.Fatalf("❌ Usage: yapl [--game <name>] import lutris <file-or-slug>")
+		}
+		result, err := importer.Lutris(userPath(args[1]))
+		if err != nil {
+			log.Fatalf("❌ Import failed: %v", err)
+		}
+		results = append(results, result)
+	case "heroic":
+		var err error
+		results, err = importer.Heroic(args[1:])
+		if err != nil {
+			log.Fatalf("❌ Import failed: %v", err)
+		}
+		if len(results) == 0 {
+			log.Fatalf("❌ No Windows games found in the Heroic configuration.")
+		}
+	case "prefix":
+		if len(args) != 2 {
+			log.Fatalf("❌ Usage: yapl [--game <name>] [--copy] import prefix <path>")
+		}
+		result, err := importer.Prefix(userPath(args[1]), copyPrefix)
+		if err != nil {
+			log.Fatalf("❌ Import failed: %v", err)
+		}
+		results = append(results, result)
+	default:
+		log.Fatalf("❌ Error: Unknown import source '%s'.", args[0])
+	}
+	if nameOverride != "" {
+		if len(results) != 1 {
+			log.Fatalf("❌ --game can only be used when importing a single game.")
+		}
+		results[0].Name = nameOverride
+	}
+
+	for _, result := range results {
+		if err := result.Save("games", "runner.json"); err != nil {
+			log.Printf("⚠️  Skipping '%s': %v", result.Name, err)
+			continue
+		}
+		fmt.Printf("✅ Imported '%s'. Review '%s' and run setup.\n", result.Name, config.ConfigPath("games", result.Name))
+		if result.PrefixLink != "" {
+			detectExecutable("games", result.Name)
+		}
+	}
+}
+
+// handleRuntime lists and prunes the installed Steam Linux Runtime snapshots.
+func handleRuntime(args []string) {
+	// Games may inherit their runtime_version from runner.json's defaults.
+	globalCfg, err := yapl.LoadGlobalConfig()
+	if err != nil {
+		log.Fatalf("❌ Could not load global config: %v", err)
+	}
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "list":
+		snapshots, err := dependency.RuntimeSnapshots(globalCfg.Defaults)
+		if err != nil {
+			log.Fatalf("❌ Could not list runtime snapshots: %v", err)
+		}
+		if len(snapshots) == 0 {
+			fmt.Println("-> No runtime snapshots installed.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RUNTIME\tBUILD\tSTATUS\tPINNED BY")
+		for _, s := range snapshots {
+			var status []string
+			if s.Current {
+				status = append(status, "current")
+			}
+			if s.InUse {
+				status = append(status, "running")
+			}
+			if len(status) == 0 && len(s.PinnedBy) == 0 {
+				status = append(status, "unused")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Version, s.BuildID, strings.Join(status, ","), strings.Join(s.PinnedBy, ", "))
+			events.Emit("runtime_snapshot", map[string]interface{}{"runtime": s.Version, "build_id": s.BuildID, "current": s.Current, "running": s.InUse, "pinned_by": s.PinnedBy})
+		}
+		w.Flush()
+	case "verify":
+		snapshots, err := dependency.RuntimeSnapshots(globalCfg.Defaults)
+		if err != nil {
+			log.Fatalf("❌ Could not list runtime snapshots: %v", err)
+		}
+		failed := 0
+		for _, s := range snapshots {
+			fmt.Printf("-> Verifying %s build %s...\n", s.Version, s.BuildID)
+			if err := dependency.VerifyRuntime(s.Dir); err != nil {
+				log.Printf("⚠️  %s build %s: %v", s.Version, s.BuildID, err)
+				failed++
+			}
+		}
+		if failed > 0 {
+			log.Fatalf("❌ %d of %d runtime snapshot(s) failed verification.", failed, len(snapshots))
+		}
+		fmt.Printf("✅ %d runtime snapshot(s) verified.\n", len(snapshots))
+	case "prune":
+		removed, err := dependency.PruneRuntimes(globalCfg.Defaults)
+		for _, s := range removed {
+			fmt.Printf("-> Removed %s build %s.\n", s.Version, s.BuildID)
+		}
+		if err != nil {
+			log.Fatalf("❌ Pruning failed: %v", err)
+		}
+		fmt.Printf("✅ Removed %d unused runtime snapshot(s).\n", len(removed))
+	default:
+		log.Fatalf("❌ Usage: yapl runtime [list | verify | prune]")
+	}
+}
+
+// handleProton reports what an installed Proton build contains.
+func handleProton(args []string) {
+	if len(args) != 2 || args[0] != "info" {
+		log.Fatalf("❌ Usage: yapl proton info <version>")
+	}
+	globalCfg, err := yapl.LoadGlobalConfig()
+	if err != nil {
+		log.Fatalf("❌ Could not load global config: %v", err)
+	}
+	build, err := command.InspectProton(args[1], globalCfg)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	orNone := func(s string) string {
+		if s == "" {
+			return "not bundled"
+		}
+		return s
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Proton:\t%s\n", build.Name)
+	fmt.Fprintf(w, "Directory:\t%s\n", build.Dir)
+	if build.ProtonVersion != "" {
+		fmt.Fprintf(w, "Build:\t%s\n", build.ProtonVersion)
+	}
+	fmt.Fprintf(w, "Wine:\t%s\n", build.WineVersion)
+	fmt.Fprintf(w, "Staging patches:\t%t\n", build.Staging)
+	fmt.Fprintf(w, "WoW64:\t%s\n", build.WoW64)
+	fmt.Fprintf(w, "DXVK:\t%s\n", orNone(build.DXVK))
+	fmt.Fprintf(w, "VKD3D-Proton:\t%s\n", orNone(build.VKD3D))
+	w.Flush()
+	events.Emit("proton_info", map[string]interface{}{
+		"proton": build.Name, "dir": build.Dir, "build": build.ProtonVersion, "wine": build.WineVersion,
+		"staging": build.Staging, "wow64": build.WoW64, "dxvk": build.DXVK, "vkd3d": build.VKD3D,
+	})
+}
+
+// handleValidate checks runner.json and every game and app config.
+func handleValidate() {
+	issues := config.Validate("runner.json")
+	failed := 0
+	for _, issue := range issues {
+		if issue.Warning {
+			log.Printf("⚠️  %s: %s", issue.File, issue.Message)
+		} else {
+			log.Printf("❌ %s: %s", issue.File, issue.Message)
+			failed++
+		}
+		events.Emit("validation_issue", map[string]interface{}{"file": issue.File, "message": issue.Message, "warning": issue.Warning})
+	}
+	if failed > 0 {
+		log.Fatalf("❌ Found %d error(s) and %d warning(s).", failed, len(issues)-failed)
+	}
+	fmt.Printf("✅ Configs are valid (%d warning(s)).\n", len(issues))
+}
+
+// handleLint reports suspicious settings in every game and app config. It exits with 2
+// if it finds errors and 1 if it only finds warnings, so CI can tell them apart.
+func handleLint() {
+	findings := config.Lint("runner.json")
+	counts := map[config.Severity]int{}
+	for _, f := range findings {
+		icon := map[config.Severity]string{config.SeverityError: "❌", config.SeverityWarning: "⚠️ ", config.SeverityInfo: "ℹ️ "}[f.Severity]
+		log.Printf("%s %s: %s [%s]", icon, f.File, f.Message, f.Rule)
+		counts[f.Severity]++
+		events.Emit("lint_finding", map[string]interface{}{"file": f.File, "rule": f.Rule, "severity": f.Severity.String(), "message": f.Message})
+	}
+	summary := fmt.Sprintf("%d error(s), %d warning(s), %d note(s)", counts[config.SeverityError], counts[config.SeverityWarning], counts[config.SeverityInfo])
+	switch {
+	case counts[config.SeverityError] > 0:
+		log.Printf("❌ Lint found %s.", summary)
+		os.Exit(2)
+	case counts[config.SeverityWarning] > 0:
+		log.Printf("⚠️  Lint found %s.", summary)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ No problems found (%d note(s)).\n", counts[config.SeverityInfo])
+}
+
+// handleConfig reads or changes a single setting of runner.json, or of a game's or app's
+// config when --game or --app is given.
+func handleConfig(args []string, gameName, appName string) {
+	if len(args) > 3 && args[0] == "set" {
+		log.Fatalf("❌ 'config set' takes a single value; quote values that contain spaces.")
+	}
+	if len(args) == 0 || !(args[0] == "get" && len(args) == 2 || args[0] == "set" && len(args) == 3) {
+		log.Fatalf("❌ Usage: yapl [--game <name>|--app <name>] config get <key> | config set <key> <value>")
+	}
+	key := args[1]
+	path := "runner.json"
+	var cfg interface{} = &config.Global{}
+	appType, name := "", gameName
+	if gameName != "" {
+		appType = "games"
+	} else if appName != "" {
+		appType, name = "apps", appName
+	}
+	if appType != "" {
+		path = config.ConfigPath(appType, name)
+		cfg = &config.App{}
+	}
+
+	if args[0] == "get" {
+		// Games and apps show the value yapl uses, with templates and defaults applied.
+		var err error
+		if appType != "" {
+			var globalCfg config.Global
+			var appCfg config.App
+			if globalCfg, err = yapl.LoadGlobalConfig(); err == nil {
+				if appCfg, err = config.LoadApp(appType, name, globalCfg.Defaults); err == nil {
+					cfg = appCfg
+				}
+			}
+		} else {
+			cfg, err = config.LoadOrCreateGlobal(path)
+		}
+		if err != nil {
+			log.Fatalf("❌ Could not read %s: %v", path, err)
+		}
+		value, err := config.GetField(cfg, key)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		fmt.Println(string(value))
+		events.Emit("config_value", map[string]interface{}{"file": path, "key": key, "value": json.RawMessage(value)})
+		return
+	}
+
+	if err := config.SetField(path, cfg, key, args[2]); err != nil {
+		log.Fatalf("❌ Could not set '%s': %v", key, err)
+	}
+	fmt.Printf("✅ Set %s in %s.\n", key, path)
+}
+
+// handlePurge removes yapl-managed data, asking about each category unless --force is
+// given. Arguments limit it to the named categories.
+func handlePurge(args []string, force bool) {
+	if !force && !isInteractive() {
+		log.Fatalf("❌ purge asks before removing anything; use --force to run it without a terminal.")
+	}
+	cats := purge.Categories()
+	for _, name := range args {
+		if !slices.ContainsFunc(cats, func(c purge.Category) bool { return c.Name == name }) {
+			log.Fatalf("❌ Unknown category '%s'. Use 'caches', 'prefixes', 'dependencies', or 'configs'.", name)
+		}
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+	var freed int64
+	for _, cat := range cats {
+		if len(args) > 0 && !slices.Contains(args, cat.Name) {
+			continue
+		}
+		if len(cat.Paths) == 0 {
+			fmt.Printf("-> No %s to remove.\n", cat.Name)
+			continue
+		}
+		if !force {
+			fmt.Printf("Remove %s (%s; %d MiB in %s)? [y/N]: ", cat.Name, cat.Description, cat.Size>>20, strings.Join(cat.Paths, ", "))
+			answer, _ := stdin.ReadString('\n')
+			if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+				fmt.Printf("-> Keeping %s.\n", cat.Name)
+				continue
+			}
+		}
+		for _, path := range cat.Paths {
+			err := purge.Remove(path, func(path string, size int64) {
+				fmt.Printf("-> Removed %s (%d MiB).\n", path, size>>20)
+				events.Emit("purged", map[string]interface{}{"category": cat.Name, "path": path, "bytes": size})
+				freed += size
+			})
+			if err != nil {
+				log.Fatalf("❌ Could not remove '%s': %v", path, err)
+			}
+		}
+	}
+	fmt.Printf("✅ Purge complete, freed %d MiB.\n", freed>>20)
+}
+
+// handleStore deduplicates the installed Proton builds and dependencies into the shared
+// store, or removes objects nothing uses any more.
+func handleStore(args []string) {
+	sub := "dedup"
+	if len(args) > 0 {
+		sub = args[0]
+	}
+	switch sub {
+	case "dedup":
+		dirs, _ := filepath.Glob(filepath.Join("proton", "*"))
+		deps, _ := filepath.Glob(filepath.Join("dependencies", "*", "*"))
+		var total store.Stats
+		for _, dir := range append(dirs, deps...) {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				continue
+			}
+			stats, err := store.Dedup(dir)
+			if err != nil {
+				log.Fatalf("❌ Could not deduplicate '%s': %v", dir, err)
+			}
+			if stats.Linked > 0 {
+				fmt.Printf("-> %s: shared %d of %d files (%d MiB).\n", dir, stats.Linked, stats.Files, stats.Saved>>20)
+			}
+			events.Emit("store_dedup", map[string]interface{}{"dir": dir, "files": stats.Files, "linked": stats.Linked, "saved": stats.Saved})
+			total.Files += stats.Files
+			total.Linked += stats.Linked
+			total.Saved += stats.Saved
+		}
+		fmt.Printf("✅ Shared %d of %d files, saving %d MiB.\n", total.Linked, total.Files, total.Saved>>20)
+	case "gc":
+		removed, freed, err := store.GC()
+		if err != nil {
+			log.Fatalf("❌ Could not clean up the store: %v", err)
+		}
+		fmt.Printf("✅ Removed %d unused object(s), freeing %d MiB.\n", removed, freed>>20)
+	default:
+		log.Fatalf("❌ Usage: yapl store [dedup|gc]")
+	}
+}
+
+// handleKnownIssues lists, updates or checks logs against the crash signature database.
+func handleKnownIssues(args []string, gameName, appName string) {
+	sub := "list"
+	if len(args) > 0 {
+		sub = args[0]
+	}
+	switch sub {
+	case "list":
+		sigs, err := crash.Load()
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tISSUE\tFIX")
+		for _, sig := range sigs {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", sig.ID, sig.Issue, sig.Fix)
+			events.Emit("known_issue_signature", map[string]interface{}{"id": sig.ID, "pattern": sig.Pattern, "issue": sig.Issue, "fix": sig.Fix})
+		}
+		w.Flush()
+	case "update":
+		if len(args) != 2 {
+			log.Fatalf("❌ Usage: yapl known-issues update <url-or-file>")
+		}
+		n, err := crash.Update(userPath(args[1]))
+		if err != nil {
+			log.Fatalf("❌ Could not update the crash signatures: %v", err)
+		}
+		fmt.Printf("✅ Installed %d crash signature(s) to %s.\n", n, crash.DatabasePath)
+	case "check":
+		var text string
+		switch {
+		case len(args) == 2:
+			data, err := os.ReadFile(userPath(args[1]))
+			if err != nil {
+				log.Fatalf("❌ %v", err)
+			}
+			text = string(data)
+		case gameName != "":
+			text = crash.ReadLogs(filepath.Join("games", gameName, "logs"), time.Time{}, 1<<20)
+		case appName != "":
+			text = crash.ReadLogs(filepath.Join("apps", appName, "logs"), time.Time{}, 1<<20)
+		default:
+			log.Fatalf("❌ Usage: yapl known-issues check <log-file> | yapl --game <name> known-issues check")
+		}
+		sigs, err := crash.Load()
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		matches := crash.Scan(text, sigs)
+		for _, m := range matches {
+			fmt.Printf("💡 %s: %s.\n   Fix: %s.\n   Matched: %s\n", m.ID, m.Issue, m.Fix, m.Line)
+			events.Emit("known_issue", map[string]interface{}{"id": m.ID, "issue": m.Issue, "fix": m.Fix, "line": m.Line})
+		}
+		if len(matches) == 0 {
+			fmt.Println("-> No known issues found.")
+		}
+	default:
+		log.Fatalf("❌ Usage: yapl known-issues [list | update <url-or-file> | check [log-file]]")
+	}
+}
+
+// handleTelemetry shows, enables, disables or submits the opt-in usage statistics.
+func handleTelemetry(args []string) {
+	if _, err := yapl.LoadGlobalConfig(); err != nil {
+		log.Fatalf("❌ Error loading global config: %v", err)
+	}
+	sub := "show"
+	if len(args) > 0 {
+		sub = args[0]
+	}
+	switch sub {
+	case "show":
+		report, err := telemetry.Build()
+		if err != nil {
+			log.Fatalf("❌ Could not build the report: %v", err)
+		}
+		data, _ := json.MarshalIndent(report, "", "  ")
+		if telemetry.Enabled() {
+			fmt.Println("-> Telemetry is enabled. This is what 'yapl telemetry submit' would send:")
+		} else {
+			fmt.Println("-> Telemetry is disabled; nothing is collected or sent. If enabled, a report would look like:")
+		}
+		fmt.Println(string(data))
+	case "enable", "disable":
+		enabled := sub == "enable"
+		err := config.UpdateGlobal("runner.json", func(g *config.Global) {
+			if g.Telemetry == nil {
+				g.Telemetry = &config.Telemetry{}
+			}
+			g.Telemetry.Enabled = enabled
+			if enabled && len(args) > 1 {
+				g.Telemetry.Endpoint = args[1]
+			}
+		})
+		if err != nil {
+			log.Fatalf("❌ Could not update runner.json: %v", err)
+		}
+		if !enabled {
+			if err := telemetry.Reset(); err != nil {
+				log.Printf("⚠️  Could not discard collected statistics: %v", err)
+			}
+		}
+		fmt.Printf("✅ Telemetry %sd.\n", sub)
+	case "submit":
+		if err := telemetry.Submit(); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		fmt.Println("✅ Statistics submitted. Thank you!")
+	default:
+		log.Fatalf("❌ Usage: yapl telemetry [show|enable [endpoint]|disable|submit]")
+	}
+}
+
+// handlePlugins lists the installed plugins and the hook points they are enabled for.
+func handlePlugins() {
+	globalCfg, err := yapl.LoadGlobalConfig()
+	if err != nil {
+		log.Fatalf("❌ Could not load global config: %v", err)
+	}
+	plugins := plugin.List(globalCfg.Plugins)
+	if len(plugins) == 0 {
+		fmt.Printf("-> No plugins installed. Put yapl-<name> executables in %s/ or on PATH.\n", plugin.Dir)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMMAND\tHOOKS\tPATH")
+	for _, p := range plugins {
+		if slices.Contains(commands, p.Name) {
+			log.Printf("⚠️  %s is never run as a command: 'yapl %s' is built in.", p.Path, p.Name)
+		}
+		hooks := make([]string, len(p.Hooks))
+		for i, h := range p.Hooks {
+			hooks[i] = string(h)
+		}
+		shown := strings.Join(hooks, ", ")
+		if shown == "" {
+			shown = "-"
+		}
+		fmt.Fprintf(w, "yapl %s\t%s\t%s\n", p.Name, shown, p.Path)
+		events.Emit("plugin", map[string]interface{}{"name": p.Name, "path": p.Path, "hooks": hooks})
+	}
+	w.Flush()
+}
+
+// handleLicenses prints where every installed component came from and its license files.
+func handleLicenses() {
+	components, err := dependency.Licenses()
+	if err != nil {
+		log.Fatalf("❌ Could not read installed components: %v", err)
+	}
+	if len(components) == 0 {
+		fmt.Println("-> No components installed.")
+		return
+	}
+	orUnknown := func(s string) string {
+		if s == "" {
+			return "unknown"
+		}
+		return s
+	}
+	for i, c := range components {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s %s\n", c.Component, c.Version)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "  Project:\t%s\n", orUnknown(c.Project))
+		fmt.Fprintf(w, "  Source:\t%s\n", orUnknown(c.Source))
+		fmt.Fprintf(w, "  SHA-256:\t%s\n", orUnknown(c.SHA256))
+		if len(c.Licenses) == 0 {
+			fmt.Fprintf(w, "  License:\tnone found\n")
+		}
+		for _, l := range c.Licenses {
+			fmt.Fprintf(w, "  License:\t%s\n", filepath.Join(c.Dir, l))
+		}
+		w.Flush()
+		events.Emit("license", map[string]interface{}{
+			"component": c.Component, "version": c.Version, "dir": c.Dir, "project": c.Project,
+			"source": c.Source, "sha256": c.SHA256, "licenses": c.Licenses,
+		})
+	}
+}
+
+// handleVerify checks every installed Proton build, runtime snapshot and dependency against
+// the files it was installed with and, with repair, reinstalls the damaged ones.
+func handleVerify(repair bool) {
+	globalCfg, err := yapl.LoadGlobalConfig()
+	if err != nil {
+		log.Fatalf("❌ Could not load global config: %v", err)
+	}
+	components, err := dependency.Licenses()
+	if err != nil {
+		log.Fatalf("❌ Could not read installed components: %v", err)
+	}
+	if len(components) == 0 {
+		fmt.Println("-> No components installed.")
+		return
+	}
+	const shown = 10 // Files listed per component
+	verified, unrecorded, damaged := 0, 0, 0
+	for _, c := range components {
+		fmt.Printf("-> Verifying %s %s...\n", c.Component, c.Version)
+		damage, recorded, err := dependency.VerifyFiles(c.Dir)
+		switch {
+		case err != nil:
+			log.Printf("⚠️  %s %s: %v", c.Component, c.Version, err)
+			damaged++
+			continue
+		case !recorded:
+			fmt.Printf("   No file list; it was installed by an older yapl or from a local path.\n")
+			unrecorded++
+			continue
+		case !damage.Damaged():
+			verified++
+			continue
+		}
+		events.Emit("damaged", map[string]interface{}{"component": c.Component, "version": c.Version, "dir": c.Dir, "missing": damage.Missing, "modified": damage.Modified})
+		log.Printf("⚠️  %s %s: %d file(s) missing, %d modified.", c.Component, c.Version, len(damage.Missing), len(damage.Modified))
+		for i, name := range append(damage.Missing, damage.Modified...) {
+			if i == shown {
+				fmt.Printf("   ...and %d more.\n", len(damage.Missing)+len(damage.Modified)-shown)
+				break
+			}
+			state := "modified"
+			if i < len(damage.Missing) {
+				state = "missing"
+			}
+			fmt.Printf("   %s: %s\n", state, name)
+		}
+		if !repair {
+			damaged++
+			continue
+		}
+		fmt.Printf("-> Reinstalling %s %s...\n", c.Component, c.Version)
+		if err := dependency.Repair(c, globalCfg); err != nil {
+			log.Printf("⚠️  Could not repair %s %s: %v", c.Component, c.Version, err)
+			damaged++
+			continue
+		}
+		fmt.Printf("✅ Repaired %s %s.\n", c.Component, c.Version)
+		verified++
+	}
+	if damaged > 0 {
+		hint := ""
+		if !repair {
+			hint = "; run 'yapl verify --repair' to reinstall them"
+		}
+		log.Fatalf("❌ %d of %d component(s) are damaged%s.", damaged, len(components), hint)
+	}
+	fmt.Printf("✅ %d component(s) intact", verified)
+	if unrecorded > 0 {
+		fmt.Printf(", %d without a file list", unrecorded)
+	}
+	fmt.Println(".")
+}
+
+// handleDownloads lists, pauses and resumes the downloads of all running yapl processes.
+func handleDownloads(args []string) {
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "list":
+		tickets, err := downloads.List()
+		if err != nil {
+			log.Fatalf("❌ Could not read the download queue: %v", err)
+		}
+		if len(tickets) == 0 {
+			fmt.Println("-> No downloads running.")
+			return
+		}
+		fmt.Printf("%-12s %-10s %-8s %-16s %s\n", "ID", "PRIORITY", "STATE", "PROGRESS", "URL")
+		for _, t := range tickets {
+			state := t.State
+			if t.Paused {
+				state = "paused"
+			}
+			progress := fmt.Sprintf("%d MiB", t.Bytes>>20)
+			if t.Total > 0 {
+				progress = fmt.Sprintf("%d/%d MiB", t.Bytes>>20, t.Total>>20)
+			}
+			fmt.Printf("%-12s %-10s %-8s %-16s %s\n", t.ID, t.Priority, state, progress, t.URL)
+			events.Emit("download", map[string]interface{}{"id": t.ID, "priority": t.Priority, "state": state, "bytes": t.Bytes, "total": t.Total, "url": t.URL})
+		}
+	case "pause", "resume":
+		id := "all"
+		if len(args) > 1 {
+			id = args[1]
+		}
+		changed, err := downloads.SetPaused(id, action == "pause")
+		if err != nil {
+			log.Fatalf("❌ Could not %s '%s': %v", action, id, err)
+		}
+		fmt.Printf("✅ %sd %d download(s).\n", strings.ToUpper(action[:1])+action[1:], changed)
+	default:
+		log.Fatalf("❌ Usage: yapl downloads [list | pause [id] | resume [id]]")
+	}
+}
+
+// handleTUI runs the interactive game list, where games can be launched, set up, packaged,
+// backed up and stopped.
+func handleTUI(force, debug, steam bool) {
+	// The list shows Proton versions games may inherit from runner.json's defaults.
+	globalCfg, err := yapl.LoadGlobalConfig()
+	if err != nil {
+		log.Fatalf("❌ Could not load global config: %v", err)
+	}
+	perform := func(action string, item tui.Item) error {
+		gameName, appName := item.Name, ""
+		if item.Type == "apps" {
+			gameName, appName = "", item.Name
+		}
+		a, err := initializeApp(gameName, appName, force, debug, steam)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(lockedCommands, action) {
+			unlock, err := a.Lock(action, false)
+			if err != nil {
+				return err
+			}
+			defer unlock()
+		}
+		switch action {
+		case "run":
+			return a.Run()
+		case "setup":
+			return a.Setup()
+		case "package":
+			return a.Package("gz", app.PackageOptions{})
+		case "backup":
+			return a.BackupSaves()
+		case "kill":
+			return a.Kill(false)
+		}
+		return fmt.Errorf("unknown action '%s'", action)
+	}
+	if err := tui.Run(func() ([]tui.Item, error) { return loadTUIItems(globalCfg.Defaults) }, perform); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+}
+
+// loadTUIItems lists every local game and app with its status, with the runner.json
+// defaults d filled in.
+func loadTUIItems(d *config.Defaults) ([]tui.Item, error) {
+	sessions, err := journal.Query(journal.DefaultPath, journal.Filter{})
+	if err != nil {
+		return nil, err
+	}
+	lastPlayed := make(map[string]time.Time)
+	for _, s := range sessions {
+		lastPlayed[s.Type+"/"+s.Name] = s.Start
+	}
+
+	installed, err := config.LoadAll(d)
+	if err != nil {
+		return nil, err
+	}
+	var items []tui.Item
+	for _, a := range installed {
+		_, prefixErr := os.Stat(filepath.Join(a.Type, a.Name, "prefix", "system.reg"))
+		items = append(items, tui.Item{
+			Type:          a.Type,
+			Name:          a.Name,
+			ProtonVersion: a.Config.ProtonVersion,
+			Initialized:   prefixErr == nil,
+			LastPlayed:    lastPlayed[a.Type+"/"+a.Name],
+		})
+	}
+	return items, nil
+}
+
+// handleSessions prints the session journal, optionally filtered by game/app and user.
+func handleSessions(name, user string) {
+	sessions, err := journal.Query(journal.DefaultPath, journal.Filter{User: user, Name: name})
+	if err != nil {
+		log.Fatalf("❌ Could not read session journal: %v", err)
+	}
+	if len(sessions) == 0 {
+		fmt.Println("-> No sessions recorded yet.")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tUSER\tNAME\tDURATION\tEXIT\tMETHOD\tPROTON\tRUNTIME")
+	for _, s := range sessions {
+		duration := time.Duration(s.Duration * float64(time.Second)).Round(time.Second)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			s.Start.Local().Format("2006-01-02 15:04"), s.User, s.Name, duration,
+			s.ExitCode, s.LaunchMethod, s.ProtonVersion, s.RuntimeVersion)
+		events.Emit("session", map[string]interface{}{"session": s})
+	}
+	w.Flush()
+}
+
+// handleParental prints the admin_pin hash of a PIN, read without echo from a terminal or
+// else from standard input, so the PIN stays out of the shell history.
+func handleParental(args []string) {
+	if len(args) != 1 || args[0] != "hash-pin" {
+		log.Fatalf("❌ Usage: yapl parental hash-pin")
+	}
+	var pin string
+	var err error
+	if isInteractive() {
+		pin, err = tui.ReadPassword("🔑 Admin PIN: ")
+	} else {
+		pin, err = bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+	if err != nil && pin == "" {
+		log.Fatalf("❌ Could not read the PIN: %v", err)
+	}
+	hash, err := policy.HashPIN(strings.TrimRight(pin, "\r\n"))
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	fmt.Println(hash)
 }
@@ -3,4 +3,7 @@ module yapl
 go 1.24.6
 
 require github.com/ulikunitz/xz v0.5.15
+
 require github.com/klauspost/compress v1.18.0
+
+require github.com/klauspost/pgzip v1.2.6
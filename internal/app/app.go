@@ -1,14 +1,38 @@
 package app
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"yapl/internal/archive"
 	"yapl/internal/command"
+	"yapl/internal/compress"
 	"yapl/internal/config"
+	"yapl/internal/crash"
 	"yapl/internal/dependency"
+	"yapl/internal/desktop"
+	"yapl/internal/detect"
+	"yapl/internal/events"
 	"yapl/internal/fs"
+	"yapl/internal/journal"
+	"yapl/internal/ntfs"
+	"yapl/internal/plugin"
+	"yapl/internal/policy"
+	"yapl/internal/saves"
+	"yapl/internal/sbom"
+	"yapl/internal/startup"
+	"yapl/internal/steam"
+	"yapl/internal/telemetry"
 )
 
 // App holds the runtime state and configuration for a specific game or application.
@@ -22,6 +46,7 @@ type App struct {
 	AppConfig     config.App
 	AppDir        string
 	PrefixPath    string
+	AdminPIN      string
 }
 
 // New creates and initializes a new App instance.
@@ -52,44 +77,549 @@ func (a *App) Setup() error {
 	if err := command.InitializePrefix(a.PrefixPath, a.AppConfig, a.GlobalConfig, a.DebugMode); err != nil {
 		return err
 	}
+	if err := command.ApplyRegistry(a.PrefixPath, a.AppConfig); err != nil {
+		return err
+	}
 	if a.AppConfig.Dependencies.DXVKMode == "custom" {
 		if err := dependency.InstallCustomComponents(a.PrefixPath, a.AppConfig.Dependencies); err != nil {
 			return err
 		}
 	}
+	if err := a.runScripts(string(plugin.PostSetup)); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	if _, err := plugin.Run(a.GlobalConfig.Plugins, plugin.PostSetup, a.hookPayload()); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
 	fmt.Println("\n✅ Setup complete!")
 	fmt.Printf("➡️ If you haven't already, install your application into the prefix at '%s'\n", fs.MustGetAbsolutePath(a.PrefixPath))
 	return nil
 }
 
+// PackageOptions are the per-bundle settings of Package.
+type PackageOptions struct {
+	Full    bool   // Also bundle the Proton build, runtime and dependencies the app uses
+	Version string // Release version recorded in the bundle's manifest
+	Notes   string // Changelog recorded in the bundle's manifest
+	// DiffAgainst is an earlier bundle of the app; if set, only a patch from it is packaged.
+	DiffAgainst string
+}
+
 // Package creates a compressed tarball of the application directory.
-func (a *App) Package(format string) error {
+func (a *App) Package(format string, opts PackageOptions) error {
 	fmt.Println("📦 Starting packaging process...")
-	return archive.Package(a.AppDir, format)
+	files, _ := config.ConfigFiles(config.ConfigPath(a.Type, a.Name))
+	for _, file := range files {
+		if rel, err := filepath.Rel(a.AppDir, file); err != nil || strings.HasPrefix(rel, "..") {
+			log.Printf("⚠️  Warning: the config extends '%s', which lives outside '%s' and will not be in the package.", file, a.AppDir)
+		}
+	}
+	bom, err := sbom.Generate(a.Name, a.AppConfig, a.GlobalConfig, archive.BuildTime())
+	if err != nil {
+		return fmt.Errorf("generating SBOM: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(a.AppDir, sbom.FileName), append(bom, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing SBOM: %w", err)
+	}
+	fmt.Printf("-> Wrote software bill of materials to %s.\n", sbom.FileName)
+	exclude := archive.DefaultExclude
+	if a.AppConfig.Package != nil && a.AppConfig.Package.Exclude != nil {
+		exclude = a.AppConfig.Package.Exclude
+	}
+	resolved, err := json.Marshal(a.AppConfig)
+	if err != nil {
+		return err
+	}
+	manifest := &archive.Manifest{
+		YaplVersion: sbom.YaplVersion(),
+		Created:     archive.BuildTime(),
+		Version:     opts.Version,
+		Notes:       opts.Notes,
+		Config:      resolved,
+	}
+	if opts.DiffAgainst != "" {
+		if opts.Full {
+			return errors.New("a patch cannot bundle the Proton build and dependencies; package --full without --diff-against")
+		}
+		return archive.PackagePatch(a.AppDir, format, exclude, manifest, opts.DiffAgainst)
+	}
+	var extra []archive.Tree
+	if opts.Full {
+		tmpDir, err := os.MkdirTemp("", "yapl-bundled-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+		if err := os.Chmod(tmpDir, 0755); err != nil {
+			return err
+		}
+		if extra, err = dependency.Bundle(a.AppConfig, a.GlobalConfig, tmpDir); err != nil {
+			return err
+		}
+	}
+	return archive.Package(a.AppDir, format, exclude, manifest, extra)
 }
 
 // Run prepares the environment and launches the application.
 func (a *App) Run() error {
 	fmt.Printf("🚀 Launching '%s'...\n", a.Name)
 	if err := dependency.EnsureAll(a.AppConfig, a.ForceUpgrade, a.GlobalConfig); err != nil {
+		telemetry.RecordFailure(telemetry.FailureDependencies)
 		return err
 	}
 	if err := dependency.EnsureRuntime(a.AppConfig, a.GlobalConfig); err != nil {
+		telemetry.RecordFailure(telemetry.FailureRuntime)
 		return err
 	}
+	startup.Phase("dependency checks")
 	if err := command.InitializePrefix(a.PrefixPath, a.AppConfig, a.GlobalConfig, a.DebugMode); err != nil {
+		telemetry.RecordFailure(telemetry.FailurePrefix)
+		return err
+	}
+	if err := command.ApplyRegistry(a.PrefixPath, a.AppConfig); err != nil {
+		telemetry.RecordFailure(telemetry.FailurePrefix)
 		return err
 	}
+	startup.Phase("prefix init")
 
 	method := a.AppConfig.LaunchMethod
 	if method == "" {
 		method = "container"
 	}
 
+	if err := a.enforceParentalControls(); err != nil {
+		return err
+	}
+	if err := a.runScripts(string(plugin.PreRun)); err != nil {
+		telemetry.RecordFailure(telemetry.FailureHook)
+		return err
+	}
+	if _, err := plugin.Run(a.GlobalConfig.Plugins, plugin.PreRun, a.hookPayload()); err != nil {
+		telemetry.RecordFailure(telemetry.FailureHook)
+		return err
+	}
+	startup.Phase("pre-run hooks")
+
 	fmt.Printf("-> Using launch method from config: %s\n", method)
+	command.SetPIDFile(a.pidFile())
+	command.SetLogDir(a.LogsDir())
+	command.SetMangoHudConfig(filepath.Join(a.AppDir, "MangoHud.conf"))
+	start := time.Now()
+	if err := a.launch(method); err != nil {
+		telemetry.RecordFailure(telemetry.FailureLaunch)
+		return err
+	}
+	a.recordSession(method, start)
+	if command.LastExitCode() != 0 {
+		a.reportKnownIssues(command.LastOutput() + crash.ReadLogs(a.LogsDir(), start, 1<<20))
+	}
+	return nil
+}
+
+// reportKnownIssues prints the known problems whose signatures appear in the output or
+// logs of a failed run, with what to do about them.
+func (a *App) reportKnownIssues(text string) {
+	sigs, err := crash.Load()
+	if err != nil {
+		log.Printf("⚠️  Could not load the crash signatures: %v", err)
+		return
+	}
+	for _, m := range crash.Scan(text, sigs) {
+		log.Printf("💡 Known issue: %s. Fix: %s.", m.Issue, m.Fix)
+		log.Printf("   Matched: %s", m.Line)
+		events.Emit("known_issue", map[string]interface{}{"id": m.ID, "issue": m.Issue, "fix": m.Fix, "line": m.Line})
+	}
+}
+
+// RunTool opens a Wine configuration tool (winecfg, regedit, control) in the app's prefix.
+func (a *App) RunTool(tool string) error {
+	if err := dependency.EnsureAll(a.AppConfig, a.ForceUpgrade, a.GlobalConfig); err != nil {
+		return err
+	}
+	return command.RunWineTool(a.PrefixPath, tool, a.AppConfig, a.GlobalConfig, a.DebugMode)
+}
+
+// Clone copies the app directory, including its prefix, under a new name so that changes
+// (a different Proton version, mods) can be tried without risking the working install.
+func (a *App) Clone(newName string) error {
+	if newName == "" || newName != filepath.Base(newName) {
+		return fmt.Errorf("invalid clone name '%s'", newName)
+	}
+	cloneDir := filepath.Join(a.Type, newName)
+	if _, err := os.Stat(cloneDir); err == nil {
+		return fmt.Errorf("'%s' already exists", cloneDir)
+	}
+
+	fmt.Printf("-> Cloning '%s' to '%s'...\n", a.AppDir, cloneDir)
+	if err := fs.CopyDir(a.AppDir, cloneDir); err != nil {
+		os.RemoveAll(cloneDir)
+		return fmt.Errorf("failed to copy app directory: %w", err)
+	}
+	os.Remove(filepath.Join(cloneDir, "yapl.pid"))
+	os.Remove(filepath.Join(cloneDir, LockFile))
+
+	// Absolute paths pointing into the original (e.g. in environment_vars) must follow the copy.
+	configPath := config.ConfigPath(a.Type, newName)
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("could not read cloned config: %w", err)
+	}
+	rewritten := strings.ReplaceAll(string(data), fs.MustGetAbsolutePath(a.AppDir)+"/", fs.MustGetAbsolutePath(cloneDir)+"/")
+	if err := os.WriteFile(configPath, []byte(rewritten), 0644); err != nil {
+		return fmt.Errorf("could not write cloned config: %w", err)
+	}
+
+	fmt.Printf("✅ Cloned to '%s'. Edit '%s' to change its setup.\n", cloneDir, configPath)
+	return nil
+}
+
+// BackupSaves archives the app's save paths into its saves directory.
+func (a *App) BackupSaves() error {
+	paths, err := a.SavePaths()
+	if err != nil {
+		return err
+	}
+	bundle, err := saves.Backup(a.PrefixPath, a.savesDir(), a.Name, paths)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Saves backed up to '%s'\n", bundle)
+	return nil
+}
+
+// RestoreSaves extracts a save backup into the prefix, defaulting to the most recent one.
+func (a *App) RestoreSaves(archivePath string) error {
+	restored, err := saves.Restore(a.PrefixPath, a.savesDir(), archivePath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Saves restored from '%s'\n", restored)
+	return nil
+}
+
+// ListSaves prints the available save backups.
+func (a *App) ListSaves() error {
+	backups, err := saves.List(a.savesDir())
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		fmt.Println("-> No save backups found.")
+	}
+	for _, b := range backups {
+		fmt.Println(b)
+		events.Emit("save_backup", map[string]interface{}{"path": b})
+	}
+	return nil
+}
+
+// SavePaths returns the configured save paths or, if there are none, those the save
+// location database knows for the app's Steam AppID.
+func (a *App) SavePaths() ([]string, error) {
+	if len(a.AppConfig.SavePaths) > 0 {
+		return a.AppConfig.SavePaths, nil
+	}
+	appID := a.AppConfig.SteamAppID
+	if id, ok := strings.CutPrefix(a.AppConfig.UMUOptions.GameID, "umu-"); appID == "" && ok {
+		if _, err := strconv.Atoi(id); err == nil {
+			appID = id
+		}
+	}
+	if appID == "" || appID == "0" {
+		return nil, nil
+	}
+	paths, name, err := saves.Lookup(appID)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) > 0 {
+		fmt.Printf("-> Using the save locations of %s (Steam app %s) from the save location database.\n", name, appID)
+	}
+	return paths, nil
+}
+
+func (a *App) savesDir() string {
+	return filepath.Join(a.AppDir, "saves")
+}
+
+// Kill stops the app's wineserver and, with force, kills every process left over from the last run.
+func (a *App) Kill(force bool) error {
+	return command.KillPrefix(a.PrefixPath, a.pidFile(), a.AppConfig, a.GlobalConfig, force)
+}
+
+// Warm installs what the app needs and gets its files and wineserver ready ahead of a
+// launch, so the launch itself starts right away.
+func (a *App) Warm() error {
+	fmt.Printf("🔥 Warming up '%s'...\n", a.Name)
+	if err := dependency.EnsureAll(a.AppConfig, a.ForceUpgrade, a.GlobalConfig); err != nil {
+		return err
+	}
+	if err := dependency.EnsureRuntime(a.AppConfig, a.GlobalConfig); err != nil {
+		return err
+	}
+	if err := command.Warm(a.PrefixPath, a.AppConfig, a.GlobalConfig, a.DebugMode); err != nil {
+		return err
+	}
+	fmt.Printf("✅ '%s' is ready to launch.\n", a.Name)
+	return nil
+}
+
+// AddToSteam adds the app as a non-Steam game to every Steam account on this machine,
+// including any artwork found in the app's art/ directory.
+func (a *App) AddToSteam() error {
+	userDirs := steam.UserDataDirs()
+	if len(userDirs) == 0 {
+		return fmt.Errorf("no Steam user data found")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	flagName := strings.TrimSuffix(a.Type, "s")
+	shortcut := steam.Shortcut{
+		AppName:       a.Name,
+		Exe:           fmt.Sprintf("%q", exe),
+		StartDir:      fmt.Sprintf("%q", fs.MustGetAbsolutePath(".")),
+		LaunchOptions: fmt.Sprintf("--%s %q run", flagName, a.Name),
+	}
+	for _, userDir := range userDirs {
+		if err := steam.AddShortcut(userDir, shortcut, filepath.Join(a.AppDir, "art")); err != nil {
+			return fmt.Errorf("%s: %w", userDir, err)
+		}
+		fmt.Printf("-> Added '%s' to %s\n", a.Name, filepath.Join(userDir, "config", "shortcuts.vdf"))
+	}
+	fmt.Println("✅ Restart Steam to see the new shortcut.")
+	return nil
+}
+
+// CreateShortcut adds the app to the desktop's application menu, using the icon of its
+// executable (or art/icon.png if the executable has none).
+func (a *App) CreateShortcut() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	entry := desktop.Entry{
+		ID:      "yapl-" + strings.ToLower(strings.Join(strings.Fields(a.Type+" "+a.Name), "-")),
+		Name:    a.Name,
+		Exec:    []string{exe, "--" + strings.TrimSuffix(a.Type, "s"), a.Name, "run"},
+		WorkDir: fs.MustGetAbsolutePath("."),
+	}
+
+	exePath := filepath.Join(a.PrefixPath, a.AppConfig.Executable)
+	if icons, err := desktop.ExtractIcons(exePath); err == nil {
+		entry.Icons = icons
+	} else if data, artErr := os.ReadFile(filepath.Join(a.AppDir, "art", "icon.png")); artErr == nil {
+		entry.Icons = []desktop.Icon{{Size: 0, PNG: data}}
+	} else {
+		log.Printf("⚠️  No icon found: %v", err)
+	}
+
+	path, err := desktop.Install(entry)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Created menu entry %s\n", path)
+	return nil
+}
+
+// Compress enables transparent filesystem compression for the app directory and reports
+// the space it takes before and after.
+func (a *App) Compress() error {
+	before, err := compress.Report(a.AppDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("-> Before:\n%s\n", before)
+	if err := compress.Apply(a.AppDir); err != nil {
+		return err
+	}
+	after, err := compress.Report(a.AppDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Compression enabled for '%s'. After:\n%s\n", a.AppDir, after)
+	return nil
+}
+
+// LinkWindowsGame links a game installed on a dual-boot Windows partition into the prefix,
+// by default at drive_c/Games/<name>, after checking that the partition is usable by Wine.
+func (a *App) LinkWindowsGame(source, target string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", source)
+	}
+	source = fs.MustGetAbsolutePath(source)
+
+	mount, err := ntfs.FindMount(source)
+	if err != nil {
+		return err
+	}
+	if driver := mount.Driver(); driver == "" {
+		log.Printf("⚠️  '%s' is on %s, not NTFS. Linking anyway.", source, mount.FSType)
+	} else {
+		fmt.Printf("-> '%s' is on NTFS (%s driver, mounted at %s).\n", source, driver, mount.Point)
+		for _, problem := range ntfs.Check(mount, source) {
+			log.Printf("⚠️  %s", problem)
+		}
+	}
+
+	if target == "" {
+		target = filepath.Join("drive_c", "Games", filepath.Base(source))
+	}
+	if filepath.IsAbs(target) || strings.HasPrefix(filepath.Clean(target), "..") {
+		return fmt.Errorf("target '%s' must be a path inside the prefix", target)
+	}
+	linkPath := filepath.Join(a.PrefixPath, target)
+	if _, err := os.Lstat(linkPath); err == nil {
+		return fmt.Errorf("'%s' already exists", linkPath)
+	}
+	if err := fs.MustCreateDirectory(filepath.Dir(linkPath)); err != nil {
+		return err
+	}
+	if err := os.Symlink(source, linkPath); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Linked '%s' to '%s'.\n", linkPath, source)
+	fmt.Printf("➡️ Point 'executable' in the config at the game's .exe below '%s'.\n", target)
+	return nil
+}
+
+// DetectExecutable checks the configured executable and, if it is missing or still the
+// explorer.exe placeholder, suggests likely game executables from drive_c. With prompt set
+// the user picks one and the config is updated.
+func (a *App) DetectExecutable(prompt bool) error {
+	current := a.AppConfig.Executable
+	if _, err := os.Stat(filepath.Join(a.PrefixPath, current)); err == nil && current != config.DefaultExecutable {
+		fmt.Printf("-> Executable '%s' exists.\n", current)
+		return nil
+	}
+
+	candidates, err := detect.Executables(a.PrefixPath)
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		log.Printf("⚠️  Executable '%s' not found and no game executables found in '%s'.", current, filepath.Join(a.PrefixPath, "drive_c"))
+		return nil
+	}
+	if len(candidates) > 9 {
+		candidates = candidates[:9]
+	}
+
+	fmt.Printf("-> Executable '%s' is not set up for this copy. Likely candidates:\n", current)
+	for i, c := range candidates {
+		kind := "console"
+		if c.GUI {
+			kind = "GUI"
+		}
+		fmt.Printf("   %d) %s (%s, %.1f MiB)\n", i+1, c.Path, kind, float64(c.Size)/(1<<20))
+	}
+	if !prompt {
+		fmt.Printf("➡️ Set 'executable' in '%s', or run 'yapl --%s \"%s\" detect-exe' in a terminal.\n", config.ConfigPath(a.Type, a.Name), strings.TrimSuffix(a.Type, "s"), a.Name)
+		return nil
+	}
+
+	fmt.Print("Use which executable? [1, 0 to keep the current one]: ")
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	choice := 1
+	if line = strings.TrimSpace(line); line != "" {
+		if choice, err = strconv.Atoi(line); err != nil || choice < 0 || choice > len(candidates) {
+			return fmt.Errorf("invalid choice '%s'", line)
+		}
+	}
+	if choice == 0 {
+		return nil
+	}
+	a.AppConfig.Executable = candidates[choice-1].Path
+	if err := config.UpdateApp(a.Type, a.Name, func(cfg *config.App) { cfg.Executable = a.AppConfig.Executable }); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Executable set to '%s'.\n", a.AppConfig.Executable)
+	return nil
+}
+
+// LogsDir is where debug runs write their Proton and DXVK logs.
+func (a *App) LogsDir() string {
+	return filepath.Join(a.AppDir, "logs")
+}
+
+// ShowLogs prints the newest log of the most recent debug run and lists the other logs
+// it wrote. With follow set it keeps printing lines appended to the newest log.
+func (a *App) ShowLogs(follow bool) error {
+	entries, err := os.ReadDir(a.LogsDir())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var files []os.FileInfo
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			files = append(files, info)
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no logs yet; run with --debug to write them to '%s'", a.LogsDir())
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().After(files[j].ModTime()) })
+
+	// Proton's own log is the most useful one; fall back to whatever was written last.
+	newest := files[0]
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), "steam-") {
+			newest = f
+			break
+		}
+	}
+	for _, f := range files {
+		if f != newest {
+			fmt.Printf("-> Also written: %s (%s)\n", filepath.Join(a.LogsDir(), f.Name()), f.ModTime().Format("2006-01-02 15:04:05"))
+		}
+	}
+	path := filepath.Join(a.LogsDir(), newest.Name())
+	fmt.Printf("-> %s (%s)\n", path, newest.ModTime().Format("2006-01-02 15:04:05"))
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := io.Copy(os.Stdout, file); err != nil {
+		return err
+	}
+	for follow {
+		time.Sleep(500 * time.Millisecond)
+		if _, err := io.Copy(os.Stdout, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hookPayload is the JSON plugins receive on stdin at the pre-run and post-setup hooks.
+func (a *App) hookPayload() map[string]interface{} {
+	return map[string]interface{}{
+		"type":   a.Type,
+		"name":   a.Name,
+		"dir":    fs.MustGetAbsolutePath(a.AppDir),
+		"prefix": fs.MustGetAbsolutePath(a.PrefixPath),
+		"config": a.AppConfig,
+	}
+}
+
+// pidFile is where the PID of the running application is tracked.
+func (a *App) pidFile() string {
+	return filepath.Join(a.AppDir, "yapl.pid")
+}
+
+// launch dispatches to the command runner matching the launch method.
+func (a *App) launch(method string) error {
 	switch method {
 	case "direct":
-		return command.RunDirectly(a.AppDir, a.AppConfig, a.GlobalConfig, a.IsSteamPrefix, a.DebugMode)
+		return command.RunDirectly(a.PrefixPath, a.AppConfig, a.GlobalConfig, a.IsSteamPrefix, a.DebugMode)
 	case "container":
 		return command.RunInContainer(a.PrefixPath, a.AppConfig, a.GlobalConfig, a.DebugMode)
 	case "umu":
@@ -98,3 +628,52 @@ func (a *App) Run() error {
 		return fmt.Errorf("unknown launch_method: '%s'. Please use 'direct', 'container', or 'umu'", method)
 	}
 }
+
+// enforceParentalControls refuses the launch when the playtime policy forbids it and
+// otherwise limits the session to the remaining budget. A valid admin PIN bypasses it.
+func (a *App) enforceParentalControls() error {
+	pc := a.GlobalConfig.ParentalControls
+	if pc == nil || len(pc.Rules) == 0 {
+		return nil
+	}
+	if policy.CheckPIN(*pc, a.AdminPIN) {
+		fmt.Println("-> Admin PIN accepted, parental controls bypassed.")
+		return nil
+	}
+
+	user := journal.CurrentUser()
+	sessions, err := journal.Query(journal.DefaultPath, journal.Filter{User: user})
+	if err != nil {
+		return fmt.Errorf("could not read session journal: %w", err)
+	}
+	decision, err := policy.Evaluate(*pc, user, a.Name, time.Now(), sessions)
+	if err != nil {
+		return err
+	}
+	if !decision.Allowed {
+		return fmt.Errorf("parental controls: %s", decision.Reason)
+	}
+	if decision.Remaining > 0 {
+		fmt.Printf("-> Parental controls: %s of playtime remaining.\n", decision.Remaining.Round(time.Minute))
+		command.SetTimeLimit(decision.Remaining, time.Duration(pc.WarnMinutes)*time.Minute)
+	}
+	return nil
+}
+
+// recordSession appends the finished launch to the session journal.
+func (a *App) recordSession(method string, start time.Time) {
+	session := journal.Session{
+		User:           journal.CurrentUser(),
+		Type:           a.Type,
+		Name:           a.Name,
+		Start:          start,
+		Duration:       time.Since(start).Seconds(),
+		ExitCode:       command.LastExitCode(),
+		LaunchMethod:   method,
+		ProtonVersion:  a.AppConfig.ProtonVersion,
+		RuntimeVersion: a.AppConfig.RuntimeVersion,
+	}
+	if err := journal.Append(journal.DefaultPath, session); err != nil {
+		log.Printf("⚠️  Could not record session: %v", err)
+	}
+}
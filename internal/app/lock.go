@@ -0,0 +1,81 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// LockFile is held by the yapl process working on a game or app, e.g. running it or
+// setting up its prefix, so another one cannot change the prefix under it. The lock is
+// an flock, released by the kernel when the process exits, so a crash never leaves it
+// behind; the file only says who holds it.
+const LockFile = "yapl.lock"
+
+// LockedError is returned by Lock when another process holds the lock.
+type LockedError struct {
+	Name      string
+	PID       int
+	Operation string
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("'%s' is already in use by %s; wait for it to finish, or use --force if you are sure it does not touch the prefix", e.Name, e.holder())
+}
+
+// holder describes the process holding the lock, e.g. "PID 1234 ('yapl run')".
+func (e *LockedError) holder() string {
+	holder := "another process"
+	if e.PID > 0 {
+		holder = fmt.Sprintf("PID %d", e.PID)
+	}
+	if e.Operation != "" {
+		holder += fmt.Sprintf(" ('yapl %s')", e.Operation)
+	}
+	return holder
+}
+
+// Lock takes the app's lock for operation (the command, e.g. "run") and returns the
+// function that releases it. If another process holds it, a *LockedError says which,
+// unless force is set: then the lock is ignored with a warning.
+func (a *App) Lock(operation string, force bool) (func(), error) {
+	if err := os.MkdirAll(a.AppDir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(a.AppDir, LockFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		held := &LockedError{Name: a.Name}
+		if data, err := os.ReadFile(f.Name()); err == nil {
+			pid, op, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+			held.PID, _ = strconv.Atoi(pid)
+			held.Operation = op
+		}
+		f.Close()
+		if !force {
+			return nil, held
+		}
+		log.Printf("⚠️  '%s' is in use by %s; continuing anyway because of --force.", a.Name, held.holder())
+		return func() {}, nil
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not lock '%s': %w", f.Name(), err)
+	}
+	if err := f.Truncate(0); err == nil {
+		fmt.Fprintf(f, "%d %s\n", os.Getpid(), operation)
+	}
+	return func() {
+		// The file stays; removing it would let a waiting process lock a file that a third
+		// one has already replaced.
+		f.Close()
+	}, nil
+}
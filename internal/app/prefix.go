@@ -0,0 +1,187 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"yapl/internal/command"
+	"yapl/internal/dependency"
+	"yapl/internal/events"
+	"yapl/internal/prefix"
+)
+
+// snapshotPath is where the prefix's last snapshot is kept. It describes this machine's
+// prefix only, so it lives outside the app directory and is never packaged.
+func (a *App) snapshotPath() string {
+	return filepath.Join("state", "prefix-snapshots", a.Type, a.Name+".json")
+}
+
+// SnapshotPrefix records the prefix's current files and registry, as the baseline for
+// DiffPrefix and ExportRecipe.
+func (a *App) SnapshotPrefix() error {
+	snap, err := prefix.Take(a.PrefixPath)
+	if err != nil {
+		return err
+	}
+	if err := snap.Save(a.snapshotPath()); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Recorded %d files and %d registry keys. Make your changes, then use 'prefix diff' or 'prefix export'.\n", len(snap.Files), len(snap.Registry))
+	return nil
+}
+
+// prefixDiff compares the prefix with its snapshot.
+func (a *App) prefixDiff() (prefix.Diff, error) {
+	before, err := prefix.Load(a.snapshotPath())
+	if os.IsNotExist(err) {
+		return prefix.Diff{}, fmt.Errorf("no snapshot of the prefix yet. Run 'prefix snapshot' before making changes")
+	}
+	if err != nil {
+		return prefix.Diff{}, err
+	}
+	now, err := prefix.Take(a.PrefixPath)
+	if err != nil {
+		return prefix.Diff{}, err
+	}
+	return prefix.Compare(before, now), nil
+}
+
+// DiffPrefix prints what changed in the prefix since its snapshot.
+func (a *App) DiffPrefix() error {
+	d, err := a.prefixDiff()
+	if err != nil {
+		return err
+	}
+	if d.Empty() {
+		fmt.Println("-> Nothing changed since the snapshot.")
+		return nil
+	}
+	for _, f := range d.Added {
+		fmt.Println("+ " + f)
+	}
+	for _, f := range d.Changed {
+		fmt.Println("~ " + f)
+	}
+	for _, f := range d.Removed {
+		fmt.Println("- " + f)
+	}
+	for _, r := range d.Registry {
+		name := r.Name
+		if name == "" {
+			name = "@"
+		}
+		if r.Removed {
+			fmt.Printf("- [%s] %s\n", r.Key, name)
+		} else {
+			fmt.Printf("~ [%s] %s=%s\n", r.Key, name, r.Data)
+		}
+	}
+	if verbs := d.Verbs(); len(verbs) > 0 {
+		fmt.Printf("-> Looks like winetricks: %s\n", strings.Join(verbs, " "))
+	}
+	events.Emit("prefix_diff", map[string]interface{}{"added": len(d.Added), "changed": len(d.Changed), "removed": len(d.Removed), "registry": len(d.Registry)})
+	return nil
+}
+
+// ExportRecipe writes the changes since the snapshot as a recipe directory.
+func (a *App) ExportRecipe(dest string) error {
+	d, err := a.prefixDiff()
+	if err != nil {
+		return err
+	}
+	if d.Empty() {
+		return fmt.Errorf("nothing changed since the snapshot")
+	}
+	recipe, err := prefix.Export(a.PrefixPath, d, dest)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Wrote a recipe with %d files, %d removals and %d registry values to '%s'.\n", len(recipe.Files), len(recipe.Removed), len(recipe.Registry), dest)
+	if len(recipe.Winetricks) > 0 {
+		fmt.Printf("-> The changes match the winetricks verbs: %s\n", strings.Join(recipe.Winetricks, " "))
+	}
+	return nil
+}
+
+// ApplyRecipe makes a recipe's changes to the prefix.
+func (a *App) ApplyRecipe(dir string) error {
+	recipe, err := prefix.LoadRecipe(dir)
+	if err != nil {
+		return err
+	}
+	if command.PrefixInUse(a.PrefixPath) {
+		return fmt.Errorf("wine is running in the prefix; stop it with 'kill' first")
+	}
+	if err := prefix.Apply(dir, recipe, a.PrefixPath); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Applied %d files, %d removals and %d registry values.\n", len(recipe.Files), len(recipe.Removed), len(recipe.Registry))
+	if len(recipe.Winetricks) > 0 {
+		fmt.Printf("-> The recipe's files match the winetricks verbs: %s. To install those instead of shipping their files, add them to 'winetricks'.\n", strings.Join(recipe.Winetricks, " "))
+	}
+	return nil
+}
+
+// ListPrograms prints the Windows programs installed in the prefix.
+func (a *App) ListPrograms() error {
+	programs, err := prefix.Programs(a.PrefixPath)
+	if err != nil {
+		return err
+	}
+	if command.PrefixInUse(a.PrefixPath) {
+		fmt.Println("-> Wine is running in the prefix; programs installed since it started are listed once it exits.")
+	}
+	if len(programs) == 0 {
+		fmt.Println("-> No programs installed.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tPUBLISHER\tUNINSTALL")
+	for _, p := range programs {
+		uninstall := p.UninstallString
+		if p.QuietUninstallString != "" {
+			uninstall = p.QuietUninstallString
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Name, p.Version, p.Publisher, uninstall)
+		events.Emit("program", map[string]interface{}{
+			"name": p.Name, "version": p.Version, "publisher": p.Publisher, "key": p.Key,
+			"install_location": p.InstallLocation, "uninstall": p.UninstallString, "quiet_uninstall": p.QuietUninstallString,
+		})
+	}
+	return w.Flush()
+}
+
+// UninstallProgram runs the uninstaller of the installed program matching name (see
+// prefix.FindProgram), silently where the kind of uninstaller allows it.
+func (a *App) UninstallProgram(name string) error {
+	programs, err := prefix.Programs(a.PrefixPath)
+	if err != nil {
+		return err
+	}
+	p, err := prefix.FindProgram(programs, name)
+	if err != nil {
+		return err
+	}
+	args, silent, err := p.SilentUninstall()
+	if err != nil {
+		return err
+	}
+	if err := dependency.EnsureAll(a.AppConfig, a.ForceUpgrade, a.GlobalConfig); err != nil {
+		return err
+	}
+	fmt.Printf("-> Uninstalling %s %s...\n", p.Name, p.Version)
+	if !silent {
+		fmt.Println("-> Its uninstaller has no known silent mode; answer its dialogs to finish.")
+	}
+	if err := command.RunInPrefix(a.PrefixPath, args, a.AppConfig, a.GlobalConfig, a.DebugMode); err != nil {
+		return err
+	}
+	if code := command.LastExitCode(); code != 0 {
+		return fmt.Errorf("the uninstaller exited with code %d", code)
+	}
+	fmt.Printf("✅ Uninstalled %s. 'prefix programs' shows it gone once Wine has saved the registry.\n", p.Name)
+	return nil
+}
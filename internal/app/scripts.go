@@ -0,0 +1,97 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"yapl/internal/fs"
+	"yapl/internal/script"
+)
+
+// runScripts runs the hook scripts for hook: hooks/<hook>.yapl next to runner.json for
+// every game, then the one in the game's own hooks/ directory. Their changes to the
+// launch apply to this run only.
+func (a *App) runScripts(hook string) error {
+	paths := []string{
+		filepath.Join("hooks", hook+".yapl"),
+		filepath.Join(a.AppDir, "hooks", hook+".yapl"),
+	}
+	var env *script.Env
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if env == nil {
+			env = a.scriptEnv()
+		}
+		fmt.Printf("-> Running %s...\n", path)
+		if err := script.Run(path, string(source), env); err != nil {
+			return err
+		}
+	}
+	if env == nil {
+		return nil
+	}
+
+	a.AppConfig.Executable = env.Vars["executable"].(string)
+	a.AppConfig.LaunchArgs = env.Vars["launch_args"].([]string)
+	a.AppConfig.GPU = env.Vars["gpu"].(string)
+	a.AppConfig.EnvironmentVars = env.Environment
+	return nil
+}
+
+// scriptEnv exposes the game's config and the machine's state to scripts.
+func (a *App) scriptEnv() *script.Env {
+	hostname, _ := os.Hostname()
+	onBattery, percent := batteryState()
+	environment := make(map[string]string, len(a.AppConfig.EnvironmentVars))
+	for k, v := range a.AppConfig.EnvironmentVars {
+		environment[k] = v
+	}
+	return &script.Env{
+		Vars: map[string]script.Value{
+			"name":            a.Name,
+			"type":            strings.TrimSuffix(a.Type, "s"),
+			"game_dir":        fs.MustGetAbsolutePath(a.AppDir),
+			"prefix":          fs.MustGetAbsolutePath(a.PrefixPath),
+			"proton_version":  a.AppConfig.ProtonVersion,
+			"launch_method":   a.AppConfig.LaunchMethod,
+			"executable":      a.AppConfig.Executable,
+			"launch_args":     append([]string{}, a.AppConfig.LaunchArgs...),
+			"gpu":             a.AppConfig.GPU,
+			"hostname":        hostname,
+			"on_battery":      onBattery,
+			"battery_percent": percent,
+		},
+		Settable:    map[string]bool{"executable": true, "launch_args": true, "gpu": true},
+		Environment: environment,
+		Output:      func(s string) { fmt.Println("-> " + s) },
+	}
+}
+
+// batteryState reports whether the machine runs on battery and the charge left, or -1
+// without a battery.
+func batteryState() (bool, float64) {
+	supplies, _ := filepath.Glob("/sys/class/power_supply/*")
+	for _, supply := range supplies {
+		kind, _ := os.ReadFile(filepath.Join(supply, "type"))
+		if strings.TrimSpace(string(kind)) != "Battery" {
+			continue
+		}
+		status, _ := os.ReadFile(filepath.Join(supply, "status"))
+		capacity, _ := os.ReadFile(filepath.Join(supply, "capacity"))
+		percent, err := strconv.ParseFloat(strings.TrimSpace(string(capacity)), 64)
+		if err != nil {
+			percent = -1
+		}
+		return strings.TrimSpace(string(status)) == "Discharging", percent
+	}
+	return false, -1
+}
@@ -2,27 +2,58 @@ package archive
 
 import (
 	"archive/tar"
+	"bufio"
 	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
 	"github.com/ulikunitz/xz"
+
+	"yapl/internal/events"
+	"yapl/internal/fs"
+	"yapl/internal/warn"
 )
 
+// walkCtx stops packaging when it is done.
+var walkCtx = context.Background()
+
+// SetContext makes packaging stop, removing the unfinished bundle, once ctx is done, e.g.
+// when the user presses Ctrl-C.
+func SetContext(ctx context.Context) {
+	walkCtx = ctx
+}
+
 // Archive represents a local or remote compressed tarball.
 type Archive struct {
 	Source string
+	SHA256 string // Checksum of the compressed tarball, set by Extract
+	// StripComponents is how many leading path components Extract removes from entry
+	// names, overriding the detection of a top-level directory.
+	StripComponents *int
 }
 
-// Extract unpacks the archive to a destination path.
+// Extract unpacks the archive to a destination path. With stripTopLevelDir, the
+// archive's top-level directory is left out if all entries are below a single one.
 func (a *Archive) Extract(destPath string, stripTopLevelDir bool) error {
+	opts := extractOptions
+	opts.CaseConflicts = ""
+	opts.InvalidNames = ""
+	return a.extract(destPath, stripTopLevelDir, opts)
+}
+
+func (a *Archive) extract(destPath string, stripTopLevelDir bool, opts ExtractOptions) error {
 	if a.Source == "" {
 		return errors.New("archive source cannot be empty")
 	}
@@ -33,16 +64,65 @@ func (a *Archive) Extract(destPath string, stripTopLevelDir bool) error {
 	}
 	defer stream.Close()
 
-	decompressedReader, err := getDecompressedReader(stream, a.Source)
+	var sidecar map[string]string
+	if opts.Sidecar {
+		if sidecar, err = readSidecar(a.Source); err != nil {
+			return fmt.Errorf("reading the %s file: %w", SidecarSuffix, err)
+		}
+	}
+
+	hash := sha256.New()
+	hashed := io.TeeReader(stream, hash)
+	decompressedReader, err := getDecompressedReader(hashed, a.Source)
+	if err != nil {
+		return err
+	}
+	opts.size = estimateSize(a.Source, sourceSize(stream))
+	events.Emit("extract_start", map[string]interface{}{"source": a.Source, "destination": destPath})
+	extracted, err := extractTar(decompressedReader, destPath, newStripper(stripTopLevelDir, a.StripComponents), opts)
 	if err != nil {
 		return err
 	}
-	// **FIX:** Pass the stripTopLevelDir boolean to the extractTar function.
-	return extractTar(decompressedReader, destPath, stripTopLevelDir)
+	if err := normalizeModes(extracted, opts); err != nil {
+		return fmt.Errorf("normalizing permissions: %w", err)
+	}
+	// The tar reader stops at the end-of-archive marker; hash the padding after it too.
+	if _, err := io.Copy(io.Discard, hashed); err != nil {
+		return err
+	}
+	a.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	if sidecar != nil {
+		sums := map[string]string{path.Base(a.Source): a.SHA256}
+		bundle := path.Base(a.Source)
+		if volumes, ok := stream.(*multiFile); ok {
+			sums = volumes.checksums()
+			bundle, _, _ = SplitVolume(bundle)
+		}
+		if err := checkSidecar(sidecar, sums); err != nil {
+			return err
+		}
+		fmt.Printf("-> Checksums match %s%s.\n", bundle, SidecarSuffix)
+	}
+	events.Emit("extract_done", map[string]interface{}{"source": a.Source, "destination": destPath, "entries": len(extracted)})
+	return nil
+}
+
+// DefaultExclude is left out of packages whose config sets no exclude patterns: logs,
+// shader caches and crash dumps, which are specific to the machine that made them.
+var DefaultExclude = []string{"logs", "shadercache", "**/*.log", "**/*.dmp", "**/*.mdmp", "drive_c/users/*/AppData/Local/CrashDumps"}
+
+// Tree is a directory packaged into a bundle under another name, e.g. a Proton build
+// bundled with a game. Name is relative to the bundle's top-level directory.
+type Tree struct {
+	Dir  string
+	Name string
 }
 
-// Package creates a new compressed bundle from a source directory.
-func Package(sourceDir, format string) error {
+// Package creates a new compressed bundle from a source directory, leaving out the paths
+// matching the exclude patterns (see Included for how they match), and adds the extra
+// trees as they are. If manifest is not nil, its file list is filled in and it is written
+// into the bundle.
+func Package(sourceDir, format string, exclude []string, manifest *Manifest, extra []Tree) error {
 	if _, err := os.Stat(sourceDir); os.IsNotExist(err) {
 		return fmt.Errorf("application directory '%s' not found", sourceDir)
 	}
@@ -53,37 +133,105 @@ func Package(sourceDir, format string) error {
 	}
 
 	packageName := filepath.Base(sourceDir) + extension
+	if encrypt {
+		packageName += EncryptedSuffix
+	}
 	fmt.Printf("-> Creating %s bundle '%s'...\n", strings.ToUpper(format), packageName)
-	if err := createBundle(packageName, sourceDir, format); err != nil {
+	if encrypt {
+		fmt.Printf("-> Encrypting with %s.\n", describeEncryption())
+	}
+	if manifest != nil {
+		fmt.Println("-> Listing files...")
+		if err := fillManifest(manifest, sourceDir, exclude, extra, false); err != nil {
+			return fmt.Errorf("failed to create manifest: %w", err)
+		}
+	}
+	if err := createBundle(packageName, sourceDir, format, exclude, manifest, extra); err != nil {
 		return fmt.Errorf("failed to create package: %w", err)
 	}
 	fmt.Println("\n✅ Packaging complete!")
+	if splitSize > 0 {
+		volumes := Volumes(packageName)
+		fmt.Printf("➡️ Distribute the %d volumes '%s' to '%s' together; unpackage the first.\n", len(volumes), filepath.Base(volumes[0]), filepath.Base(volumes[len(volumes)-1]))
+		return nil
+	}
 	fmt.Printf("➡️ Distribute '%s' to other machines.\n", packageName)
 	return nil
 }
 
 // Unpackage extracts one or more archives into a target directory.
 func Unpackage(targetDir string, archivePaths []string) error {
+	return UnpackageSelected(targetDir, archivePaths, nil)
+}
+
+// UnpackageSelected extracts only the entries matching the include patterns (see Included).
+// Unlike a full unpackage it may write into an existing installation, e.g. to restore a
+// save directory from a bundle.
+func UnpackageSelected(targetDir string, archivePaths []string, include []string) error {
+	return UnpackageInto(targetDir, archivePaths, include, "", false)
+}
+
+// UnpackageInto is UnpackageSelected with the destination named name instead of after the
+// archive, which needs a single archive, and with merge set, extracting over an existing
+// destination instead of skipping it. Files the bundle does not contain are kept.
+func UnpackageInto(targetDir string, archivePaths []string, include []string, name string, merge bool) error {
 	if len(archivePaths) == 0 {
 		return errors.New("no archive files provided")
 	}
+	if name != "" && (len(archivePaths) > 1 || filepath.Base(name) != name || !filepath.IsLocal(name)) {
+		return fmt.Errorf("invalid destination '%s': give a single archive and a directory name", name)
+	}
 	fmt.Println("📦 Starting unpackaging process...")
 	for _, archivePath := range archivePaths {
 		fmt.Printf("-> Unpackaging '%s'...\n", archivePath)
-		nameWithoutExt, ok := trimArchiveSuffix(filepath.Base(archivePath))
+		nameWithoutExt, ok := TrimArchiveSuffix(filepath.Base(archivePath))
 		if !ok {
 			log.Printf("⚠️  Skipping '%s': unrecognized archive extension.", archivePath)
 			continue
 		}
+		if strings.HasSuffix(nameWithoutExt, PatchSuffix) && len(include) == 0 {
+			log.Printf("⚠️  Skipping '%s': it is a patch; apply it with --apply-patch.", archivePath)
+			continue
+		}
 
+		if name != "" {
+			nameWithoutExt = name
+		}
 		destPath := filepath.Join(targetDir, nameWithoutExt)
-		if _, err := os.Stat(destPath); err == nil {
-			log.Printf("⚠️  Skipping '%s': destination '%s' already exists.", archivePath, destPath)
+		_, err := os.Stat(destPath)
+		existed := err == nil
+		if existed && len(include) == 0 && !merge {
+			log.Printf("⚠️  Skipping '%s': destination '%s' already exists. Use --force to extract over it or --dest to choose another name.", archivePath, destPath)
 			continue
 		}
+		if existed && merge {
+			fmt.Printf("-> Extracting over the existing '%s'...\n", destPath)
+		}
 
+		// The bundle is extracted and checked next to the destination, and only moved into
+		// place once its checksums match, so a corrupt bundle changes nothing.
+		if err := os.MkdirAll(targetDir, 0755); err != nil {
+			return err
+		}
+		staging, err := os.MkdirTemp(targetDir, "."+nameWithoutExt+"-unpack-")
+		if err != nil {
+			return err
+		}
+		opts := extractOptions
+		opts.Include = include
+		opts.Verify, opts.Sidecar = true, true
 		ar := &Archive{Source: archivePath}
-		if err := ar.Extract(destPath, false); err != nil {
+		err = os.Chmod(staging, 0755)
+		if err == nil {
+			err = ar.extract(staging, true, opts)
+		}
+		if err == nil && existed {
+			err = mergeInto(staging, destPath)
+		} else if err == nil {
+			err = os.Rename(staging, destPath)
+		}
+		os.RemoveAll(staging)
+		if err != nil {
 			log.Printf("❌ Failed to unpackage '%s': %v", archivePath, err)
 		} else {
 			fmt.Printf("✅ Successfully unpackaged to '%s'\n", destPath)
@@ -93,24 +241,100 @@ func Unpackage(targetDir string, archivePaths []string) error {
 	return nil
 }
 
+// mergeInto moves the tree extracted to staging into the existing directory dest. Files of
+// the same name are replaced, other files of dest are kept. Where dest has a symlink or
+// file in place of one of the tree's directories, it is replaced by the directory, so
+// nothing is moved through a symlink.
+func mergeInto(staging, dest string) error {
+	return filepath.Walk(staging, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(staging, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		existing, err := os.Lstat(target)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if info.IsDir() {
+			if existing != nil && existing.IsDir() {
+				return nil
+			}
+			if existing != nil {
+				if err := os.Remove(target); err != nil {
+					return err
+				}
+			}
+			return os.Mkdir(target, info.Mode().Perm())
+		}
+		if existing != nil && existing.IsDir() {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+		return os.Rename(path, target)
+	})
+}
+
+// List returns the headers of the archive's entries that match the include patterns.
+func List(source string, include []string) ([]*tar.Header, error) {
+	ar := &Archive{Source: source}
+	stream, err := ar.open()
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	decompressedReader, err := getDecompressedReader(stream, source)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(decompressedReader)
+	var headers []*tar.Header
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return headers, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tar: %w", err)
+		}
+		if Included(include, hdr.Name) {
+			headers = append(headers, hdr)
+		}
+	}
+}
+
 func (a *Archive) open() (io.ReadCloser, error) {
 	if strings.HasPrefix(a.Source, "http") {
 		fmt.Printf(" Downloading from %s...\n", a.Source)
-		resp, err := http.Get(a.Source)
-		if err != nil {
-			return nil, fmt.Errorf("http get: %w", err)
-		}
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			return nil, fmt.Errorf("download failed: %s", resp.Status)
+		return openHTTP(a.Source)
+	}
+	if bundle, i, ok := SplitVolume(a.Source); ok {
+		if i != 1 {
+			return nil, fmt.Errorf("'%s' is not the first volume; open '%s' instead", a.Source, VolumeName(bundle, 1))
 		}
-		return resp.Body, nil
+		fmt.Printf(" Reading volumes of %s...\n", bundle)
+		return openVolumes(bundle)
 	}
 	fmt.Printf(" Reading local file %s...\n", a.Source)
 	return os.Open(a.Source)
 }
 
 func getDecompressedReader(r io.Reader, sourceFilename string) (io.Reader, error) {
+	if bundle, _, ok := SplitVolume(sourceFilename); ok {
+		sourceFilename = bundle
+	}
+	if name, encrypted := trimEncrypted(sourceFilename); encrypted {
+		dr, err := newDecryptReader(r)
+		if err != nil {
+			return nil, err
+		}
+		r, sourceFilename = dr, name
+	}
 	switch {
 	case strings.HasSuffix(sourceFilename, ".tar.gz"):
 		return gzip.NewReader(r)
@@ -125,78 +349,304 @@ func getDecompressedReader(r io.Reader, sourceFilename string) (io.Reader, error
 	}
 }
 
-func extractTar(r io.Reader, destPath string, stripTopLevelDir bool) error {
+// extractTar writes the tar stream to destPath and returns the files and directories it created.
+func extractTar(r io.Reader, destPath string, strip *stripper, opts ExtractOptions) ([]string, error) {
 	tr := tar.NewReader(r)
 	fmt.Println(" Extracting archive...")
+	defer warn.Summary()
+	cases := newCaseIndex(opts.CaseConflicts)
+	defer cases.report()
+	names := newNameChecker(opts.InvalidNames)
+	owners := newOwnerTracker(opts)
+	check := newManifestCheck(opts.Verify)
+	guard := newPathGuard(destPath)
+	written := map[string]string{} // Extracted files by entry name, for hard links to them
+	files := map[string]bool{}     // Every file entry so far, extracted or not
+	type dir struct {
+		path string
+		hdr  *tar.Header
+	}
+	var dirs []dir // Their times are restored last, once nothing more is added to them
+	var extracted []string
+	entries, _ := os.ReadDir(destPath)
+	fresh := len(entries) == 0 // Everything in destPath comes from the archive
+	first := true
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
-			return nil // End of archive
+			// End of archive
+			if err := check.finish(opts.Include); err != nil {
+				return nil, err
+			}
+			if err := names.finish(destPath); err != nil {
+				return nil, err
+			}
+			if err := owners.finish(destPath); err != nil {
+				return nil, err
+			}
+			for i := len(dirs) - 1; i >= 0; i-- {
+				restoreTimes(dirs[i].path, dirs[i].hdr)
+			}
+			return extracted, nil
 		}
 		if err != nil {
-			return fmt.Errorf("reading tar: %w", err)
+			return nil, fmt.Errorf("reading tar: %w", err)
 		}
-
-		var target string
-		if stripTopLevelDir {
-			parts := strings.Split(hdr.Name, string(filepath.Separator))
-			if len(parts) <= 1 {
-				continue // Skip top-level directory or files at root
+		if hdr.Typeflag == tar.TypeReg {
+			if err := check.load(tr, hdr.Name); err != nil {
+				return nil, err
+			}
+		}
+		if first && fresh && len(opts.Include) == 0 {
+			// A bundle's manifest comes first and knows its size; otherwise estimate.
+			need := opts.size
+			if check.manifest != nil {
+				need = check.manifest.TotalSize
+			}
+			if err := checkSpace(destPath, need); err != nil {
+				return nil, err
 			}
-			relativePath := strings.Join(parts[1:], string(filepath.Separator))
-			target = filepath.Join(destPath, relativePath)
-		} else {
-			target = filepath.Join(destPath, hdr.Name)
 		}
+		first = false
 
-		// **FIX:** Clean the path and add a security check to prevent path traversal.
-		target = filepath.Clean(target)
-		if !strings.HasPrefix(target, destPath) {
-			return fmt.Errorf("archive contains invalid path: %s", hdr.Name)
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue // pax_global_header, e.g. of 'git archive'
+		}
+		if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeLink {
+			files[hdr.Name] = true
+		}
+		relativePath, stripped, relocate := strip.strip(hdr.Name, hdr.Typeflag == tar.TypeDir)
+		if relocate {
+			if !fresh || cases != nil || names != nil || owners != nil {
+				return nil, fmt.Errorf("the archive has no single top-level directory ('%s' is not below '%s'); extract it without stripping, e.g. with \"strip_components\": 0", hdr.Name, strip.root)
+			}
+			moved, err := strip.relocate(destPath)
+			if err != nil {
+				return nil, fmt.Errorf("moving the extracted files below '%s': %w", strip.root, err)
+			}
+			for name, p := range written {
+				written[name] = moved(p)
+			}
+			for i := range dirs {
+				dirs[i].path = moved(dirs[i].path)
+			}
+			for i := range extracted {
+				extracted[i] = moved(extracted[i])
+			}
+			guard.dirs = map[string]bool{}
+		}
+		if stripped {
+			continue
+		}
+		if !Included(opts.Include, hdr.Name) {
+			continue
+		}
+		relativePath, skip, err := cases.resolve(relativePath, hdr.Typeflag == tar.TypeDir)
+		if err != nil {
+			return nil, err
+		}
+		if skip {
+			check.skip(hdr.Name)
+			continue
+		}
+		relativePath = names.resolve(relativePath)
+		target, err := guard.target(hdr.Name, relativePath)
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeSymlink {
+			if err := guard.replace(hdr.Name, target); err != nil {
+				return nil, err
+			}
 		}
 
 		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
-			return fmt.Errorf("mkdirAll failed for %s: %w", filepath.Dir(target), err)
+			return nil, fmt.Errorf("mkdirAll failed for %s: %w", filepath.Dir(target), err)
 		}
 
 		switch hdr.Typeflag {
 		case tar.TypeDir:
 			if err := os.MkdirAll(target, os.FileMode(hdr.Mode)); err != nil {
-				return fmt.Errorf("mkdir dir: %w", err)
+				return nil, fmt.Errorf("mkdir dir: %w", err)
 			}
+			restoreXattrs(target, hdr)
+			extracted = append(extracted, target)
+			dirs = append(dirs, dir{target, hdr})
 		case tar.TypeReg:
-			out, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(hdr.Mode))
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR|os.O_TRUNC, os.FileMode(hdr.Mode))
 			if err != nil {
-				return fmt.Errorf("create file: %w", err)
+				return nil, fmt.Errorf("create file: %w", err)
 			}
-			_, err = io.Copy(out, tr)
+			content, hashed := check.wrap(tr, hdr.Name)
+			err = copySparse(out, content)
 			out.Close()
 			if err != nil {
-				return fmt.Errorf("copy file: %w", err)
+				return nil, fmt.Errorf("copy file: %w", err)
 			}
+			hashed()
+			restoreXattrs(target, hdr)
+			restoreTimes(target, hdr)
+			written[hdr.Name] = target
+			extracted = append(extracted, target)
 		case tar.TypeSymlink:
 			if err := os.Symlink(hdr.Linkname, target); err != nil {
-				return fmt.Errorf("create symlink: %w", err)
+				return nil, fmt.Errorf("create symlink: %w", err)
+			}
+			guard.symlink(hdr.Name)
+		case tar.TypeLink:
+			source, ok := written[hdr.Linkname]
+			if !ok && !files[hdr.Linkname] {
+				// Only earlier entries may be linked to, never files already on disk.
+				return nil, fmt.Errorf("archive entry '%s' is a hard link to '%s', which is not a file earlier in the archive", hdr.Name, hdr.Linkname)
+			}
+			if !ok {
+				warn.Printf("Skipping '%s': it is a hard link to '%s', which was not extracted.", hdr.Name, hdr.Linkname)
+				check.skip(hdr.Name)
+				continue
+			}
+			if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+				if err := os.Remove(target); err != nil {
+					return nil, err
+				}
+			}
+			if err := os.Link(source, target); err != nil {
+				return nil, fmt.Errorf("create hard link: %w", err)
+			}
+			check.link(hdr.Name, hdr.Linkname)
+			written[hdr.Name] = target
+			extracted = append(extracted, target)
+		default:
+			continue
+		}
+		if err := owners.apply(target, relativePath, hdr); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// createBundle packages sourceDir and the extra trees. The files are hashed as they are
+// packaged, so the manifest goes first without checksums, for what only needs the
+// bundle's start, and again complete at the end.
+func createBundle(bundleName, sourceDir, format string, exclude []string, manifest *Manifest, extra []Tree) error {
+	top := filepath.Base(sourceDir)
+	return writeBundle(bundleName, format, true, func(tw *tar.Writer) error {
+		if manifest != nil {
+			manifest.Trailing = true
+			if err := writeManifest(tw, top, manifest); err != nil {
+				return err
+			}
+		}
+		if err := addTreeAs(tw, sourceDir, top, exclude, manifest); err != nil {
+			return err
+		}
+		for _, t := range extra {
+			if err := addTreeAs(tw, t.Dir, filepath.Join(top, t.Name), nil, manifest); err != nil {
+				return err
 			}
 		}
+		if manifest != nil {
+			manifest.Trailing = false
+			return writeManifest(tw, top, manifest)
+		}
+		return nil
+	})
+}
+
+// PackagePaths creates a compressed bundle containing only the given paths, which are
+// stored relative to baseDir. Paths may contain glob patterns; missing paths are skipped.
+func PackagePaths(bundleName, baseDir string, paths []string, format string) (int, error) {
+	if _, err := getExtensionForFormat(format); err != nil {
+		return 0, err
+	}
+	added := 0
+	err := writeBundle(bundleName, format, false, func(tw *tar.Writer) error {
+		for _, p := range paths {
+			matches, err := filepath.Glob(filepath.Join(baseDir, p))
+			if err != nil {
+				return fmt.Errorf("invalid path pattern '%s': %w", p, err)
+			}
+			for _, match := range matches {
+				if err := addTree(tw, baseDir, match, nil); err != nil {
+					return err
+				}
+				added++
+			}
+		}
+		return nil
+	})
+	return added, err
+}
+
+// WriteTar streams an uncompressed tarball of root to w, leaving out the paths matching
+// the exclude patterns, with entries named relative to root's parent so the archive has a
+// single top-level directory.
+func WriteTar(w io.Writer, root string, exclude []string) error {
+	tw := tar.NewWriter(w)
+	if err := addTree(tw, filepath.Dir(root), root, exclude); err != nil {
+		return err
 	}
+	return tw.Close()
 }
 
-func createBundle(bundleName, sourceDir, format string) error {
-	f, err := os.Create(bundleName)
+// writeBundle writes a compressed tar stream to bundleName, or to its volumes, and with
+// sidecar set the checksum file next to it (see SidecarSuffix).
+func writeBundle(bundleName, format string, sidecar bool, write func(tw *tar.Writer) error) error {
+	var f io.WriteCloser
+	var volumes *volumeWriter
+	var single *hashingWriter
+	var err error
+	if splitSize > 0 {
+		volumes, err = newVolumeWriter(bundleName, splitSize)
+		f = volumes
+	} else {
+		var file *os.File
+		file, err = os.Create(bundleName)
+		single = &hashingWriter{File: file, h: sha256.New()}
+		f = single
+	}
 	if err != nil {
 		return fmt.Errorf("create bundle: %w", err)
 	}
 	defer f.Close()
+	out := io.Writer(f)
+	var enc *encryptWriter
+	if _, encrypted := trimEncrypted(bundleName); encrypted {
+		if enc, err = newEncryptWriter(f); err != nil {
+			return fmt.Errorf("encrypt bundle: %w", err)
+		}
+		out = enc
+	}
+	buf := bufio.NewWriterSize(out, bufferSize)
 
+	n := compressThreads()
+	level, hasLevel := levels[format]
 	var compressor io.WriteCloser
 	switch format {
 	case "gz":
-		compressor = gzip.NewWriter(f)
+		if !hasLevel {
+			level = gzip.DefaultCompression
+		}
+		if n == 1 {
+			compressor, err = gzip.NewWriterLevel(buf, level)
+		} else {
+			var zw *pgzip.Writer
+			if zw, err = pgzip.NewWriterLevel(buf, level); err == nil {
+				err = zw.SetConcurrency(bufferSize, n)
+			}
+			compressor = zw
+		}
 	case "xz":
-		compressor, err = xz.NewWriter(f)
+		cfg := xz.WriterConfig{}
+		if hasLevel {
+			cfg.DictCap = xzDictSizes[level]
+		}
+		compressor, err = cfg.NewWriter(buf)
 	case "zst":
-		compressor, err = zstd.NewWriter(f)
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(n)}
+		if hasLevel {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		compressor, err = zstd.NewWriter(buf, opts...)
 	}
 	if err != nil {
 		return fmt.Errorf("create %s writer: %w", format, err)
@@ -206,18 +656,89 @@ func createBundle(bundleName, sourceDir, format string) error {
 	tw := tar.NewWriter(compressor)
 	defer tw.Close()
 
-	return filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+	if err := write(tw); err != nil {
+		f.Close()
+		removeBundle(bundleName)
+		if walkCtx.Err() != nil {
+			return errors.New("interrupted")
+		}
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := compressor.Close(); err != nil {
+		return fmt.Errorf("finish %s stream: %w", format, err)
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	if enc != nil {
+		if err := enc.Close(); err != nil {
+			return fmt.Errorf("write bundle: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if !sidecar {
+		return nil
+	}
+	if volumes != nil {
+		return writeSidecar(bundleName, volumes.names, volumes.sums)
+	}
+	return writeSidecar(bundleName, []string{bundleName}, []string{hex.EncodeToString(single.h.Sum(nil))})
+}
+
+// removeBundle deletes an unfinished bundle, or all its volumes.
+func removeBundle(bundleName string) {
+	if splitSize > 0 {
+		for _, v := range Volumes(bundleName) {
+			os.Remove(v)
+		}
+		return
+	}
+	os.Remove(bundleName)
+}
+
+// addTree writes root and everything below it to the tar stream, naming entries relative to
+// baseDir and skipping those that match an exclude pattern.
+func addTree(tw *tar.Writer, baseDir, root string, exclude []string) error {
+	name, err := filepath.Rel(baseDir, root)
+	if err != nil {
+		return err
+	}
+	return addTreeAs(tw, root, name, exclude, nil)
+}
+
+// addTreeAs is addTree with root's entry named name and everything below it named
+// relative to that. The checksums of the files are added to manifest if it is missing
+// them (see hashEntry).
+func addTreeAs(tw *tar.Writer, root, name string, exclude []string, manifest *Manifest) error {
+	owners := readOwnershipManifest(root)
+	copyBuffer := make([]byte, bufferSize)
+	links := map[[2]uint64]string{} // Entry names of files with several links, by device and inode
+	// The walker lists directories ahead in the background while files are compressed.
+	return fs.Walk(walkCtx, root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		header, err := tar.FileInfoHeader(info, info.Name())
+		rel, err := filepath.Rel(root, path)
 		if err != nil {
 			return err
 		}
-		header.Name, err = filepath.Rel(filepath.Dir(sourceDir), path)
+		entry := filepath.Join(name, rel)
+		if skip, skipDir := skipEntry(root, path, entry, info, exclude); skip {
+			if skipDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, info.Name())
 		if err != nil {
 			return err
 		}
+		header.Name = restoreName(entry)
 
 		if info.Mode()&os.ModeSymlink != 0 {
 			header.Linkname, err = os.Readlink(path)
@@ -225,26 +746,59 @@ func createBundle(bundleName, sourceDir, format string) error {
 				return err
 			}
 		}
-		header.Uid, header.Gid = 65534, 65534
-		header.Uname, header.Gname = "nobody", "nobody"
+		stampOwner(header, owners, rel)
+		pinHeader(header)
+		applyFormat(header, path, info)
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && info.Mode().IsRegular() && st.Nlink > 1 {
+			// Further links to a file are stored as links, not as copies of its content.
+			key := [2]uint64{uint64(st.Dev), uint64(st.Ino)}
+			if first, ok := links[key]; ok {
+				header.Typeflag, header.Linkname, header.Size = tar.TypeLink, first, 0
+				linkEntry(manifest, header)
+			} else {
+				links[key] = header.Name
+			}
+		}
 
 		if err := tw.WriteHeader(header); err != nil {
 			return err
 		}
-		if info.Mode().IsRegular() {
+		if header.Typeflag == tar.TypeReg {
 			file, err := os.Open(path)
 			if err != nil {
 				return err
 			}
 			defer file.Close()
-			if _, err := io.Copy(tw, file); err != nil {
+			content, hashed := hashEntry(manifest, file, header)
+			// Hide WriteTo so the copy goes through the large buffer.
+			if _, err := io.CopyBuffer(tw, struct{ io.Reader }{content}, copyBuffer); err != nil {
 				return err
 			}
+			hashed()
 		}
 		return nil
 	})
 }
 
+// skipEntry reports whether addTree leaves path, packaged as entry, out of the bundle, and
+// whether it is a directory whose contents are left out with it.
+func skipEntry(root, path, entry string, info os.FileInfo, exclude []string) (bool, bool) {
+	switch path {
+	case filepath.Join(root, OwnershipFile):
+		return true, false // Recorded owners go into the headers instead
+	case filepath.Join(root, "game.local.json"), filepath.Join(root, "app.local.json"):
+		return true, false // Overrides for this machine only
+	case filepath.Join(root, ManifestFile):
+		return true, false // Left by an earlier unpackage; a new one is written first
+	case filepath.Join(root, "yapl.lock"):
+		return true, false // Held by whoever is working on the game here
+	}
+	if len(exclude) > 0 && path != root && Included(exclude, filepath.ToSlash(entry)) {
+		return true, info.IsDir()
+	}
+	return false, false
+}
+
 func getExtensionForFormat(format string) (string, error) {
 	switch format {
 	case "gz":
@@ -258,7 +812,13 @@ func getExtensionForFormat(format string) (string, error) {
 	}
 }
 
-func trimArchiveSuffix(filename string) (string, bool) {
+// TrimArchiveSuffix strips a supported archive extension, and the volume number of a split
+// or the suffix of an encrypted bundle, reporting whether one was found.
+func TrimArchiveSuffix(filename string) (string, bool) {
+	if bundle, _, ok := SplitVolume(filename); ok {
+		filename = bundle
+	}
+	filename, _ = trimEncrypted(filename)
 	suffixes := []string{".tar.gz", ".tar.xz", ".tar.zst"}
 	for _, suffix := range suffixes {
 		if strings.HasSuffix(filename, suffix) {
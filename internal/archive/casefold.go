@@ -0,0 +1,106 @@
+package archive
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// Strategies for entries whose paths differ only by case, which Windows treats as the same file.
+const (
+	// CaseWarn extracts entries as named and reports the collisions.
+	CaseWarn = "warn"
+	// CaseMerge maps every path onto the first spelling seen, like a case-insensitive
+	// filesystem would; later files overwrite earlier ones.
+	CaseMerge = "merge"
+	// CaseSkip maps paths like CaseMerge but keeps the first file and skips later duplicates.
+	CaseSkip = "skip"
+	// CaseError aborts the extraction on the first collision.
+	CaseError = "error"
+)
+
+const maxReportedCollisions = 20
+
+// ValidCaseStrategy reports whether strategy is a known case collision strategy.
+func ValidCaseStrategy(strategy string) bool {
+	switch strategy {
+	case "", CaseWarn, CaseMerge, CaseSkip, CaseError:
+		return true
+	}
+	return false
+}
+
+// caseIndex remembers the first spelling of every path seen in an archive.
+type caseIndex struct {
+	strategy   string
+	spellings  map[string]string
+	files      map[string]bool
+	collisions []string
+}
+
+func newCaseIndex(strategy string) *caseIndex {
+	if strategy == "" {
+		return nil
+	}
+	return &caseIndex{strategy: strategy, spellings: make(map[string]string), files: make(map[string]bool)}
+}
+
+// resolve returns the relative path an entry should be written to, or skip=true if it must be left out.
+func (c *caseIndex) resolve(relPath string, isDir bool) (string, bool, error) {
+	if c == nil {
+		return relPath, false, nil
+	}
+	parts := strings.Split(filepath.Clean(relPath), string(filepath.Separator))
+	canonical := make([]string, 0, len(parts))
+	collided := false
+	for i := range parts {
+		key := strings.ToLower(strings.Join(parts[:i+1], "/"))
+		spelling, seen := c.spellings[key]
+		if !seen {
+			spelling = parts[i]
+			c.spellings[key] = spelling
+		} else if spelling != parts[i] {
+			collided = true
+		}
+		canonical = append(canonical, spelling)
+	}
+
+	key := strings.ToLower(strings.Join(parts, "/"))
+	duplicateFile := !isDir && c.files[key]
+	if !isDir {
+		c.files[key] = true
+	}
+	if !collided && !duplicateFile {
+		return relPath, false, nil
+	}
+
+	resolved := filepath.Join(canonical...)
+	if collided {
+		c.collisions = append(c.collisions, fmt.Sprintf("%s <-> %s", filepath.Clean(relPath), resolved))
+	}
+	switch c.strategy {
+	case CaseError:
+		return "", false, fmt.Errorf("paths differ only by case: %s and %s", relPath, resolved)
+	case CaseMerge:
+		return resolved, false, nil
+	case CaseSkip:
+		return resolved, duplicateFile, nil
+	}
+	return relPath, false, nil
+}
+
+// report logs a summary of the collisions found during extraction.
+func (c *caseIndex) report() {
+	if c == nil || len(c.collisions) == 0 {
+		return
+	}
+	log.Printf("⚠️  Found %d path(s) that differ only by case (strategy: %s):", len(c.collisions), c.strategy)
+	for i, collision := range c.collisions {
+		if i == maxReportedCollisions {
+			log.Printf("   ... and %d more", len(c.collisions)-maxReportedCollisions)
+			break
+		}
+		log.Printf("   %s", collision)
+	}
+}
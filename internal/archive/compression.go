@@ -0,0 +1,56 @@
+package archive
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// bufferSize is used for the bundle file and for copying file contents into it, so
+// packaging runs in large sequential writes instead of 32 KiB ones.
+const bufferSize = 1 << 20
+
+var (
+	threads int
+	levels  = map[string]int{}
+)
+
+// levelRanges are the compression levels each format accepts, as for gzip, xz and zstd.
+var levelRanges = map[string][2]int{
+	"gz":  {1, 9},
+	"xz":  {0, 9},
+	"zst": {1, 22},
+}
+
+// xzDictSizes are the dictionary sizes of xz's presets 0 to 9; the dictionary is the only
+// setting of the pure Go xz writer that trades speed for size.
+var xzDictSizes = [10]int{256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+
+// SetThreads sets how many goroutines compress a bundle; 0 uses every CPU. xz always
+// runs single-threaded, and reproducible packages ignore this.
+func SetThreads(n int) {
+	threads = n
+}
+
+// SetLevel sets the compression level for a format: 1-9 for gz, 0-9 for xz and 1-22 for
+// zst. zstd levels are mapped to the closest of the four speeds the Go encoder offers.
+func SetLevel(format string, level int) error {
+	r, ok := levelRanges[format]
+	if !ok {
+		return fmt.Errorf("unsupported package format: %s. Use 'gz', 'xz', or 'zst'", format)
+	}
+	if level < r[0] || level > r[1] {
+		return fmt.Errorf("%s compression level must be between %d and %d, not %d", format, r[0], r[1], level)
+	}
+	levels[format] = level
+	return nil
+}
+
+func compressThreads() int {
+	if reproducible {
+		return 1
+	}
+	if threads > 0 {
+		return threads
+	}
+	return runtime.NumCPU()
+}
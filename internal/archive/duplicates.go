@@ -0,0 +1,182 @@
+package archive
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// sampleSize is how much of the start and end of an archive the quick fingerprint reads.
+	sampleSize = 1 << 20
+	// sampleEntries and sampleBytes bound how much of an archive is decompressed to compare contents.
+	sampleEntries = 512
+	sampleBytes   = 256 << 20
+	// nearIdentical is the share of sampled entries two archives must have in common.
+	nearIdentical = 0.9
+)
+
+// Duplicate reports two archives that would produce the same install.
+type Duplicate struct {
+	First, Second string
+	// Identical is set when the files are byte-for-byte the same; otherwise Similarity
+	// is the share of sampled entries (path below the top-level directory and size) they share.
+	Identical  bool
+	Similarity float64
+}
+
+// FindDuplicates compares archives before they are unpackaged. Byte-identical archives are
+// found by hashing, differently compressed or renamed copies by comparing their first entries.
+func FindDuplicates(paths []string) ([]Duplicate, error) {
+	type fingerprint struct {
+		path    string
+		quick   string
+		entries map[string]bool
+	}
+	var prints []fingerprint
+	for _, path := range paths {
+		if _, ok := TrimArchiveSuffix(path); !ok || strings.HasPrefix(path, "http") {
+			continue
+		}
+		if _, _, split := SplitVolume(path); split {
+			continue // Fingerprints read single files
+		}
+		quick, err := quickHash(path)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := sampleEntrySet(path)
+		if err != nil {
+			return nil, err
+		}
+		prints = append(prints, fingerprint{path, quick, entries})
+	}
+
+	var duplicates []Duplicate
+	for i := range prints {
+		for j := i + 1; j < len(prints); j++ {
+			a, b := prints[i], prints[j]
+			if a.quick == b.quick {
+				same, err := sameContent(a.path, b.path)
+				if err != nil {
+					return nil, err
+				}
+				if same {
+					duplicates = append(duplicates, Duplicate{First: a.path, Second: b.path, Identical: true, Similarity: 1})
+					continue
+				}
+			}
+			if similarity := overlap(a.entries, b.entries); similarity >= nearIdentical {
+				duplicates = append(duplicates, Duplicate{First: a.path, Second: b.path, Similarity: similarity})
+			}
+		}
+	}
+	return duplicates, nil
+}
+
+// quickHash hashes an archive's size with its first and last megabyte.
+func quickHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n", info.Size())
+	if _, err := io.CopyN(h, f, sampleSize); err != nil && err != io.EOF {
+		return "", err
+	}
+	if info.Size() > 2*sampleSize {
+		if _, err := f.Seek(-sampleSize, io.SeekEnd); err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sameContent compares two files byte by byte.
+func sameContent(a, b string) (bool, error) {
+	ha, err := fileHash(a)
+	if err != nil {
+		return false, err
+	}
+	hb, err := fileHash(b)
+	return ha == hb, err
+}
+
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sampleEntrySet reads the first entries of an archive, ignoring the top-level directory
+// so that bundles of the same game under different names compare equal.
+func sampleEntrySet(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := getDecompressedReader(f, path)
+	if err != nil {
+		return nil, err
+	}
+	counted := &countingReader{r: r}
+	tr := tar.NewReader(counted)
+	entries := make(map[string]bool)
+	for len(entries) < sampleEntries && counted.n < sampleBytes {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		_, rest, _ := strings.Cut(strings.TrimPrefix(hdr.Name, "./"), "/")
+		entries[fmt.Sprintf("%s\x00%d", rest, hdr.Size)] = true
+	}
+	return entries, nil
+}
+
+func overlap(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for key := range a {
+		if b[key] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(max(len(a), len(b)))
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,223 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// EncryptedSuffix marks bundles whose compressed stream is encrypted with a passphrase,
+// e.g. MyGame.tar.zst.enc.
+const EncryptedSuffix = ".enc"
+
+// Encrypted bundles start with a header (magic, PBKDF2 iteration count, salt and nonce
+// prefix) followed by the stream in AES-256-GCM sealed chunks. Each chunk's nonce is the
+// prefix, its index and a flag for the last chunk, so chunks cannot be reordered, dropped
+// or cut off without failing authentication.
+const (
+	encMagic      = "YAPLENC1"
+	encIterations = 600000
+	// encMaxIterations bounds the count read from a bundle, so a crafted header cannot
+	// make unpackage derive keys for hours. Newer yapl versions may raise encIterations
+	// up to this.
+	encMaxIterations = 10 * encIterations
+	encSaltSize      = 16
+	encPrefixSize    = 7
+	encChunkSize     = 64 << 10
+	encHeaderSize    = len(encMagic) + 4 + encSaltSize + encPrefixSize
+)
+
+var (
+	encrypt    bool
+	passphrase func(confirm bool) (string, error)
+)
+
+// SetEncrypt makes packaging encrypt bundles with the passphrase from SetPassphrase.
+func SetEncrypt(on bool) {
+	encrypt = on
+}
+
+// SetPassphrase sets where the passphrase of encrypted bundles comes from. It is only
+// asked for when a bundle is encrypted or decrypted; confirm is set when encrypting.
+func SetPassphrase(get func(confirm bool) (string, error)) {
+	passphrase = get
+}
+
+func getPassphrase(confirm bool) (string, error) {
+	if passphrase == nil {
+		return "", errors.New("no passphrase available for the encrypted bundle")
+	}
+	p, err := passphrase(confirm)
+	if err == nil && p == "" {
+		err = errors.New("the passphrase is empty")
+	}
+	return p, err
+}
+
+func newStreamCipher(pass string, header []byte) (cipher.AEAD, error) {
+	salt := header[len(encMagic)+4 : len(encMagic)+4+encSaltSize]
+	iterations := int(binary.BigEndian.Uint32(header[len(encMagic):]))
+	key, err := pbkdf2.Key(sha256.New, pass, salt, iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(header []byte, index uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, header[encHeaderSize-encPrefixSize:])
+	binary.BigEndian.PutUint32(nonce[encPrefixSize:], index)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptWriter seals what is written to it in chunks. Close writes the last chunk but
+// does not close the underlying writer.
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	index  uint32
+	buf    []byte
+}
+
+func newEncryptWriter(w io.Writer) (*encryptWriter, error) {
+	pass, err := getPassphrase(true)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, encHeaderSize)
+	copy(header, encMagic)
+	binary.BigEndian.PutUint32(header[len(encMagic):], encIterations)
+	if _, err := rand.Read(header[len(encMagic)+4:]); err != nil {
+		return nil, err
+	}
+	aead, err := newStreamCipher(pass, header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, header: header, buf: make([]byte, 0, encChunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	total := len(p)
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data follows, as the last one is marked.
+		if len(e.buf) == encChunkSize {
+			if err := e.seal(false); err != nil {
+				return total - len(p), err
+			}
+		}
+		n := min(encChunkSize-len(e.buf), len(p))
+		e.buf = append(e.buf, p[:n]...)
+		p = p[n:]
+	}
+	return total, nil
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.header, e.index, last), e.buf, e.header)
+	e.index++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+// decryptReader opens the chunks of an encrypted stream.
+type decryptReader struct {
+	r      *bufio.Reader
+	aead   cipher.AEAD
+	header []byte
+	index  uint32
+	plain  []byte
+	done   bool
+}
+
+func newDecryptReader(r io.Reader) (io.Reader, error) {
+	header := make([]byte, encHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.HasPrefix(header, []byte(encMagic)) {
+		return nil, errors.New("not an encrypted yapl bundle")
+	}
+	if iterations := binary.BigEndian.Uint32(header[len(encMagic):]); iterations < encIterations || iterations > encMaxIterations {
+		return nil, fmt.Errorf("the bundle's key derivation uses %d iterations; only %d to %d are accepted", iterations, encIterations, encMaxIterations)
+	}
+	pass, err := getPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newStreamCipher(pass, header)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: bufio.NewReaderSize(r, encChunkSize+aead.Overhead()+1), aead: aead, header: header}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decryptReader) next() error {
+	sealed := make([]byte, encChunkSize+d.aead.Overhead())
+	n, err := io.ReadFull(d.r, sealed)
+	last := err == io.ErrUnexpectedEOF || err == io.EOF
+	if err != nil && !last {
+		return err
+	}
+	if !last {
+		_, err := d.r.Peek(1)
+		last = err == io.EOF
+	}
+	plain, err := d.aead.Open(nil, chunkNonce(d.header, d.index, last), sealed[:n], d.header)
+	if err != nil {
+		return errors.New("cannot decrypt the bundle: wrong passphrase, or the bundle is corrupted or truncated")
+	}
+	d.index++
+	d.plain, d.done = plain, last
+	return nil
+}
+
+// trimEncrypted removes the encrypted suffix from a bundle's name.
+func trimEncrypted(name string) (string, bool) {
+	if trimmed, ok := strings.CutSuffix(name, EncryptedSuffix); ok {
+		return trimmed, true
+	}
+	return name, false
+}
+
+// describeEncryption is printed when a bundle is written encrypted.
+func describeEncryption() string {
+	return fmt.Sprintf("AES-256-GCM, key from the passphrase with PBKDF2-SHA256 (%d iterations)", encIterations)
+}
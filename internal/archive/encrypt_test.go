@@ -0,0 +1,116 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+func usePassphrase(t *testing.T, pass string) {
+	t.Helper()
+	SetPassphrase(func(bool) (string, error) { return pass, nil })
+	t.Cleanup(func() { SetPassphrase(nil) })
+}
+
+func encryptBytes(t *testing.T, plain []byte) []byte {
+	t.Helper()
+	var sealed bytes.Buffer
+	w, err := newEncryptWriter(&sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return sealed.Bytes()
+}
+
+func decryptBytes(sealed []byte) ([]byte, error) {
+	r, err := newDecryptReader(bytes.NewReader(sealed))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	usePassphrase(t, "correct horse")
+	for _, size := range []int{0, 1, encChunkSize - 1, encChunkSize, encChunkSize + 1, 3*encChunkSize + 17} {
+		plain := bytes.Repeat([]byte{0xA5, 0x5A, 0x00}, size/3+1)[:size]
+		got, err := decryptBytes(encryptBytes(t, plain))
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("size %d: decrypted %d bytes that differ from the input", size, len(got))
+		}
+	}
+}
+
+func TestDecryptRejectsDamage(t *testing.T) {
+	usePassphrase(t, "correct horse")
+	plain := bytes.Repeat([]byte("yapl"), encChunkSize/2) // Two full chunks
+	sealed := encryptBytes(t, plain)
+	chunk := encChunkSize + 16
+
+	tests := []struct {
+		name   string
+		sealed []byte
+	}{
+		{"flipped byte", func() []byte {
+			b := bytes.Clone(sealed)
+			b[encHeaderSize+10] ^= 1
+			return b
+		}()},
+		{"last chunk dropped", sealed[:encHeaderSize+chunk]},
+		{"cut mid-chunk", sealed[:len(sealed)-5]},
+		{"chunks swapped", func() []byte {
+			b := bytes.Clone(sealed[:encHeaderSize])
+			b = append(b, sealed[encHeaderSize+chunk:encHeaderSize+2*chunk]...)
+			b = append(b, sealed[encHeaderSize:encHeaderSize+chunk]...)
+			return append(b, sealed[encHeaderSize+2*chunk:]...)
+		}()},
+		{"header changed", func() []byte {
+			b := bytes.Clone(sealed)
+			b[len(encMagic)+4] ^= 1 // Salt
+			return b
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decryptBytes(tt.sealed); err == nil {
+				t.Fatal("decrypted without an error")
+			}
+		})
+	}
+
+	usePassphrase(t, "wrong horse")
+	if _, err := decryptBytes(sealed); err == nil {
+		t.Fatal("decrypted with the wrong passphrase")
+	}
+}
+
+func TestDecryptRejectsIterations(t *testing.T) {
+	usePassphrase(t, "correct horse")
+	sealed := encryptBytes(t, []byte("data"))
+	for _, iterations := range []uint32{0, 1, encIterations - 1, encMaxIterations + 1, 0xFFFFFFFF} {
+		b := bytes.Clone(sealed)
+		binary.BigEndian.PutUint32(b[len(encMagic):], iterations)
+		_, err := decryptBytes(b)
+		if err == nil || !strings.Contains(err.Error(), "iterations") {
+			t.Errorf("%d iterations: got %v, want the header to be rejected", iterations, err)
+		}
+	}
+}
+
+func TestDecryptRejectsPlainStream(t *testing.T) {
+	usePassphrase(t, "correct horse")
+	if _, err := decryptBytes([]byte("\x28\xb5\x2f\xfd not encrypted at all, just long enough")); err == nil {
+		t.Fatal("accepted a stream without the header")
+	}
+}
@@ -0,0 +1,58 @@
+package archive
+
+import (
+	"path"
+	"strings"
+)
+
+// matchGlob reports whether name matches pattern. Components are matched with path.Match,
+// and a "**" component matches any number of directories.
+func matchGlob(pattern, name string) bool {
+	return matchParts(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(strings.Trim(name, "/"), "/"))
+}
+
+func matchParts(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// Included reports whether an archive entry matches one of the include patterns. Patterns
+// may be written relative to the bundle root ("Game/game.json"), to the game directory
+// ("game.json") or to its Wine prefix ("drive_c/Game/**"). No patterns include everything.
+func Included(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	name = strings.Trim(path.Clean("/"+name), "/")
+	candidates := []string{name}
+	if _, rest, ok := strings.Cut(name, "/"); ok {
+		candidates = append(candidates, rest)
+	}
+	if i := strings.Index(name, "/prefix/"); i >= 0 {
+		candidates = append(candidates, name[i+len("/prefix/"):])
+	}
+	for _, pattern := range patterns {
+		for _, candidate := range candidates {
+			if matchGlob(pattern, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
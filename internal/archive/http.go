@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"yapl/internal/downloads"
+	"yapl/internal/events"
+)
+
+// openHTTP starts downloading url.
+func openHTTP(url string) (io.ReadCloser, error) {
+	// Wait for our turn in the download queue shared by all yapl processes.
+	queued, err := downloads.Acquire(url)
+	if err != nil {
+		return nil, fmt.Errorf("download queue: %w", err)
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		queued.Release()
+		return nil, fmt.Errorf("http get: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		queued.Release()
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+	queued.SetTotal(resp.ContentLength)
+	events.Emit("download_start", map[string]interface{}{"url": url, "total": resp.ContentLength})
+	body := resp.Body
+	reader := queued.Reader(body)
+	if events.Enabled() {
+		reader = &events.Progress{R: reader, Event: "download_progress", Name: url, Total: resp.ContentLength}
+	}
+	return &queuedBody{Reader: reader, body: body, queued: queued, size: resp.ContentLength}, nil
+}
+
+// queuedBody leaves the download queue when the download is closed.
+type queuedBody struct {
+	io.Reader
+	body   io.ReadCloser
+	queued *downloads.Handle
+	size   int64 // Content-Length, -1 if unknown
+}
+
+func (q *queuedBody) Close() error {
+	q.queued.Release()
+	return q.body.Close()
+}
+
+// Download saves the file at url to dest. dest is only created once the download's SHA-256
+// matches want, so a file at dest can be trusted as much as the checksum.
+func Download(url, dest, want string) error {
+	fmt.Printf(" Downloading from %s...\n", url)
+	body, err := openHTTP(url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	partial := dest + ".partial"
+	f, err := os.Create(partial)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, hash), body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, want) {
+			err = fmt.Errorf("the download's SHA-256 is %s, not the expected %s", sum, want)
+		}
+	}
+	if err != nil {
+		os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, dest)
+}
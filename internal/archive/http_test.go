@@ -0,0 +1,41 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadChecksSHA256(t *testing.T) {
+	t.Chdir(t.TempDir())
+	data := []byte("proton build")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+	h := sha256.Sum256(data)
+	sum := hex.EncodeToString(h[:])
+	dest := filepath.Join(t.TempDir(), "proton.tar.gz")
+
+	if err := Download(server.URL, dest, strings.Repeat("0", 64)); err == nil {
+		t.Fatal("a download with the wrong checksum was accepted")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatal("a download with the wrong checksum was kept")
+	}
+	if _, err := os.Stat(dest + ".partial"); !os.IsNotExist(err) {
+		t.Fatal("the partial download was left behind")
+	}
+
+	if err := Download(server.URL, dest, strings.ToUpper(sum)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != string(data) {
+		t.Fatalf("downloaded %q", got)
+	}
+}
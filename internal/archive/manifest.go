@@ -0,0 +1,399 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"yapl/internal/fs"
+)
+
+// ManifestFile is written into the top-level directory of every bundle 'package' makes,
+// ahead of the files it describes. If that copy is Trailing, a complete one follows them.
+const ManifestFile = "yapl-manifest.json"
+
+// Manifest describes a bundle's contents so unpackage can detect corruption.
+type Manifest struct {
+	YaplVersion string          `json:"yapl_version,omitempty"`
+	Created     time.Time       `json:"created"`
+	Version     string          `json:"version,omitempty"` // The packager's release version
+	Notes       string          `json:"notes,omitempty"`   // Changelog for this release
+	Config      json.RawMessage `json:"config,omitempty"`  // The config the bundle was made from, with templates and defaults applied
+	TotalSize   int64           `json:"total_size"`
+	// Trailing is set in the copy of the manifest at the start of a bundle when the
+	// checksums were computed while the files were packaged. They are missing from it, and
+	// the complete manifest follows the files at the end of the bundle.
+	Trailing bool `json:"trailing,omitempty"`
+	// Files maps the path of every regular file, relative to the top-level directory,
+	// to its size and checksum.
+	Files map[string]ManifestEntry `json:"files"`
+}
+
+// ManifestEntry is one file of a manifest.
+type ManifestEntry struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// fillManifest lists every file addTree would package from root, and those of the extra
+// trees. With hash set it computes their checksums too; otherwise createBundle adds them
+// while it packages the files, which saves reading everything twice.
+func fillManifest(m *Manifest, root string, exclude []string, extra []Tree, hash bool) error {
+	m.Files = map[string]ManifestEntry{}
+	m.TotalSize = 0
+	if err := hashTree(m, root, "", exclude, hash); err != nil {
+		return err
+	}
+	for _, t := range extra {
+		if err := hashTree(m, t.Dir, t.Name, nil, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HashTree returns the size and checksum of every regular file below root, by slash
+// separated path relative to it.
+func HashTree(root string) (map[string]ManifestEntry, error) {
+	m := &Manifest{Files: map[string]ManifestEntry{}}
+	if err := hashTree(m, root, "", nil, true); err != nil {
+		return nil, err
+	}
+	return m.Files, nil
+}
+
+// hashTree adds the files below root to the manifest, named relative to name. The tree is
+// walked in the background and, with hash set, the files are hashed on every CPU.
+func hashTree(m *Manifest, root, name string, exclude []string, hash bool) error {
+	type file struct {
+		path, key string
+		size      int64
+	}
+	files := make(chan file, 256)
+	errs := make(chan error, runtime.NumCPU())
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range files {
+				sum, err := hashFile(f.path)
+				if err != nil {
+					errs <- err
+					for range files {
+						// Let the walk finish
+					}
+					return
+				}
+				mu.Lock()
+				m.Files[f.key] = ManifestEntry{Size: f.size, SHA256: sum}
+				m.TotalSize += f.size
+				mu.Unlock()
+			}
+		}()
+	}
+
+	err := fs.Walk(walkCtx, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		entry := filepath.Join(filepath.Base(root), name, rel)
+		if skip, skipDir := skipEntry(root, path, entry, info, exclude); skip {
+			if skipDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		key := restoreName(filepath.ToSlash(filepath.Join(name, rel)))
+		if !hash {
+			m.Files[key] = ManifestEntry{Size: info.Size()}
+			m.TotalSize += info.Size()
+			return nil
+		}
+		select {
+		case err := <-errs:
+			return err
+		default:
+		}
+		files <- file{path, key, info.Size()}
+		return nil
+	})
+	close(files)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// writeManifest adds the manifest to the tar stream as <top>/yapl-manifest.json.
+func writeManifest(tw *tar.Writer, top string, m *Manifest) error {
+	return writeJSONEntry(tw, top, ManifestFile, m)
+}
+
+// hashEntry returns the reader to package a file's content from, hashing what is read
+// into the manifest's entry for it when the manifest's checksums are still missing.
+func hashEntry(m *Manifest, r io.Reader, header *tar.Header) (io.Reader, func()) {
+	rel, ok := bundlePath(header.Name)
+	if m == nil || !m.Trailing || !ok {
+		return r, func() {}
+	}
+	h := sha256.New()
+	return io.TeeReader(r, h), func() {
+		m.Files[rel] = ManifestEntry{Size: header.Size, SHA256: hex.EncodeToString(h.Sum(nil))}
+	}
+}
+
+// linkEntry gives a hard link entry the checksum of the file it links to.
+func linkEntry(m *Manifest, header *tar.Header) {
+	rel, ok := bundlePath(header.Name)
+	source, sourceOK := bundlePath(header.Linkname)
+	if m != nil && m.Trailing && ok && sourceOK {
+		if entry, ok := m.Files[source]; ok {
+			m.Files[rel] = entry
+		}
+	}
+}
+
+// writeJSONEntry adds v to the tar stream as the file <top>/<name>.
+func writeJSONEntry(tw *tar.Writer, top, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{
+		Name:     filepath.ToSlash(filepath.Join(top, name)),
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  BuildTime(),
+		Typeflag: tar.TypeReg,
+	}
+	stampOwner(header, nil, name)
+	pinHeader(header)
+	if tarFormat != tar.FormatUnknown {
+		header.Format = tarFormat
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// manifestCheck collects the checksums of extracted files and compares them with the
+// bundle's manifest once the whole archive has been read.
+type manifestCheck struct {
+	enabled  bool
+	manifest *Manifest
+	data     []byte
+	top      string            // The bundle's top-level directory, from the manifest's entry
+	sums     map[string]string // Checksums of the extracted files
+	skipped  map[string]bool   // Files left out on purpose, e.g. case conflicts
+}
+
+func newManifestCheck(enabled bool) *manifestCheck {
+	return &manifestCheck{enabled: enabled, sums: map[string]string{}, skipped: map[string]bool{}}
+}
+
+// skip records that an entry was deliberately not extracted.
+func (c *manifestCheck) skip(name string) {
+	if rel, ok := bundlePath(name); c.enabled && ok {
+		c.skipped[rel] = true
+	}
+}
+
+// link records that an entry is a hard link to an extracted file, so it has its content.
+func (c *manifestCheck) link(name, linkname string) {
+	rel, ok := bundlePath(name)
+	source, sourceOK := bundlePath(linkname)
+	if c.enabled && ok && sourceOK {
+		if sum, ok := c.sums[source]; ok {
+			c.sums[rel] = sum
+		}
+	}
+}
+
+// load reads the manifest when the entry is the bundle's manifest. It is read whether or
+// not the include patterns select it; wrap hands its content on for extraction.
+func (c *manifestCheck) load(r io.Reader, name string) error {
+	if rel, ok := bundlePath(name); !c.enabled || !ok || rel != ManifestFile {
+		return nil
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("unreadable %s: %w", ManifestFile, err)
+	}
+	c.manifest, c.data = &m, data
+	c.top, _, _ = strings.Cut(strings.TrimPrefix(name, "./"), "/")
+	return nil
+}
+
+// wrap returns the reader to extract an entry's content from, hashing what is read when
+// the entry is a file to verify, and a function that records the checksum.
+func (c *manifestCheck) wrap(r io.Reader, name string) (io.Reader, func()) {
+	if !c.enabled {
+		return r, func() {}
+	}
+	rel, ok := bundlePath(name)
+	if !ok {
+		return r, func() {}
+	}
+	if rel == ManifestFile {
+		return bytes.NewReader(c.data), func() {}
+	}
+	h := sha256.New()
+	return io.TeeReader(r, h), func() { c.sums[rel] = hex.EncodeToString(h.Sum(nil)) }
+}
+
+// finish verifies the extracted files. With include patterns only the files that were
+// selected are expected.
+func (c *manifestCheck) finish(include []string) error {
+	if !c.enabled {
+		return nil
+	}
+	if c.manifest == nil {
+		fmt.Printf(" The bundle has no %s; its files could not be verified.\n", ManifestFile)
+		return nil
+	}
+	if c.manifest.Trailing {
+		return fmt.Errorf("the bundle is incomplete: the %s with its checksums, written at its end, is missing", ManifestFile)
+	}
+	var problems []string
+	for rel, entry := range c.manifest.Files {
+		sum, ok := c.sums[rel]
+		switch {
+		case !ok && !c.skipped[rel] && Included(include, c.top+"/"+rel):
+			problems = append(problems, "missing: "+rel)
+		case ok && sum != entry.SHA256:
+			problems = append(problems, "checksum mismatch: "+rel)
+		}
+	}
+	for rel := range c.sums {
+		if _, ok := c.manifest.Files[rel]; !ok {
+			problems = append(problems, "not in manifest: "+rel)
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		if len(problems) > 10 {
+			problems = append(problems[:10], fmt.Sprintf("... and %d more", len(problems)-10))
+		}
+		return fmt.Errorf("the bundle is corrupted:\n  %s", strings.Join(problems, "\n  "))
+	}
+	fmt.Printf(" Verified %d files against the bundle's manifest.\n", len(c.sums))
+	c.manifest.PrintRelease()
+	return nil
+}
+
+// PrintRelease shows the release version and notes the packager recorded, if any.
+func (m *Manifest) PrintRelease() {
+	if m.Version != "" {
+		fmt.Printf("-> Release %s\n", m.Version)
+	}
+	if notes := strings.TrimSpace(m.Notes); notes != "" {
+		fmt.Println("   " + strings.ReplaceAll(notes, "\n", "\n   "))
+	}
+}
+
+// ReadManifest returns the manifest of a bundle, or nil if it has none. Only the start of
+// the bundle is read, where package writes the manifest, so its checksums may be missing
+// (see Manifest.Trailing); ReadCompleteManifest reads on to them.
+func ReadManifest(source string) (*Manifest, error) {
+	return readManifest(source, false)
+}
+
+// ReadCompleteManifest is ReadManifest with the checksums, reading as much of the bundle
+// as it takes to find them.
+func ReadCompleteManifest(source string) (*Manifest, error) {
+	return readManifest(source, true)
+}
+
+func readManifest(source string, complete bool) (*Manifest, error) {
+	ar := &Archive{Source: source}
+	stream, err := ar.open()
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	decompressedReader, err := getDecompressedReader(stream, source)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(decompressedReader)
+	var m *Manifest
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			if m != nil && m.Trailing {
+				return nil, fmt.Errorf("the bundle is incomplete: the %s with its checksums, written at its end, is missing", ManifestFile)
+			}
+			return m, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if rel, ok := bundlePath(hdr.Name); !ok || rel != ManifestFile {
+			if m == nil {
+				return nil, nil
+			}
+			continue // Reading on to the complete manifest
+		}
+		m = &Manifest{}
+		if err := json.NewDecoder(tr).Decode(m); err != nil {
+			return nil, fmt.Errorf("unreadable %s: %w", ManifestFile, err)
+		}
+		if !complete || !m.Trailing {
+			return m, nil
+		}
+	}
+}
+
+// bundlePath returns an entry's path below the bundle's top-level directory.
+func bundlePath(name string) (string, bool) {
+	_, rel, ok := strings.Cut(strings.TrimPrefix(name, "./"), "/")
+	return rel, ok && rel != ""
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,122 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Permission policies applied to extracted files.
+const (
+	// ModePreserve keeps the modes stored in the archive.
+	ModePreserve = "preserve"
+	// ModeUmask keeps the archived modes but strips the bits masked by the umask.
+	ModeUmask = "umask"
+	// ModeNormalize sets directories to 0755 and files to 0644, or 0755 for executables
+	// (files with any exec bit, ELF binaries and scripts), then applies the umask.
+	ModeNormalize = "normalize"
+)
+
+const defaultUmask os.FileMode = 0022
+
+// ExtractOptions controls how archives are extracted.
+type ExtractOptions struct {
+	ModePolicy string
+	Umask      os.FileMode
+	// CaseConflicts and InvalidNames are the strategies used by Unpackage. Downloaded
+	// dependencies are Linux trees and are never checked.
+	CaseConflicts string
+	InvalidNames  string
+	// Ownership is one of the Ownership* modes. Owner overrides the owners stored in
+	// the archive; if nil they are used as they are.
+	Ownership string
+	Owner     *Owner
+	// Include limits extraction to matching entries (see Included).
+	Include []string
+	// Verify checks the extracted files against the bundle's manifest (see Manifest).
+	Verify bool
+	// Sidecar checks the bundle against its checksum file, if it has one (see SidecarSuffix).
+	Sidecar bool
+
+	size int64 // Expected size of the extracted files, 0 if unknown
+}
+
+var extractOptions = ExtractOptions{ModePolicy: ModePreserve, Umask: defaultUmask}
+
+// SetExtractOptions configures every subsequent extraction.
+func SetExtractOptions(opts ExtractOptions) {
+	extractOptions = opts
+}
+
+// ParseUmask converts an octal umask string such as "022"; an empty string yields the default.
+func ParseUmask(s string) (os.FileMode, error) {
+	if s == "" {
+		return defaultUmask, nil
+	}
+	v, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || v > 0777 {
+		return 0, fmt.Errorf("invalid umask '%s'", s)
+	}
+	return os.FileMode(v), nil
+}
+
+// ValidModePolicy reports whether policy is a known permission policy.
+func ValidModePolicy(policy string) bool {
+	switch policy {
+	case "", ModePreserve, ModeUmask, ModeNormalize:
+		return true
+	}
+	return false
+}
+
+// normalizeModes applies the configured permission policy to the extracted paths.
+func normalizeModes(paths []string, opts ExtractOptions) error {
+	if opts.ModePolicy == "" || opts.ModePolicy == ModePreserve {
+		return nil
+	}
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+
+		mode := info.Mode().Perm()
+		if opts.ModePolicy == ModeNormalize {
+			switch {
+			case info.IsDir(), mode&0111 != 0, looksExecutable(path):
+				mode = 0755
+			default:
+				mode = 0644
+			}
+		}
+		mode &^= opts.Umask
+		if info.IsDir() {
+			mode |= 0700 // Never lock ourselves out of a directory
+		}
+		if mode == info.Mode().Perm() {
+			continue
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// looksExecutable detects ELF binaries and scripts that lost their exec bits.
+func looksExecutable(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return false
+	}
+	return string(magic) == "\x7fELF" || string(magic[:2]) == "#!"
+}
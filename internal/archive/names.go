@@ -0,0 +1,177 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Strategies for names that Windows filesystems (NTFS, SMB shares) cannot store.
+const (
+	// NamesKeep extracts names as they are.
+	NamesKeep = "keep"
+	// NamesWarn extracts names as they are and reports the problematic ones.
+	NamesWarn = "warn"
+	// NamesSanitize maps invalid characters to the Unicode private use area (the
+	// reversible scheme used by Cygwin and WSL) and shortens over-long names.
+	NamesSanitize = "sanitize"
+)
+
+const (
+	// LongNamesFile records shortened names so they can be mapped back.
+	LongNamesFile    = ".yapl-longnames.json"
+	maxComponentLen  = 255
+	maxWindowsPath   = 260
+	privateUseOffset = 0xF000
+)
+
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// ValidNameStrategy reports whether strategy is a known invalid name strategy.
+func ValidNameStrategy(strategy string) bool {
+	switch strategy {
+	case "", NamesKeep, NamesWarn, NamesSanitize:
+		return true
+	}
+	return false
+}
+
+// nameChecker finds and optionally fixes names that would break on Windows filesystems.
+type nameChecker struct {
+	strategy  string
+	issues    []string
+	longNames map[string]string
+}
+
+func newNameChecker(strategy string) *nameChecker {
+	if strategy == "" || strategy == NamesKeep {
+		return nil
+	}
+	return &nameChecker{strategy: strategy, longNames: make(map[string]string)}
+}
+
+// resolve returns the path an entry should be extracted to.
+func (n *nameChecker) resolve(relPath string) string {
+	if n == nil {
+		return relPath
+	}
+	parts := strings.Split(filepath.Clean(relPath), string(filepath.Separator))
+	var problems []string
+	changed := false
+	for i, part := range parts {
+		// Wine's drive links (dosdevices/c:, z:) must keep their names.
+		if i > 0 && parts[i-1] == "dosdevices" {
+			continue
+		}
+		fixed, problem := checkComponent(part)
+		if problem == "" {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("'%s': %s", part, problem))
+		if n.strategy != NamesSanitize {
+			continue
+		}
+		if len(fixed) > maxComponentLen {
+			sum := sha256.Sum256([]byte(part))
+			cut := maxComponentLen - 9
+			for !utf8.RuneStart(fixed[cut]) {
+				cut--
+			}
+			fixed = fixed[:cut] + "~" + hex.EncodeToString(sum[:4])
+			n.longNames[strings.Join(append(parts[:i:i], fixed), "/")] = part
+		}
+		parts[i] = fixed
+		changed = true
+	}
+
+	resolved := filepath.Join(parts...)
+	if len(resolved) > maxWindowsPath {
+		problems = append(problems, fmt.Sprintf("path longer than %d characters", maxWindowsPath))
+	}
+	if len(problems) > 0 {
+		n.issues = append(n.issues, fmt.Sprintf("%s (%s)", filepath.Clean(relPath), strings.Join(problems, "; ")))
+	}
+	if !changed {
+		return relPath
+	}
+	return resolved
+}
+
+// finish reports the problems found and records shortened names next to the extracted files.
+func (n *nameChecker) finish(destPath string) error {
+	if n == nil {
+		return nil
+	}
+	if len(n.issues) > 0 {
+		log.Printf("⚠️  Found %d name(s) that Windows filesystems cannot store (strategy: %s):", len(n.issues), n.strategy)
+		for i, issue := range n.issues {
+			if i == maxReportedCollisions {
+				log.Printf("   ... and %d more", len(n.issues)-maxReportedCollisions)
+				break
+			}
+			log.Printf("   %s", issue)
+		}
+	}
+	if len(n.longNames) == 0 {
+		return nil
+	}
+	data, err := json.MarshalIndent(n.longNames, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(destPath, LongNamesFile), data, 0644)
+}
+
+// checkComponent returns the sanitized form of a path component and what was wrong with it.
+func checkComponent(name string) (string, string) {
+	var problems []string
+	var b strings.Builder
+	for i, r := range name {
+		trailing := i == len(name)-1 && (r == '.' || r == ' ')
+		if r < 0x20 || strings.ContainsRune(`<>:"\|?*`, r) || trailing {
+			b.WriteRune(privateUseOffset + r)
+			if len(problems) == 0 {
+				problems = append(problems, "invalid characters")
+			}
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	base := strings.ToUpper(strings.SplitN(name, ".", 2)[0])
+	if reservedNames[base] {
+		problems = append(problems, "reserved device name")
+	}
+	if len(b.String()) > maxComponentLen {
+		problems = append(problems, "name too long")
+	}
+	return b.String(), strings.Join(problems, ", ")
+}
+
+// restoreName reverses the private use character mapping of NamesSanitize.
+func restoreName(name string) string {
+	if !strings.ContainsFunc(name, isMappedRune) {
+		return name
+	}
+	return strings.Map(func(r rune) rune {
+		if isMappedRune(r) {
+			return r - privateUseOffset
+		}
+		return r
+	}, name)
+}
+
+func isMappedRune(r rune) bool {
+	orig := r - privateUseOffset
+	return orig >= 0 && orig < 0x80 && (orig < 0x20 || strings.ContainsRune(`<>:"\|?*. `, orig))
+}
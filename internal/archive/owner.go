@@ -0,0 +1,187 @@
+package archive
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Ownership modes for extracted files.
+const (
+	// OwnershipNone leaves extracted files owned by the extracting user.
+	OwnershipNone = "none"
+	// OwnershipChown sets the owner of extracted files, which needs root privileges.
+	OwnershipChown = "chown"
+	// OwnershipManifest records the owner of every entry in OwnershipFile instead of
+	// applying it, like fakeroot. Packaging the tree again restores the recorded owners.
+	OwnershipManifest = "manifest"
+)
+
+// OwnershipFile is the fakeroot-style ownership manifest of an extracted tree.
+const OwnershipFile = ".yapl-ownership.json"
+
+// Owner identifies a user and group.
+type Owner struct {
+	UID   int    `json:"uid"`
+	GID   int    `json:"gid"`
+	User  string `json:"user,omitempty"`
+	Group string `json:"group,omitempty"`
+}
+
+// Nobody is the owner stamped on packaged files unless configured otherwise.
+var Nobody = Owner{UID: 65534, GID: 65534, User: "nobody", Group: "nobody"}
+
+var packageOwner = Nobody
+
+// SetPackageOwner sets the owner stamped on files that have no entry in an ownership manifest.
+func SetPackageOwner(owner Owner) {
+	packageOwner = owner
+}
+
+// ValidOwnership reports whether mode is a known ownership mode.
+func ValidOwnership(mode string) bool {
+	switch mode {
+	case "", OwnershipNone, OwnershipChown, OwnershipManifest:
+		return true
+	}
+	return false
+}
+
+// ParseOwner resolves "user[:group]", given as names or numeric IDs. Without a group,
+// the user's primary group is used.
+func ParseOwner(spec string) (Owner, error) {
+	userPart, groupPart, hasGroup := strings.Cut(spec, ":")
+	var owner Owner
+
+	if uid, err := strconv.Atoi(userPart); err == nil {
+		owner.UID = uid
+		if u, err := user.LookupId(userPart); err == nil {
+			owner.User = u.Username
+			owner.GID, _ = strconv.Atoi(u.Gid)
+		}
+	} else {
+		u, err := user.Lookup(userPart)
+		if err != nil {
+			return Owner{}, fmt.Errorf("unknown user '%s'", userPart)
+		}
+		owner.UID, _ = strconv.Atoi(u.Uid)
+		owner.GID, _ = strconv.Atoi(u.Gid)
+		owner.User = u.Username
+	}
+
+	if !hasGroup {
+		if g, err := user.LookupGroupId(strconv.Itoa(owner.GID)); err == nil {
+			owner.Group = g.Name
+		}
+		return owner, nil
+	}
+	if gid, err := strconv.Atoi(groupPart); err == nil {
+		owner.GID = gid
+		owner.Group = ""
+		if g, err := user.LookupGroupId(groupPart); err == nil {
+			owner.Group = g.Name
+		}
+		return owner, nil
+	}
+	g, err := user.LookupGroup(groupPart)
+	if err != nil {
+		return Owner{}, fmt.Errorf("unknown group '%s'", groupPart)
+	}
+	owner.GID, _ = strconv.Atoi(g.Gid)
+	owner.Group = g.Name
+	return owner, nil
+}
+
+// ownerTracker applies or records the ownership of extracted entries.
+type ownerTracker struct {
+	mode     string
+	owner    *Owner
+	manifest map[string]Owner
+	failed   bool
+}
+
+func newOwnerTracker(opts ExtractOptions) *ownerTracker {
+	if opts.Ownership == "" || opts.Ownership == OwnershipNone {
+		return nil
+	}
+	return &ownerTracker{mode: opts.Ownership, owner: opts.Owner, manifest: make(map[string]Owner)}
+}
+
+// apply handles the entry extracted to target; relPath is its path below the destination.
+// The configured owner wins over the one stored in the archive.
+func (o *ownerTracker) apply(target, relPath string, hdr *tar.Header) error {
+	if o == nil {
+		return nil
+	}
+	owner := Owner{UID: hdr.Uid, GID: hdr.Gid, User: hdr.Uname, Group: hdr.Gname}
+	if o.owner != nil {
+		owner = *o.owner
+	}
+
+	if o.mode == OwnershipManifest {
+		o.manifest[filepath.ToSlash(filepath.Clean(relPath))] = owner
+		return nil
+	}
+	if o.failed {
+		return nil
+	}
+	if err := os.Lchown(target, owner.UID, owner.GID); err != nil {
+		if os.IsPermission(err) {
+			log.Printf("⚠️  Cannot change ownership without root privileges, files keep the current owner. Use \"ownership\": \"manifest\" to record ownership instead.")
+			o.failed = true
+			return nil
+		}
+		return fmt.Errorf("chown %s: %w", target, err)
+	}
+	return nil
+}
+
+// finish writes the ownership manifest, merged with any earlier one in destPath.
+func (o *ownerTracker) finish(destPath string) error {
+	if o == nil || o.mode != OwnershipManifest || len(o.manifest) == 0 {
+		return nil
+	}
+	manifest := readOwnershipManifest(destPath)
+	if manifest == nil {
+		manifest = make(map[string]Owner, len(o.manifest))
+	}
+	for path, owner := range o.manifest {
+		manifest[path] = owner
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(destPath, OwnershipFile), data, 0644)
+}
+
+// readOwnershipManifest returns the manifest recorded in dir, or nil if there is none.
+func readOwnershipManifest(dir string) map[string]Owner {
+	data, err := os.ReadFile(filepath.Join(dir, OwnershipFile))
+	if err != nil {
+		return nil
+	}
+	var manifest map[string]Owner
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		log.Printf("⚠️  Ignoring unreadable ownership manifest in '%s': %v", dir, err)
+		return nil
+	}
+	return manifest
+}
+
+// stampOwner sets the owner of a packaged entry from the tree's manifest, falling back
+// to the package owner.
+func stampOwner(header *tar.Header, manifest map[string]Owner, relPath string) {
+	owner, ok := manifest[filepath.ToSlash(relPath)]
+	if !ok {
+		owner = packageOwner
+	}
+	header.Uid, header.Gid = owner.UID, owner.GID
+	header.Uname, header.Gname = owner.User, owner.Group
+}
@@ -0,0 +1,229 @@
+package archive
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"yapl/internal/fs"
+)
+
+// PatchSuffix marks patch bundles, e.g. MyGame.patch.tar.zst, which update a game
+// unpackaged from one bundle to the next version.
+const PatchSuffix = ".patch"
+
+// PatchFile follows the manifest in a patch bundle and says what the patch changes.
+const PatchFile = "yapl-patch.json"
+
+// Patch lists the changes between two bundles of a game. The patch bundle holds the new
+// bundle's manifest and the changed files.
+type Patch struct {
+	Base        string   `json:"base"` // Digest of the file list of the bundle the patch applies to
+	BaseVersion string   `json:"base_version,omitempty"`
+	Changed     []string `json:"changed"`
+	Deleted     []string `json:"deleted,omitempty"`
+}
+
+// filesDigest identifies a manifest's file list, independently of how it is formatted.
+func filesDigest(files map[string]ManifestEntry) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s\x00%s\n", name, files[name].SHA256)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// PackagePatch creates a patch bundle holding the files of sourceDir that were added or
+// changed since the bundle base, and the list of those deleted. Base must have a manifest;
+// manifest is filled in as Package does.
+func PackagePatch(sourceDir, format string, exclude []string, manifest *Manifest, base string) error {
+	extension, err := getExtensionForFormat(format)
+	if err != nil {
+		return err
+	}
+	old, err := ReadCompleteManifest(base)
+	if err != nil {
+		return fmt.Errorf("reading '%s': %w", base, err)
+	}
+	if old == nil {
+		return fmt.Errorf("'%s' has no %s to compare against; it was made by an older yapl", base, ManifestFile)
+	}
+
+	fmt.Println("-> Computing checksums...")
+	if err := fillManifest(manifest, sourceDir, exclude, nil, true); err != nil {
+		return fmt.Errorf("failed to create manifest: %w", err)
+	}
+	patch := Patch{Base: filesDigest(old.Files), BaseVersion: old.Version, Changed: []string{}}
+	changed := map[string]bool{}
+	var size int64
+	for name, entry := range manifest.Files {
+		if prev, ok := old.Files[name]; !ok || prev.SHA256 != entry.SHA256 {
+			patch.Changed = append(patch.Changed, name)
+			changed[name] = true
+			size += entry.Size
+		}
+	}
+	for name := range old.Files {
+		if _, ok := manifest.Files[name]; !ok {
+			patch.Deleted = append(patch.Deleted, name)
+		}
+	}
+	sort.Strings(patch.Changed)
+	sort.Strings(patch.Deleted)
+	fmt.Printf("-> %d files added or changed (%d of %d MB), %d deleted.\n", len(patch.Changed), size>>20, manifest.TotalSize>>20, len(patch.Deleted))
+
+	top := filepath.Base(sourceDir)
+	packageName := top + PatchSuffix + extension
+	if encrypt {
+		packageName += EncryptedSuffix
+	}
+	fmt.Printf("-> Creating %s patch '%s'...\n", strings.ToUpper(format), packageName)
+	err = writeBundle(packageName, format, true, func(tw *tar.Writer) error {
+		if err := writeManifest(tw, top, manifest); err != nil {
+			return err
+		}
+		if err := writeJSONEntry(tw, top, PatchFile, patch); err != nil {
+			return err
+		}
+		return fs.Walk(walkCtx, sourceDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(sourceDir, path)
+			if err != nil {
+				return err
+			}
+			entry := filepath.Join(top, rel)
+			if skip, skipDir := skipEntry(sourceDir, path, entry, info, exclude); skip {
+				if skipDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.Mode().IsRegular() || !changed[restoreName(filepath.ToSlash(rel))] {
+				return nil
+			}
+			return addTreeAs(tw, path, entry, nil, nil)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create patch: %w", err)
+	}
+	fmt.Println("\n✅ Packaging complete!")
+	fmt.Printf("➡️ Apply it with 'yapl unpackage --apply-patch %s' where '%s' was unpackaged.\n", packageName, filepath.Base(base))
+	return nil
+}
+
+// ApplyPatch updates the installation in targetDir that a patch bundle was made for. The
+// patch is extracted and checked next to it first, so a bad patch changes nothing; the
+// manifest is replaced last, so an interrupted update can be applied again.
+func ApplyPatch(targetDir, source string) error {
+	bundle, _ := TrimArchiveSuffix(filepath.Base(source))
+	name, ok := strings.CutSuffix(bundle, PatchSuffix)
+	if !ok {
+		return fmt.Errorf("'%s' is not a patch bundle (<name>.patch.tar.*)", source)
+	}
+	destPath := filepath.Join(targetDir, name)
+	installed, err := readManifestFile(filepath.Join(destPath, ManifestFile))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("'%s' has no %s; unpackage the full bundle first", destPath, ManifestFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	staging, err := os.MkdirTemp(targetDir, "."+name+"-patch-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+	opts := extractOptions
+	opts.Include, opts.Verify, opts.Sidecar = nil, false, true
+	ar := &Archive{Source: source}
+	if err := ar.extract(staging, true, opts); err != nil {
+		return err
+	}
+	manifest, err := readManifestFile(filepath.Join(staging, ManifestFile))
+	if err != nil {
+		return fmt.Errorf("the patch has no readable %s: %w", ManifestFile, err)
+	}
+	data, err := os.ReadFile(filepath.Join(staging, PatchFile))
+	if err != nil {
+		return fmt.Errorf("the patch has no %s: %w", PatchFile, err)
+	}
+	var patch Patch
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return fmt.Errorf("unreadable %s: %w", PatchFile, err)
+	}
+
+	for _, file := range append(patch.Changed, patch.Deleted...) {
+		if !filepath.IsLocal(filepath.FromSlash(file)) {
+			return fmt.Errorf("the patch contains invalid path: %s", file)
+		}
+	}
+	if filesDigest(installed.Files) != patch.Base {
+		if installed.Version != "" && patch.BaseVersion != "" {
+			return fmt.Errorf("the patch applies to version %s, but '%s' is version %s", patch.BaseVersion, destPath, installed.Version)
+		}
+		return fmt.Errorf("'%s' was not unpackaged from the bundle this patch was made against", destPath)
+	}
+	for _, file := range patch.Changed {
+		entry, ok := manifest.Files[file]
+		if !ok {
+			return fmt.Errorf("the patch changes '%s', which its manifest does not list", file)
+		}
+		sum, err := hashFile(filepath.Join(staging, filepath.FromSlash(file)))
+		if err != nil {
+			return fmt.Errorf("the patch is missing '%s': %w", file, err)
+		}
+		if sum != entry.SHA256 {
+			return fmt.Errorf("'%s' in the patch does not match its checksum", file)
+		}
+	}
+
+	fmt.Printf("-> Updating '%s': %d files added or changed, %d deleted...\n", destPath, len(patch.Changed), len(patch.Deleted))
+	for _, file := range patch.Deleted {
+		if err := os.Remove(filepath.Join(destPath, filepath.FromSlash(file))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	for _, file := range append(patch.Changed, ManifestFile) {
+		target := filepath.Join(destPath, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(staging, filepath.FromSlash(file)), target); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("✅ Updated '%s'.\n", destPath)
+	manifest.PrintRelease()
+	return nil
+}
+
+func readManifestFile(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("unreadable %s: %w", path, err)
+	}
+	if m.Files == nil {
+		return nil, errors.New("the manifest lists no files")
+	}
+	return &m, nil
+}
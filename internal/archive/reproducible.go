@@ -0,0 +1,45 @@
+package archive
+
+import (
+	"archive/tar"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	reproducible     bool
+	reproducibleTime = time.Unix(0, 0)
+)
+
+// SetReproducible makes packaging produce byte-identical bundles for identical trees:
+// entries keep the walk's sorted order, all timestamps are pinned (to SOURCE_DATE_EPOCH
+// if set, otherwise the Unix epoch) and compressors run with fixed, single-threaded settings.
+// Owners are always stamped from the packaging config, never taken from the filesystem.
+func SetReproducible(on bool) {
+	reproducible = on
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		reproducibleTime = time.Unix(epoch, 0)
+	}
+}
+
+// BuildTime is the time to record in generated files that go into a bundle: the pinned
+// time for reproducible packages, otherwise now.
+func BuildTime() time.Time {
+	if reproducible {
+		return reproducibleTime.UTC()
+	}
+	return time.Now().UTC()
+}
+
+// pinHeader strips everything from a header that depends on when or where it was created.
+func pinHeader(header *tar.Header) {
+	if !reproducible {
+		return
+	}
+	header.ModTime = reproducibleTime
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.PAXRecords = nil
+	header.Format = tar.FormatPAX
+}
@@ -0,0 +1,70 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// pathGuard keeps extraction inside the destination. Bundles come from arbitrary URLs, so
+// an entry may name a path outside it ('../../x', '/etc/x'), or write through a symlink an
+// earlier entry created, e.g. 'lib' -> '/usr/lib' followed by 'lib/libc.so'.
+//
+// Symlinks themselves are extracted as they are, absolute targets included: Wine prefixes
+// need them (dosdevices/z: points to /). They are never followed while extracting, and an
+// archive may not replace a symlink it created itself.
+type pathGuard struct {
+	dest  string
+	dirs  map[string]bool // Directories below dest known not to be symlinks
+	links map[string]bool // Entries extracted as symlinks
+}
+
+func newPathGuard(dest string) *pathGuard {
+	return &pathGuard{dest: filepath.Clean(dest), dirs: map[string]bool{}, links: map[string]bool{}}
+}
+
+// target returns where the entry name, relative to the destination, is extracted to. It
+// fails if the name leaves the destination or one of its parent directories is a symlink.
+func (g *pathGuard) target(name, rel string) (string, error) {
+	if rel == "" {
+		rel = "." // The stripped top-level directory
+	}
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("archive contains invalid path: %s", name)
+	}
+	target := filepath.Join(g.dest, rel)
+	for dir := filepath.Dir(target); len(dir) > len(g.dest) && !g.dirs[dir]; dir = filepath.Dir(dir) {
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			continue // Created by MkdirAll as a real directory
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("archive entry '%s' would be written through the symlink '%s'", name, dir)
+		}
+		g.dirs[dir] = true
+	}
+	return target, nil
+}
+
+// symlink records that the entry name was extracted as a symlink.
+func (g *pathGuard) symlink(name string) {
+	g.links[path.Clean(name)] = true
+}
+
+// replace removes a symlink at target so that writing the entry replaces it instead of
+// following it. A symlink from the same archive is not replaced: no archive yapl writes
+// holds a path twice, so a later entry for it means the archive is hostile.
+func (g *pathGuard) replace(name, target string) error {
+	if g.links[path.Clean(name)] {
+		return fmt.Errorf("archive entry '%s' would replace the symlink the archive created there", name)
+	}
+	info, err := os.Lstat(target)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+	return os.Remove(target)
+}
@@ -0,0 +1,181 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// tarEntry is a tar entry of a test archive; Type defaults to a regular file.
+type tarEntry struct {
+	Name     string
+	Type     byte
+	Linkname string
+	Body     string
+}
+
+func buildTar(t *testing.T, entries []tarEntry) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.Name, Typeflag: e.Type, Linkname: e.Linkname, Mode: 0644}
+		switch e.Type {
+		case 0, tar.TypeReg:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(len(e.Body))
+		case tar.TypeDir:
+			hdr.Mode = 0755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.Body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+// listTree returns every path below root, relative to it.
+func listTree(t *testing.T, root string) []string {
+	t.Helper()
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if rel, _ := filepath.Rel(root, path); rel != "." {
+			paths = append(paths, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func TestExtractRejectsHostileArchives(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries func(outside string) []tarEntry
+	}{
+		{"parent directory", func(string) []tarEntry {
+			return []tarEntry{{Name: "../escape", Body: "x"}}
+		}},
+		{"parent directory inside a path", func(string) []tarEntry {
+			return []tarEntry{{Name: "game/", Type: tar.TypeDir}, {Name: "game/../../escape", Body: "x"}}
+		}},
+		{"absolute name", func(outside string) []tarEntry {
+			return []tarEntry{{Name: filepath.Join(outside, "escape"), Body: "x"}}
+		}},
+		{"file below a symlink", func(outside string) []tarEntry {
+			return []tarEntry{
+				{Name: "lib", Type: tar.TypeSymlink, Linkname: outside},
+				{Name: "lib/escape", Body: "x"},
+			}
+		}},
+		{"file below a relative symlink", func(string) []tarEntry {
+			return []tarEntry{
+				{Name: "lib", Type: tar.TypeSymlink, Linkname: "../outside"},
+				{Name: "lib/sub/escape", Body: "x"},
+			}
+		}},
+		{"symlink replaced by a file", func(outside string) []tarEntry {
+			return []tarEntry{
+				{Name: "secret", Type: tar.TypeSymlink, Linkname: filepath.Join(outside, "secret")},
+				{Name: "secret", Body: "overwritten"},
+			}
+		}},
+		{"symlink replaced by a symlink", func(outside string) []tarEntry {
+			return []tarEntry{
+				{Name: "lib", Type: tar.TypeSymlink, Linkname: "."},
+				{Name: "lib", Type: tar.TypeSymlink, Linkname: outside},
+			}
+		}},
+		{"hard link outside the destination", func(string) []tarEntry {
+			return []tarEntry{{Name: "link", Type: tar.TypeLink, Linkname: "../outside/secret"}}
+		}},
+		{"hard link to an absolute path", func(outside string) []tarEntry {
+			return []tarEntry{{Name: "link", Type: tar.TypeLink, Linkname: filepath.Join(outside, "secret")}}
+		}},
+		{"hard link to an tarEntry that was not extracted", func(string) []tarEntry {
+			return []tarEntry{{Name: "link", Type: tar.TypeLink, Linkname: "missing"}}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dest := filepath.Join(root, "dest")
+			outside := filepath.Join(root, "outside")
+			if err := os.Mkdir(outside, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := extractTar(buildTar(t, tt.entries(outside)), dest, newStripper(false, nil), ExtractOptions{})
+			if err == nil {
+				t.Fatal("the archive was extracted without an error")
+			}
+			for _, p := range listTree(t, root) {
+				inside := p == "dest" || strings.HasPrefix(p, "dest"+string(filepath.Separator))
+				if !inside && p != "outside" && p != filepath.Join("outside", "secret") {
+					t.Errorf("extraction wrote '%s' outside the destination", p)
+				}
+			}
+			if data, _ := os.ReadFile(filepath.Join(outside, "secret")); string(data) != "secret" {
+				t.Errorf("the file outside the destination was changed to %q", data)
+			}
+			if fi, err := os.Stat(filepath.Join(dest, "link")); err == nil && fi.Mode().IsRegular() {
+				t.Error("a hard link to a file outside the archive was created")
+			}
+		})
+	}
+}
+
+func TestExtractKeepsSafeLinks(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "dest")
+	entries := []tarEntry{
+		{Name: "game/", Type: tar.TypeDir},
+		{Name: "game/data.bin", Body: "data"},
+		{Name: "game/copy.bin", Type: tar.TypeLink, Linkname: "game/data.bin"},
+		// Prefixes link to / and to other directories of their own; those stay as they are.
+		{Name: "game/z:", Type: tar.TypeSymlink, Linkname: "/"},
+		{Name: "game/current", Type: tar.TypeSymlink, Linkname: "data.bin"},
+	}
+	if _, err := extractTar(buildTar(t, entries), dest, newStripper(true, nil), ExtractOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "copy.bin")); err != nil || string(data) != "data" {
+		t.Fatalf("hard link: %q, %v", data, err)
+	}
+	if target, err := os.Readlink(filepath.Join(dest, "z:")); err != nil || target != "/" {
+		t.Fatalf("absolute symlink: %q, %v", target, err)
+	}
+}
+
+func TestExtractSkipsLinksToExcludedEntries(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "dest")
+	entries := []tarEntry{
+		{Name: "data.bin", Body: "data"},
+		{Name: "saves/copy.bin", Type: tar.TypeLink, Linkname: "data.bin"},
+	}
+	opts := ExtractOptions{Include: []string{"saves"}}
+	if _, err := extractTar(buildTar(t, entries), dest, newStripper(false, nil), opts); err != nil {
+		t.Fatalf("a hard link to an tarEntry left out by --include failed the extraction: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dest, "saves", "copy.bin")); !os.IsNotExist(err) {
+		t.Fatalf("the hard link was created without its source: %v", err)
+	}
+}
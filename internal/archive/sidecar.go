@@ -0,0 +1,115 @@
+package archive
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SidecarSuffix names the checksum file package writes next to a bundle, e.g.
+// MyGame.tar.zst.sha256, in the format of sha256sum, so 'sha256sum -c' can check it too.
+// A split bundle's sidecar lists every volume.
+const SidecarSuffix = ".sha256"
+
+// hashingWriter hashes what is written to the file it wraps.
+type hashingWriter struct {
+	*os.File
+	h hash.Hash
+}
+
+func (w *hashingWriter) Write(p []byte) (int, error) {
+	n, err := w.File.Write(p)
+	w.h.Write(p[:n])
+	return n, err
+}
+
+// writeSidecar writes the checksums of a bundle's files, in order, to <bundle>.sha256.
+func writeSidecar(bundle string, names, sums []string) error {
+	var b strings.Builder
+	for i, name := range names {
+		fmt.Fprintf(&b, "%s  %s\n", sums[i], filepath.Base(name))
+	}
+	tmp := bundle + SidecarSuffix + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, bundle+SidecarSuffix)
+}
+
+// readSidecar returns the checksums in a bundle's sidecar by file name, or nil if it has
+// none. source is the bundle, its first volume, or a URL.
+func readSidecar(source string) (map[string]string, error) {
+	bundle := source
+	if b, _, ok := SplitVolume(source); ok {
+		bundle = b
+	}
+	var r io.Reader
+	if strings.HasPrefix(bundle, "http") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(bundle + SidecarSuffix)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("download failed: %s", resp.Status)
+		}
+		r = io.LimitReader(resp.Body, 1<<20)
+	} else {
+		f, err := os.Open(bundle + SidecarSuffix)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		if _, err := hex.DecodeString(sum); !ok || err != nil || len(sum) != 2*sha256.Size {
+			return nil, fmt.Errorf("%s%s: malformed line '%s'", path.Base(bundle), SidecarSuffix, line)
+		}
+		sums[strings.TrimPrefix(strings.TrimSpace(name), "*")] = strings.ToLower(sum)
+	}
+	return sums, scanner.Err()
+}
+
+// checkSidecar compares the checksums of the files a bundle was read from with its sidecar.
+func checkSidecar(want, got map[string]string) error {
+	for name, sum := range want {
+		actual, ok := got[name]
+		if !ok {
+			return fmt.Errorf("'%s' is listed in the %s file but was not read; is a volume missing?", name, SidecarSuffix)
+		}
+		if actual != sum {
+			return fmt.Errorf("'%s' does not match its %s checksum; it is corrupted or incomplete", name, SidecarSuffix)
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			return fmt.Errorf("'%s' is not listed in the %s file", name, SidecarSuffix)
+		}
+	}
+	return nil
+}
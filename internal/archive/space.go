@@ -0,0 +1,65 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"yapl/internal/fs"
+)
+
+// expansion is how many times its compressed size an archive is assumed to take once
+// extracted when nothing better is known. Proton builds and runtimes expand about two to
+// three times; game bundles carry a manifest with their exact size.
+const expansion = 2
+
+// sourceSize returns the size of the archive being read, or 0 if it is unknown, e.g. for
+// a download without a Content-Length.
+func sourceSize(stream io.ReadCloser) int64 {
+	switch s := stream.(type) {
+	case *queuedBody:
+		return max(s.size, 0)
+	case *os.File:
+		if info, err := s.Stat(); err == nil {
+			return info.Size()
+		}
+	case *multiFile:
+		var total int64
+		for _, f := range s.files {
+			info, err := f.Stat()
+			if err != nil {
+				return 0
+			}
+			total += info.Size()
+		}
+		return total
+	}
+	return 0
+}
+
+// estimateSize returns the space the archive named source of the given size is expected
+// to take once extracted.
+func estimateSize(source string, size int64) int64 {
+	if bundle, _, ok := SplitVolume(source); ok {
+		source = bundle
+	}
+	if name, _ := trimEncrypted(source); strings.HasSuffix(name, ".tar") {
+		return size
+	}
+	return size * expansion
+}
+
+// checkSpace fails if the filesystem holding destPath has less than need bytes free, so
+// an extraction stops before writing anything instead of leaving a half-written install
+// on a full disk. If the free space cannot be determined, the extraction goes ahead.
+func checkSpace(destPath string, need int64) error {
+	if need <= 0 {
+		return nil
+	}
+	free, err := fs.Free(destPath)
+	if err != nil || free >= need {
+		return nil
+	}
+	return fmt.Errorf("not enough disk space for '%s': about %d MiB needed, but only %d MiB free; free up %d MiB and try again", destPath, need>>20, free>>20, (need-free+1<<20-1)>>20)
+}
@@ -0,0 +1,99 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stripper removes leading components from entry names: a fixed number of them, or, when
+// detecting, the archive's top-level directory if all entries are below a single one.
+//
+// Archives are read as a stream, so detection decides on the first entry and learns it was
+// wrong when a later one is not below that directory. What was extracted until then is
+// moved below it (see relocate), and nothing is stripped from then on.
+type stripper struct {
+	n    int    // Components to strip, or -1 to detect
+	root string // Detecting: the top-level directory being stripped, "" until known
+	off  bool   // Detecting: there is no single top-level directory
+}
+
+// newStripper strips count components if it is set, else detects a top-level directory
+// if detect is set, else strips nothing.
+func newStripper(detect bool, count *int) *stripper {
+	switch {
+	case count != nil:
+		return &stripper{n: *count}
+	case detect:
+		return &stripper{n: -1}
+	}
+	return &stripper{}
+}
+
+// strip returns an entry's path below the destination. skip is set for entries that are
+// stripped entirely, such as the top-level directory itself. relocate is set when the
+// entry shows the archive has no single top-level directory after all.
+func (s *stripper) strip(name string, isDir bool) (rel string, skip, relocate bool) {
+	name = strings.TrimPrefix(name, "./")
+	parts := strings.Split(name, "/")
+	if s.n >= 0 {
+		if len(parts) <= s.n {
+			return "", true, false
+		}
+		return strings.Join(parts[s.n:], "/"), false, false
+	}
+	if s.off || name == "" {
+		return name, name == "", false
+	}
+	// A file at the top level means there is no top-level directory.
+	single := len(parts) > 1 || isDir
+	if s.root == "" {
+		if !single {
+			s.off = true
+			return name, false, false
+		}
+		s.root = parts[0]
+	} else if !single || parts[0] != s.root {
+		s.off = true
+		return name, false, true
+	}
+	if len(parts) == 1 {
+		return "", true, false // The top-level directory itself
+	}
+	return strings.Join(parts[1:], "/"), false, false
+}
+
+// relocate moves everything in destPath into destPath/root, where it belongs when root is
+// not stripped after all. It returns the function that maps paths extracted so far to
+// their new place.
+func (s *stripper) relocate(destPath string) (func(string) string, error) {
+	if !filepath.IsLocal(s.root) || strings.Contains(s.root, "/") {
+		return nil, fmt.Errorf("archive contains invalid path: %s", s.root)
+	}
+	tmp, err := os.MkdirTemp(destPath, ".yapl-strip-")
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(destPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if p := filepath.Join(destPath, e.Name()); p != tmp {
+			if err := os.Rename(p, filepath.Join(tmp, e.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := os.Rename(tmp, filepath.Join(destPath, s.root)); err != nil {
+		return nil, err
+	}
+	return func(p string) string {
+		rel, err := filepath.Rel(destPath, p)
+		if err != nil || rel == "." {
+			return filepath.Join(destPath, s.root)
+		}
+		return filepath.Join(destPath, s.root, rel)
+	}, nil
+}
@@ -0,0 +1,153 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"syscall"
+
+	"yapl/internal/warn"
+)
+
+// xattrPrefix is the PAX record prefix used by GNU tar and bsdtar for extended attributes.
+// Wine keeps DOS file attributes (hidden, system) in the user.DOSATTRIB attribute.
+const xattrPrefix = "SCHILY.xattr."
+
+// sparseBlock is the granularity at which runs of zeros become holes on extraction.
+const sparseBlock = 4096
+
+var (
+	tarFormat    = tar.FormatUnknown
+	xattrWarning bool
+)
+
+// SetTarFormat selects the tar format written by packaging: "pax", "gnu", or "" to let
+// each entry use the simplest format that can hold it (USTAR, then PAX).
+func SetTarFormat(name string) error {
+	switch name {
+	case "":
+		tarFormat = tar.FormatUnknown
+	case "pax":
+		tarFormat = tar.FormatPAX
+	case "gnu":
+		tarFormat = tar.FormatGNU
+	default:
+		return fmt.Errorf("unknown tar format '%s'. Use 'pax' or 'gnu'", name)
+	}
+	return nil
+}
+
+// applyFormat sets the configured format and stores the file's extended attributes,
+// which only PAX can carry.
+func applyFormat(header *tar.Header, path string, info os.FileInfo) {
+	if tarFormat != tar.FormatUnknown {
+		header.Format = tarFormat
+	}
+	if !info.Mode().IsRegular() && !info.IsDir() {
+		return
+	}
+	xattrs := readXattrs(path)
+	if len(xattrs) == 0 {
+		return
+	}
+	if tarFormat == tar.FormatGNU {
+		if !xattrWarning {
+			log.Printf("⚠️  The GNU tar format cannot store extended attributes (e.g. '%s'); use --tar-format pax to keep them.", path)
+			xattrWarning = true
+		}
+		return
+	}
+	if header.PAXRecords == nil {
+		header.PAXRecords = make(map[string]string, len(xattrs))
+	}
+	for name, value := range xattrs {
+		header.PAXRecords[xattrPrefix+name] = value
+	}
+}
+
+func readXattrs(path string) map[string]string {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil || size == 0 {
+		return nil
+	}
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(path, buf)
+	if err != nil {
+		return nil
+	}
+	xattrs := make(map[string]string)
+	for _, name := range strings.Split(strings.TrimRight(string(buf[:size]), "\x00"), "\x00") {
+		// Only user attributes are portable; security.* and trusted.* need privileges.
+		if !strings.HasPrefix(name, "user.") {
+			continue
+		}
+		vsize, err := syscall.Getxattr(path, name, nil)
+		if err != nil {
+			continue
+		}
+		value := make([]byte, vsize)
+		if vsize, err = syscall.Getxattr(path, name, value); err == nil {
+			xattrs[name] = string(value[:vsize])
+		}
+	}
+	return xattrs
+}
+
+// restoreXattrs sets the extended attributes recorded for an extracted entry.
+func restoreXattrs(target string, hdr *tar.Header) {
+	for key, value := range hdr.PAXRecords {
+		name, ok := strings.CutPrefix(key, xattrPrefix)
+		if !ok {
+			continue
+		}
+		if err := syscall.Setxattr(target, name, []byte(value), 0); err != nil {
+			warn.Printf("Could not restore extended attribute '%s' on '%s': %v", name, target, err)
+		}
+	}
+}
+
+// restoreTimes sets an extracted entry's access and modification times from its header.
+func restoreTimes(target string, hdr *tar.Header) {
+	atime := hdr.AccessTime
+	if atime.IsZero() {
+		atime = hdr.ModTime
+	}
+	if err := os.Chtimes(target, atime, hdr.ModTime); err != nil {
+		warn.Printf("Could not restore the modification time of '%s': %v", target, err)
+	}
+}
+
+// copySparse writes r to out, leaving holes where whole blocks are zero, so sparse
+// files (which tar readers hand out expanded) take no more space than before packaging.
+func copySparse(out *os.File, r io.Reader) error {
+	buf := make([]byte, 64*sparseBlock)
+	zero := make([]byte, sparseBlock)
+	var size int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		for off := 0; off < n; off += sparseBlock {
+			end := min(off+sparseBlock, n)
+			chunk := buf[off:end]
+			if len(chunk) == sparseBlock && bytes.Equal(chunk, zero) {
+				if _, err := out.Seek(int64(len(chunk)), io.SeekCurrent); err != nil {
+					return err
+				}
+			} else if _, err := out.Write(chunk); err != nil {
+				return err
+			}
+			size += int64(len(chunk))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// A trailing hole only exists once the file is extended to its full size.
+	return out.Truncate(size)
+}
@@ -0,0 +1,125 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeBundleFile writes a gzip bundle of the entries to dir/name, with a sidecar
+// holding sum, or its real checksum if sum is empty.
+func writeBundleFile(t *testing.T, dir, name string, entries []tarEntry, sum string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := io.Copy(gz, buildTar(t, entries)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	bundle := filepath.Join(dir, name)
+	if err := os.WriteFile(bundle, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if sum == "" {
+		h := sha256.Sum256(data)
+		sum = hex.EncodeToString(h[:])
+	}
+	if err := os.WriteFile(bundle+SidecarSuffix, []byte(fmt.Sprintf("%s  %s\n", sum, name)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return bundle
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestUnpackageMergeChecksFirst(t *testing.T) {
+	dir := t.TempDir()
+	games := filepath.Join(dir, "games")
+	installed := filepath.Join(games, "G")
+	if err := os.MkdirAll(installed, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(installed, "data.txt"), []byte("good"), 0644)
+	os.WriteFile(filepath.Join(installed, "save.txt"), []byte("save"), 0644)
+	entries := []tarEntry{{Name: "G/", Type: tar.TypeDir}, {Name: "G/data.txt", Body: "new"}, {Name: "G/new.txt", Body: "added"}}
+
+	bad := writeBundleFile(t, dir, "G.tar.gz", entries, strings.Repeat("0", 64))
+	if err := UnpackageInto(games, []string{bad}, nil, "", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(installed, "data.txt")); got != "good" {
+		t.Fatalf("a bundle failing its checksum overwrote data.txt with %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(installed, "new.txt")); !os.IsNotExist(err) {
+		t.Fatal("a bundle failing its checksum added new.txt")
+	}
+
+	good := writeBundleFile(t, dir, "G.tar.gz", entries, "")
+	if err := UnpackageInto(games, []string{good}, nil, "", true); err != nil {
+		t.Fatal(err)
+	}
+	for file, want := range map[string]string{"data.txt": "new", "new.txt": "added", "save.txt": "save"} {
+		if got := readFile(t, filepath.Join(installed, file)); got != want {
+			t.Errorf("%s: got %q, want %q", file, got, want)
+		}
+	}
+	if left, _ := filepath.Glob(filepath.Join(games, ".*")); len(left) > 0 {
+		t.Errorf("staging directories were left behind: %v", left)
+	}
+}
+
+func TestUnpackageFreshFailureLeavesNothing(t *testing.T) {
+	dir := t.TempDir()
+	games := filepath.Join(dir, "games")
+	bundle := writeBundleFile(t, dir, "G.tar.gz", []tarEntry{{Name: "G/data.txt", Body: "x"}}, strings.Repeat("0", 64))
+	if err := UnpackageInto(games, []string{bundle}, nil, "", false); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(games); len(entries) > 0 {
+		t.Fatalf("a failed unpackage left %s behind", entries[0].Name())
+	}
+}
+
+func TestMergeIntoReplacesSymlinkedDirectories(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside")
+	dest := filepath.Join(dir, "dest")
+	staging := filepath.Join(dir, "staging")
+	for _, d := range []string{outside, dest, filepath.Join(staging, "lib")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(staging, "lib", "x.dll"), []byte("x"), 0644)
+	if err := os.Symlink(outside, filepath.Join(dest, "lib")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mergeInto(staging, dest); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) > 0 {
+		t.Fatal("merging wrote through the symlink in the destination")
+	}
+	if got := readFile(t, filepath.Join(dest, "lib", "x.dll")); got != "x" {
+		t.Fatalf("lib/x.dll: got %q", got)
+	}
+}
@@ -0,0 +1,202 @@
+package archive
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var splitSize int64
+
+// SetSplitSize makes packaging write bundles as volumes of at most n bytes, named
+// <bundle>.001, <bundle>.002 and so on; 0 writes a single file.
+func SetSplitSize(n int64) {
+	splitSize = n
+}
+
+// ParseSize reads a size such as "4G", "700M" or "512k" (binary units) or a plain
+// number of bytes.
+func ParseSize(s string) (int64, error) {
+	units := map[byte]int64{'k': 1 << 10, 'm': 1 << 20, 'g': 1 << 30, 't': 1 << 40}
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "b"), "i")
+	mult := int64(1)
+	if n := len(trimmed); n > 0 && units[trimmed[n-1]] != 0 {
+		mult, trimmed = units[trimmed[n-1]], trimmed[:n-1]
+	}
+	n, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size '%s'. Use e.g. '4G' or '700M'", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// VolumeName returns the name of a bundle's i-th volume, counting from 1.
+func VolumeName(bundle string, i int) string {
+	return fmt.Sprintf("%s.%03d", bundle, i)
+}
+
+// SplitVolume splits a volume's name into the bundle's name and the volume's number. It
+// reports false for names that are not volumes of a supported archive.
+func SplitVolume(name string) (string, int, bool) {
+	ext := filepath.Ext(name)
+	if len(ext) < 4 {
+		return "", 0, false
+	}
+	i, err := strconv.Atoi(ext[1:])
+	if err != nil || i < 1 {
+		return "", 0, false
+	}
+	bundle := strings.TrimSuffix(name, ext)
+	if _, ok := TrimArchiveSuffix(bundle); !ok {
+		return "", 0, false
+	}
+	return bundle, i, true
+}
+
+// Volumes returns the volumes of a split bundle that exist, in order.
+func Volumes(bundle string) []string {
+	var names []string
+	for i := 1; ; i++ {
+		name := VolumeName(bundle, i)
+		if _, err := os.Stat(name); err != nil {
+			return names
+		}
+		names = append(names, name)
+	}
+}
+
+// volumeWriter writes a stream into volumes of at most size bytes.
+type volumeWriter struct {
+	bundle  string
+	size    int64
+	count   int
+	f       *os.File
+	written int64
+	h       hash.Hash
+	names   []string // The volumes written so far
+	sums    []string // and their checksums
+}
+
+func newVolumeWriter(bundle string, size int64) (*volumeWriter, error) {
+	// Volumes left from an earlier, larger bundle would be read as part of this one.
+	for _, old := range Volumes(bundle) {
+		if err := os.Remove(old); err != nil {
+			return nil, err
+		}
+	}
+	os.Remove(bundle)
+	return &volumeWriter{bundle: bundle, size: size}, nil
+}
+
+func (w *volumeWriter) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		if w.f == nil || w.written == w.size {
+			if err := w.next(); err != nil {
+				return total, err
+			}
+		}
+		chunk := p
+		if room := w.size - w.written; int64(len(chunk)) > room {
+			chunk = chunk[:room]
+		}
+		n, err := w.f.Write(chunk)
+		w.h.Write(chunk[:n])
+		total += n
+		w.written += int64(n)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+	}
+	return total, nil
+}
+
+func (w *volumeWriter) next() error {
+	if w.f != nil {
+		if err := w.finish(); err != nil {
+			return err
+		}
+	}
+	w.count++
+	f, err := os.Create(VolumeName(w.bundle, w.count))
+	if err != nil {
+		return err
+	}
+	w.f, w.written, w.h = f, 0, sha256.New()
+	return nil
+}
+
+// finish closes the current volume and records its checksum.
+func (w *volumeWriter) finish() error {
+	err := w.f.Close()
+	w.names = append(w.names, w.f.Name())
+	w.sums = append(w.sums, hex.EncodeToString(w.h.Sum(nil)))
+	w.f = nil
+	return err
+}
+
+func (w *volumeWriter) Close() error {
+	if w.count == 0 {
+		if err := w.next(); err != nil { // An empty stream still makes one volume
+			return err
+		}
+	}
+	if w.f == nil {
+		return nil
+	}
+	return w.finish()
+}
+
+// openVolumes reads a split bundle's volumes as one stream.
+func openVolumes(bundle string) (io.ReadCloser, error) {
+	names := Volumes(bundle)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no volumes of '%s' found", bundle)
+	}
+	files := make([]*os.File, 0, len(names))
+	readers := make([]io.Reader, 0, len(names))
+	sums := make([]hash.Hash, 0, len(names))
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		h := sha256.New()
+		files = append(files, f)
+		readers = append(readers, io.TeeReader(f, h))
+		sums = append(sums, h)
+	}
+	return &multiFile{Reader: io.MultiReader(readers...), files: files, sums: sums}, nil
+}
+
+type multiFile struct {
+	io.Reader
+	files []*os.File
+	sums  []hash.Hash // Checksums of what was read of each volume
+}
+
+// checksums returns the checksum of every volume by file name.
+func (m *multiFile) checksums() map[string]string {
+	sums := make(map[string]string, len(m.files))
+	for i, f := range m.files {
+		sums[filepath.Base(f.Name())] = hex.EncodeToString(m.sums[i].Sum(nil))
+	}
+	return sums
+}
+
+func (m *multiFile) Close() error {
+	for _, f := range m.files {
+		f.Close()
+	}
+	return nil
+}
@@ -3,14 +3,23 @@ package command
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"yapl/internal/config"
+	"yapl/internal/events"
 	"yapl/internal/fs"
+	"yapl/internal/startup"
 )
 
 // InitializePrefix creates and sets up a new Wine prefix.
@@ -65,10 +74,23 @@ func InitializePrefix(prefixPath string, appCfg config.App, globalCfg config.Glo
 		return RunDirectly(prefixPath, explorerCfg, globalCfg, false, debug)
 	}
 
-	// Default 64-bit prefix initialization using the proton script
-	fmt.Println("-> Initializing Wine prefix using the proton script...")
+	fast := false
+	if appCfg.PrefixInit == "fast" && appCfg.ProtonVersion != "system" {
+		ok, err := initializeFast(absPrefix, protonBasePath)
+		if err != nil {
+			log.Printf("⚠️  Fast prefix creation failed, falling back to wineboot: %v", err)
+			if err := clearPrefix(absPrefix); err != nil {
+				return err
+			}
+		} else if !ok {
+			fmt.Println("-> This Proton build has no default prefix or wine.inf to start from; using wineboot.")
+		}
+		fast = ok && err == nil
+	}
 
-	if appCfg.ProtonVersion != "system" {
+	// Default 64-bit prefix initialization using the proton script
+	if appCfg.ProtonVersion != "system" && !fast {
+		fmt.Println("-> Initializing Wine prefix using the proton script...")
 		protonScriptPath := getProtonScriptPath(appCfg, globalCfg, wineArch)
 		if _, err := os.Stat(protonScriptPath); os.IsNotExist(err) {
 			return fmt.Errorf("could not find 'proton' script at %s", protonScriptPath)
@@ -111,6 +133,7 @@ func RunDirectly(prefixPath string, appCfg config.App, globalCfg config.Global,
 	protonVersionInfo := getProtonInfo(appCfg, globalCfg)
 	wineArch := getWineArch(appCfg)
 	protonBasePath, _ := filepath.Abs(getProtonPath(appCfg.ProtonVersion, protonVersionInfo, wineArch))
+	appCfg = expandPlaceholders(appCfg, absPrefix, protonBasePath)
 
 	wineExecutablePath, err := getWineExecutablePath(protonBasePath, wineArch)
 	if err != nil {
@@ -119,7 +142,7 @@ func RunDirectly(prefixPath string, appCfg config.App, globalCfg config.Global,
 	fmt.Printf("-> Found wine executable for %s: %s\n", wineArch, wineExecutablePath)
 
 	if appCfg.SteamAppID != "" && appCfg.SteamAppID != "0" {
-		fullExePath := filepath.Join(absPrefix, appCfg.Executable)
+		fullExePath := executablePath(absPrefix, appCfg.Executable)
 		exeDir := filepath.Dir(fullExePath)
 		appIDPath := filepath.Join(exeDir, "steam_appid.txt")
 		if err := os.WriteFile(appIDPath, []byte(appCfg.SteamAppID), 0644); err != nil {
@@ -127,10 +150,51 @@ func RunDirectly(prefixPath string, appCfg config.App, globalCfg config.Global,
 		}
 	}
 
-	fullExePath := filepath.Join(absPrefix, appCfg.Executable)
+	fullExePath := executablePath(absPrefix, appCfg.Executable)
 	args := []string{fullExePath}
 	args = append(args, appCfg.LaunchArgs...)
 
+	if err := startPersistentServer(absPrefix, protonBasePath, appCfg, protonVersionInfo, debug); err != nil {
+		return err
+	}
+	cmd := exec.Command(wineExecutablePath, args...)
+	cmd.Env = buildProtonEnv(absPrefix, protonBasePath, appCfg, protonVersionInfo, debug)
+
+	wrapped, err := wrapLaunch(cmd, appCfg)
+	if err != nil {
+		return err
+	}
+	return executeCommand(wrapped)
+}
+
+// RunWineTool launches one of Wine's built-in programs (winecfg, regedit, control, ...)
+// inside the prefix, using the same wine binary and environment as RunDirectly.
+func RunWineTool(prefixPath, tool string, appCfg config.App, globalCfg config.Global, debug bool) error {
+	return RunInPrefix(prefixPath, []string{tool}, appCfg, globalCfg, debug)
+}
+
+// RunInPrefix runs a Windows program with its arguments in the prefix, like RunWineTool,
+// e.g. an uninstaller. The program is a Windows path or the name of a built-in program.
+func RunInPrefix(prefixPath string, args []string, appCfg config.App, globalCfg config.Global, debug bool) error {
+	absPrefix := fs.MustGetAbsolutePath(prefixPath)
+	if _, err := os.Stat(filepath.Join(absPrefix, "system.reg")); os.IsNotExist(err) {
+		return fmt.Errorf("no Wine prefix found at %s. Run 'setup' first", absPrefix)
+	}
+
+	protonVersionInfo := getProtonInfo(appCfg, globalCfg)
+	wineArch := getWineArch(appCfg)
+	protonBasePath, _ := filepath.Abs(getProtonPath(appCfg.ProtonVersion, protonVersionInfo, wineArch))
+	appCfg = expandPlaceholders(appCfg, absPrefix, protonBasePath)
+
+	wineExecutablePath, err := getWineExecutablePath(protonBasePath, wineArch)
+	if err != nil {
+		return err
+	}
+
+	if err := startPersistentServer(absPrefix, protonBasePath, appCfg, protonVersionInfo, debug); err != nil {
+		return err
+	}
+	fmt.Printf("-> Launching %s in prefix %s...\n", args[0], absPrefix)
 	cmd := exec.Command(wineExecutablePath, args...)
 	cmd.Env = buildProtonEnv(absPrefix, protonBasePath, appCfg, protonVersionInfo, debug)
 
@@ -142,14 +206,25 @@ func RunInContainer(prefixPath string, appCfg config.App, globalCfg config.Globa
 	if appCfg.RuntimeVersion == "" {
 		return errors.New("launch_method 'container' requires 'runtime_version' to be set in game.json")
 	}
+	if fallback, err := containerFallback(appCfg, config.RuntimeDir(appCfg)); err != nil {
+		return err
+	} else if fallback {
+		return RunDirectly(prefixPath, appCfg, globalCfg, false, debug)
+	}
 
 	fmt.Println("-> Running in container mode...")
 	protonVersionInfo := getProtonInfo(appCfg, globalCfg)
 	wineArch := getWineArch(appCfg)
 	protonBasePath, _ := filepath.Abs(getProtonPath(appCfg.ProtonVersion, protonVersionInfo, wineArch))
 	absPrefix := fs.MustGetAbsolutePath(prefixPath)
+	appCfg = expandPlaceholders(appCfg, absPrefix, protonBasePath)
 
-	runtimeDir := filepath.Join("dependencies", "runtime", appCfg.RuntimeVersion)
+	runtimeDir := config.RuntimeDir(appCfg)
+	// Launch from the snapshot itself, so a runtime update switching the link does not
+	// affect the running game and pruning can tell the snapshot is in use.
+	if resolved, err := filepath.EvalSymlinks(runtimeDir); err == nil {
+		runtimeDir = resolved
+	}
 	entryPointPath := filepath.Join(runtimeDir, "yapl-entry-point")
 	shimPath := filepath.Join(runtimeDir, "yapl-shim")
 	protonScriptPath := getProtonScriptPath(appCfg, globalCfg, wineArch)
@@ -159,7 +234,7 @@ func RunInContainer(prefixPath string, appCfg config.App, globalCfg config.Globa
 	}
 
 	if appCfg.SteamAppID != "" && appCfg.SteamAppID != "0" {
-		fullExePath := filepath.Join(absPrefix, appCfg.Executable)
+		fullExePath := executablePath(absPrefix, appCfg.Executable)
 		exeDir := filepath.Dir(fullExePath)
 		appIDPath := filepath.Join(exeDir, "steam_appid.txt")
 		if err := os.WriteFile(appIDPath, []byte(appCfg.SteamAppID), 0644); err != nil {
@@ -167,27 +242,63 @@ func RunInContainer(prefixPath string, appCfg config.App, globalCfg config.Globa
 		}
 	}
 
-	fullExePath := filepath.Join(absPrefix, appCfg.Executable)
-	protonVerb := "waitforexitandrun"
+	fullExePath := executablePath(absPrefix, appCfg.Executable)
+	protonVerb := launchVerb(appCfg)
 
-	args := []string{
-		"--verb=" + protonVerb,
+	args := []string{"--verb=" + protonVerb}
+	args = append(args, appCfg.Container.EntryPointArgs...)
+	args = append(args,
 		"--",
 		shimPath,
 		protonScriptPath,
 		protonVerb,
 		fullExePath,
-	}
+	)
 	args = append(args, appCfg.LaunchArgs...)
 
+	runtimeEnv, err := containerEnv(appCfg.Container)
+	if err != nil {
+		return err
+	}
+	if err := startPersistentServer(absPrefix, protonBasePath, appCfg, protonVersionInfo, debug); err != nil {
+		return err
+	}
 	cmd := exec.Command(entryPointPath, args...)
-	cmd.Env = buildProtonEnv(absPrefix, protonBasePath, appCfg, protonVersionInfo, debug)
+	cmd.Env = append(buildProtonEnv(absPrefix, protonBasePath, appCfg, protonVersionInfo, debug), runtimeEnv...)
 
-	return executeCommand(cmd)
+	wrapped, err := wrapLaunch(cmd, appCfg)
+	if err != nil {
+		return err
+	}
+	return executeCommand(wrapped)
+}
+
+// containerEnv returns the variables from container_options.environment. Only those read
+// by pressure-vessel and the runtime itself belong there; the game's own variables go in
+// environment_vars.
+func containerEnv(opts config.ContainerOptions) ([]string, error) {
+	keys := make([]string, 0, len(opts.Environment))
+	for k := range opts.Environment {
+		if !strings.HasPrefix(k, "PRESSURE_VESSEL_") && !strings.HasPrefix(k, "STEAM_RUNTIME_") {
+			return nil, fmt.Errorf("container_options.environment only accepts PRESSURE_VESSEL_* and STEAM_RUNTIME_* variables, put '%s' in environment_vars", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	env := make([]string, 0, len(keys))
+	for _, k := range keys {
+		env = append(env, k+"="+opts.Environment[k])
+	}
+	return env, nil
 }
 
 // RunWithUMU launches the application using the umu-launcher helper.
 func RunWithUMU(prefixPath string, appCfg config.App, globalCfg config.Global, debug bool) error {
+	if fallback, err := containerFallback(appCfg, ""); err != nil {
+		return err
+	} else if fallback {
+		return RunDirectly(prefixPath, appCfg, globalCfg, false, debug)
+	}
 	fmt.Println("-> Running with umu-launcher...")
 
 	umuRunPath := "umu-run"
@@ -207,8 +318,12 @@ func RunWithUMU(prefixPath string, appCfg config.App, globalCfg config.Global, d
 	protonVersionInfo := getProtonInfo(appCfg, globalCfg)
 	wineArch := getWineArch(appCfg)
 	protonBasePath, _ := filepath.Abs(getProtonPath(appCfg.ProtonVersion, protonVersionInfo, wineArch))
-	fullExePath := filepath.Join(absPrefix, appCfg.Executable)
+	appCfg = expandPlaceholders(appCfg, absPrefix, protonBasePath)
+	fullExePath := executablePath(absPrefix, appCfg.Executable)
 
+	if err := startPersistentServer(absPrefix, protonBasePath, appCfg, protonVersionInfo, debug); err != nil {
+		return err
+	}
 	args := append([]string{fullExePath}, append(appCfg.LaunchArgs, appCfg.UMUOptions.LaunchArgs...)...)
 	cmd := exec.Command(umuRunPath, args...)
 
@@ -221,12 +336,16 @@ func RunWithUMU(prefixPath string, appCfg config.App, globalCfg config.Global, d
 		cmd.Env = append(cmd.Env, "STORE="+appCfg.UMUOptions.Store)
 	}
 
-	return executeCommand(cmd)
+	wrapped, err := wrapLaunch(cmd, appCfg)
+	if err != nil {
+		return err
+	}
+	return executeCommand(wrapped)
 }
 
 // buildProtonEnv constructs the necessary environment for Proton/Wine to run.
 func buildProtonEnv(absPrefix, protonBasePath string, appCfg config.App, vinfo config.VersionInfo, debug bool) []string {
-	clientInstallPath := filepath.Dir(filepath.Join(absPrefix, appCfg.Executable))
+	clientInstallPath := filepath.Dir(executablePath(absPrefix, appCfg.Executable))
 	env := os.Environ()
 
 	var newLdPaths []string
@@ -267,7 +386,7 @@ func buildProtonEnv(absPrefix, protonBasePath string, appCfg config.App, vinfo c
 	env = append(env, "STEAM_COMPAT_TOOL_PATHS="+protonBasePath)
 	env = append(env, "STEAM_COMPAT_MOUNTS="+protonBasePath)
 	env = append(env, "STEAM_COMPAT_SHADER_PATH="+filepath.Join(absPrefix, "shadercache"))
-	env = append(env, "PROTON_VERB=waitforexitandrun")
+	env = append(env, "PROTON_VERB="+launchVerb(appCfg))
 
 	// Set UMU_ID for compatibility with patched Proton scripts.
 	// This signals that we are a third-party launcher.
@@ -284,6 +403,10 @@ func buildProtonEnv(absPrefix, protonBasePath string, appCfg config.App, vinfo c
 	}
 	env = append(env, "UMU_ID="+umuID)
 
+	env = append(env, syncEnv(appCfg)...)
+	env = append(env, scalingEnv(appCfg)...)
+	env = append(env, gpuEnv(appCfg)...)
+
 	for k, v := range appCfg.EnvironmentVars {
 		env = append(env, k+"="+v)
 	}
@@ -294,23 +417,185 @@ func buildProtonEnv(absPrefix, protonBasePath string, appCfg config.App, vinfo c
 	if debug {
 		fmt.Println("-> Debug mode enabled.")
 		env = append(env, "PROTON_LOG=1", "DXVK_LOG_LEVEL=info")
+		if logDir != "" {
+			if err := os.MkdirAll(logDir, 0755); err != nil {
+				log.Printf("⚠️  Warning: Failed to create log directory: %v", err)
+			} else {
+				absLogDir := fs.MustGetAbsolutePath(logDir)
+				fmt.Printf("-> Writing logs to %s\n", absLogDir)
+				env = append(env, "PROTON_LOG_DIR="+absLogDir, "DXVK_LOG_PATH="+absLogDir)
+			}
+		}
 	}
 
 	return env
 }
 
+// LastExitCode returns the exit code of the most recently executed application.
+// It is -1 if the process could not be started or was killed by a signal.
+func LastExitCode() int {
+	return lastExitCode
+}
+
 // --- Private Helpers ---
 
+var (
+	lastExitCode   int
+	timeLimit      time.Duration
+	timeLimitWarn  time.Duration
+	terminateGrace = 30 * time.Second
+	pidFile        string
+	logDir         string
+	mangoHudConfig string
+	outputTail     = &tailBuffer{max: 256 << 10}
+)
+
+// SetLogDir makes debug runs write Proton and DXVK logs to dir instead of $HOME and the
+// game's directory.
+func SetLogDir(dir string) {
+	logDir = dir
+}
+
+// SetMangoHudConfig sets where the game's mangohud_config options are written before
+// launching it with MangoHud.
+func SetMangoHudConfig(path string) {
+	mangoHudConfig = path
+}
+
+// SetPIDFile makes the next launched application record its PID in path while it runs,
+// so that 'yapl kill' can find it later.
+func SetPIDFile(path string) {
+	pidFile = path
+}
+
+// SetTimeLimit restricts how long the next launched application may run. The user is
+// warned warnBefore the limit; when it is reached the application is asked to quit and
+// killed if it is still running after a short grace period. A zero limit disables it.
+func SetTimeLimit(limit, warnBefore time.Duration) {
+	timeLimit = limit
+	timeLimitWarn = warnBefore
+}
+
+// LastOutput returns the end of what the most recently executed application wrote to
+// stdout and stderr.
+func LastOutput() string {
+	return outputTail.String()
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = append(t.buf[:0], t.buf[len(t.buf)-t.max:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+func (t *tailBuffer) Reset() {
+	t.mu.Lock()
+	t.buf = t.buf[:0]
+	t.mu.Unlock()
+}
+
 func executeCommand(cmd *exec.Cmd) error {
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	outputTail.Reset()
+	stdout, stderr := gameOutput()
+	cmd.Stdout = io.MultiWriter(stdout, outputTail)
+	cmd.Stderr = io.MultiWriter(stderr, outputTail)
 	fmt.Printf("-> Executing: %s\n", strings.Join(cmd.Args, " "))
-	if err := cmd.Run(); err != nil {
+	err := runProcess(cmd)
+	lastExitCode = exitCodeOf(err)
+	if err != nil {
 		log.Printf("❌ Application exited with an error: %v", err)
 	}
 	return nil
 }
 
+func runProcess(cmd *exec.Cmd) error {
+	startup.Phase("env build")
+	defer startup.Watch()()
+	// The application leads its own process group, so 'yapl kill' can reach helpers that
+	// do not carry WINEPREFIX. Ctrl-C in the terminal no longer reaches the group, so it
+	// is passed on.
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	defer func() {
+		signal.Stop(interrupts)
+		close(done)
+	}()
+	go func() {
+		for {
+			select {
+			case sig := <-interrupts:
+				syscall.Kill(-cmd.Process.Pid, sig.(syscall.Signal))
+			case <-done:
+				return
+			}
+		}
+	}()
+	events.Emit("launch", map[string]interface{}{"pid": cmd.Process.Pid, "command": cmd.Args})
+	defer func() {
+		events.Emit("exit", map[string]interface{}{"pid": cmd.Process.Pid, "exit_code": cmd.ProcessState.ExitCode()})
+	}()
+	if pidFile != "" {
+		if err := os.WriteFile(pidFile, []byte(strconv.Itoa(cmd.Process.Pid)), 0644); err != nil {
+			log.Printf("⚠️  Warning: Failed to write PID file: %v", err)
+		}
+		defer os.Remove(pidFile)
+	}
+	if timeLimit <= 0 {
+		return cmd.Wait()
+	}
+
+	if timeLimitWarn > 0 && timeLimitWarn < timeLimit {
+		warn := time.AfterFunc(timeLimit-timeLimitWarn, func() {
+			log.Printf("⏰ Playtime ends in %s. Please save your game.", timeLimitWarn.Round(time.Minute))
+		})
+		defer warn.Stop()
+	}
+	stop := time.AfterFunc(timeLimit, func() {
+		log.Printf("⏰ Playtime is over. Asking the application to quit...")
+		cmd.Process.Signal(syscall.SIGTERM)
+		time.AfterFunc(terminateGrace, func() { cmd.Process.Kill() })
+	})
+	defer stop.Stop()
+
+	return cmd.Wait()
+}
+
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
 func restructureProtonPrefix(absPrefix string) error {
 	fmt.Println("-> Restructuring prefix to standard layout...")
 	pfxDir := filepath.Join(absPrefix, "pfx")
@@ -340,6 +625,34 @@ func restructureProtonPrefix(absPrefix string) error {
 	return nil
 }
 
+// syncEnv translates the esync, fsync and ntsync toggles for both the proton script
+// (PROTON_NO_*) and Proton's wine started directly (WINE*SYNC). Esync and fsync are on
+// unless disabled, as in Proton; ntsync is left to the Proton version unless set.
+func syncEnv(appCfg config.App) []string {
+	var env []string
+	toggle := func(setting *bool, wineVar, protonOff string) {
+		if setting == nil || *setting {
+			env = append(env, wineVar+"=1")
+		} else {
+			env = append(env, wineVar+"=0", protonOff+"=1")
+		}
+	}
+	toggle(appCfg.Esync, "WINEESYNC", "PROTON_NO_ESYNC")
+	toggle(appCfg.Fsync, "WINEFSYNC", "PROTON_NO_FSYNC")
+
+	if appCfg.NTsync != nil {
+		if *appCfg.NTsync {
+			if _, err := os.Stat("/dev/ntsync"); err != nil {
+				log.Printf("⚠️  Warning: 'ntsync' is enabled but /dev/ntsync does not exist (needs Linux 6.14+ and the ntsync module).")
+			}
+			env = append(env, "WINENTSYNC=1", "PROTON_USE_NTSYNC=1")
+		} else {
+			env = append(env, "WINENTSYNC=0", "PROTON_NO_NTSYNC=1")
+		}
+	}
+	return env
+}
+
 func buildDllOverridesString(overrides map[string]string) string {
 	if len(overrides) == 0 {
 		return ""
@@ -387,6 +700,14 @@ func getWineExecutablePath(protonBasePath string, wineArch string) (string, erro
 		binariesToSearch = []string{"wine64", "wine"}
 	}
 
+	if path, ok := findProtonBinary(protonBasePath, binariesToSearch...); ok {
+		return path, nil
+	}
+	return "", fmt.Errorf("could not find a suitable wine/wine64 executable in %s for architecture %s", protonBasePath, wineArch)
+}
+
+// findProtonBinary returns the absolute path of the first binary found in a Proton distribution.
+func findProtonBinary(protonBasePath string, names ...string) (string, bool) {
 	// Wine builds can place the binaries in different locations. Check the most common ones.
 	possibleBasePaths := []string{
 		filepath.Join(protonBasePath, "files", "bin"),
@@ -394,16 +715,16 @@ func getWineExecutablePath(protonBasePath string, wineArch string) (string, erro
 		filepath.Join(protonBasePath, "bin"),
 	}
 
-	for _, binName := range binariesToSearch {
+	for _, binName := range names {
 		for _, basePath := range possibleBasePaths {
 			fullPath := filepath.Join(basePath, binName)
 			if _, err := os.Stat(fullPath); err == nil {
-				return filepath.Abs(fullPath)
+				abs, err := filepath.Abs(fullPath)
+				return abs, err == nil
 			}
 		}
 	}
-
-	return "", fmt.Errorf("could not find a suitable wine/wine64 executable in %s for architecture %s", protonBasePath, wineArch)
+	return "", false
 }
 
 func getWineArch(appCfg config.App) string {
@@ -0,0 +1,103 @@
+package command
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"yapl/internal/fs"
+	"yapl/internal/registry"
+)
+
+// skeleton holds minimal win64 hives: enough for Wine to start simple programs, without
+// the COM registrations, fonts and fake DLLs wineboot installs.
+//
+//go:embed skeleton/*.reg
+var skeleton embed.FS
+
+// initializeFast creates a win64 prefix with file operations alone instead of booting
+// Wine: it copies the default prefix the Proton build ships, or else writes yapl's
+// skeleton hives and directories. It reports false when neither is possible, so the
+// caller can fall back to wineboot.
+func initializeFast(absPrefix, protonBasePath string) (bool, error) {
+	for _, dir := range []string{"files", "dist"} {
+		template := filepath.Join(protonBasePath, dir, "share", "default_pfx")
+		hive, err := registry.Load(filepath.Join(template, registry.SystemFile))
+		if err != nil || hive.Arch() != "win64" {
+			continue
+		}
+		fmt.Println("-> Copying Proton's default prefix...")
+		if err := fs.CopyDir(template, absPrefix); err != nil {
+			return false, err
+		}
+		return true, os.Symlink(".", filepath.Join(absPrefix, "pfx"))
+	}
+
+	// Wine updates a prefix older than its wine.inf on first start, which is the slow
+	// part of wineboot. Recording the file's time marks the skeleton as current.
+	var infTime time.Time
+	for _, dir := range []string{"files", "dist", ""} {
+		if info, err := os.Stat(filepath.Join(protonBasePath, dir, "share", "wine", "wine.inf")); err == nil {
+			infTime = info.ModTime()
+			break
+		}
+	}
+	if infTime.IsZero() {
+		return false, nil
+	}
+
+	fmt.Println("-> Writing skeleton prefix...")
+	for _, dir := range []string{
+		"drive_c/windows/system32", "drive_c/windows/syswow64", "drive_c/windows/temp",
+		"drive_c/Program Files/Common Files", "drive_c/Program Files (x86)", "drive_c/ProgramData",
+		"drive_c/users/steamuser", "drive_c/users/Public", "dosdevices",
+	} {
+		if err := os.MkdirAll(filepath.Join(absPrefix, dir), 0755); err != nil {
+			return false, err
+		}
+	}
+	for name, target := range map[string]string{"dosdevices/c:": "../drive_c", "dosdevices/z:": "/", "pfx": "."} {
+		if err := os.Symlink(target, filepath.Join(absPrefix, name)); err != nil {
+			return false, err
+		}
+	}
+
+	now := time.Unix(time.Now().Unix(), 0)
+	for _, name := range []string{registry.SystemFile, registry.UserFile, "userdef.reg"} {
+		f, err := skeleton.Open("skeleton/" + name)
+		if err != nil {
+			return false, err
+		}
+		hive, err := registry.Parse(f)
+		f.Close()
+		if err != nil {
+			return false, fmt.Errorf("skeleton %s: %w", name, err)
+		}
+		for _, key := range hive.Keys {
+			key.Modified = now
+		}
+		if err := hive.Save(filepath.Join(absPrefix, name)); err != nil {
+			return false, err
+		}
+	}
+	stamp := strconv.FormatInt(infTime.Unix(), 10) + "\n"
+	return true, os.WriteFile(filepath.Join(absPrefix, ".update-timestamp"), []byte(stamp), 0644)
+}
+
+// clearPrefix removes what a failed initialization left in the prefix directory, keeping
+// the directory itself, which may be a link to a prefix elsewhere.
+func clearPrefix(absPrefix string) error {
+	entries, err := os.ReadDir(absPrefix)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(absPrefix, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package command
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"yapl/internal/config"
+)
+
+// pciID matches a "vendor:device" pair as printed by lspci -nn, e.g. "10de:1f91".
+var pciID = regexp.MustCompile(`^[0-9a-fA-F]{4}:[0-9a-fA-F]{4}$`)
+
+// gpuEnv picks the GPU the game renders on, for laptops with hybrid graphics and desktops
+// with more than one card. The gpu option is one of:
+//
+//	"nvidia-prime"   NVIDIA's PRIME render offload
+//	"discrete"       Mesa's PRIME offload to the other GPU
+//	"integrated"     The GPU driving the display
+//	"1"              The Nth GPU as Mesa numbers them
+//	"10de:1f91"      A Vulkan device by its PCI vendor and device ID
+//	"/path/icd.json" A specific Vulkan driver
+func gpuEnv(appCfg config.App) []string {
+	gpu := appCfg.GPU
+	switch {
+	case gpu == "":
+		return nil
+	case gpu == "nvidia-prime":
+		return []string{
+			"__NV_PRIME_RENDER_OFFLOAD=1",
+			"__GLX_VENDOR_LIBRARY_NAME=nvidia",
+			"__VK_LAYER_NV_optimus=NVIDIA_only",
+		}
+	case gpu == "discrete":
+		return []string{"DRI_PRIME=1"}
+	case gpu == "integrated":
+		return []string{"DRI_PRIME=0"}
+	case pciID.MatchString(gpu):
+		return []string{"MESA_VK_DEVICE_SELECT=" + strings.ToLower(gpu), "DRI_PRIME=" + strings.ToLower(gpu)}
+	case strings.HasSuffix(gpu, ".json"):
+		if _, err := os.Stat(gpu); err != nil {
+			log.Printf("⚠️  Warning: Vulkan ICD file '%s' not found, using the default GPU.", gpu)
+			return nil
+		}
+		// VK_DRIVER_FILES replaces VK_ICD_FILENAMES in newer Vulkan loaders.
+		return []string{"VK_ICD_FILENAMES=" + gpu, "VK_DRIVER_FILES=" + gpu}
+	}
+	if n, err := strconv.Atoi(gpu); err == nil && n >= 0 {
+		return []string{"DRI_PRIME=" + gpu}
+	}
+	log.Printf("⚠️  Warning: Unknown gpu '%s'. Use 'nvidia-prime', 'discrete', 'integrated', a device index, a vendor:device ID, or an ICD file.", gpu)
+	return nil
+}
@@ -0,0 +1,100 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"yapl/internal/config"
+	"yapl/internal/fs"
+)
+
+// KillPrefix stops the wineserver of a prefix, which makes all Wine processes using it exit.
+// With force, any process still running from the last launch or using the prefix is SIGKILLed.
+func KillPrefix(prefixPath, pidPath string, appCfg config.App, globalCfg config.Global, force bool) error {
+	absPrefix := fs.MustGetAbsolutePath(prefixPath)
+	protonVersionInfo := getProtonInfo(appCfg, globalCfg)
+	wineArch := getWineArch(appCfg)
+	protonBasePath, _ := filepath.Abs(getProtonPath(appCfg.ProtonVersion, protonVersionInfo, wineArch))
+
+	if wineserverPath, ok := findProtonBinary(protonBasePath, "wineserver"); ok {
+		fmt.Printf("-> Stopping wineserver for prefix %s...\n", absPrefix)
+		cmd := exec.Command(wineserverPath, "-k")
+		cmd.Env = buildProtonEnv(absPrefix, protonBasePath, appCfg, protonVersionInfo, false)
+		if err := cmd.Run(); err != nil {
+			fmt.Println("-> No running wineserver found.")
+		}
+	} else {
+		log.Printf("⚠️  Could not find wineserver in %s", protonBasePath)
+	}
+
+	if !force {
+		return nil
+	}
+
+	// The PID file may be left over from an earlier run and name a reused PID, so the
+	// launched process group is only killed while its leader still uses this prefix.
+	leader := 0
+	if data, err := os.ReadFile(pidPath); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && usesPrefix(pid, absPrefix) {
+			leader = pid
+		}
+		os.Remove(pidPath)
+	}
+	if leader > 0 && syscall.Kill(-leader, syscall.SIGKILL) == nil {
+		fmt.Printf("-> Killed the process group of the last launch (PID %d).\n", leader)
+	}
+
+	killed := 0
+	for _, pid := range prefixProcesses(absPrefix) {
+		if pid == os.Getpid() || pid == leader {
+			continue
+		}
+		if err := syscall.Kill(pid, syscall.SIGKILL); err == nil {
+			killed++
+		}
+	}
+	fmt.Printf("-> Killed %d remaining process(es).\n", killed)
+	return nil
+}
+
+// PrefixInUse reports whether any process is running with WINEPREFIX set to the prefix.
+func PrefixInUse(prefixPath string) bool {
+	return len(prefixProcesses(fs.MustGetAbsolutePath(prefixPath))) > 0
+}
+
+// prefixProcesses returns the PIDs of all processes whose environment points WINEPREFIX at absPrefix.
+func prefixProcesses(absPrefix string) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	var pids []int
+	for _, entry := range entries {
+		if pid, err := strconv.Atoi(entry.Name()); err == nil && usesPrefix(pid, absPrefix) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// usesPrefix reports whether the environment of process pid points WINEPREFIX at absPrefix.
+func usesPrefix(pid int, absPrefix string) bool {
+	environ, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "environ"))
+	if err != nil {
+		return false // Process exited or belongs to another user
+	}
+	needle := []byte("WINEPREFIX=" + absPrefix)
+	for _, kv := range bytes.Split(environ, []byte{0}) {
+		if bytes.Equal(kv, needle) {
+			return true
+		}
+	}
+	return false
+}
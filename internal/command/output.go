@@ -0,0 +1,64 @@
+package command
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// gameStdout and gameStderr receive the output of launched programs; nil means yapl's
+// own stdout and stderr.
+var gameStdout, gameStderr io.Writer
+
+// SetGameOutput sends the output of launched programs to stdout and stderr instead of
+// yapl's own, so it can be kept apart from yapl's messages.
+func SetGameOutput(stdout, stderr io.Writer) {
+	gameStdout, gameStderr = stdout, stderr
+}
+
+// gameOutput returns where launched programs write to.
+func gameOutput() (io.Writer, io.Writer) {
+	stdout, stderr := gameStdout, gameStderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	return stdout, stderr
+}
+
+// PrefixLines returns a writer that starts every line written to w with prefix, to tell
+// a program's lines from yapl's where both go to the same stream. It must not be shared
+// between goroutines.
+func PrefixLines(w io.Writer, prefix string) io.Writer {
+	return &prefixWriter{w: w, prefix: []byte(prefix)}
+}
+
+type prefixWriter struct {
+	w       io.Writer
+	prefix  []byte
+	midLine bool // The last write did not end its line
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	var buf []byte
+	for rest := b; len(rest) > 0; {
+		if !p.midLine {
+			buf = append(buf, p.prefix...)
+		}
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			buf = append(buf, rest...)
+			p.midLine = true
+			break
+		}
+		buf = append(buf, rest[:i+1]...)
+		p.midLine = false
+		rest = rest[i+1:]
+	}
+	if _, err := p.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,64 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"yapl/internal/config"
+)
+
+var placeholder = regexp.MustCompile(`\$\{([A-Z_]+)\}`)
+
+// expandPlaceholders replaces ${PREFIX}, ${GAME_DIR}, ${PROTON_PATH} and ${HOME} in the
+// executable, launch arguments and environment variables with this machine's paths, so a
+// config does not have to hardcode them. Other ${...} sequences are left as they are.
+func expandPlaceholders(appCfg config.App, absPrefix, protonBasePath string) config.App {
+	home, _ := os.UserHomeDir()
+	vars := map[string]string{
+		"PREFIX":      absPrefix,
+		"GAME_DIR":    filepath.Dir(absPrefix),
+		"PROTON_PATH": protonBasePath,
+		"HOME":        home,
+	}
+	expand := func(s string) string {
+		return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+			if v, ok := vars[m[2:len(m)-1]]; ok {
+				return v
+			}
+			return m
+		})
+	}
+	expandAll := func(values []string) []string {
+		if values == nil {
+			return nil
+		}
+		expanded := make([]string, len(values))
+		for i, v := range values {
+			expanded[i] = expand(v)
+		}
+		return expanded
+	}
+
+	// The slices and maps are copied, as they are shared with the caller's config.
+	appCfg.Executable = expand(appCfg.Executable)
+	appCfg.LaunchArgs = expandAll(appCfg.LaunchArgs)
+	appCfg.UMUOptions.LaunchArgs = expandAll(appCfg.UMUOptions.LaunchArgs)
+	if appCfg.EnvironmentVars != nil {
+		env := make(map[string]string, len(appCfg.EnvironmentVars))
+		for k, v := range appCfg.EnvironmentVars {
+			env[k] = expand(v)
+		}
+		appCfg.EnvironmentVars = env
+	}
+	return appCfg
+}
+
+// executablePath returns the executable to launch: relative paths are inside the prefix,
+// absolute ones (e.g. from ${GAME_DIR}) are used as they are.
+func executablePath(absPrefix, executable string) string {
+	if filepath.IsAbs(executable) {
+		return executable
+	}
+	return filepath.Join(absPrefix, executable)
+}
@@ -0,0 +1,123 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"yapl/internal/config"
+)
+
+// ProtonBuild describes what an installed Proton or Wine build actually contains.
+type ProtonBuild struct {
+	Name          string
+	Dir           string
+	ProtonVersion string // From the build's "version" file, if it has one
+	WineVersion   string // Output of "wine --version"
+	Staging       bool   // Built with the wine-staging patches
+	WoW64         string // "new" (32-bit apps without 32-bit Unix libraries), "classic" or "64-bit only"
+	DXVK          string
+	VKD3D         string
+}
+
+// dllVersion matches the version strings DXVK and VKD3D-Proton compile into their DLLs,
+// e.g. "v2.3.1" or "v2.3-42-g1a2b3c4", as a NUL-terminated string.
+var dllVersion = regexp.MustCompile(`\x00(v\d+\.\d+(?:\.\d+)?(?:-\d+-g[0-9a-f]{7,})?)\x00`)
+
+// InspectProton reports the Wine version, build flags and bundled translation layers of
+// an installed Proton version from runner.json.
+func InspectProton(name string, globalCfg config.Global) (ProtonBuild, error) {
+	vinfo, ok := globalCfg.ProtonVersions[name]
+	if !ok {
+		return ProtonBuild{}, fmt.Errorf("proton version '%s' not defined in runner.json", name)
+	}
+	dir := getProtonPath(name, vinfo, "win64")
+	if _, err := os.Stat(dir); err != nil {
+		return ProtonBuild{}, fmt.Errorf("proton version '%s' is not installed (run 'setup' for a game that uses it)", name)
+	}
+
+	build := ProtonBuild{Name: name, Dir: dir}
+	if data, err := os.ReadFile(filepath.Join(dir, "version")); err == nil {
+		// Proton's version file is "<build timestamp> <name>".
+		fields := strings.Fields(string(data))
+		build.ProtonVersion = strings.Join(fields[min(1, len(fields)):], " ")
+	}
+
+	wine, ok := findProtonBinary(dir, "wine64", "wine")
+	if !ok {
+		return build, fmt.Errorf("could not find a wine executable in %s", dir)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, wine, "--version").Output()
+	if err != nil {
+		return build, fmt.Errorf("running '%s --version': %w", wine, err)
+	}
+	build.WineVersion = strings.TrimSpace(string(out))
+	build.Staging = strings.Contains(strings.ToLower(build.WineVersion), "staging")
+
+	// Older builds keep 32-bit Wine in lib/ and 64-bit Wine in lib64/; newer ones have
+	// per-architecture directories under lib/wine/.
+	root := filepath.Dir(filepath.Dir(wine))
+	libDir := filepath.Join(root, "lib", "wine")
+	switch {
+	case dirExists(filepath.Join(libDir, "i386-unix")), dirExists(filepath.Join(root, "lib64", "wine")) && dirExists(libDir):
+		build.WoW64 = "classic"
+	case dirExists(filepath.Join(libDir, "i386-windows")):
+		build.WoW64 = "new"
+	default:
+		build.WoW64 = "64-bit only"
+	}
+
+	build.DXVK = bundledVersion(dir, "dxvk", "d3d11.dll")
+	build.VKD3D = bundledVersion(dir, "vkd3d", "d3d12.dll", "d3d12core.dll")
+	return build, nil
+}
+
+// bundledVersion finds a DLL shipped in a directory named after the component (Proton
+// keeps DXVK in lib/wine/dxvk/, for example) and reads its version, preferring a
+// "version" file next to it.
+func bundledVersion(protonDir, component string, dlls ...string) string {
+	var found []string
+	filepath.WalkDir(protonDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.Contains(filepath.Dir(path), component) {
+			return nil
+		}
+		for _, dll := range dlls {
+			if d.Name() == dll {
+				found = append(found, path)
+			}
+		}
+		return nil
+	})
+	if len(found) == 0 {
+		return ""
+	}
+	for _, path := range found {
+		for _, dir := range []string{filepath.Dir(path), filepath.Dir(filepath.Dir(path))} {
+			if data, err := os.ReadFile(filepath.Join(dir, "version")); err == nil {
+				if v := strings.TrimSpace(string(data)); v != "" {
+					return v
+				}
+			}
+		}
+		if data, err := os.ReadFile(path); err == nil {
+			if m := dllVersion.FindSubmatch(data); m != nil {
+				return string(bytes.TrimSpace(m[1]))
+			}
+		}
+	}
+	return "unknown version"
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
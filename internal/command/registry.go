@@ -0,0 +1,108 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"yapl/internal/config"
+	"yapl/internal/fs"
+	"yapl/internal/registry"
+)
+
+// ApplyRegistry writes the config's windows_version and registry values into the prefix's
+// hives. Hives that already hold them are left alone, so this is cheap on every launch.
+// Nothing is written while Wine runs in the prefix, since its wineserver would overwrite
+// the hives again when it exits.
+func ApplyRegistry(prefixPath string, appCfg config.App) error {
+	settings := map[string]map[string]string{}
+	for key, values := range appCfg.Registry {
+		settings[key] = values
+	}
+	if appCfg.WindowsVersion != "" {
+		wine := map[string]string{"Version": appCfg.WindowsVersion}
+		for name, value := range settings[`HKCU\Software\Wine`] {
+			wine[name] = value
+		}
+		settings[`HKCU\Software\Wine`] = wine
+	}
+	if len(settings) == 0 {
+		return nil
+	}
+
+	absPrefix := fs.MustGetAbsolutePath(prefixPath)
+	hives := map[string]*registry.Hive{}
+	changed := map[string]bool{}
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, keyPath := range keys {
+		file, name, err := registry.SplitPath(keyPath)
+		if err != nil {
+			return err
+		}
+		hive := hives[file]
+		if hive == nil {
+			if hive, err = registry.Load(filepath.Join(absPrefix, file)); err != nil {
+				return err
+			}
+			hives[file] = hive
+		}
+		names := make([]string, 0, len(settings[keyPath]))
+		for valueName := range settings[keyPath] {
+			names = append(names, valueName)
+		}
+		sort.Strings(names)
+		for _, valueName := range names {
+			data, remove := registryData(settings[keyPath][valueName])
+			key := hive.Key(name)
+			if remove {
+				if key != nil && key.Delete(valueName) {
+					changed[file] = true
+				}
+				continue
+			}
+			if key != nil {
+				if v := key.Value(valueName); v != nil && v.Data == data {
+					continue
+				}
+			}
+			hive.CreateKey(name).Set(valueName, data)
+			changed[file] = true
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	if len(prefixProcesses(absPrefix)) > 0 {
+		log.Printf("⚠️  Wine is running in %s; registry settings will be applied on the next launch after it exits ('kill' stops it now).", absPrefix)
+		return nil
+	}
+	for file := range changed {
+		if err := hives[file].Save(filepath.Join(absPrefix, file)); err != nil {
+			return fmt.Errorf("writing %s: %w", file, err)
+		}
+	}
+	fmt.Println("-> Applied registry settings to the prefix.")
+	return nil
+}
+
+// registryData converts a value from a config's registry block to Wine's notation: "-"
+// removes the value, "dword:", "hex" and "str(" values are taken as written and anything
+// else is stored as a string.
+func registryData(value string) (string, bool) {
+	if value == "-" {
+		return "", true
+	}
+	for _, prefix := range []string{"dword:", "hex:", "hex(", "str("} {
+		if strings.HasPrefix(value, prefix) {
+			return value, false
+		}
+	}
+	return registry.Quote(value), false
+}
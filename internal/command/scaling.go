@@ -0,0 +1,79 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"yapl/internal/config"
+)
+
+// fsrModes are Proton's FSR presets and the factor by which each divides the output
+// resolution.
+var fsrModes = map[string]float64{
+	"ultra":       1.3,
+	"quality":     1.5,
+	"balanced":    1.7,
+	"performance": 2.0,
+}
+
+// scalingEnv enables Proton's fullscreen FSR. With gamescope the upscaling happens there
+// instead (see gamescopeArgs), so the game is not upscaled twice.
+func scalingEnv(appCfg config.App) []string {
+	sc := appCfg.Scaling
+	if sc == nil || !sc.FSR || (appCfg.Gamescope != nil && appCfg.Gamescope.Enabled) {
+		return nil
+	}
+	env := []string{"WINE_FULLSCREEN_FSR=1"}
+	if sharpness, ok := fsrSharpness(sc); ok {
+		env = append(env, "WINE_FULLSCREEN_FSR_STRENGTH="+strconv.Itoa(sharpness))
+	}
+	if sc.Width > 0 && sc.Height > 0 {
+		env = append(env, fmt.Sprintf("WINE_FULLSCREEN_FSR_CUSTOM_MODE=%dx%d", sc.Width, sc.Height))
+	} else if sc.Mode != "" {
+		if _, ok := fsrModes[sc.Mode]; ok {
+			env = append(env, "WINE_FULLSCREEN_FSR_MODE="+sc.Mode)
+		} else {
+			log.Printf("⚠️  Warning: Unknown scaling mode '%s'. Use 'ultra', 'quality', 'balanced', or 'performance'.", sc.Mode)
+		}
+	}
+	return env
+}
+
+// fsrSharpness returns the configured sharpness if it is set and valid.
+func fsrSharpness(sc *config.Scaling) (int, bool) {
+	if sc.Sharpness == nil {
+		return 0, false
+	}
+	if *sc.Sharpness < 0 || *sc.Sharpness > 5 {
+		log.Printf("⚠️  Warning: Scaling sharpness %d is out of range (0-5), using the default.", *sc.Sharpness)
+		return 0, false
+	}
+	return *sc.Sharpness, true
+}
+
+// gamescopeScalingArgs translates the scaling block into gamescope's FSR filter and, if
+// gamescope has no game resolution of its own, the render resolution.
+func gamescopeScalingArgs(gs *config.Gamescope, sc *config.Scaling) []string {
+	if sc == nil || !sc.FSR {
+		return nil
+	}
+	var args []string
+	if gs.Upscaler == "" {
+		args = append(args, "-F", "fsr")
+	}
+	if sharpness, ok := fsrSharpness(sc); ok {
+		args = append(args, "--sharpness", strconv.Itoa(sharpness))
+	}
+	if gs.GameWidth > 0 || gs.GameHeight > 0 {
+		return args
+	}
+	width, height := sc.Width, sc.Height
+	if factor, ok := fsrModes[sc.Mode]; ok && width == 0 && height == 0 && gs.Width > 0 && gs.Height > 0 {
+		width, height = int(float64(gs.Width)/factor), int(float64(gs.Height)/factor)
+	}
+	if width > 0 && height > 0 {
+		args = append(args, "-w", strconv.Itoa(width), "-h", strconv.Itoa(height))
+	}
+	return args
+}
@@ -0,0 +1,69 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"yapl/internal/config"
+)
+
+// Container launches need bwrap, which needs unprivileged user namespaces. Some kernels,
+// hardened distros and containers block them, and pressure-vessel then fails with an
+// error that does not say why.
+
+var userNamespaceErr error
+var userNamespaceChecked bool
+
+// checkUserNamespaces reports why the runtime container cannot start on this host, or nil.
+// The runtime's own requirements check is used when runtimeDir ships one.
+func checkUserNamespaces(runtimeDir string) error {
+	if userNamespaceChecked {
+		return userNamespaceErr
+	}
+	userNamespaceChecked = true
+
+	tool := filepath.Join(runtimeDir, "pressure-vessel", "bin", "steam-runtime-check-requirements")
+	if _, err := os.Stat(tool); runtimeDir != "" && err == nil {
+		if out, err := exec.Command(tool).CombinedOutput(); err != nil {
+			userNamespaceErr = fmt.Errorf("the runtime's requirements check failed: %s", strings.TrimSpace(string(out)))
+		}
+		return userNamespaceErr
+	}
+
+	// Otherwise try to start a process in a new user namespace ourselves.
+	probe := exec.Command("/bin/true")
+	probe.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  syscall.CLONE_NEWUSER,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+	}
+	if err := probe.Run(); err != nil && !errors.Is(err, exec.ErrNotFound) && !os.IsNotExist(err) {
+		userNamespaceErr = fmt.Errorf("unprivileged user namespaces are not available: %w", err)
+	}
+	return userNamespaceErr
+}
+
+// containerFallback decides what to do when the runtime container cannot start: unless
+// the config sets container_fallback to "never", it warns and reports that the game
+// should be launched directly instead.
+func containerFallback(appCfg config.App, runtimeDir string) (bool, error) {
+	err := checkUserNamespaces(runtimeDir)
+	if err == nil {
+		return false, nil
+	}
+	if appCfg.Fallback != "" && appCfg.Fallback != "direct" && appCfg.Fallback != "never" {
+		return false, fmt.Errorf("invalid container_fallback '%s': must be 'direct' or 'never'", appCfg.Fallback)
+	}
+	if appCfg.Fallback == "never" {
+		return false, fmt.Errorf("cannot start the Steam Linux Runtime container: %w. Allow unprivileged user namespaces (e.g. sysctl kernel.unprivileged_userns_clone=1) or use launch_method 'direct'", err)
+	}
+	log.Printf("⚠️  Warning: Cannot start the Steam Linux Runtime container (%v).", err)
+	log.Printf("⚠️  Falling back to 'direct' mode. Set \"container_fallback\": \"never\" in the config to fail instead.")
+	return true, nil
+}
@@ -0,0 +1,82 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"yapl/internal/config"
+	"yapl/internal/fs"
+)
+
+// warmPersist is how long 'warm' keeps wineserver up when wineserver_persist is not set.
+const warmPersist = 10 * time.Minute
+
+// Warm prepares the prefix for a fast launch. It reads the game's directory, the Proton
+// build and, in container mode, the runtime into the page cache, using up to half of the
+// available memory, then starts the prefix's wineserver and boots Wine so the launch finds
+// its services running.
+func Warm(prefixPath string, appCfg config.App, globalCfg config.Global, debug bool) error {
+	absPrefix := fs.MustGetAbsolutePath(prefixPath)
+	if _, err := os.Stat(filepath.Join(absPrefix, "system.reg")); os.IsNotExist(err) {
+		return fmt.Errorf("no Wine prefix found at %s. Run 'setup' first", absPrefix)
+	}
+	protonVersionInfo := getProtonInfo(appCfg, globalCfg)
+	wineArch := getWineArch(appCfg)
+	protonBasePath, _ := filepath.Abs(getProtonPath(appCfg.ProtonVersion, protonVersionInfo, wineArch))
+	appCfg = expandPlaceholders(appCfg, absPrefix, protonBasePath)
+	method := appCfg.LaunchMethod
+	if method == "" {
+		method = "container"
+	}
+
+	dirs := []string{filepath.Dir(executablePath(absPrefix, appCfg.Executable)), protonBasePath}
+	if method == "container" && appCfg.RuntimeVersion != "" {
+		runtimeDir := config.RuntimeDir(appCfg)
+		if resolved, err := filepath.EvalSymlinks(runtimeDir); err == nil {
+			runtimeDir = resolved
+		}
+		dirs = append(dirs, runtimeDir)
+	}
+	budget := fs.MemAvailable() / 2
+	if budget == 0 {
+		budget = 2 << 30
+	}
+	fmt.Printf("-> Reading the files the launch needs into memory (up to %d MiB)...\n", budget>>20)
+	read, files, err := fs.Readahead(dirs, budget)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("-> Cached %d files (%d MiB).\n", files, read>>20)
+
+	persist, err := config.PersistDuration(appCfg)
+	if err != nil {
+		return err
+	}
+	if persist == 0 {
+		if method != "direct" {
+			// Without wineserver_persist the proton script waits for wineserver to exit
+			// before launching, so a wineserver started here would hold up the launch.
+			log.Printf("⚠️  Set 'wineserver_persist' to also keep wineserver running until the launch in '%s' mode.", method)
+			return nil
+		}
+		appCfg.ServerPersist = warmPersist.String()
+	}
+	if err := startPersistentServer(absPrefix, protonBasePath, appCfg, protonVersionInfo, debug); err != nil {
+		return err
+	}
+	wineExecutablePath, err := getWineExecutablePath(protonBasePath, wineArch)
+	if err != nil {
+		return err
+	}
+	fmt.Println("-> Booting Wine in the prefix...")
+	cmd := exec.Command(wineExecutablePath, "wineboot")
+	cmd.Env = buildProtonEnv(absPrefix, protonBasePath, appCfg, protonVersionInfo, debug)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("wineboot failed: %w\n%s", err, output)
+	}
+	return nil
+}
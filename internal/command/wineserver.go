@@ -0,0 +1,47 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"yapl/internal/config"
+)
+
+// startPersistentServer starts the prefix's wineserver ahead of the launch with
+// wineserver_persist as its idle timeout, so it keeps the prefix booted after the game
+// exits and the next launch or tool skips Wine's startup. It does nothing when
+// wineserver_persist is unset. A wineserver already running for the prefix is left as it
+// is; the new one sees that and exits.
+func startPersistentServer(absPrefix, protonBasePath string, appCfg config.App, vinfo config.VersionInfo, debug bool) error {
+	persist, err := config.PersistDuration(appCfg)
+	if err != nil || persist == 0 {
+		return err
+	}
+	wineserverPath, ok := findProtonBinary(protonBasePath, "wineserver")
+	if !ok {
+		log.Printf("⚠️  Could not find wineserver in %s; wineserver_persist is ignored.", protonBasePath)
+		return nil
+	}
+	seconds := int((persist + time.Second - 1) / time.Second)
+	cmd := exec.Command(wineserverPath, "-p"+strconv.Itoa(seconds))
+	cmd.Env = buildProtonEnv(absPrefix, protonBasePath, appCfg, vinfo, debug)
+	// wineserver forks into the background once it listens, so this returns right away.
+	// It exits with an error when another wineserver already serves the prefix.
+	if err := cmd.Run(); err == nil {
+		fmt.Printf("-> Started wineserver; it stays up for %s after the last program exits ('yapl kill' stops it).\n", persist)
+	}
+	return nil
+}
+
+// launchVerb returns the proton script verb to launch with. waitforexitandrun first waits
+// for the prefix's wineserver to exit, which a persistent one only does after its idle
+// timeout, so the plain run verb is used then.
+func launchVerb(appCfg config.App) string {
+	if persist, _ := config.PersistDuration(appCfg); persist > 0 {
+		return "run"
+	}
+	return "waitforexitandrun"
+}
@@ -0,0 +1,143 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"yapl/internal/config"
+	"yapl/internal/fs"
+)
+
+// wrapLaunch prefixes the game's command line with the configured wrapper programs. The
+// first wrapper is the outermost one, so it also wraps the runtime container: gamescope
+// has to run on the host to present the game's window. The config's wrapper_commands come
+// last, directly in front of the game's command, in the order they are listed.
+func wrapLaunch(cmd *exec.Cmd, appCfg config.App) (*exec.Cmd, error) {
+	var wrappers [][]string
+	if gs := appCfg.Gamescope; gs != nil && gs.Enabled {
+		args, err := gamescopeArgs(gs, appCfg.Scaling)
+		if err != nil {
+			return nil, err
+		}
+		wrappers = append(wrappers, args)
+	}
+	if appCfg.GameMode {
+		// gamemode is only an optimisation, so the game still starts without it.
+		if _, err := exec.LookPath("gamemoderun"); err != nil {
+			log.Printf("⚠️  Warning: 'gamemode' is enabled but gamemoderun is not installed; launching without it.")
+		} else {
+			wrappers = append(wrappers, []string{"gamemoderun"})
+		}
+	}
+	if appCfg.MangoHud {
+		if args := enableMangoHud(cmd, appCfg); args != nil {
+			wrappers = append(wrappers, args)
+		}
+	}
+	for _, wrapper := range appCfg.WrapperCommands {
+		if args := strings.Fields(wrapper); len(args) > 0 {
+			wrappers = append(wrappers, args)
+		}
+	}
+	if len(wrappers) == 0 {
+		return cmd, nil
+	}
+
+	var argv []string
+	for _, args := range wrappers {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			return nil, fmt.Errorf("'%s' is enabled in the config but not installed", args[0])
+		}
+		argv = append(argv, args...)
+	}
+	wrapped := exec.Command(argv[0], append(argv[1:], cmd.Args...)...)
+	wrapped.Args[len(argv)] = cmd.Path // cmd.Args[0] may be a bare name
+	wrapped.Env = cmd.Env
+	wrapped.Dir = cmd.Dir
+	return wrapped, nil
+}
+
+// enableMangoHud points MangoHud at the game's generated config and returns the wrapper
+// needed to load it. In direct mode the mangohud script preloads the overlay (which also
+// covers OpenGL games); in the runtime container the host's Vulkan layer is picked up by
+// pressure-vessel and only needs MANGOHUD=1.
+func enableMangoHud(cmd *exec.Cmd, appCfg config.App) []string {
+	if len(appCfg.MangoHudConfig) > 0 && mangoHudConfig != "" {
+		if err := writeMangoHudConfig(mangoHudConfig, appCfg.MangoHudConfig); err != nil {
+			log.Printf("⚠️  Warning: Failed to write MangoHud config: %v", err)
+		} else {
+			cmd.Env = append(cmd.Env, "MANGOHUD_CONFIGFILE="+fs.MustGetAbsolutePath(mangoHudConfig))
+		}
+	}
+	if appCfg.LaunchMethod == "direct" {
+		return []string{"mangohud"}
+	}
+	cmd.Env = append(cmd.Env, "MANGOHUD=1")
+	return nil
+}
+
+// writeMangoHudConfig writes options in MangoHud's "key=value" format, one per line and
+// sorted so the file only changes when the config does.
+func writeMangoHudConfig(path string, options map[string]string) error {
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("# Generated by yapl from mangohud_config; edits will be overwritten.\n")
+	for _, key := range keys {
+		if options[key] == "" {
+			b.WriteString(key + "\n")
+		} else {
+			b.WriteString(key + "=" + options[key] + "\n")
+		}
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// gamescopeArgs builds the gamescope command line, ending with the "--" separator.
+func gamescopeArgs(gs *config.Gamescope, sc *config.Scaling) ([]string, error) {
+	args := []string{"gamescope"}
+	addSize := func(wFlag, hFlag string, w, h int) {
+		if w > 0 {
+			args = append(args, wFlag, strconv.Itoa(w))
+		}
+		if h > 0 {
+			args = append(args, hFlag, strconv.Itoa(h))
+		}
+	}
+	addSize("-W", "-H", gs.Width, gs.Height)
+	addSize("-w", "-h", gs.GameWidth, gs.GameHeight)
+	if gs.Refresh > 0 {
+		args = append(args, "-r", strconv.Itoa(gs.Refresh))
+	}
+
+	switch gs.Mode {
+	case "", "windowed":
+	case "fullscreen":
+		args = append(args, "-f")
+	case "borderless":
+		args = append(args, "-b")
+	default:
+		return nil, fmt.Errorf("unknown gamescope mode '%s'. Use 'fullscreen', 'borderless', or 'windowed'", gs.Mode)
+	}
+
+	switch gs.Upscaler {
+	case "":
+	case "fsr", "nis", "linear", "nearest", "pixel":
+		args = append(args, "-F", gs.Upscaler)
+	default:
+		return nil, fmt.Errorf("unknown gamescope upscaler '%s'. Use 'fsr', 'nis', 'linear', 'nearest', or 'pixel'", gs.Upscaler)
+	}
+
+	args = append(args, gamescopeScalingArgs(gs, sc)...)
+	args = append(args, gs.Args...)
+	return append(args, "--"), nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import "runtime"
+
+// HostArch is the machine's architecture in the names runner.json uses.
+func HostArch() string {
+	return archName(runtime.GOARCH)
+}
+
+// archName accepts both Go's and the kernel's names for an architecture.
+func archName(arch string) string {
+	switch arch {
+	case "amd64", "x86-64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	case "386", "i686":
+		return "i386"
+	}
+	return arch
+}
+
+// forHostArch replaces every version with the variant for this machine's architecture.
+func forHostArch(g Global) Global {
+	arch := HostArch()
+	pick := func(versions map[string]VersionInfo) map[string]VersionInfo {
+		if versions == nil {
+			return nil
+		}
+		picked := make(map[string]VersionInfo, len(versions))
+		for name, vinfo := range versions {
+			picked[name] = vinfo.ForArch(arch)
+		}
+		return picked
+	}
+	g.ProtonVersions = pick(g.ProtonVersions)
+	g.RuntimeVersions = pick(g.RuntimeVersions)
+	for dep, versions := range g.DependencyVersions {
+		g.DependencyVersions[dep] = pick(versions)
+	}
+	return g
+}
+
+// ForArch returns the version with the fields its variant for arch sets. Without such a
+// variant a non-x86_64 machine gets the x86_64 build, marked as emulated.
+func (v VersionInfo) ForArch(arch string) VersionInfo {
+	var variant *VersionInfo
+	for name, vinfo := range v.Arch {
+		if archName(name) == archName(arch) {
+			vinfo := vinfo
+			variant = &vinfo
+		}
+	}
+	out := v
+	out.Arch = nil
+	if variant == nil {
+		out.Emulated = archName(arch) != "x86_64" && (v.URL != "" || v.Path != "")
+		return out
+	}
+	// Fields the variant leaves empty are shared with the x86_64 build.
+	if variant.URL != "" || variant.Path != "" {
+		out.URL, out.Path, out.SHA256 = variant.URL, variant.Path, variant.SHA256
+	}
+	if variant.BinPath != "" {
+		out.BinPath = variant.BinPath
+	}
+	if variant.LDLibraryPathComponents != nil {
+		out.LDLibraryPathComponents = variant.LDLibraryPathComponents
+	}
+	if variant.WineDllPathComponents != nil {
+		out.WineDllPathComponents = variant.WineDllPathComponents
+	}
+	if variant.PythonHome != "" {
+		out.PythonHome = variant.PythonHome
+	}
+	if variant.PythonPath != "" {
+		out.PythonPath = variant.PythonPath
+	}
+	if variant.StripComponents != nil {
+		out.StripComponents = variant.StripComponents
+	}
+	return out
+}
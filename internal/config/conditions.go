@@ -0,0 +1,72 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Condition is a block of config that only applies on some machines, so one config can
+// be shared by a user's desktop and handheld.
+type Condition struct {
+	When   When            `json:"when"`
+	Config json.RawMessage `json:"config"` // Any game.json keys; objects are merged, other values replaced
+}
+
+// When selects the machines a condition applies to. Every field that is set must match.
+type When struct {
+	Hostname  string            `json:"hostname,omitempty"`   // Glob, e.g. "steamdeck*"
+	GPUVendor string            `json:"gpu_vendor,omitempty"` // "nvidia", "amd" or "intel"
+	Env       map[string]string `json:"env,omitempty"`        // Variables that must have these values
+}
+
+// gpuVendors maps PCI vendor IDs to the names used in conditions.
+var gpuVendors = map[string]string{"0x10de": "nvidia", "0x1002": "amd", "0x8086": "intel"}
+
+// applyConditions merges the config of every matching condition into cfg, in order.
+func applyConditions(cfg *App) error {
+	conditions := cfg.Conditions
+	for i, c := range conditions {
+		if !c.When.matches() {
+			continue
+		}
+		if err := json.Unmarshal(c.Config, cfg); err != nil {
+			return fmt.Errorf("conditions[%d].config: %w", i, err)
+		}
+	}
+	cfg.Conditions = conditions
+	return nil
+}
+
+func (w When) matches() bool {
+	if w.Hostname != "" {
+		hostname, _ := os.Hostname()
+		if ok, _ := path.Match(strings.ToLower(w.Hostname), strings.ToLower(hostname)); !ok {
+			return false
+		}
+	}
+	if w.GPUVendor != "" && !hasGPUVendor(strings.ToLower(w.GPUVendor)) {
+		return false
+	}
+	for k, v := range w.Env {
+		if os.Getenv(k) != v {
+			return false
+		}
+	}
+	return true
+}
+
+// hasGPUVendor reports whether any GPU in the machine is made by vendor.
+func hasGPUVendor(vendor string) bool {
+	ids, _ := filepath.Glob("/sys/class/drm/card[0-9]*/device/vendor")
+	for _, id := range ids {
+		data, err := os.ReadFile(id)
+		if err == nil && gpuVendors[strings.TrimSpace(string(data))] == vendor {
+			return true
+		}
+	}
+	return false
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"yapl/internal/fs"
+	"yapl/internal/policy"
 )
 
 // --- Configuration Structs ---
@@ -20,12 +23,118 @@ type VersionInfo struct {
 	WineDllPathComponents   []string `json:"wine_dll_path_components,omitempty"`
 	PythonHome              string   `json:"python_home,omitempty"`
 	PythonPath              string   `json:"python_path,omitempty"`
+	Project                 string   `json:"project,omitempty"` // Upstream home page, for 'yapl licenses'
+	// SHA256 is the checksum of the download at URL. Downloads must match it, and only
+	// components with one are fetched from LAN peers.
+	SHA256 string `json:"sha256,omitempty"`
+	// StripComponents is how many leading directories to drop from the archive's paths. By
+	// default the top-level directory is dropped if the archive has a single one.
+	StripComponents *int `json:"strip_components,omitempty"`
+	// Arch overrides the fields above on machines of an architecture ("x86_64",
+	// "aarch64"); the entry itself describes the x86_64 build.
+	Arch map[string]VersionInfo `json:"arch,omitempty"`
+	// Emulated is set when no native build exists for this machine and the x86_64 one
+	// must run through the x86 emulator.
+	Emulated bool `json:"-"`
 }
 
 type Global struct {
 	ProtonVersions     map[string]VersionInfo            `json:"proton_versions"`
 	RuntimeVersions    map[string]VersionInfo            `json:"runtime_versions"`
 	DependencyVersions map[string]map[string]VersionInfo `json:"dependency_versions"`
+	ParentalControls   *policy.ParentalControls          `json:"parental_controls,omitempty"`
+	Library            *Library                          `json:"library,omitempty"`
+	LANPeers           *LANPeers                         `json:"lan_peers,omitempty"`
+	Extraction         *Extraction                       `json:"extraction,omitempty"`
+	Packaging          *Packaging                        `json:"packaging,omitempty"`
+	Downloads          *Downloads                        `json:"downloads,omitempty"`
+	Plugins            map[string][]string               `json:"plugins,omitempty"` // Plugin name to the hooks it runs at
+	Defaults           *Defaults                         `json:"defaults,omitempty"`
+	Telemetry          *Telemetry                        `json:"telemetry,omitempty"`
+	Root               string                            `json:"root,omitempty"` // Storage root, if not next to this file
+	Store              *Store                            `json:"store,omitempty"`
+	Emulator           string                            `json:"x86_emulator,omitempty"` // "fex" or "box64" on non-x86 machines
+	BundleSources      []BundleSource                    `json:"bundle_sources,omitempty"`
+}
+
+// BundleSource is a repository of bundles that 'yapl install' installs from: a signed
+// index made by 'yapl repo index', and the public key that signed it.
+type BundleSource struct {
+	Name      string `json:"name"`
+	URL       string `json:"url"` // The index, or the directory holding it
+	PublicKey string `json:"public_key"`
+}
+
+// Store makes new Proton and dependency installs share identical files through hardlinks.
+type Store struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Telemetry opts in to sending anonymous usage statistics. It is off unless enabled.
+type Telemetry struct {
+	Enabled  bool   `json:"enabled"`
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Defaults are inherited by every game and app config that does not set them itself.
+// Environment variables and DLL overrides are merged, the game's own entries winning.
+type Defaults struct {
+	ProtonVersion   string            `json:"proton_version,omitempty"`
+	RuntimeVersion  string            `json:"runtime_version,omitempty"`
+	LaunchMethod    string            `json:"launch_method,omitempty"`
+	EnvironmentVars map[string]string `json:"environment_vars,omitempty"`
+	DLLOverrides    map[string]string `json:"dll_overrides,omitempty"`
+}
+
+// Downloads configures the download queue shared by all running yapl processes.
+type Downloads struct {
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+	// AllowedHours limits background downloads and update checks to a daily window.
+	AllowedHours string `json:"allowed_hours,omitempty"`
+	// Metered is "defer" (default) to hold them on metered connections, or "allow".
+	Metered string `json:"metered,omitempty"`
+	// UpdateCheckBudget caps how long an update check may delay a launch ("3s").
+	UpdateCheckBudget string `json:"update_check_budget,omitempty"`
+	// UpdateCheckTTL is how long a check that found nothing is not repeated ("6h").
+	UpdateCheckTTL string `json:"update_check_ttl,omitempty"`
+}
+
+// Extraction configures how downloaded and unpackaged archives are written to disk.
+type Extraction struct {
+	ModePolicy    string `json:"mode_policy,omitempty"`
+	Umask         string `json:"umask,omitempty"`
+	CaseConflicts string `json:"case_conflicts,omitempty"`
+	InvalidNames  string `json:"invalid_names,omitempty"`
+	Ownership     string `json:"ownership,omitempty"`
+	Owner         string `json:"owner,omitempty"`
+}
+
+// Packaging configures the bundles created by 'package'.
+type Packaging struct {
+	// Owner is stamped on packaged files as "user:group" (default "nobody:nobody").
+	Owner string `json:"owner,omitempty"`
+	// Levels are the compression levels per format (e.g. {"zst": 19}); --level overrides them.
+	Levels map[string]int `json:"levels,omitempty"`
+}
+
+// PackageOptions configures how 'package' bundles a game or app.
+type PackageOptions struct {
+	// Exclude lists globs to leave out, relative to the game directory or its prefix. Unset,
+	// logs, shader caches and crash dumps are left out; an empty list includes everything.
+	Exclude []string `json:"exclude"`
+}
+
+// LANPeers enables fetching Proton, runtimes and dependencies from other yapl machines on the LAN.
+type LANPeers struct {
+	Enabled bool     `json:"enabled"`
+	Port    int      `json:"port,omitempty"`
+	Listen  string   `json:"listen,omitempty"` // Address 'seed' serves on; all interfaces if unset
+	Allow   []string `json:"allow,omitempty"`  // Addresses or CIDR ranges 'seed' serves; the machine's own subnets if unset
+}
+
+// Library points at a shared, read-only catalog of configs and packages (e.g. on a NAS).
+type Library struct {
+	Path string `json:"path"`
 }
 
 type UMUOptions struct {
@@ -36,6 +145,42 @@ type UMUOptions struct {
 	LaunchArgs      []string `json:"launch_args,omitempty"`
 }
 
+// ContainerOptions are passed to the Steam Linux Runtime in 'container' mode.
+type ContainerOptions struct {
+	EntryPointArgs []string          `json:"entry_point_args,omitempty"` // Before the "--" that ends the entry point's options
+	Environment    map[string]string `json:"environment,omitempty"`      // PRESSURE_VESSEL_* and STEAM_RUNTIME_* variables
+}
+
+// Gamescope runs the game inside the gamescope micro-compositor.
+type Gamescope struct {
+	Enabled bool `json:"enabled"`
+	// Width and Height are the output resolution; GameWidth and GameHeight the resolution
+	// the game renders at, which is upscaled to the output.
+	Width      int `json:"width,omitempty"`
+	Height     int `json:"height,omitempty"`
+	GameWidth  int `json:"game_width,omitempty"`
+	GameHeight int `json:"game_height,omitempty"`
+	Refresh    int `json:"refresh,omitempty"`
+	// Mode is "fullscreen", "borderless" or "windowed" (default).
+	Mode string `json:"mode,omitempty"`
+	// Upscaler is gamescope's filter: "fsr", "nis", "linear", "nearest" or "pixel".
+	Upscaler string   `json:"upscaler,omitempty"`
+	Args     []string `json:"args,omitempty"`
+}
+
+// Scaling upscales the game with AMD FSR 1, through Proton's fullscreen hack or, when
+// gamescope is enabled, through gamescope.
+type Scaling struct {
+	FSR bool `json:"fsr"`
+	// Sharpness ranges from 0 (sharpest) to 5 (softest); Proton's default is 2.
+	Sharpness *int `json:"sharpness,omitempty"`
+	// Mode is a preset render resolution: "ultra", "quality", "balanced" or "performance".
+	Mode string `json:"mode,omitempty"`
+	// Width and Height set the render resolution directly instead of a preset.
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+}
+
 type AppDependencies struct {
 	DXVKVersion        string `json:"dxvk_version,omitempty"`
 	VKD3DVersion       string `json:"vkd3d_version,omitempty"`
@@ -46,18 +191,40 @@ type AppDependencies struct {
 }
 
 type App struct {
+	Extends         string            `json:"extends,omitempty"` // Template config this one builds on
 	ProtonVersion   string            `json:"proton_version"`
 	RuntimeVersion  string            `json:"runtime_version,omitempty"`
+	RuntimeBuildID  string            `json:"runtime_build_id,omitempty"`
 	LaunchMethod    string            `json:"launch_method,omitempty"`
+	Fallback        string            `json:"container_fallback,omitempty"`
 	Executable      string            `json:"executable"`
 	SteamAppID      string            `json:"steam_app_id,omitempty"`
 	WineArch        string            `json:"wine_arch,omitempty"`
+	PrefixInit      string            `json:"prefix_init,omitempty"`        // "wineboot" (default) or "fast"
+	ServerPersist   string            `json:"wineserver_persist,omitempty"` // How long wineserver outlives the game, e.g. "10m"
 	LaunchArgs      []string          `json:"launch_args,omitempty"`
 	Winetricks      []string          `json:"winetricks,omitempty"`
+	SavePaths       []string          `json:"save_paths,omitempty"`
 	UMUOptions      UMUOptions        `json:"umu_options,omitempty"`
+	Container       ContainerOptions  `json:"container_options,omitempty"`
 	Dependencies    AppDependencies   `json:"dependencies"`
 	DLLOverrides    map[string]string `json:"dll_overrides"`
 	EnvironmentVars map[string]string `json:"environment_vars"`
+	Esync           *bool             `json:"esync,omitempty"`
+	Fsync           *bool             `json:"fsync,omitempty"`
+	NTsync          *bool             `json:"ntsync,omitempty"`
+	GPU             string            `json:"gpu,omitempty"`
+	Conditions      []Condition       `json:"conditions,omitempty"`
+	Gamescope       *Gamescope        `json:"gamescope,omitempty"`
+	Scaling         *Scaling          `json:"scaling,omitempty"`
+	GameMode        bool              `json:"gamemode,omitempty"`
+	WrapperCommands []string          `json:"wrapper_commands,omitempty"`
+	MangoHud        bool              `json:"mangohud,omitempty"`
+	MangoHudConfig  map[string]string `json:"mangohud_config,omitempty"`
+	WindowsVersion  string            `json:"windows_version,omitempty"`
+	Package         *PackageOptions   `json:"package,omitempty"`
+	// Registry maps keys (e.g. `HKCU\Software\Wine\Direct3D`) to the values to set in them.
+	Registry map[string]map[string]string `json:"registry,omitempty"`
 }
 
 // --- Loading and Saving Logic ---
@@ -65,6 +232,12 @@ type App struct {
 func LoadOrCreateGlobal(path string) (Global, error) {
 	var g Global
 	err := readJSONFile(path, &g)
+	if err == nil && g.Library != nil && g.Library.Path != "" {
+		g, err = withLibrary(g)
+	}
+	if err == nil {
+		return forHostArch(g), nil
+	}
 	if !os.IsNotExist(err) {
 		return g, err
 	}
@@ -82,18 +255,196 @@ func LoadOrCreateGlobal(path string) (Global, error) {
 	return defaultCfg, nil
 }
 
-func LoadOrCreateApp(appType, appName string, globalCfg Global) (App, error) {
-	appDir := filepath.Join(appType, appName)
+// ConfigPath returns the location of a game's game.json or an app's app.json.
+func ConfigPath(appType, appName string) string {
 	configName := "game.json"
 	if appType == "apps" {
 		configName = "app.json"
 	}
-	configPath := filepath.Join(appDir, configName)
+	return filepath.Join(appType, appName, configName)
+}
+
+// DefaultExecutable is the placeholder executable of new configs.
+const DefaultExecutable = "drive_c/windows/explorer.exe"
+
+// WithDefaults returns cfg with the unset fields taken from d.
+func WithDefaults(cfg App, d *Defaults) App {
+	if d == nil {
+		return cfg
+	}
+	if cfg.ProtonVersion == "" {
+		cfg.ProtonVersion = d.ProtonVersion
+	}
+	if cfg.RuntimeVersion == "" {
+		cfg.RuntimeVersion = d.RuntimeVersion
+	}
+	if cfg.LaunchMethod == "" {
+		cfg.LaunchMethod = d.LaunchMethod
+	}
+	cfg.EnvironmentVars = mergeStrings(d.EnvironmentVars, cfg.EnvironmentVars)
+	cfg.DLLOverrides = mergeStrings(d.DLLOverrides, cfg.DLLOverrides)
+	return cfg
+}
+
+func mergeStrings(base, override map[string]string) map[string]string {
+	if len(base) == 0 {
+		return override
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+// LoadApp reads an existing game or app config without creating a default one, with the
+// runner.json defaults d filled in.
+func LoadApp(appType, appName string, d *Defaults) (App, error) {
+	cfg, err := readApp(ConfigPath(appType, appName))
+	return WithDefaults(cfg, d), err
+}
+
+// LocalConfigPath returns the machine's own overrides of a config, e.g. game.local.json,
+// which are merged over it and never packaged or shared.
+func LocalConfigPath(configPath string) string {
+	return strings.TrimSuffix(configPath, ".json") + ".local.json"
+}
+
+// readApp reads a game or app config on top of the templates it extends, merges its
+// local overrides over it and then its conditions that match this machine.
+func readApp(path string) (App, error) {
+	var cfg App
+	files, err := ConfigFiles(path)
+	if err != nil {
+		return cfg, err
+	}
+	for _, file := range files {
+		if err := readJSONFile(file, &cfg); err != nil {
+			if file == path {
+				return cfg, err
+			}
+			return cfg, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	cfg.Extends = "" // Only meaningful in the file itself
+	err = applyConditions(&cfg)
+	return cfg, err
+}
 
+// ConfigFiles lists the files a config is made of, in the order they are merged: the
+// templates it extends (the most basic first), the config itself and its local overrides.
+func ConfigFiles(path string) ([]string, error) {
+	files := []string{path}
+	seen := map[string]bool{}
+	for current := path; ; {
+		abs, _ := filepath.Abs(current)
+		if seen[abs] {
+			return nil, fmt.Errorf("templates extend each other in a loop at '%s'", current)
+		}
+		seen[abs] = true
+		var head struct {
+			Extends string `json:"extends"`
+		}
+		if err := readJSONFile(current, &head); err != nil {
+			if current == path {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%s: %w", current, err)
+		}
+		if head.Extends == "" {
+			break
+		}
+		base := head.Extends
+		if !filepath.IsAbs(base) {
+			base = filepath.Join(filepath.Dir(current), base)
+		}
+		files = append([]string{base}, files...)
+		current = base
+	}
+	if _, err := os.Stat(LocalConfigPath(path)); err == nil {
+		files = append(files, LocalConfigPath(path))
+	}
+	return files, nil
+}
+
+// UpdateApp applies fn to a game or app config as stored on disk (without the runner.json
+// defaults filled in) and writes the result back.
+func UpdateApp(appType, appName string, fn func(*App)) error {
 	var cfg App
-	err := readJSONFile(configPath, &cfg)
+	if err := readJSONFile(ConfigPath(appType, appName), &cfg); err != nil {
+		return err
+	}
+	fn(&cfg)
+	return writeJSONFile(ConfigPath(appType, appName), cfg)
+}
+
+// InstalledApp is a local game or app with its config.
+type InstalledApp struct {
+	Type   string // "games" or "apps"
+	Name   string
+	Config App
+}
+
+// LoadAll reads the configs of every local game and app with the runner.json defaults d
+// filled in, skipping directories without a readable config.
+func LoadAll(d *Defaults) ([]InstalledApp, error) {
+	var all []InstalledApp
+	for _, appType := range []string{"games", "apps"} {
+		entries, err := os.ReadDir(appType)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			cfg, err := LoadApp(appType, entry.Name(), d)
+			if err != nil {
+				continue
+			}
+			all = append(all, InstalledApp{Type: appType, Name: entry.Name(), Config: cfg})
+		}
+	}
+	return all, nil
+}
+
+// RuntimeSnapshotsDir holds every installed build of each Steam Linux Runtime version.
+const RuntimeSnapshotsDir = "dependencies/runtime-snapshots"
+
+// RuntimeDir returns the Steam Linux Runtime directory a game launches with: the snapshot
+// of its pinned runtime_build_id, or dependencies/runtime/<version>, which links to the
+// newest installed build.
+func RuntimeDir(appCfg App) string {
+	if appCfg.RuntimeBuildID != "" {
+		return filepath.Join(RuntimeSnapshotsDir, appCfg.RuntimeVersion, appCfg.RuntimeBuildID)
+	}
+	return filepath.Join("dependencies", "runtime", appCfg.RuntimeVersion)
+}
+
+// PersistDuration returns how long the game's wineserver outlives it, zero if it exits
+// right away as usual.
+func PersistDuration(appCfg App) (time.Duration, error) {
+	if appCfg.ServerPersist == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(appCfg.ServerPersist)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid wineserver_persist '%s'. Use a duration such as '10m' or '1h'", appCfg.ServerPersist)
+	}
+	return d, nil
+}
+
+func LoadOrCreateApp(appType, appName string, globalCfg Global) (App, error) {
+	appDir := filepath.Join(appType, appName)
+	configPath := ConfigPath(appType, appName)
+	configName := filepath.Base(configPath)
+
+	cfg, err := readApp(configPath)
 	if !os.IsNotExist(err) {
-		return cfg, err // Return on success or any error other than file not found
+		return WithDefaults(cfg, globalCfg.Defaults), err // Return on success or any error other than file not found
 	}
 
 	fmt.Printf("-> No config found. Creating a default '%s' in '%s'...\n", configName, appDir)
@@ -110,16 +461,100 @@ func LoadOrCreateApp(appType, appName string, globalCfg Global) (App, error) {
 	defaultCfg := App{
 		ProtonVersion: firstProton,
 		LaunchMethod:  "direct",
-		Executable:    "drive_c/windows/explorer.exe",
+		Executable:    DefaultExecutable,
 		LaunchArgs:    []string{},
 		Winetricks:    []string{},
 	}
+	// Leave what runner.json sets for every game to runner.json.
+	if d := globalCfg.Defaults; d != nil {
+		if d.ProtonVersion != "" {
+			defaultCfg.ProtonVersion = ""
+		}
+		if d.LaunchMethod != "" {
+			defaultCfg.LaunchMethod = ""
+		}
+	}
 
 	if err := writeJSONFile(configPath, defaultCfg); err != nil {
 		return App{}, err
 	}
 	fmt.Printf("✅ Default %s created.\n", configName)
-	return defaultCfg, nil
+	return WithDefaults(defaultCfg, globalCfg.Defaults), nil
+}
+
+// withLibrary layers the local runner.json over the shared library's runner.json.
+// Local entries win, and the library's file is never written to.
+func withLibrary(local Global) (Global, error) {
+	var shared Global
+	if err := readJSONFile(filepath.Join(local.Library.Path, "runner.json"), &shared); err != nil {
+		if os.IsNotExist(err) {
+			return local, nil
+		}
+		return Global{}, fmt.Errorf("could not read library runner.json: %w", err)
+	}
+
+	merged := local
+	merged.ProtonVersions = mergeVersions(shared.ProtonVersions, local.ProtonVersions)
+	merged.RuntimeVersions = mergeVersions(shared.RuntimeVersions, local.RuntimeVersions)
+	merged.DependencyVersions = make(map[string]map[string]VersionInfo)
+	for name, versions := range shared.DependencyVersions {
+		merged.DependencyVersions[name] = mergeVersions(versions, nil)
+	}
+	for name, versions := range local.DependencyVersions {
+		merged.DependencyVersions[name] = mergeVersions(merged.DependencyVersions[name], versions)
+	}
+	if merged.ParentalControls == nil {
+		merged.ParentalControls = shared.ParentalControls
+	}
+	return merged, nil
+}
+
+func mergeVersions(base, override map[string]VersionInfo) map[string]VersionInfo {
+	merged := make(map[string]VersionInfo, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
+
+// SaveApp writes a game.json or app.json, creating the app directory if needed.
+func SaveApp(appType, appName string, cfg App) error {
+	if err := fs.MustCreateDirectory(filepath.Join(appType, appName)); err != nil {
+		return err
+	}
+	return writeJSONFile(ConfigPath(appType, appName), cfg)
+}
+
+// StorageRoot returns the 'root' a runner.json points at, relative to the file's own
+// directory, or "" if there is no such file or it sets no root.
+func StorageRoot(path string) (string, error) {
+	var g struct {
+		Root string `json:"root"`
+	}
+	if err := readJSONFile(path, &g); err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if g.Root == "" || filepath.IsAbs(g.Root) || strings.HasPrefix(g.Root, "~/") {
+		return g.Root, nil
+	}
+	return filepath.Join(filepath.Dir(path), g.Root), nil
+}
+
+// UpdateGlobal applies fn to the local runner.json as stored on disk (without any
+// shared library entries merged in) and writes the result back.
+func UpdateGlobal(path string, fn func(*Global)) error {
+	var g Global
+	if err := readJSONFile(path, &g); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fn(&g)
+	return writeJSONFile(path, g)
 }
 
 func readJSONFile(path string, v interface{}) error {
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLoadAppDefaults(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.MkdirAll("games/G", 0755)
+	os.WriteFile("games/G/game.json", []byte(`{"proton_version": "GE-Proton9-1", "environment_vars": {"A": "game"}}`), 0644)
+	d := &Defaults{
+		ProtonVersion:   "GE-Proton10-1",
+		RuntimeVersion:  "sniper",
+		EnvironmentVars: map[string]string{"A": "default", "B": "default"},
+	}
+
+	cfg, err := LoadApp("games", "G", d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ProtonVersion != "GE-Proton9-1" || cfg.RuntimeVersion != "sniper" {
+		t.Errorf("got proton %q and runtime %q", cfg.ProtonVersion, cfg.RuntimeVersion)
+	}
+	if cfg.EnvironmentVars["A"] != "game" || cfg.EnvironmentVars["B"] != "default" {
+		t.Errorf("environment_vars = %v", cfg.EnvironmentVars)
+	}
+
+	// Without defaults the config is as written, and loading with some did not change them.
+	if cfg, _ := LoadApp("games", "G", nil); cfg.RuntimeVersion != "" || len(cfg.EnvironmentVars) != 1 {
+		t.Errorf("got %+v without defaults", cfg)
+	}
+	if len(d.EnvironmentVars) != 2 || d.EnvironmentVars["A"] != "default" {
+		t.Errorf("the defaults were changed to %v", d.EnvironmentVars)
+	}
+
+	if _, err := LoadApp("games", "missing", d); !os.IsNotExist(err) {
+		t.Errorf("got %v for a missing game", err)
+	}
+}
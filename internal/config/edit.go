@@ -0,0 +1,292 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// GetField returns the value at a dotted path (e.g. "dependencies.dxvk_version") of v, a
+// config struct, as indented JSON.
+func GetField(v interface{}, path string) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not set", path)
+		}
+		if value, ok = obj[key]; !ok {
+			return nil, fmt.Errorf("'%s' is not set", path)
+		}
+	}
+	return json.MarshalIndent(value, "", "  ")
+}
+
+// SetField sets a dotted path in the JSON config file at path, leaving the rest of the
+// file's formatting alone. cfg is a pointer to the type of config the file holds (e.g.
+// &App{}); the new file must still decode into it. The value is taken as a string where
+// the field is one and parsed as JSON otherwise.
+func SetField(path string, cfg interface{}, field, value string) error {
+	t := reflect.TypeOf(cfg).Elem()
+	keys := strings.Split(field, ".")
+	ft, err := fieldType(t, keys)
+	if err != nil {
+		return err
+	}
+	encoded, err := encodeValue(ft, value)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	updated, err := setJSON(data, keys, encoded)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := json.Unmarshal(updated, cfg); err != nil {
+		return fmt.Errorf("the new value does not fit: %w", err)
+	}
+	return os.WriteFile(path, updated, 0644)
+}
+
+// fieldType follows JSON keys through t's fields and map values.
+func fieldType(t reflect.Type, keys []string) (reflect.Type, error) {
+	for i, key := range keys {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Map:
+			t = t.Elem()
+			continue
+		case reflect.Struct:
+			found := false
+			for j := 0; j < t.NumField(); j++ {
+				f := t.Field(j)
+				name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+				if name == key && f.IsExported() {
+					t, found = f.Type, true
+					break
+				}
+			}
+			if found {
+				continue
+			}
+		}
+		return nil, fmt.Errorf("unknown key '%s'", strings.Join(keys[:i+1], "."))
+	}
+	return t, nil
+}
+
+func encodeValue(t reflect.Type, value string) ([]byte, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return json.Marshal(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not true or false", value)
+		}
+		return json.Marshal(b)
+	}
+	if value == "null" {
+		return []byte("null"), nil
+	}
+	if !json.Valid([]byte(value)) {
+		return nil, fmt.Errorf("'%s' is not valid JSON", value)
+	}
+	var compact bytes.Buffer
+	json.Compact(&compact, []byte(value))
+	return compact.Bytes(), nil
+}
+
+// setJSON replaces the value at keys in a JSON document, or adds the missing keys to the
+// innermost existing object, matching the indentation of its members.
+func setJSON(data []byte, keys []string, value []byte) ([]byte, error) {
+	start := skipSpace(data, 0)
+	if start >= len(data) || data[start] != '{' {
+		return nil, fmt.Errorf("the file does not hold a JSON object")
+	}
+	obj := start
+	for i, key := range keys {
+		valStart, valEnd, lastEnd, err := findMember(data, obj, key)
+		if err != nil {
+			return nil, err
+		}
+		nested := value
+		for j := len(keys) - 1; j > i; j-- {
+			nested = []byte(fmt.Sprintf("{%q: %s}", keys[j], nested))
+		}
+		if valStart < 0 {
+			return insertMember(data, obj, lastEnd, key, nested), nil
+		}
+		if i == len(keys)-1 || string(data[valStart:valEnd]) == "null" {
+			return append(append(append([]byte{}, data[:valStart]...), nested...), data[valEnd:]...), nil
+		}
+		if data[valStart] != '{' {
+			return nil, fmt.Errorf("'%s' is not an object", strings.Join(keys[:i+1], "."))
+		}
+		obj = valStart
+	}
+	return data, nil
+}
+
+// findMember scans the object starting at obj for key. It returns the span of the key's
+// value, or -1 and the end of the last member (obj+1 if the object is empty).
+func findMember(data []byte, obj int, key string) (int, int, int, error) {
+	i := obj + 1
+	lastEnd := obj + 1
+	for {
+		i = skipSpace(data, i)
+		if i >= len(data) {
+			return 0, 0, 0, fmt.Errorf("unexpected end of file")
+		}
+		if data[i] == '}' {
+			return -1, 0, lastEnd, nil
+		}
+		if data[i] == ',' {
+			i++
+			continue
+		}
+		keyEnd, err := skipValue(data, i)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		var name string
+		if err := json.Unmarshal(data[i:keyEnd], &name); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid key at offset %d", i)
+		}
+		i = skipSpace(data, keyEnd)
+		if i >= len(data) || data[i] != ':' {
+			return 0, 0, 0, fmt.Errorf("expected ':' at offset %d", i)
+		}
+		valStart := skipSpace(data, i+1)
+		valEnd, err := skipValue(data, valStart)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		if name == key {
+			return valStart, valEnd, 0, nil
+		}
+		i, lastEnd = valEnd, valEnd
+	}
+}
+
+func insertMember(data []byte, obj, lastEnd int, key string, value []byte) []byte {
+	root := skipSpace(data, 0)
+	// A file written on a single line stays on one.
+	if end, err := skipValue(data, root); err == nil && !bytes.ContainsRune(data[root:end], '\n') && skipSpace(data, root+1) < end-1 {
+		if lastEnd == obj+1 {
+			member := fmt.Sprintf("%q: %s", key, value)
+			return append(append(append([]byte{}, data[:obj+1]...), member...), data[skipSpace(data, obj+1):]...)
+		}
+		member := fmt.Sprintf(", %q: %s", key, value)
+		return append(append(append([]byte{}, data[:lastEnd]...), member...), data[lastEnd:]...)
+	}
+
+	lineStart := bytes.LastIndexByte(data[:obj], '\n') + 1
+	outer := leadingSpace(data[lineStart:obj])
+	unit := "  "
+	if first := skipSpace(data, root+1); first > root+1 {
+		if nl := bytes.LastIndexByte(data[root+1:first], '\n'); nl >= 0 && first > root+1+nl+1 {
+			unit = string(data[root+1+nl+1 : first])
+		}
+	}
+	var member string
+	if lastEnd == obj+1 {
+		// Empty object: put the member on its own line, one level deeper.
+		member = fmt.Sprintf("\n%s%s%q: %s\n%s", outer, unit, key, value, outer)
+		end := skipSpace(data, obj+1)
+		return append(append(append([]byte{}, data[:obj+1]...), member...), data[end:]...)
+	}
+	indent := outer + unit
+	if first := skipSpace(data, obj+1); first > obj+1 {
+		if nl := bytes.LastIndexByte(data[obj+1:first], '\n'); nl >= 0 {
+			indent = string(data[obj+1+nl+1 : first])
+		} else {
+			indent = ""
+		}
+	}
+	if indent == "" && !bytes.Contains(data[obj:lastEnd], []byte("\n")) {
+		member = fmt.Sprintf(", %q: %s", key, value)
+	} else {
+		member = fmt.Sprintf(",\n%s%q: %s", indent, key, value)
+	}
+	return append(append(append([]byte{}, data[:lastEnd]...), member...), data[lastEnd:]...)
+}
+
+func leadingSpace(line []byte) string {
+	n := 0
+	for n < len(line) && (line[n] == ' ' || line[n] == '\t') {
+		n++
+	}
+	return string(line[:n])
+}
+
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// skipValue returns the end of the JSON value starting at i.
+func skipValue(data []byte, i int) (int, error) {
+	if i >= len(data) {
+		return 0, fmt.Errorf("unexpected end of file")
+	}
+	switch data[i] {
+	case '"':
+		for j := i + 1; j < len(data); j++ {
+			switch data[j] {
+			case '\\':
+				j++
+			case '"':
+				return j + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("unterminated string at offset %d", i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(data); j++ {
+			switch data[j] {
+			case '"':
+				end, err := skipValue(data, j)
+				if err != nil {
+					return 0, err
+				}
+				j = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1, nil
+				}
+			}
+		}
+		return 0, fmt.Errorf("unterminated value at offset %d", i)
+	}
+	j := i
+	for j < len(data) && !strings.ContainsRune(",}] \t\r\n", rune(data[j])) {
+		j++
+	}
+	return j, nil
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		key   string
+		value string
+		want  string
+	}{
+		{"replace", "{\n  \"a\": 1,\n  \"b\": 2\n}\n", "a", `3`,
+			"{\n  \"a\": 3,\n  \"b\": 2\n}\n"},
+		{"insert", "{\n  \"a\": 1\n}\n", "b", `"x"`,
+			"{\n  \"a\": 1,\n  \"b\": \"x\"\n}\n"},
+		{"nested insert", "{\n  \"a\": 1,\n  \"deps\": {\n    \"dxvk\": \"1\"\n  }\n}\n", "deps.vkd3d", `"2"`,
+			"{\n  \"a\": 1,\n  \"deps\": {\n    \"dxvk\": \"1\",\n    \"vkd3d\": \"2\"\n  }\n}\n"},
+		{"nested replace", "{\n  \"deps\": {\n    \"dxvk\": \"1\"\n  }\n}\n", "deps.dxvk", `"2"`,
+			"{\n  \"deps\": {\n    \"dxvk\": \"2\"\n  }\n}\n"},
+		{"missing parents", "{\n  \"a\": 1\n}\n", "env.vars.FOO", `"bar"`,
+			"{\n  \"a\": 1,\n  \"env\": {\"vars\": {\"FOO\": \"bar\"}}\n}\n"},
+		{"empty root", "{}\n", "a", `1`,
+			"{\n  \"a\": 1\n}\n"},
+		{"empty nested object", "{\n  \"env\": {}\n}\n", "env.FOO", `"bar"`,
+			"{\n  \"env\": {\n    \"FOO\": \"bar\"\n  }\n}\n"},
+		{"tabs", "{\n\t\"a\": 1,\n\t\"deps\": {\n\t\t\"dxvk\": \"1\"\n\t}\n}\n", "deps.vkd3d", `"2"`,
+			"{\n\t\"a\": 1,\n\t\"deps\": {\n\t\t\"dxvk\": \"1\",\n\t\t\"vkd3d\": \"2\"\n\t}\n}\n"},
+		{"tabs into an empty object", "{\n\t\"env\": {}\n}\n", "env.FOO", `"bar"`,
+			"{\n\t\"env\": {\n\t\t\"FOO\": \"bar\"\n\t}\n}\n"},
+		{"compact", `{"a":1,"deps":{"dxvk":"1"}}`, "deps.vkd3d", `"2"`,
+			`{"a":1,"deps":{"dxvk":"1", "vkd3d": "2"}}`},
+		{"compact empty object", `{"a":1,"env":{}}`, "env.FOO", `"bar"`,
+			`{"a":1,"env":{"FOO": "bar"}}`},
+		{"empty root without a newline", `{}`, "a", `1`,
+			"{\n  \"a\": 1\n}"},
+		{"one line with spaces", `{ "a": 1 }`, "b", `2`,
+			`{ "a": 1, "b": 2 }`},
+		{"escaped quotes", `{"name": "say \"hi\" {[", "path": "C:\\", "x": 1}`, "x", `2`,
+			`{"name": "say \"hi\" {[", "path": "C:\\", "x": 2}`},
+		{"escaped quote in a key", `{"a\"b": {"c": 1}, "d": 1}`, "d", `2`,
+			`{"a\"b": {"c": 1}, "d": 2}`},
+		{"strings with braces in nested objects", "{\n  \"o\": {\"s\": \"}\"},\n  \"x\": 1\n}", "x", `2`,
+			"{\n  \"o\": {\"s\": \"}\"},\n  \"x\": 2\n}"},
+		{"replace null", "{\n  \"deps\": null\n}\n", "deps", `{"dxvk": "2"}`,
+			"{\n  \"deps\": {\"dxvk\": \"2\"}\n}\n"},
+		{"null parent", "{\n  \"deps\": null\n}\n", "deps.dxvk", `"2"`,
+			"{\n  \"deps\": {\"dxvk\": \"2\"}\n}\n"},
+		{"set to null", "{\n  \"deps\": {\"dxvk\": \"2\"}\n}\n", "deps", `null`,
+			"{\n  \"deps\": null\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setJSON([]byte(tt.in), strings.Split(tt.key, "."), []byte(tt.value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("got\n%s\nwant\n%s", got, tt.want)
+			}
+			if !json.Valid(got) {
+				t.Fatal("the result is not valid JSON")
+			}
+		})
+	}
+}
+
+func TestSetJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		key  string
+		want string
+	}{
+		{"not an object", `[1, 2]`, "a", "does not hold a JSON object"},
+		{"parent not an object", `{"a": 1}`, "a.b", "'a' is not an object"},
+		{"unterminated string", `{"a": "x`, "b", "unterminated string"},
+		{"unterminated object", `{"a": {"b": 1}`, "c", "unexpected end of file"},
+		{"missing colon", `{"a" 1}`, "b", "expected ':'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := setJSON([]byte(tt.in), strings.Split(tt.key, "."), []byte(`1`))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.json")
+	os.WriteFile(path, []byte("{\n  \"executable\": \"game.exe\"\n}\n"), 0644)
+
+	if err := SetField(path, &App{}, "dependencies.dxvk_version", "2.7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetField(path, &App{}, "gamemode", "yes"); err == nil {
+		t.Error("'yes' was accepted for a bool")
+	}
+	if err := SetField(path, &App{}, "no_such_key", "1"); err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Errorf("got %v for an unknown key", err)
+	}
+	if err := SetField(path, &App{}, "dependencies", `"2.7"`); err == nil {
+		t.Error("a string was accepted for an object")
+	}
+	data, _ := os.ReadFile(path)
+	if want := "{\n  \"executable\": \"game.exe\",\n  \"dependencies\": {\"dxvk_version\": \"2.7\"}\n}\n"; string(data) != want {
+		t.Fatalf("got\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestGetField(t *testing.T) {
+	cfg := App{Executable: "game.exe", Dependencies: AppDependencies{DXVKVersion: "2.7"}}
+	value, err := GetField(cfg, "dependencies.dxvk_version")
+	if err != nil || string(value) != `"2.7"` {
+		t.Fatalf("got %s, %v", value, err)
+	}
+	if _, err := GetField(cfg, "executable.name"); err == nil {
+		t.Error("a key below a string was found")
+	}
+	if _, err := GetField(cfg, "missing"); err == nil {
+		t.Error("a missing key was found")
+	}
+	if _, err := GetField(func() {}, "a"); err == nil {
+		t.Error("a value that cannot be encoded was accepted")
+	}
+}
@@ -0,0 +1,184 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Severity ranks lint findings.
+type Severity int
+
+const (
+	SeverityInfo    Severity = iota // A hint; never fails a lint run
+	SeverityWarning                 // Works, but probably not as intended
+	SeverityError                   // Will not work
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "info"
+}
+
+// Finding is a suspicious setting Lint found in a config.
+type Finding struct {
+	File     string
+	Rule     string
+	Severity Severity
+	Message  string
+}
+
+// supersededEnv lists environment variables that have a config key of their own. Set
+// through environment_vars they fight with (or are overwritten by) what yapl sets.
+var supersededEnv = map[string]string{
+	"WINEDLLOVERRIDES":           "dll_overrides",
+	"WINEESYNC":                  "esync",
+	"PROTON_NO_ESYNC":            "esync",
+	"WINEFSYNC":                  "fsync",
+	"PROTON_NO_FSYNC":            "fsync",
+	"WINENTSYNC":                 "ntsync",
+	"PROTON_USE_NTSYNC":          "ntsync",
+	"PROTON_NO_NTSYNC":           "ntsync",
+	"MANGOHUD":                   "mangohud",
+	"DRI_PRIME":                  "gpu",
+	"__NV_PRIME_RENDER_OFFLOAD":  "gpu",
+	"MESA_VK_DEVICE_SELECT":      "gpu",
+	"WINE_FULLSCREEN_FSR":        "scaling",
+	"WINE_FULLSCREEN_FSR_MODE":   "scaling",
+	"PRESSURE_VESSEL_SHELL":      "container_options.environment",
+	"PRESSURE_VESSEL_FILESYSTEM": "container_options.environment",
+}
+
+// Lint checks every game and app config for settings that are valid but likely wrong:
+// overrides that undo another setting, conflicting toggles, paths that only exist on
+// this machine and environment variables that a config key replaces. Configs that do
+// not load at all are left to Validate, but reported as errors.
+func Lint(globalPath string) []Finding {
+	var findings []Finding
+	var global Global
+	readJSONFile(globalPath, &global)
+
+	for _, appType := range []string{"games", "apps"} {
+		entries, _ := os.ReadDir(appType)
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			path := ConfigPath(appType, entry.Name())
+			if _, err := os.Stat(path); err != nil {
+				continue // Not set up yet
+			}
+			add := func(rule string, severity Severity, format string, args ...interface{}) {
+				findings = append(findings, Finding{File: path, Rule: rule, Severity: severity, Message: fmt.Sprintf(format, args...)})
+			}
+			cfg, err := readApp(path)
+			if err != nil {
+				add("load", SeverityError, "%v (run 'yapl validate' for details)", err)
+				continue
+			}
+			lintApp(WithDefaults(cfg, global.Defaults), path, add)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].File < findings[j].File })
+	return findings
+}
+
+func lintApp(cfg App, path string, add func(string, Severity, string, ...interface{})) {
+	// Launch method
+	if (cfg.LaunchMethod == "" || cfg.LaunchMethod == "container") && cfg.RuntimeVersion == "" {
+		add("container-runtime", SeverityError, "launch_method 'container' (the default) needs a 'runtime_version'; set one or use 'direct'")
+	}
+	if cfg.LaunchMethod == "direct" && cfg.RuntimeVersion != "" {
+		add("container-runtime", SeverityInfo, "runtime_version '%s' is unused with launch_method 'direct'", cfg.RuntimeVersion)
+	}
+
+	// DLL overrides
+	switch cfg.Dependencies.DXVKMode {
+	case "":
+	case "custom":
+		installed := map[string]string{}
+		dxvk := map[string][]string{
+			"9":  {"d3d9"},
+			"10": {"d3d10", "d3d10_1", "d3d10core", "d3d11", "dxgi"},
+			"11": {"d3d11", "dxgi"},
+		}
+		if cfg.Dependencies.DXVKInstallPath != "" {
+			for _, dll := range dxvk[cfg.Dependencies.DXVKDirectXVersion] {
+				installed[dll] = "dxvk"
+			}
+		}
+		if cfg.Dependencies.VKD3DInstallPath != "" {
+			installed["d3d12"], installed["d3d12core"] = "vkd3d", "vkd3d"
+		}
+		dlls := make([]string, 0, len(cfg.DLLOverrides))
+		for dll := range cfg.DLLOverrides {
+			dlls = append(dlls, dll)
+		}
+		sort.Strings(dlls)
+		for _, dll := range dlls {
+			setting := cfg.DLLOverrides[dll]
+			name := strings.ToLower(strings.TrimSuffix(dll, ".dll"))
+			if dep, ok := installed[name]; ok && !strings.HasPrefix(setting, "n") {
+				add("dll-override-shadowed", SeverityWarning, "dll_overrides '%s=%s' keeps the %s copy installed by dxvk_mode 'custom' from loading; use 'n' or 'n,b'", dll, setting, dep)
+			}
+		}
+	default:
+		add("dll-override-shadowed", SeverityError, "unknown dxvk_mode '%s'. Only 'custom' is supported", cfg.Dependencies.DXVKMode)
+	}
+
+	// Sync primitives
+	if cfg.NTsync != nil && *cfg.NTsync {
+		for i, setting := range []*bool{cfg.Esync, cfg.Fsync} {
+			if name := []string{"esync", "fsync"}[i]; setting != nil && *setting {
+				add("sync-conflict", SeverityWarning, "'%s' is enabled alongside 'ntsync', which replaces it; drop '%s'", name, name)
+			}
+		}
+	}
+	if cfg.Esync != nil && cfg.Fsync != nil && !*cfg.Esync && !*cfg.Fsync && (cfg.NTsync == nil || !*cfg.NTsync) {
+		add("sync-conflict", SeverityInfo, "esync and fsync are both disabled, which usually costs performance")
+	}
+
+	// Environment
+	envKeys := make([]string, 0, len(cfg.EnvironmentVars))
+	for k := range cfg.EnvironmentVars {
+		envKeys = append(envKeys, k)
+	}
+	sort.Strings(envKeys)
+	for _, k := range envKeys {
+		if key, ok := supersededEnv[k]; ok {
+			add("deprecated-env", SeverityWarning, "environment_vars '%s' is superseded by '%s'", k, key)
+		}
+	}
+
+	// Portability: packages and shared configs must not point at this machine.
+	absolute := func(where, value string) {
+		if filepath.IsAbs(value) && !strings.HasPrefix(value, "/dev/") {
+			add("absolute-path", SeverityWarning, "%s '%s' is an absolute path and will break once packaged; use a relative path or a placeholder such as ${GAME_DIR}", where, value)
+		}
+	}
+	absolute("executable", cfg.Executable)
+	for _, arg := range cfg.LaunchArgs {
+		absolute("launch_args", arg)
+	}
+	for _, arg := range cfg.UMUOptions.LaunchArgs {
+		absolute("umu_options.launch_args", arg)
+	}
+	for _, k := range envKeys {
+		absolute("environment_vars."+k, cfg.EnvironmentVars[k])
+	}
+	if files, err := ConfigFiles(path); err == nil {
+		for _, file := range files {
+			if rel, err := filepath.Rel(filepath.Dir(path), file); err != nil || strings.HasPrefix(rel, "..") {
+				add("external-template", SeverityInfo, "extends '%s', which is outside the directory and not packaged", file)
+			}
+		}
+	}
+}
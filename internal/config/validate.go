@@ -0,0 +1,323 @@
+package config
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"yapl/internal/policy"
+	"yapl/internal/registry"
+)
+
+// windowsVersions are the versions Wine accepts in HKCU\Software\Wine's "Version" value.
+var windowsVersions = []string{
+	"win11", "win10", "win81", "win8", "win2008r2", "win7", "win2008", "vista", "win2003",
+	"winxp64", "winxp", "win2k", "winme", "win98", "win95", "nt40", "nt351", "win31", "win30", "win20",
+}
+
+// Issue is a problem Validate found in a config file.
+type Issue struct {
+	File    string
+	Message string
+	Warning bool // The config works, but probably not as intended
+}
+
+// Validate checks runner.json and every game and app config: that they parse, that they
+// contain no unknown keys (which encoding/json ignores, so typos go unnoticed), that
+// every version they use is defined and that their executable exists in their prefix.
+func Validate(globalPath string) []Issue {
+	var issues []Issue
+	add := func(file string, warning bool, format string, args ...interface{}) {
+		issues = append(issues, Issue{File: file, Message: fmt.Sprintf(format, args...), Warning: warning})
+	}
+
+	var global Global
+	if _, err := os.Stat(globalPath); err != nil {
+		add(globalPath, false, "%v", err)
+	} else if err := checkFile(globalPath, &global, add); err == nil {
+		if global.Library != nil && global.Library.Path != "" {
+			merged, err := withLibrary(global)
+			if err != nil {
+				add(globalPath, false, "%v", err)
+			} else {
+				global = merged
+			}
+		}
+	}
+	for name, vinfo := range global.ProtonVersions {
+		checkVersion(globalPath, "proton_versions", name, vinfo, add)
+	}
+	for name, vinfo := range global.RuntimeVersions {
+		checkVersion(globalPath, "runtime_versions", name, vinfo, add)
+	}
+	for dep, versions := range global.DependencyVersions {
+		for name, vinfo := range versions {
+			checkVersion(globalPath, "dependency_versions."+dep, name, vinfo, add)
+		}
+	}
+	if pc := global.ParentalControls; pc != nil && pc.AdminPIN != "" {
+		if err := policy.ParsePINHash(pc.AdminPIN); err != nil {
+			add(globalPath, false, "parental_controls.admin_pin: %v (use 'yapl parental hash-pin')", err)
+		}
+	}
+
+	// Versions a resolve-version plugin may provide are only worth a warning.
+	resolvable := false
+	for _, hooks := range global.Plugins {
+		for _, h := range hooks {
+			resolvable = resolvable || h == "resolve-version"
+		}
+	}
+	undefined := func(file, key, version, where string) {
+		if resolvable {
+			add(file, true, "%s '%s' is not defined in %s (a resolve-version plugin may provide it)", key, version, where)
+		} else {
+			add(file, false, "%s '%s' is not defined in %s", key, version, where)
+		}
+	}
+
+	for _, appType := range []string{"games", "apps"} {
+		entries, _ := os.ReadDir(appType)
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			path := ConfigPath(appType, entry.Name())
+			if _, err := os.Stat(path); err != nil {
+				continue // Not set up yet
+			}
+			// Each file the config is made of must parse and have no unknown keys.
+			files, err := ConfigFiles(path)
+			if err != nil {
+				add(path, false, "%v", err)
+				continue
+			}
+			broken := false
+			for _, file := range files {
+				var part App
+				if err := checkFile(file, &part, add); err != nil {
+					broken = true
+					continue
+				}
+				for i, c := range part.Conditions {
+					var raw interface{}
+					if err := json.Unmarshal(c.Config, &raw); err != nil {
+						add(file, false, "conditions[%d].config: %v", i, err)
+						continue
+					}
+					for _, key := range unknownKeys(raw, reflect.TypeOf(App{}), fmt.Sprintf("conditions[%d].config.", i)) {
+						add(file, false, "unknown key '%s'", key)
+					}
+					if vendor := c.When.GPUVendor; vendor != "" && vendor != "nvidia" && vendor != "amd" && vendor != "intel" {
+						add(file, false, "conditions[%d].when.gpu_vendor '%s' is not 'nvidia', 'amd', or 'intel'", i, vendor)
+					}
+				}
+			}
+			if broken {
+				continue
+			}
+			cfg, err := readApp(path)
+			if err != nil {
+				add(path, false, "%v", err)
+				continue
+			}
+			cfg = WithDefaults(cfg, global.Defaults)
+
+			switch cfg.LaunchMethod {
+			case "", "direct", "container", "umu":
+			default:
+				add(path, false, "unknown launch_method '%s'. Use 'direct', 'container', or 'umu'", cfg.LaunchMethod)
+			}
+			if _, ok := global.ProtonVersions[cfg.ProtonVersion]; !ok {
+				undefined(path, "proton_version", cfg.ProtonVersion, "runner.json's proton_versions")
+			}
+			if cfg.LaunchMethod == "" || cfg.LaunchMethod == "container" {
+				if cfg.RuntimeVersion == "" {
+					add(path, false, "launch_method 'container' requires 'runtime_version'")
+				} else if _, ok := global.RuntimeVersions[cfg.RuntimeVersion]; !ok {
+					undefined(path, "runtime_version", cfg.RuntimeVersion, "runner.json's runtime_versions")
+				}
+			}
+			deps := map[string]string{"dxvk": cfg.Dependencies.DXVKVersion, "vkd3d": cfg.Dependencies.VKD3DVersion}
+			if cfg.LaunchMethod == "umu" && !cfg.UMUOptions.UseSystemBinary {
+				deps["umu-launcher"] = cfg.UMUOptions.Version
+			}
+			for _, dep := range []string{"dxvk", "vkd3d", "umu-launcher"} {
+				if version := deps[dep]; version != "" {
+					if _, ok := global.DependencyVersions[dep][version]; !ok {
+						undefined(path, dep, version, "runner.json's dependency_versions."+dep)
+					}
+				}
+			}
+			if cfg.Fallback != "" && cfg.Fallback != "direct" && cfg.Fallback != "never" {
+				add(path, false, "unknown container_fallback '%s'. Use 'direct' or 'never'", cfg.Fallback)
+			}
+			if cfg.PrefixInit != "" && cfg.PrefixInit != "wineboot" && cfg.PrefixInit != "fast" {
+				add(path, false, "unknown prefix_init '%s'. Use 'wineboot' or 'fast'", cfg.PrefixInit)
+			}
+			if _, err := PersistDuration(cfg); err != nil {
+				add(path, false, "%v", err)
+			}
+
+			if cfg.WindowsVersion != "" && !slices.Contains(windowsVersions, cfg.WindowsVersion) {
+				add(path, false, "unknown windows_version '%s'. Use one of: %s", cfg.WindowsVersion, strings.Join(windowsVersions, ", "))
+			}
+			for key, values := range cfg.Registry {
+				if _, _, err := registry.SplitPath(key); err != nil {
+					add(path, false, "registry: %v", err)
+				}
+				for name, value := range values {
+					if hex, ok := strings.CutPrefix(value, "dword:"); ok {
+						if _, err := strconv.ParseUint(hex, 16, 32); err != nil {
+							add(path, false, "registry '%s' value '%s': '%s' is not a hexadecimal DWORD", key, name, value)
+						}
+					}
+				}
+			}
+
+			// Only checked once the prefix exists; placeholders are filled in at run time.
+			prefix := filepath.Join(appType, entry.Name(), "prefix")
+			if _, err := os.Stat(filepath.Join(prefix, "drive_c")); err == nil && !strings.Contains(cfg.Executable, "${") {
+				exe := cfg.Executable
+				if !filepath.IsAbs(exe) {
+					exe = filepath.Join(prefix, exe)
+				}
+				if _, err := os.Stat(exe); err != nil {
+					add(path, false, "executable '%s' does not exist in the prefix", cfg.Executable)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(prefix, registry.SystemFile)); err == nil {
+				checkPrefix(path, prefix, cfg, add)
+			}
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool { return issues[i].File < issues[j].File })
+	return issues
+}
+
+// checkPrefix reports registry hives Wine could not load and prefixes created for another
+// architecture than the config's.
+func checkPrefix(file, prefix string, cfg App, add func(string, bool, string, ...interface{})) {
+	for _, name := range []string{registry.SystemFile, registry.UserFile} {
+		hive, err := registry.Load(filepath.Join(prefix, name))
+		if err != nil {
+			add(file, false, "prefix registry is damaged: %v", err)
+			continue
+		}
+		want := cfg.WineArch
+		if want == "" {
+			want = "win64"
+		}
+		if arch := hive.Arch(); name == registry.SystemFile && arch != "" && arch != want {
+			add(file, false, "the prefix was created as %s, but wine_arch is %s", arch, want)
+		}
+	}
+}
+
+// checkFile parses a config file into v and reports syntax errors and unknown keys.
+func checkFile(path string, v interface{}, add func(string, bool, string, ...interface{})) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		add(path, false, "%v", err)
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		add(path, false, "invalid JSON: %v", err)
+		return err
+	}
+	var raw interface{}
+	json.Unmarshal(data, &raw)
+	for _, key := range unknownKeys(raw, reflect.TypeOf(v).Elem(), "") {
+		add(path, false, "unknown key '%s'", key)
+	}
+	return nil
+}
+
+func checkVersion(file, section, name string, vinfo VersionInfo, add func(string, bool, string, ...interface{})) {
+	if vinfo.URL == "" && vinfo.Path == "" && name != "system" {
+		add(file, false, "%s.%s has neither 'url' nor 'path'", section, name)
+	}
+	if strings.Contains(name, "EDIT_ME") || strings.Contains(vinfo.URL, "URL_TO_") || strings.HasPrefix(vinfo.Path, "OR_PROVIDE_") {
+		add(file, true, "%s.%s is still the placeholder from the default runner.json", section, name)
+	}
+	if vinfo.StripComponents != nil && *vinfo.StripComponents < 0 {
+		add(file, false, "%s.%s has a negative strip_components", section, name)
+	}
+	if _, err := hex.DecodeString(vinfo.SHA256); err != nil || len(vinfo.SHA256) != 0 && len(vinfo.SHA256) != 64 {
+		add(file, false, "%s.%s has a sha256 that is not 64 hex digits", section, name)
+	}
+}
+
+// unknownKeys returns the paths of the object keys in a decoded JSON value that t has no
+// field for, matching names the way encoding/json does (case-insensitively).
+func unknownKeys(value interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		return nil // Checked by the caller
+	}
+	var unknown []string
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			fields[strings.ToLower(name)] = f.Type
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			ft, ok := fields[strings.ToLower(key)]
+			if !ok {
+				unknown = append(unknown, path+key)
+				continue
+			}
+			unknown = append(unknown, unknownKeys(obj[key], ft, path+key+".")...)
+		}
+	case reflect.Map:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			unknown = append(unknown, unknownKeys(obj[key], t.Elem(), path+key+".")...)
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, item := range items {
+			unknown = append(unknown, unknownKeys(item, t.Elem(), fmt.Sprintf("%s[%d].", strings.TrimSuffix(path, "."), i))...)
+		}
+	}
+	return unknown
+}
@@ -0,0 +1,230 @@
+// Package crash matches the output and logs of failed runs against known Proton and Wine
+// problems, so yapl can suggest a fix instead of leaving the user with a raw log.
+package crash
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DatabasePath is the local signature database. Its entries are added to the built-in
+// ones, replacing those with the same ID.
+const DatabasePath = "state/crash-signatures.json"
+
+// Signature describes a known problem: a regular expression over log lines and what to
+// do about it.
+type Signature struct {
+	ID      string `json:"id"`
+	Pattern string `json:"pattern"`
+	Issue   string `json:"issue"`
+	Fix     string `json:"fix"`
+
+	re *regexp.Regexp
+}
+
+// Match is a signature found in a log, with the first line that matched it.
+type Match struct {
+	Signature
+	Line string
+}
+
+var builtin = []Signature{
+	{
+		ID:      "esync-fd-limit",
+		Pattern: `(?i)eventfd: Too many open files|esync: .*too many open files`,
+		Issue:   "esync ran out of file descriptors",
+		Fix:     "raise the open file limit (e.g. 'ulimit -n 524288' or DefaultLimitNOFILE in systemd) or set \"esync\": false",
+	},
+	{
+		ID:      "fsync-unsupported",
+		Pattern: `(?i)fsync: .*(futex_waitv|FUTEX_WAIT_MULTIPLE).*(not supported|ENOSYS|failed)`,
+		Issue:   "the kernel does not support fsync's futex operations",
+		Fix:     "set \"fsync\": false, or use a kernel with futex_waitv (Linux 5.16+)",
+	},
+	{
+		ID:      "ntsync-permission",
+		Pattern: `(?i)/dev/ntsync.*(Permission denied|EACCES)`,
+		Issue:   "/dev/ntsync is not accessible to this user",
+		Fix:     "add a udev rule giving your user access to /dev/ntsync, or set \"ntsync\": false",
+	},
+	{
+		ID:      "vulkan-missing",
+		Pattern: `(?i)(Failed to load libvulkan|vkCreateInstance.*VK_ERROR_INCOMPATIBLE_DRIVER|VK_ERROR_INITIALIZATION_FAILED|No Vulkan-capable (devices|GPUs) found)`,
+		Issue:   "no working Vulkan driver was found",
+		Fix:     "install the Vulkan driver for your GPU, including the 32-bit (lib32) package",
+	},
+	{
+		ID:      "dxvk-feature-level",
+		Pattern: `(?i)(D3D11CoreCreateDevice: Requested feature level not supported|DxvkAdapter: .*(not supported|required)|DXVK: .*Failed to create device)`,
+		Issue:   "the GPU or driver lacks Vulkan features DXVK needs",
+		Fix:     "update the GPU driver, try an older dxvk_version, or set PROTON_USE_WINED3D=1 in environment_vars",
+	},
+	{
+		ID:      "vcrun-missing",
+		Pattern: `(?i)err:module:import_dll Library (msvcp1\d\d|vcruntime1\d\d(_1)?|msvcr1\d\d)\.dll`,
+		Issue:   "a Visual C++ runtime DLL is missing",
+		Fix:     "add \"vcrun2022\" to winetricks and run setup again",
+	},
+	{
+		ID:      "d3dx-missing",
+		Pattern: `(?i)err:module:import_dll Library (d3dx9_\d+|d3dcompiler_\d+|xinput1_\d)\.dll`,
+		Issue:   "a DirectX redistributable DLL is missing",
+		Fix:     "add the matching winetricks verb (d3dx9, d3dcompiler_47 or xinput) and run setup again",
+	},
+	{
+		ID:      "dotnet-missing",
+		Pattern: `(?i)(err:mscoree|CLR error|Mono not found|wine-mono .*not installed)`,
+		Issue:   "the application needs .NET, which the prefix does not provide",
+		Fix:     "add the needed dotnet verb (e.g. \"dotnet48\") to winetricks, or use a Proton build that ships wine-mono",
+	},
+	{
+		ID:      "prefix-arch-mismatch",
+		Pattern: `(?i)(is a (64|32)-bit installation, it cannot be used with a (32|64)-bit wineserver|could not load kernel32\.dll, status c0000135)`,
+		Issue:   "the prefix was created for a different architecture or Wine build",
+		Fix:     "check \"wine_arch\" and the Proton version, or recreate the prefix",
+	},
+	{
+		ID:      "bwrap-userns",
+		Pattern: `(?i)bwrap: (setting up uid map|No permissions to creat(e|ing) new namespace|Creating new namespace failed)`,
+		Issue:   "the container runtime cannot create user namespaces",
+		Fix:     "enable unprivileged user namespaces (sysctl kernel.unprivileged_userns_clone=1) or set \"container_fallback\": \"direct\"",
+	},
+	{
+		ID:      "easy-anti-cheat",
+		Pattern: `(?i)EasyAntiCheat.*(not installed|Launch Error|failed to initialize)|EOS_AntiCheat`,
+		Issue:   "Easy Anti-Cheat failed to start",
+		Fix:     "use a Proton build with the EAC runtime, set the game's steam_app_id, and check the game allows Proton",
+	},
+}
+
+// Load returns the built-in signatures merged with the local database.
+func Load() ([]Signature, error) {
+	byID := map[string]Signature{}
+	for _, s := range builtin {
+		byID[s.ID] = s
+	}
+	data, err := os.ReadFile(DatabasePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		local, err := parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", DatabasePath, err)
+		}
+		for _, s := range local {
+			byID[s.ID] = s
+		}
+	}
+
+	sigs := make([]Signature, 0, len(byID))
+	for _, s := range byID {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("signature '%s': %w", s.ID, err)
+		}
+		s.re = re
+		sigs = append(sigs, s)
+	}
+	sort.Slice(sigs, func(i, j int) bool { return sigs[i].ID < sigs[j].ID })
+	return sigs, nil
+}
+
+func parse(data []byte) ([]Signature, error) {
+	var sigs []Signature
+	if err := json.Unmarshal(data, &sigs); err != nil {
+		return nil, err
+	}
+	for i, s := range sigs {
+		if s.ID == "" || s.Pattern == "" {
+			return nil, fmt.Errorf("entry %d needs an 'id' and a 'pattern'", i)
+		}
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return nil, fmt.Errorf("signature '%s': %w", s.ID, err)
+		}
+	}
+	return sigs, nil
+}
+
+// Update replaces the local database with the signatures at source, a URL or a file,
+// after checking that they parse. It returns how many it installed.
+func Update(source string) (int, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		client := &http.Client{Timeout: 30 * time.Second}
+		resp, err := client.Get(source)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return 0, fmt.Errorf("download failed: %s", resp.Status)
+		}
+		if data, err = io.ReadAll(io.LimitReader(resp.Body, 4<<20)); err != nil {
+			return 0, err
+		}
+	} else if data, err = os.ReadFile(source); err != nil {
+		return 0, err
+	}
+
+	sigs, err := parse(data)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(DatabasePath), 0755); err != nil {
+		return 0, err
+	}
+	tmp := DatabasePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return 0, err
+	}
+	return len(sigs), os.Rename(tmp, DatabasePath)
+}
+
+// Scan returns the signatures that match text, each once, in the order they first appear.
+func Scan(text string, sigs []Signature) []Match {
+	var matches []Match
+	found := map[string]bool{}
+	for _, line := range strings.Split(text, "\n") {
+		for _, s := range sigs {
+			if !found[s.ID] && s.re.MatchString(line) {
+				found[s.ID] = true
+				matches = append(matches, Match{Signature: s, Line: strings.TrimSpace(line)})
+			}
+		}
+	}
+	return matches
+}
+
+// ReadLogs reads the logs in dir modified since the given time and returns their text,
+// keeping at most the last limit bytes of each.
+func ReadLogs(dir string, since time.Time, limit int64) string {
+	entries, _ := os.ReadDir(dir)
+	var b strings.Builder
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.ModTime().Before(since) {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if info.Size() > limit {
+			f.Seek(info.Size()-limit, io.SeekStart)
+		}
+		io.Copy(&b, f)
+		f.Close()
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
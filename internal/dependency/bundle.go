@@ -0,0 +1,192 @@
+package dependency
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"yapl/internal/archive"
+	"yapl/internal/config"
+	"yapl/internal/fs"
+)
+
+// BundledDir holds the Proton build, Steam Linux Runtime and dependencies a full bundle
+// ships, inside its game directory and laid out like the storage root, with a runner.json
+// that defines them.
+const BundledDir = "yapl-bundled"
+
+// Bundle returns the installed components appCfg uses, as trees to package below
+// BundledDir, and writes the runner.json describing them into tmpDir, which is packaged
+// as BundledDir itself. Every component must be installed.
+func Bundle(appCfg config.App, globalCfg config.Global, tmpDir string) ([]archive.Tree, error) {
+	runner := config.Global{
+		ProtonVersions:     map[string]config.VersionInfo{},
+		RuntimeVersions:    map[string]config.VersionInfo{},
+		DependencyVersions: map[string]map[string]config.VersionInfo{},
+	}
+	trees := []archive.Tree{{Dir: tmpDir, Name: BundledDir}}
+	add := func(dir, name string) error {
+		if !fs.DirExistsAndIsNotEmpty(dir) {
+			return fmt.Errorf("'%s' is not installed; run the game once before packaging it with --full", dir)
+		}
+		fmt.Printf("-> Bundling %s...\n", name)
+		trees = append(trees, archive.Tree{Dir: dir, Name: filepath.Join(BundledDir, name)})
+		return nil
+	}
+
+	if appCfg.ProtonVersion != "" && appCfg.ProtonVersion != "system" {
+		vinfo, ok := globalCfg.ProtonVersions[appCfg.ProtonVersion]
+		if !ok {
+			return nil, fmt.Errorf("proton version '%s' not defined in runner.json", appCfg.ProtonVersion)
+		}
+		name := filepath.Join("proton", appCfg.ProtonVersion)
+		dir := name
+		if vinfo.Path != "" {
+			// A local build is bundled like a downloaded one and found by its path.
+			dir, vinfo.Path = vinfo.Path, name
+		}
+		if err := add(dir, name); err != nil {
+			return nil, err
+		}
+		runner.ProtonVersions[appCfg.ProtonVersion] = vinfo
+	}
+
+	if appCfg.RuntimeVersion != "" && appCfg.LaunchMethod == "container" {
+		dir, err := filepath.EvalSymlinks(config.RuntimeDir(appCfg))
+		if err != nil {
+			return nil, fmt.Errorf("runtime '%s' is not installed; run the game once before packaging it with --full", appCfg.RuntimeVersion)
+		}
+		name := filepath.Join(config.RuntimeSnapshotsDir, appCfg.RuntimeVersion, filepath.Base(dir))
+		if err := add(dir, name); err != nil {
+			return nil, err
+		}
+		runner.RuntimeVersions[appCfg.RuntimeVersion] = globalCfg.RuntimeVersions[appCfg.RuntimeVersion]
+	}
+
+	deps := map[string]string{"dxvk": appCfg.Dependencies.DXVKVersion, "vkd3d": appCfg.Dependencies.VKD3DVersion}
+	if appCfg.LaunchMethod == "umu" && !appCfg.UMUOptions.UseSystemBinary {
+		deps["umu-launcher"] = appCfg.UMUOptions.Version
+	}
+	for dep, version := range deps {
+		if version == "" {
+			continue
+		}
+		name := filepath.Join("dependencies", dep, version)
+		if err := add(name, name); err != nil {
+			return nil, err
+		}
+		runner.DependencyVersions[dep] = map[string]config.VersionInfo{version: globalCfg.DependencyVersions[dep][version]}
+	}
+
+	data, err := json.MarshalIndent(runner, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return trees, os.WriteFile(filepath.Join(tmpDir, "runner.json"), append(data, '\n'), 0644)
+}
+
+// InstallBundled moves the components a full bundle unpacked into gameDir to their place
+// in the storage root and adds their definitions to runner.json. Components that are
+// already installed are kept. It reports false if gameDir holds no bundled components.
+func InstallBundled(gameDir string) (bool, error) {
+	bundled := filepath.Join(gameDir, BundledDir)
+	var runner config.Global
+	data, err := os.ReadFile(filepath.Join(bundled, "runner.json"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	if err := json.Unmarshal(data, &runner); err != nil {
+		return true, fmt.Errorf("bundled runner.json: %w", err)
+	}
+
+	var dirs []string
+	for version := range runner.ProtonVersions {
+		dirs = append(dirs, filepath.Join("proton", version))
+	}
+	for dep, versions := range runner.DependencyVersions {
+		for version := range versions {
+			dirs = append(dirs, filepath.Join("dependencies", dep, version))
+		}
+	}
+	for version := range runner.RuntimeVersions {
+		builds, _ := os.ReadDir(filepath.Join(bundled, config.RuntimeSnapshotsDir, version))
+		for _, b := range builds {
+			dirs = append(dirs, filepath.Join(config.RuntimeSnapshotsDir, version, b.Name()))
+		}
+	}
+	for _, dir := range dirs {
+		if err := installBundledDir(filepath.Join(bundled, dir), dir); err != nil {
+			return true, err
+		}
+	}
+	for version := range runner.RuntimeVersions {
+		if _, err := os.Lstat(config.RuntimeDir(config.App{RuntimeVersion: version})); err == nil {
+			continue
+		}
+		builds, _ := os.ReadDir(filepath.Join(bundled, config.RuntimeSnapshotsDir, version))
+		for _, b := range builds {
+			if err := linkCurrent(version, b.Name()); err != nil {
+				return true, err
+			}
+		}
+	}
+
+	err = config.UpdateGlobal("runner.json", func(g *config.Global) {
+		g.ProtonVersions = addVersions(g.ProtonVersions, runner.ProtonVersions)
+		g.RuntimeVersions = addVersions(g.RuntimeVersions, runner.RuntimeVersions)
+		for dep, versions := range runner.DependencyVersions {
+			if g.DependencyVersions == nil {
+				g.DependencyVersions = map[string]map[string]config.VersionInfo{}
+			}
+			g.DependencyVersions[dep] = addVersions(g.DependencyVersions[dep], versions)
+		}
+	})
+	if err != nil {
+		return true, fmt.Errorf("updating runner.json: %w", err)
+	}
+	return true, os.RemoveAll(bundled)
+}
+
+func installBundledDir(src, dst string) error {
+	if fs.DirExistsAndIsNotEmpty(dst) {
+		fmt.Printf("-> '%s' is already installed; keeping it.\n", dst)
+		return nil
+	}
+	if !fs.DirExistsAndIsNotEmpty(src) {
+		return fmt.Errorf("the bundle lists '%s' but does not contain it", dst)
+	}
+	fmt.Printf("-> Installing bundled '%s'...\n", dst)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	os.Remove(dst) // An empty directory left by a failed download
+	if err := os.Rename(src, dst); err == nil {
+		dedup(dst)
+		return nil
+	}
+	// The game directory may be on another file system, e.g. through the shared library.
+	if err := fs.CopyDir(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	dedup(dst)
+	return nil
+}
+
+// addVersions adds the entries of bundled that versions does not define; the machine's
+// own definitions win.
+func addVersions(versions, bundled map[string]config.VersionInfo) map[string]config.VersionInfo {
+	if versions == nil && len(bundled) > 0 {
+		versions = map[string]config.VersionInfo{}
+	}
+	for name, vinfo := range bundled {
+		if _, ok := versions[name]; !ok {
+			versions[name] = vinfo
+		}
+	}
+	return versions
+}
@@ -10,6 +10,9 @@ import (
 	"yapl/internal/archive"
 	"yapl/internal/config"
 	"yapl/internal/fs"
+	"yapl/internal/peer"
+	"yapl/internal/store"
+	"yapl/internal/warn"
 )
 
 // EnsureAll checks and acquires all configured dependencies.
@@ -37,6 +40,9 @@ func ensureProton(appCfg config.App, forceUpgrade bool, globalCfg config.Global)
 	if !ok {
 		return fmt.Errorf("proton version '%s' not defined in runner.json", appCfg.ProtonVersion)
 	}
+	if err := checkEmulation("proton", appCfg.ProtonVersion, vinfo, globalCfg.Emulator); err != nil {
+		return err
+	}
 
 	protonPath := filepath.Join("proton", appCfg.ProtonVersion)
 	if vinfo.Path != "" {
@@ -55,8 +61,7 @@ func ensureProton(appCfg config.App, forceUpgrade bool, globalCfg config.Global)
 					return fmt.Errorf("failed to remove existing proton path: %w", err)
 				}
 			}
-			ar := &archive.Archive{Source: vinfo.URL}
-			if err := ar.Extract(protonPath, true); err != nil {
+			if err := acquire(protonPath, "proton", appCfg.ProtonVersion, vinfo, globalCfg.LANPeers); err != nil {
 				return fmt.Errorf("failed to acquire proton: %w", err)
 			}
 		}
@@ -83,13 +88,57 @@ func ensure(name, version string, globalCfg config.Global) error {
 		return err
 	}
 	fmt.Printf("-> Acquiring %s '%s'...\n", name, version)
-	ar := &archive.Archive{Source: vinfo.URL}
-	if err := ar.Extract(depPath, true); err != nil {
+	if err := acquire(depPath, name, version, vinfo, globalCfg.LANPeers); err != nil {
 		return fmt.Errorf("failed to acquire dependency '%s': %w", name, err)
 	}
 	return nil
 }
 
+// acquire downloads a component into dir, through LAN peers if they are enabled, and
+// records where it came from and the files it was installed with.
+func acquire(dir, component, version string, vinfo config.VersionInfo, peers *config.LANPeers) error {
+	source, err := peer.Source(peers, vinfo)
+	if err != nil {
+		return err
+	}
+	ar := &archive.Archive{Source: source, StripComponents: vinfo.StripComponents}
+	if err := ar.Extract(dir, true); err != nil {
+		return err
+	}
+	if vinfo.SHA256 != "" && !strings.EqualFold(ar.SHA256, vinfo.SHA256) {
+		os.RemoveAll(dir)
+		return fmt.Errorf("the download's SHA-256 is %s, not the expected %s", ar.SHA256, vinfo.SHA256)
+	}
+	if err := recordProvenance(dir, component, version, vinfo, ar.SHA256); err != nil {
+		log.Printf("⚠️  Could not record where %s came from: %v", component, err)
+	}
+	if err := recordFiles(dir); err != nil {
+		log.Printf("⚠️  Could not record the files of %s: %v", component, err)
+	}
+	dedup(dir)
+	return nil
+}
+
+// dedup links a fresh install's files into the shared store, if it is enabled. Failing
+// only costs disk space, so it is a warning.
+func dedup(dir string) {
+	if !store.Enabled() {
+		return
+	}
+	stats, err := store.Dedup(dir)
+	if store.CrossDevice(err) {
+		log.Printf("⚠️  Could not deduplicate '%s': the store is on another filesystem.", dir)
+		return
+	}
+	if err != nil {
+		log.Printf("⚠️  Could not deduplicate '%s': %v", dir, err)
+		return
+	}
+	if stats.Linked > 0 {
+		fmt.Printf("-> Shared %d of %d files with other installs, saving %d MiB.\n", stats.Linked, stats.Files, stats.Saved>>20)
+	}
+}
+
 // InstallCustomComponents copies specific DLLs to the Wine prefix for custom DXVK/VKD3D setups.
 func InstallCustomComponents(prefixPath string, deps config.AppDependencies) error {
 	dxvkMap := map[string][]string{
@@ -118,13 +167,19 @@ func install(name, version, installPath, prefixPath string, dlls []string) error
 	if err := fs.MustCreateDirectory(destDir); err != nil {
 		return err
 	}
+	failed := 0
 	for _, file := range dlls {
 		srcPath := filepath.Join(sourceDir, file)
 		dstPath := filepath.Join(destDir, file)
 		if err := fs.CopyFile(srcPath, dstPath); err != nil {
-			log.Printf("⚠️  Failed to copy %s: %v", file, err)
+			warn.Printf("Failed to copy %s: %v", file, err)
+			failed++
 		}
 	}
+	warn.Summary()
+	if failed == len(dlls) {
+		return fmt.Errorf("none of the %d %s DLLs could be copied to '%s'", len(dlls), name, destDir)
+	}
 	return nil
 }
 
@@ -151,9 +206,15 @@ func patchProtonForWin32(version string) error {
 
 	fmt.Printf("-> Creating patched Proton version for win32 at '%s'...\n", patchedPath)
 
-	if err := fs.CopyDir(originalPath, patchedPath); err != nil {
+	copyTree := fs.CopyDir
+	if store.Enabled() {
+		copyTree = store.LinkTree // Only the proton script differs
+	}
+	if err := copyTree(originalPath, patchedPath); err != nil {
 		return fmt.Errorf("failed to copy proton directory for win32 patch: %w", err)
 	}
+	// The copy's proton script differs from the download's, so it is not verified.
+	os.Remove(filepath.Join(patchedPath, filesFile))
 
 	protonScriptPath := filepath.Join(patchedPath, "proton")
 	scriptBytes, err := os.ReadFile(protonScriptPath)
@@ -163,6 +224,8 @@ func patchProtonForWin32(version string) error {
 
 	modifiedScript := strings.ReplaceAll(string(scriptBytes), "wine64", "wine")
 
+	// The script may be a link to the original's, which must stay unpatched.
+	os.Remove(protonScriptPath)
 	if err := os.WriteFile(protonScriptPath, []byte(modifiedScript), 0755); err != nil {
 		return fmt.Errorf("could not write patched proton script: %w", err)
 	}
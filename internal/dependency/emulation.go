@@ -0,0 +1,43 @@
+package dependency
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"yapl/internal/config"
+)
+
+// binfmtDir is where the kernel lists the registered binary format handlers.
+const binfmtDir = "/proc/sys/fs/binfmt_misc"
+
+// binfmtEntries are the handlers each x86 emulator registers for x86_64 binaries.
+var binfmtEntries = map[string][]string{
+	"fex":   {"FEX-x86_64"},
+	"box64": {"box64", "box64.conf"},
+}
+
+// checkEmulation makes sure an x86_64 build picked for another architecture can run: the
+// configured x86 emulator (or, if none is set, FEX or Box64) must be registered with
+// binfmt_misc so the kernel hands it the build's binaries.
+func checkEmulation(component, version string, vinfo config.VersionInfo, emulator string) error {
+	if !vinfo.Emulated {
+		return nil
+	}
+	candidates := []string{"fex", "box64"}
+	if emulator != "" {
+		if _, ok := binfmtEntries[emulator]; !ok {
+			return fmt.Errorf("unknown x86_emulator '%s'. Use 'fex' or 'box64'", emulator)
+		}
+		candidates = []string{emulator}
+	}
+	for _, name := range candidates {
+		for _, entry := range binfmtEntries[name] {
+			if _, err := os.Stat(filepath.Join(binfmtDir, entry)); err == nil {
+				fmt.Printf("-> No %s build of %s '%s'; running the x86_64 build through %s.\n", config.HostArch(), component, version, name)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s '%s' has no %s build in runner.json ('arch'), and the x86_64 build needs FEX or Box64 registered with binfmt_misc", component, version, config.HostArch())
+}
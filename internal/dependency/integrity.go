@@ -0,0 +1,148 @@
+package dependency
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"yapl/internal/archive"
+	"yapl/internal/config"
+)
+
+// filesFile lists the files of an installed component with their sizes and checksums. It
+// is written when the component is installed, so 'yapl verify' can tell which files went
+// missing or were changed since. Like provenanceFile it travels with the component.
+const filesFile = ".yapl-files.json"
+
+// recordFiles writes the file list of a freshly installed component.
+func recordFiles(dir string) error {
+	files, err := archive.HashTree(dir)
+	if err != nil {
+		return err
+	}
+	delete(files, filesFile)
+	delete(files, provenanceFile)
+	data, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, filesFile), append(data, '\n'), 0644)
+}
+
+// Damage lists the files of a component that no longer match the ones it was installed
+// with, by path relative to its directory.
+type Damage struct {
+	Missing  []string
+	Modified []string
+}
+
+// Damaged reports whether any file is missing or modified.
+func (d Damage) Damaged() bool {
+	return len(d.Missing)+len(d.Modified) > 0
+}
+
+// VerifyFiles checks the component installed in dir against its file list, hashing the
+// files on every CPU. Files added since it was installed, e.g. by Proton unpacking its
+// builtin libraries on first use, are not reported. recorded is false if the component
+// has no file list, because it was installed by an older yapl or from a local path.
+func VerifyFiles(dir string) (damage Damage, recorded bool, err error) {
+	data, err := os.ReadFile(filepath.Join(dir, filesFile))
+	if os.IsNotExist(err) {
+		return Damage{}, false, nil
+	}
+	if err != nil {
+		return Damage{}, false, err
+	}
+	var files map[string]archive.ManifestEntry
+	if err := json.Unmarshal(data, &files); err != nil {
+		return Damage{}, false, fmt.Errorf("unreadable %s: %w", filesFile, err)
+	}
+
+	names := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				missing, modified := checkFile(filepath.Join(dir, filepath.FromSlash(name)), files[name])
+				mu.Lock()
+				if missing {
+					damage.Missing = append(damage.Missing, name)
+				} else if modified {
+					damage.Modified = append(damage.Modified, name)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for name := range files {
+		names <- name
+	}
+	close(names)
+	wg.Wait()
+	sort.Strings(damage.Missing)
+	sort.Strings(damage.Modified)
+	return damage, true, nil
+}
+
+// checkFile compares a file with its entry in a file list. Only files of the recorded
+// size are hashed.
+func checkFile(path string, want archive.ManifestEntry) (missing, modified bool) {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return true, false
+	}
+	if info.Size() != want.Size {
+		return false, true
+	}
+	sum, err := sha256File(path)
+	return false, err != nil || sum != want.SHA256
+}
+
+// Repair reinstalls a damaged component from the URL it was downloaded from. The damaged
+// copy is moved aside and put back if the reinstall fails, so repairing never leaves a
+// component worse off.
+func Repair(p Provenance, globalCfg config.Global) error {
+	if p.Source == "" {
+		return fmt.Errorf("it was not downloaded by yapl, so it cannot be reinstalled automatically")
+	}
+	aside := p.Dir + ".damaged"
+	if err := os.RemoveAll(aside); err != nil {
+		return err
+	}
+	if err := os.Rename(p.Dir, aside); err != nil {
+		return err
+	}
+
+	var err error
+	switch {
+	case p.Component == "proton":
+		vinfo := globalCfg.ProtonVersions[p.Version]
+		vinfo.URL, vinfo.SHA256 = p.Source, p.SHA256
+		err = acquire(p.Dir, p.Component, p.Version, vinfo, globalCfg.LANPeers)
+	case strings.HasPrefix(p.Component, "runtime-"):
+		version := strings.TrimPrefix(p.Component, "runtime-")
+		info := globalCfg.RuntimeVersions[version]
+		info.URL, info.SHA256 = p.Source, p.SHA256
+		err = installSnapshot(version, p.Version, info, p.Source)
+	default:
+		vinfo := globalCfg.DependencyVersions[p.Component][p.Version]
+		vinfo.URL, vinfo.SHA256 = p.Source, p.SHA256
+		err = acquire(p.Dir, p.Component, p.Version, vinfo, globalCfg.LANPeers)
+	}
+	if err != nil {
+		os.RemoveAll(p.Dir)
+		if renameErr := os.Rename(aside, p.Dir); renameErr != nil {
+			return fmt.Errorf("%w; the damaged copy was left at '%s'", err, aside)
+		}
+		return err
+	}
+	return os.RemoveAll(aside)
+}
@@ -0,0 +1,161 @@
+package dependency
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"yapl/internal/config"
+)
+
+// provenanceFile records where an installed component came from. It lives inside the
+// component's directory, so it travels with it to LAN peers and into bundles.
+const provenanceFile = ".yapl-provenance.json"
+
+// Provenance describes the origin and license of an installed component.
+type Provenance struct {
+	Component   string    `json:"component"`
+	Version     string    `json:"version"`
+	Dir         string    `json:"-"`
+	Source      string    `json:"source,omitempty"`
+	SHA256      string    `json:"sha256,omitempty"`
+	Project     string    `json:"project,omitempty"`
+	Licenses    []string  `json:"licenses,omitempty"` // License files, relative to Dir
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// recordProvenance writes the provenance file of a freshly installed component.
+func recordProvenance(dir, component, version string, vinfo config.VersionInfo, sha string) error {
+	p := Provenance{
+		Component:   component,
+		Version:     version,
+		Source:      vinfo.URL,
+		SHA256:      sha,
+		Project:     upstreamProject(vinfo),
+		Licenses:    findLicenses(dir),
+		InstalledAt: time.Now().UTC(),
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, provenanceFile), append(data, '\n'), 0644)
+}
+
+// ReadProvenance returns the provenance recorded for the component installed in dir.
+func ReadProvenance(dir string) (Provenance, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, provenanceFile))
+	if err != nil {
+		return Provenance{}, false
+	}
+	var p Provenance
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Provenance{}, false
+	}
+	p.Dir = dir
+	return p, true
+}
+
+// upstreamProject returns the project's home page: the configured one, or the repository a
+// GitHub or GitLab release URL belongs to, or else the download's host.
+func upstreamProject(vinfo config.VersionInfo) string {
+	if vinfo.Project != "" {
+		return vinfo.Project
+	}
+	u, err := url.Parse(vinfo.URL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if (u.Host == "github.com" || u.Host == "gitlab.com") && len(parts) >= 2 {
+		return "https://" + u.Host + "/" + parts[0] + "/" + parts[1]
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// findLicenses lists the license and copyright files at the top of a component and one
+// directory below it (Proton keeps some in files/, the runtime in its platform directories).
+func findLicenses(dir string) []string {
+	var found []string
+	for _, pattern := range []string{"*", filepath.Join("*", "*")} {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, m := range matches {
+			name := strings.ToUpper(filepath.Base(m))
+			if !strings.Contains(name, "LICENSE") && !strings.Contains(name, "LICENCE") &&
+				!strings.HasPrefix(name, "COPYING") && !strings.HasPrefix(name, "COPYRIGHT") &&
+				!strings.HasPrefix(name, "PATENTS") {
+				continue
+			}
+			if info, err := os.Stat(m); err == nil && info.Mode().IsRegular() {
+				rel, _ := filepath.Rel(dir, m)
+				found = append(found, rel)
+			}
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// Licenses returns the provenance of every installed Proton build, runtime snapshot and
+// dependency. Components installed before yapl recorded it, or from a local path, only
+// have their name, version and license files.
+func Licenses() ([]Provenance, error) {
+	type candidate struct{ component, version, dir string }
+	var candidates []candidate
+	add := func(component, root string) error {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		for _, e := range entries {
+			if e.IsDir() && !strings.HasSuffix(e.Name(), ".partial") {
+				candidates = append(candidates, candidate{component, e.Name(), filepath.Join(root, e.Name())})
+			}
+		}
+		return nil
+	}
+
+	if err := add("proton", "proton"); err != nil {
+		return nil, err
+	}
+	runtimes, _ := os.ReadDir(config.RuntimeSnapshotsDir)
+	for _, r := range runtimes {
+		if err := add("runtime-"+r.Name(), filepath.Join(config.RuntimeSnapshotsDir, r.Name())); err != nil {
+			return nil, err
+		}
+	}
+	deps, _ := os.ReadDir("dependencies")
+	for _, d := range deps {
+		switch d.Name() {
+		case "runtime", "runtime-snapshots", "runtime-cache", "archives":
+			continue
+		}
+		if d.IsDir() {
+			if err := add(d.Name(), filepath.Join("dependencies", d.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	list := make([]Provenance, 0, len(candidates))
+	for _, c := range candidates {
+		p := Provenance{Component: c.component, Version: c.version}
+		if data, err := os.ReadFile(filepath.Join(c.dir, provenanceFile)); err == nil {
+			if err := json.Unmarshal(data, &p); err != nil {
+				return nil, err
+			}
+		} else {
+			p.Licenses = findLicenses(c.dir)
+		}
+		p.Dir = c.dir
+		list = append(list, p)
+	}
+	return list, nil
+}
@@ -1,17 +1,23 @@
 package dependency
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"yapl/internal/archive"
 	"yapl/internal/config"
+	"yapl/internal/downloads"
+	"yapl/internal/peer"
+	"yapl/internal/zsync"
 )
 
 // EnsureRuntime checks if the Steam Linux Runtime is installed and up-to-date.
@@ -24,23 +30,36 @@ func EnsureRuntime(appCfg config.App, globalCfg config.Global) error {
 	if !ok {
 		return fmt.Errorf("runtime version '%s' not defined in runner.json", appCfg.RuntimeVersion)
 	}
+	if err := checkEmulation("runtime", appCfg.RuntimeVersion, runtimeInfo, globalCfg.Emulator); err != nil {
+		return err
+	}
 
 	if runtimeInfo.URL == "" {
 		return fmt.Errorf("runtime version '%s' has no URL specified in runner.json", appCfg.RuntimeVersion)
 	}
 
-	runtimeDir := filepath.Join("dependencies", "runtime", appCfg.RuntimeVersion)
-	if err := os.MkdirAll(runtimeDir, 0755); err != nil {
-		return fmt.Errorf("could not create runtime directory: %w", err)
+	if err := migrateRuntime(appCfg.RuntimeVersion); err != nil {
+		return fmt.Errorf("could not move runtime into a snapshot: %w", err)
+	}
+	if appCfg.RuntimeBuildID != "" {
+		return ensurePinnedRuntime(appCfg.RuntimeVersion, appCfg.RuntimeBuildID, runtimeInfo)
 	}
+	runtimeDir := config.RuntimeDir(config.App{RuntimeVersion: appCfg.RuntimeVersion})
 
 	// Determine if an update check is needed
 	updateNeeded := false
+	remoteBuild := ""
 	if _, err := os.Stat(filepath.Join(runtimeDir, "version.txt")); os.IsNotExist(err) {
 		updateNeeded = true // Not installed, so it needs an "update"
+	} else if reason := downloads.Deferred(); runtimeInfo.CheckForUpdates && reason != "" {
+		fmt.Printf("-> Skipping runtime update check: %s.\n", reason)
+	} else if reason := downloads.CheckSkipped(runtimeInfo.URL); runtimeInfo.CheckForUpdates && reason != "" {
+		fmt.Printf("-> Skipping runtime update check: %s.\n", reason)
 	} else if runtimeInfo.CheckForUpdates {
 		var err error
-		updateNeeded, err = runtimeNeedsUpdate(runtimeDir, runtimeInfo.URL)
+		updateNeeded, remoteBuild, err = downloads.CheckUpdate(runtimeInfo.URL, func(ctx context.Context) (bool, string, error) {
+			return runtimeNeedsUpdate(ctx, runtimeDir, runtimeInfo.URL)
+		})
 		if err != nil {
 			log.Printf("⚠️  Could not check for runtime update, proceeding with local version: %v", err)
 		}
@@ -52,53 +71,120 @@ func EnsureRuntime(appCfg config.App, globalCfg config.Global) error {
 	}
 
 	fmt.Println("-> Steam Linux Runtime needs to be installed or updated.")
-	ar := &archive.Archive{Source: runtimeInfo.URL}
-	if err := ar.Extract(runtimeDir, true); err != nil {
-		fmt.Printf("❌ Runtime installation failed: %v. Cleaning up...\n", err)
-		os.RemoveAll(runtimeDir)
+	if remoteBuild == "" {
+		var err error
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		remoteBuild, err = remoteBuildID(ctx, runtimeInfo.URL)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("could not fetch runtime BUILD_ID: %w", err)
+		}
+	}
+	// Peers only share runtimes pinned to a checksum; the rest are brought up to date with
+	// a delta update where the server allows it.
+	var source string
+	if peers := globalCfg.LANPeers; peers != nil && peers.Enabled && runtimeInfo.SHA256 != "" {
+		var err error
+		if source, err = peer.Source(peers, runtimeInfo); err != nil {
+			return err
+		}
+	} else {
+		source = runtimeSource(appCfg.RuntimeVersion, runtimeInfo.URL)
+	}
+	if err := installSnapshot(appCfg.RuntimeVersion, remoteBuild, runtimeInfo, source); err != nil {
 		return err
 	}
-
-	if err := postInstallRuntimeFixup(runtimeDir, runtimeInfo.URL); err != nil {
-		return fmt.Errorf("failed post-install fixup: %w", err)
+	// Games that are running keep using the snapshot they were started from.
+	if err := linkCurrent(appCfg.RuntimeVersion, remoteBuild); err != nil {
+		return fmt.Errorf("could not switch to the new runtime: %w", err)
 	}
 
 	fmt.Println("✅ Steam Linux Runtime setup complete.")
 	return nil
 }
 
-// runtimeNeedsUpdate compares the local runtime version with the remote version.
-func runtimeNeedsUpdate(runtimeDir, runtimeURL string) (bool, error) {
+// runtimeSource returns where to extract the runtime from. When the server publishes a
+// .zsync file next to the tarball, a copy of the tarball is kept and brought up to date
+// block by block, so an update only downloads what changed since the last one.
+func runtimeSource(version, runtimeURL string) string {
+	parsedURL, err := url.Parse(runtimeURL)
+	if err != nil || !strings.HasPrefix(parsedURL.Scheme, "http") {
+		return runtimeURL
+	}
+	cacheDir := filepath.Join("dependencies", "runtime-cache", version)
+	tarball := filepath.Join(cacheDir, path.Base(parsedURL.Path))
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return runtimeURL
+	}
+
+	stats, err := zsync.Sync(runtimeURL+".zsync", tarball)
+	if errors.Is(err, zsync.ErrUnavailable) {
+		os.RemoveAll(cacheDir)
+		os.Remove(filepath.Dir(cacheDir)) // Only if no other version is cached
+		return runtimeURL
+	}
+	if err != nil {
+		log.Printf("⚠️  Delta update failed, downloading the full runtime: %v", err)
+		return runtimeURL
+	}
+	if stats.Reused > 0 {
+		fmt.Printf("-> Delta update reused %d MiB and downloaded %d MiB.\n", stats.Reused>>20, stats.Downloaded>>20)
+	}
+	return tarball
+}
+
+// runtimeNeedsUpdate compares the local runtime version with the remote version, which
+// it also returns.
+func runtimeNeedsUpdate(ctx context.Context, runtimeDir, runtimeURL string) (bool, string, error) {
 	localVersionFile := filepath.Join(runtimeDir, "version.txt")
 	localVersion, err := os.ReadFile(localVersionFile)
 	if err != nil {
-		return true, fmt.Errorf("could not read local version file: %w", err)
+		return true, "", fmt.Errorf("could not read local version file: %w", err)
 	}
 
+	remoteVersion, err := remoteBuildID(ctx, runtimeURL)
+	if err != nil {
+		return false, "", err
+	}
+	return strings.TrimSpace(string(localVersion)) != remoteVersion, remoteVersion, nil
+}
+
+// remoteBuildID reads the BUILD_ID.txt published next to the runtime tarball.
+func remoteBuildID(ctx context.Context, runtimeURL string) (string, error) {
 	// Correctly parse the base URL to avoid the "no Host in request URL" error.
 	parsedURL, err := url.Parse(runtimeURL)
 	if err != nil {
-		return false, fmt.Errorf("could not parse runtime URL: %w", err)
+		return "", fmt.Errorf("could not parse runtime URL: %w", err)
 	}
 	parsedURL.Path = filepath.Dir(parsedURL.Path) + "/BUILD_ID.txt"
 	buildIDURL := parsedURL.String()
 
-	resp, err := http.Get(buildIDURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, buildIDURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return false, fmt.Errorf("could not fetch remote BUILD_ID: %w", err)
+		return "", fmt.Errorf("could not fetch remote BUILD_ID: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not fetch remote BUILD_ID: %s", resp.Status)
+	}
 
 	remoteVersion, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return false, fmt.Errorf("could not read remote BUILD_ID: %w", err)
+		return "", fmt.Errorf("could not read remote BUILD_ID: %w", err)
 	}
-
-	return strings.TrimSpace(string(localVersion)) != strings.TrimSpace(string(remoteVersion)), nil
+	buildID := strings.TrimSpace(string(remoteVersion))
+	if buildID == "" || buildID != filepath.Base(buildID) {
+		return "", fmt.Errorf("invalid remote BUILD_ID '%s'", buildID)
+	}
+	return buildID, nil
 }
 
 // postInstallRuntimeFixup performs tasks after extraction, like creating shims and version files.
-func postInstallRuntimeFixup(runtimeDir, runtimeURL string) error {
+func postInstallRuntimeFixup(runtimeDir, buildID string) error {
 	entryPointPath := filepath.Join(runtimeDir, "_v2-entry-point")
 	yaplEntryPointPath := filepath.Join(runtimeDir, "yapl-entry-point")
 	if _, err := os.Stat(entryPointPath); err == nil {
@@ -112,25 +198,7 @@ func postInstallRuntimeFixup(runtimeDir, runtimeURL string) error {
 		return fmt.Errorf("failed to create shim: %w", err)
 	}
 
-	// Correctly parse the URL to fetch the build ID.
-	parsedURL, err := url.Parse(runtimeURL)
-	if err != nil {
-		return fmt.Errorf("could not parse runtime URL for build ID: %w", err)
-	}
-	parsedURL.Path = filepath.Dir(parsedURL.Path) + "/BUILD_ID.txt"
-	buildIDURL := parsedURL.String()
-
-	resp, err := http.Get(buildIDURL)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	remoteVersion, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(filepath.Join(runtimeDir, "version.txt"), remoteVersion, 0644)
+	return os.WriteFile(filepath.Join(runtimeDir, "version.txt"), []byte(buildID+"\n"), 0644)
 }
 
 // createRuntimeShim creates a simple shell script needed by the runtime.
@@ -0,0 +1,247 @@
+package dependency
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"yapl/internal/archive"
+	"yapl/internal/config"
+)
+
+// Every installed build of a runtime lives in its own snapshot directory, and
+// dependencies/runtime/<version> is a symlink to the newest one. Updates install a new
+// snapshot next to the old one, so a running game never sees its runtime change.
+
+// RuntimeSnapshot is an installed build of a Steam Linux Runtime version.
+type RuntimeSnapshot struct {
+	Version  string
+	BuildID  string
+	Dir      string
+	Current  bool     // dependencies/runtime/<version> points at it
+	PinnedBy []string // Games and apps with runtime_build_id set to it
+	InUse    bool     // A running process was started from it
+}
+
+// ensurePinnedRuntime installs a specific build for a game that pins runtime_build_id.
+// Valve keeps every build under snapshots/<BUILD_ID>/, so the configured URL is pointed
+// at that directory.
+func ensurePinnedRuntime(version, buildID string, info config.VersionInfo) error {
+	dir := config.RuntimeDir(config.App{RuntimeVersion: version, RuntimeBuildID: buildID})
+	if _, err := os.Stat(filepath.Join(dir, "version.txt")); err == nil {
+		fmt.Printf("-> Using pinned Steam Linux Runtime build %s.\n", buildID)
+		return nil
+	}
+
+	parts := strings.Split(info.URL, "/")
+	found := false
+	for i := 0; i+2 < len(parts); i++ {
+		if parts[i] == "snapshots" {
+			parts[i+1] = buildID
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("runtime build %s is not installed and cannot be derived from '%s'", buildID, info.URL)
+	}
+	fmt.Printf("-> Installing pinned Steam Linux Runtime build %s...\n", buildID)
+	info.URL = strings.Join(parts, "/")
+	if err := installSnapshot(version, buildID, info, info.URL); err != nil {
+		return err
+	}
+	fmt.Println("✅ Steam Linux Runtime setup complete.")
+	return nil
+}
+
+// installSnapshot extracts a runtime build downloaded from info.URL (or a local copy of
+// it, source) into its snapshot directory. It is assembled under a temporary name, so a
+// failed or interrupted install never looks complete.
+func installSnapshot(version, buildID string, info config.VersionInfo, source string) error {
+	dir := config.RuntimeDir(config.App{RuntimeVersion: version, RuntimeBuildID: buildID})
+	if _, err := os.Stat(filepath.Join(dir, "version.txt")); err == nil {
+		return nil // Already installed, e.g. pinned by another game
+	}
+
+	partial := dir + ".partial"
+	if err := os.RemoveAll(partial); err != nil {
+		return err
+	}
+	ar := &archive.Archive{Source: source, StripComponents: info.StripComponents}
+	if err := ar.Extract(partial, true); err != nil {
+		fmt.Printf("❌ Runtime installation failed: %v. Cleaning up...\n", err)
+		os.RemoveAll(partial)
+		return err
+	}
+	if info.SHA256 != "" && !strings.EqualFold(ar.SHA256, info.SHA256) {
+		os.RemoveAll(partial)
+		return fmt.Errorf("the runtime's SHA-256 is %s, not the expected %s", ar.SHA256, info.SHA256)
+	}
+	if err := postInstallRuntimeFixup(partial, buildID); err != nil {
+		os.RemoveAll(partial)
+		return fmt.Errorf("failed post-install fixup: %w", err)
+	}
+	if err := verifyRuntimeInstall(partial); err != nil {
+		os.RemoveAll(partial)
+		return fmt.Errorf("runtime self-check failed: %w", err)
+	}
+	if err := recordProvenance(partial, "runtime-"+version, buildID, config.VersionInfo{URL: info.URL}, ar.SHA256); err != nil {
+		log.Printf("⚠️  Could not record where the runtime came from: %v", err)
+	}
+	if err := recordFiles(partial); err != nil {
+		log.Printf("⚠️  Could not record the files of the runtime: %v", err)
+	}
+	os.RemoveAll(dir)
+	return os.Rename(partial, dir)
+}
+
+// linkCurrent points dependencies/runtime/<version> at a snapshot. The link is replaced
+// atomically.
+func linkCurrent(version, buildID string) error {
+	link := config.RuntimeDir(config.App{RuntimeVersion: version})
+	target, err := filepath.Rel(filepath.Dir(link), config.RuntimeDir(config.App{RuntimeVersion: version, RuntimeBuildID: buildID}))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		return err
+	}
+	tmp := link + ".new"
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, link)
+}
+
+// migrateRuntime turns a runtime installed in place by older versions of yapl (or fetched
+// from a LAN peer) into a snapshot.
+func migrateRuntime(version string) error {
+	link := config.RuntimeDir(config.App{RuntimeVersion: version})
+	info, err := os.Lstat(link)
+	if err != nil || !info.IsDir() {
+		return nil // Not installed, or already a link
+	}
+	data, err := os.ReadFile(filepath.Join(link, "version.txt"))
+	if err != nil {
+		return nil // Incomplete install, which the next update replaces
+	}
+	buildID := strings.TrimSpace(string(data))
+	if buildID == "" || buildID != filepath.Base(buildID) {
+		return fmt.Errorf("invalid build ID '%s' in %s", buildID, filepath.Join(link, "version.txt"))
+	}
+
+	dir := config.RuntimeDir(config.App{RuntimeVersion: version, RuntimeBuildID: buildID})
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		if err := os.RemoveAll(link); err != nil {
+			return err
+		}
+	} else if err := os.Rename(link, dir); err != nil {
+		return err
+	}
+	return linkCurrent(version, buildID)
+}
+
+// RuntimeSnapshots lists the installed runtime builds with the games and apps that pin them,
+// which may inherit their runtime_version from the runner.json defaults d.
+func RuntimeSnapshots(d *config.Defaults) ([]RuntimeSnapshot, error) {
+	apps, err := config.LoadAll(d)
+	if err != nil {
+		return nil, err
+	}
+	running := runningCommandLines()
+
+	root := config.RuntimeSnapshotsDir
+	versions, err := os.ReadDir(root)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var snapshots []RuntimeSnapshot
+	for _, v := range versions {
+		builds, err := os.ReadDir(filepath.Join(root, v.Name()))
+		if err != nil {
+			return nil, err
+		}
+		current, _ := os.Readlink(config.RuntimeDir(config.App{RuntimeVersion: v.Name()}))
+		for _, b := range builds {
+			if !b.IsDir() || strings.HasSuffix(b.Name(), ".partial") {
+				continue
+			}
+			s := RuntimeSnapshot{
+				Version: v.Name(),
+				BuildID: b.Name(),
+				Dir:     filepath.Join(root, v.Name(), b.Name()),
+				Current: filepath.Base(current) == b.Name(),
+			}
+			for _, a := range apps {
+				if a.Config.RuntimeVersion == s.Version && a.Config.RuntimeBuildID == s.BuildID {
+					s.PinnedBy = append(s.PinnedBy, a.Type+"/"+a.Name)
+				}
+			}
+			needle := []byte(filepath.Join(s.Version, s.BuildID) + string(filepath.Separator))
+			for _, cmdline := range running {
+				if bytes.Contains(cmdline, needle) {
+					s.InUse = true
+					break
+				}
+			}
+			snapshots = append(snapshots, s)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].Version != snapshots[j].Version {
+			return snapshots[i].Version < snapshots[j].Version
+		}
+		return snapshots[i].BuildID < snapshots[j].BuildID
+	})
+	return snapshots, nil
+}
+
+// PruneRuntimes removes the snapshots that are neither current, pinned nor in use by a
+// running game, and returns them.
+func PruneRuntimes(d *config.Defaults) ([]RuntimeSnapshot, error) {
+	snapshots, err := RuntimeSnapshots(d)
+	if err != nil {
+		return nil, err
+	}
+	var removed []RuntimeSnapshot
+	var errs []error
+	for _, s := range snapshots {
+		if s.Current || s.InUse || len(s.PinnedBy) > 0 {
+			continue
+		}
+		if err := os.RemoveAll(s.Dir); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, s)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// runningCommandLines returns the command lines of all processes, which name the
+// runtime snapshot a container launch was started from.
+func runningCommandLines() [][]byte {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	var cmdlines [][]byte
+	for _, entry := range entries {
+		if entry.Name()[0] < '0' || entry.Name()[0] > '9' {
+			continue
+		}
+		if cmdline, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "cmdline")); err == nil {
+			cmdlines = append(cmdlines, cmdline)
+		}
+	}
+	return cmdlines
+}
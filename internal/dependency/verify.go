@@ -0,0 +1,257 @@
+package dependency
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// checkRequirements is the runtime's own test for what the host must provide (user
+// namespaces for bwrap, CPU features, ...).
+const checkRequirements = "pressure-vessel/bin/steam-runtime-check-requirements"
+
+// VerifyRuntime checks an installed runtime: the files yapl launches through must be
+// there, every file listed in the runtime's mtree manifests must match its recorded size
+// and checksum, and the runtime's requirements check must pass on this host.
+func VerifyRuntime(runtimeDir string) error {
+	for _, name := range []string{"yapl-entry-point", "yapl-shim"} {
+		info, err := os.Stat(filepath.Join(runtimeDir, name))
+		if err != nil {
+			return fmt.Errorf("runtime is missing '%s'", name)
+		}
+		if info.Mode()&0111 == 0 {
+			return fmt.Errorf("'%s' is not executable", name)
+		}
+	}
+
+	manifests, _ := filepath.Glob(filepath.Join(runtimeDir, "*", "*mtree.txt.gz"))
+	top, _ := filepath.Glob(filepath.Join(runtimeDir, "*mtree.txt.gz"))
+	for _, manifest := range append(top, manifests...) {
+		checked, err := verifyMtree(manifest)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(manifest), err)
+		}
+		fmt.Printf("-> Verified %d files against %s.\n", checked, filepath.Base(manifest))
+	}
+
+	tool := filepath.Join(runtimeDir, checkRequirements)
+	if _, err := os.Stat(tool); err != nil {
+		return nil // Older runtimes do not ship the check
+	}
+	out, err := exec.Command(tool).CombinedOutput()
+	if err != nil {
+		return &RequirementsError{Output: strings.TrimSpace(string(out)), Err: err}
+	}
+	return nil
+}
+
+// RequirementsError means the runtime is intact but this host cannot run it, e.g.
+// because unprivileged user namespaces are disabled.
+type RequirementsError struct {
+	Output string
+	Err    error
+}
+
+func (e *RequirementsError) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("the runtime's requirements check failed: %v", e.Err)
+	}
+	return fmt.Sprintf("the runtime's requirements check failed: %s", e.Output)
+}
+
+func (e *RequirementsError) Unwrap() error { return e.Err }
+
+// verifyRuntimeInstall runs VerifyRuntime on a freshly extracted runtime. A damaged
+// runtime is an error; a host that fails the requirements check only gets a warning, as
+// the runtime itself is fine and the launch will report the details.
+func verifyRuntimeInstall(runtimeDir string) error {
+	fmt.Println("-> Verifying the runtime...")
+	err := VerifyRuntime(runtimeDir)
+	var reqErr *RequirementsError
+	if errors.As(err, &reqErr) {
+		log.Printf("⚠️  Warning: %v", err)
+		return nil
+	}
+	return err
+}
+
+// verifyMtree compares the files listed in a gzipped mtree manifest (as shipped in the
+// runtime's platform directories) with those on disk and returns how many it checked.
+func verifyMtree(manifest string) (int, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return 0, err
+	}
+
+	type mtreeEntry struct {
+		path     string
+		keywords map[string]string
+	}
+	var entries []mtreeEntry
+	defaults := map[string]string{}
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "/set":
+			for k, v := range mtreeKeywords(fields[1:]) {
+				defaults[k] = v
+			}
+			continue
+		case "/unset":
+			for _, k := range fields[1:] {
+				delete(defaults, k)
+			}
+			continue
+		}
+
+		entry := make(map[string]string, len(defaults))
+		for k, v := range defaults {
+			entry[k] = v
+		}
+		for k, v := range mtreeKeywords(fields[1:]) {
+			entry[k] = v
+		}
+		if _, optional := entry["optional"]; optional {
+			continue
+		}
+		entries = append(entries, mtreeEntry{filepath.FromSlash(mtreeUnescape(fields[0])), entry})
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	// The manifest describes a tree next to it, such as the "files" directory of a
+	// platform; use the candidate that holds its first regular file.
+	base := strings.TrimSuffix(manifest, ".mtree.txt.gz")
+	dir := filepath.Dir(manifest)
+	root := ""
+	for _, e := range entries {
+		if e.keywords["type"] != "file" && e.keywords["type"] != "" {
+			continue
+		}
+		for _, candidate := range []string{filepath.Join(base, "files"), base, filepath.Join(dir, "files"), dir} {
+			if _, err := os.Lstat(filepath.Join(candidate, e.path)); err == nil {
+				root = candidate
+				break
+			}
+		}
+		break
+	}
+	if root == "" {
+		log.Printf("⚠️  Could not find the files described by %s, skipping it.", filepath.Base(manifest))
+		return 0, nil
+	}
+
+	for i, e := range entries {
+		if err := verifyMtreeEntry(filepath.Join(root, e.path), e.keywords); err != nil {
+			return i, err
+		}
+	}
+	return len(entries), nil
+}
+
+func verifyMtreeEntry(path string, entry map[string]string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("'%s' is missing", path)
+	}
+	switch entry["type"] {
+	case "dir":
+		if !info.IsDir() {
+			return fmt.Errorf("'%s' is not a directory", path)
+		}
+	case "link":
+		if info.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("'%s' is not a symlink", path)
+		}
+		if want, ok := entry["link"]; ok {
+			if got, _ := os.Readlink(path); got != mtreeUnescape(want) {
+				return fmt.Errorf("'%s' points at '%s' instead of '%s'", path, got, mtreeUnescape(want))
+			}
+		}
+	case "file", "":
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("'%s' is not a regular file", path)
+		}
+		if size, ok := entry["size"]; ok && size != strconv.FormatInt(info.Size(), 10) {
+			return fmt.Errorf("'%s' has size %d instead of %s", path, info.Size(), size)
+		}
+		want := entry["sha256digest"]
+		if want == "" {
+			want = entry["sha256"]
+		}
+		if want != "" {
+			got, err := sha256File(path)
+			if err != nil {
+				return err
+			}
+			if got != strings.ToLower(want) {
+				return fmt.Errorf("'%s' is corrupt (sha256 %s, expected %s)", path, got, want)
+			}
+		}
+	}
+	return nil
+}
+
+func mtreeKeywords(fields []string) map[string]string {
+	keywords := make(map[string]string, len(fields))
+	for _, field := range fields {
+		k, v, _ := strings.Cut(field, "=")
+		keywords[k] = v
+	}
+	return keywords
+}
+
+// mtreeUnescape decodes the \ooo octal escapes mtree uses for spaces and other special
+// characters in names.
+func mtreeUnescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,120 @@
+// Package desktop creates freedesktop.org menu entries for games and apps.
+package desktop
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"yapl/internal/fs"
+)
+
+// themeSizes are the icon sizes the hicolor theme looks up.
+var themeSizes = map[int]bool{16: true, 22: true, 24: true, 32: true, 48: true, 64: true, 96: true, 128: true, 256: true, 512: true}
+
+// Entry describes a menu entry.
+type Entry struct {
+	// ID names the .desktop file and icon, e.g. "yapl-games-foo".
+	ID      string
+	Name    string
+	Exec    []string
+	WorkDir string
+	Icons   []Icon
+}
+
+// DataHome returns $XDG_DATA_HOME, defaulting to ~/.local/share.
+func DataHome() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "share")
+}
+
+// Install writes the entry's icons into the hicolor theme and its .desktop file into
+// the applications directory, returning the path of the .desktop file.
+func Install(e Entry) (string, error) {
+	dataHome := DataHome()
+	icon, err := installIcons(dataHome, e.ID, e.Icons)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("[Desktop Entry]\n")
+	b.WriteString("Type=Application\n")
+	fmt.Fprintf(&b, "Name=%s\n", escapeValue(e.Name))
+	fmt.Fprintf(&b, "Exec=%s\n", execLine(e.Exec))
+	fmt.Fprintf(&b, "Path=%s\n", escapeValue(e.WorkDir))
+	if icon != "" {
+		fmt.Fprintf(&b, "Icon=%s\n", escapeValue(icon))
+	}
+	b.WriteString("Terminal=false\n")
+	b.WriteString("Categories=Game;\n")
+
+	appsDir := filepath.Join(dataHome, "applications")
+	if err := fs.MustCreateDirectory(appsDir); err != nil {
+		return "", err
+	}
+	path := filepath.Join(appsDir, e.ID+".desktop")
+	if err := os.WriteFile(path, []byte(b.String()), 0755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// installIcons writes every icon size the theme knows and returns the value for the
+// entry's Icon key: the theme name, or an absolute path if no size fits the theme.
+func installIcons(dataHome, id string, icons []Icon) (string, error) {
+	if len(icons) == 0 {
+		return "", nil
+	}
+	largest := icons[0]
+	themed := false
+	for _, icon := range icons {
+		if icon.Size > largest.Size {
+			largest = icon
+		}
+		if !themeSizes[icon.Size] {
+			continue
+		}
+		dir := filepath.Join(dataHome, "icons", "hicolor", fmt.Sprintf("%dx%d", icon.Size, icon.Size), "apps")
+		if err := fs.MustCreateDirectory(dir); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(dir, id+".png"), icon.PNG, 0644); err != nil {
+			return "", err
+		}
+		themed = true
+	}
+	if themed {
+		return id, nil
+	}
+
+	dir := filepath.Join(dataHome, "icons")
+	if err := fs.MustCreateDirectory(dir); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, id+".png")
+	return path, os.WriteFile(path, largest.PNG, 0644)
+}
+
+// execLine quotes arguments as the Desktop Entry Specification requires.
+func execLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\><~|&;$*?#()`=%") {
+			quoted[i] = arg
+			continue
+		}
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", `$`, `\$`)
+		quoted[i] = `"` + r.Replace(arg) + `"`
+	}
+	// '%' introduces field codes and backslashes are unescaped once more as a string value.
+	return escapeValue(strings.ReplaceAll(strings.Join(quoted, " "), "%", "%%"))
+}
+
+func escapeValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\t", `\t`).Replace(s)
+}
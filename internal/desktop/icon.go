@@ -0,0 +1,292 @@
+package desktop
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Resource types of a PE file's resource directory.
+const (
+	rtIcon      = 3
+	rtGroupIcon = 14
+)
+
+// Icon is one image of an executable's icon, encoded as PNG.
+type Icon struct {
+	Size int
+	PNG  []byte
+}
+
+// ExtractIcons returns every image of the first icon group in a Windows executable,
+// which is the icon Explorer shows for it.
+func ExtractIcons(exePath string) ([]Icon, error) {
+	f, err := pe.Open(exePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	section, sectionRVA, err := resourceSection(f)
+	if err != nil {
+		return nil, err
+	}
+	icons := make(map[uint32][]byte)
+	var group []byte
+	err = walkResources(section, sectionRVA, func(resType, id uint32, data []byte) {
+		switch resType {
+		case rtIcon:
+			icons[id] = data
+		case rtGroupIcon:
+			if group == nil {
+				group = data
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, fmt.Errorf("%s has no icon", exePath)
+	}
+
+	var result []Icon
+	for _, id := range parseGroup(group) {
+		data, ok := icons[uint32(id)]
+		if !ok {
+			continue
+		}
+		icon, err := decodeIconImage(data)
+		if err != nil {
+			continue
+		}
+		result = append(result, icon)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%s has no readable icon images", exePath)
+	}
+	return result, nil
+}
+
+// resourceSection returns the resource directory with the rest of its section, and
+// the RVA that the returned data starts at.
+func resourceSection(f *pe.File) ([]byte, uint32, error) {
+	var dir pe.DataDirectory
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if len(oh.DataDirectory) > pe.IMAGE_DIRECTORY_ENTRY_RESOURCE {
+			dir = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_RESOURCE]
+		}
+	case *pe.OptionalHeader64:
+		if len(oh.DataDirectory) > pe.IMAGE_DIRECTORY_ENTRY_RESOURCE {
+			dir = oh.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_RESOURCE]
+		}
+	}
+	if dir.VirtualAddress == 0 {
+		return nil, 0, fmt.Errorf("executable has no resources")
+	}
+	for _, s := range f.Sections {
+		if dir.VirtualAddress >= s.VirtualAddress && dir.VirtualAddress < s.VirtualAddress+s.VirtualSize {
+			data, err := s.Data()
+			if err != nil {
+				return nil, 0, err
+			}
+			offset := dir.VirtualAddress - s.VirtualAddress
+			if int(offset) >= len(data) {
+				return nil, 0, fmt.Errorf("resource directory is truncated")
+			}
+			return data[offset:], dir.VirtualAddress, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("resource directory is outside all sections")
+}
+
+// walkResources visits every type/name/language leaf of the resource tree.
+// Directory offsets are relative to the start of rsrc, data entries hold RVAs.
+func walkResources(rsrc []byte, rsrcRVA uint32, visit func(resType, id uint32, data []byte)) error {
+	types, err := readDirectory(rsrc, 0)
+	if err != nil {
+		return err
+	}
+	for _, t := range types {
+		if t.name&0x80000000 != 0 || (t.name != rtIcon && t.name != rtGroupIcon) || !t.isDir {
+			continue
+		}
+		names, err := readDirectory(rsrc, t.offset)
+		if err != nil {
+			return err
+		}
+		for _, n := range names {
+			if !n.isDir {
+				continue
+			}
+			langs, err := readDirectory(rsrc, n.offset)
+			if err != nil {
+				return err
+			}
+			if len(langs) == 0 || langs[0].isDir {
+				continue
+			}
+			off := langs[0].offset
+			if int(off)+8 > len(rsrc) {
+				return fmt.Errorf("corrupt resource entry")
+			}
+			rva := binary.LittleEndian.Uint32(rsrc[off:])
+			size := binary.LittleEndian.Uint32(rsrc[off+4:])
+			start := int64(rva) - int64(rsrcRVA)
+			if start < 0 || start+int64(size) > int64(len(rsrc)) {
+				continue
+			}
+			visit(t.name, n.name, rsrc[start:start+int64(size)])
+		}
+	}
+	return nil
+}
+
+type dirEntry struct {
+	name   uint32
+	offset uint32
+	isDir  bool
+}
+
+func readDirectory(rsrc []byte, offset uint32) ([]dirEntry, error) {
+	if int(offset)+16 > len(rsrc) {
+		return nil, fmt.Errorf("corrupt resource directory")
+	}
+	count := int(binary.LittleEndian.Uint16(rsrc[offset+12:])) + int(binary.LittleEndian.Uint16(rsrc[offset+14:]))
+	entries := make([]dirEntry, 0, count)
+	for i := 0; i < count; i++ {
+		pos := int(offset) + 16 + i*8
+		if pos+8 > len(rsrc) {
+			return nil, fmt.Errorf("corrupt resource directory")
+		}
+		name := binary.LittleEndian.Uint32(rsrc[pos:])
+		target := binary.LittleEndian.Uint32(rsrc[pos+4:])
+		entries = append(entries, dirEntry{name: name, offset: target &^ 0x80000000, isDir: target&0x80000000 != 0})
+	}
+	return entries, nil
+}
+
+// parseGroup reads a GRPICONDIR structure and returns the RT_ICON IDs of its images.
+func parseGroup(data []byte) []uint16 {
+	if len(data) < 6 {
+		return nil
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:]))
+	var ids []uint16
+	for i := 0; i < count; i++ {
+		pos := 6 + i*14
+		if pos+14 > len(data) {
+			break
+		}
+		ids = append(ids, binary.LittleEndian.Uint16(data[pos+12:]))
+	}
+	return ids
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// decodeIconImage converts an RT_ICON resource, either a PNG or a DIB, to PNG.
+func decodeIconImage(data []byte) (Icon, error) {
+	if bytes.HasPrefix(data, pngSignature) {
+		cfg, err := png.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return Icon{}, err
+		}
+		return Icon{Size: cfg.Width, PNG: data}, nil
+	}
+	img, err := decodeDIB(data)
+	if err != nil {
+		return Icon{}, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return Icon{}, err
+	}
+	return Icon{Size: img.Bounds().Dx(), PNG: buf.Bytes()}, nil
+}
+
+// decodeDIB decodes the bitmap of an icon: a BITMAPINFOHEADER, an optional palette,
+// the colour bitmap and a 1-bit transparency mask, both stored bottom-up.
+func decodeDIB(data []byte) (*image.NRGBA, error) {
+	if len(data) < 40 {
+		return nil, fmt.Errorf("icon bitmap too short")
+	}
+	headerSize := int(binary.LittleEndian.Uint32(data))
+	width := int(int32(binary.LittleEndian.Uint32(data[4:])))
+	height := int(int32(binary.LittleEndian.Uint32(data[8:]))) / 2 // colour bitmap + mask
+	bitCount := int(binary.LittleEndian.Uint16(data[14:]))
+	colorsUsed := int(binary.LittleEndian.Uint32(data[32:]))
+	if width <= 0 || height <= 0 || width > 1024 || height > 1024 {
+		return nil, fmt.Errorf("unsupported icon size %dx%d", width, height)
+	}
+
+	var palette []color.NRGBA
+	pos := headerSize
+	if bitCount <= 8 {
+		if colorsUsed == 0 {
+			colorsUsed = 1 << bitCount
+		}
+		for i := 0; i < colorsUsed; i++ {
+			if pos+4 > len(data) {
+				return nil, fmt.Errorf("icon palette truncated")
+			}
+			palette = append(palette, color.NRGBA{R: data[pos+2], G: data[pos+1], B: data[pos], A: 255})
+			pos += 4
+		}
+	}
+
+	stride := (width*bitCount + 31) / 32 * 4
+	maskStride := (width + 31) / 32 * 4
+	maskStart := pos + stride*height
+	if maskStart > len(data) {
+		return nil, fmt.Errorf("icon bitmap truncated")
+	}
+	hasMask := maskStart+maskStride*height <= len(data)
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	hasAlpha := false
+	for y := 0; y < height; y++ {
+		row := data[pos+(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bitCount {
+			case 32:
+				c = color.NRGBA{R: row[x*4+2], G: row[x*4+1], B: row[x*4], A: row[x*4+3]}
+				hasAlpha = hasAlpha || c.A != 0
+			case 24:
+				c = color.NRGBA{R: row[x*3+2], G: row[x*3+1], B: row[x*3], A: 255}
+			case 8, 4, 1:
+				bit := x * bitCount
+				index := int(row[bit/8]>>(8-bitCount-bit%8)) & (1<<bitCount - 1)
+				if index < len(palette) {
+					c = palette[index]
+				}
+			default:
+				return nil, fmt.Errorf("unsupported icon bit depth %d", bitCount)
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	// Without an alpha channel, transparency comes from the AND mask.
+	if !hasAlpha && hasMask {
+		for y := 0; y < height; y++ {
+			row := data[maskStart+(height-1-y)*maskStride:]
+			for x := 0; x < width; x++ {
+				c := img.NRGBAAt(x, y)
+				if row[x/8]&(0x80>>(x%8)) != 0 {
+					c.A = 0
+				} else {
+					c.A = 255
+				}
+				img.SetNRGBA(x, y, c)
+			}
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,107 @@
+// Package detect guesses the main executable of a game installed in a Wine prefix.
+package detect
+
+import (
+	"debug/pe"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Candidate is an executable that may be the game.
+type Candidate struct {
+	Path string // Relative to the prefix
+	Size int64
+	GUI  bool
+}
+
+// skippedDirs hold Windows, runtimes and redistributables rather than games.
+var skippedDirs = map[string]bool{
+	"windows":              true,
+	"programdata":          true,
+	"users":                true,
+	"common files":         true,
+	"internet explorer":    true,
+	"windows media player": true,
+	"windows nt":           true,
+	"_commonredist":        true,
+	"redist":               true,
+	"redistributables":     true,
+	"directx":              true,
+	"vcredist":             true,
+}
+
+// skippedPrefixes are the names of installers, uninstallers, crash reporters and launchers.
+var skippedPrefixes = []string{
+	"unins", "uninst", "setup", "install", "vcredist", "vc_redist", "dxsetup", "dotnet",
+	"ndp", "oalinst", "physx", "crashreport", "crashhandler", "unitycrashhandler",
+	"crashpad", "bugreport", "errorreport", "launcherpatcher", "easyanticheat",
+	"battleye", "be_service", "eac", "ue4prereq", "uerprereq", "directx", "winecfg",
+	"notepad", "regedit", "explorer", "iexplore", "wmplayer", "cefsharp", "quicksfv",
+}
+
+// Executables returns the likely game executables below drive_c, best guess first:
+// GUI programs before console ones, then by size.
+func Executables(prefixPath string) ([]Candidate, error) {
+	root := filepath.Join(prefixPath, "drive_c")
+	var candidates []Candidate
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Unreadable directories are not worth failing over
+		}
+		name := strings.ToLower(d.Name())
+		if d.IsDir() {
+			if skippedDirs[name] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".exe") || skippedName(name) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		gui, ok := subsystemGUI(path)
+		if !ok {
+			return nil // Not a valid PE file
+		}
+		rel, _ := filepath.Rel(prefixPath, path)
+		candidates = append(candidates, Candidate{Path: rel, Size: info.Size(), GUI: gui})
+		return nil
+	})
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].GUI != candidates[j].GUI {
+			return candidates[i].GUI
+		}
+		return candidates[i].Size > candidates[j].Size
+	})
+	return candidates, err
+}
+
+func skippedName(name string) bool {
+	for _, prefix := range skippedPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// subsystemGUI reports whether the PE file is a Windows GUI program.
+func subsystemGUI(path string) (bool, bool) {
+	f, err := pe.Open(path)
+	if err != nil {
+		return false, false
+	}
+	defer f.Close()
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		return oh.Subsystem == pe.IMAGE_SUBSYSTEM_WINDOWS_GUI, true
+	case *pe.OptionalHeader64:
+		return oh.Subsystem == pe.IMAGE_SUBSYSTEM_WINDOWS_GUI, true
+	}
+	return false, false
+}
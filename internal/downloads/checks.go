@@ -0,0 +1,126 @@
+package downloads
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ChecksPath caches the update checks that found nothing new or got no answer, relative
+// to the yapl root, so a launch does not wait on the same unreachable server every time.
+const ChecksPath = "state/update-checks.json"
+
+var (
+	checkBudget = 3 * time.Second
+	checkTTL    = 6 * time.Hour
+	checksMu    sync.Mutex
+)
+
+// SetUpdateChecks sets how long an update check may hold up a launch ("3s" by default,
+// "0s" to skip checks) and how long a check that found nothing is trusted ("6h" by
+// default, "0s" to check every time). Empty strings keep the defaults.
+func SetUpdateChecks(budget, ttl string) error {
+	if budget != "" {
+		d, err := time.ParseDuration(budget)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid update check budget '%s'", budget)
+		}
+		checkBudget = d
+	}
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid update check TTL '%s'", ttl)
+		}
+		checkTTL = d
+	}
+	return nil
+}
+
+type checkResult struct {
+	Checked time.Time `json:"checked"`
+	Remote  string    `json:"remote,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// CheckSkipped returns why the update check of key (e.g. the URL it reads) should not run
+// now, or "" if it may: checks are disabled, or the last one found nothing within the TTL.
+func CheckSkipped(key string) string {
+	if checkBudget == 0 {
+		return "update checks are disabled"
+	}
+	last, ok := loadChecks()[key]
+	if !ok || checkTTL == 0 || time.Since(last.Checked) >= checkTTL {
+		return ""
+	}
+	found := "found no update"
+	if last.Error != "" {
+		found = "got no answer"
+	}
+	next := time.Until(last.Checked.Add(checkTTL)).Round(time.Minute)
+	return fmt.Sprintf("the last check %s, next check in %s", found, next)
+}
+
+// CheckUpdate runs the update check of key within the budget. check is given a context
+// that ends with the budget and reports whether an update is available and the remote
+// version. A check that finds no update, fails or runs out of time is remembered, so
+// CheckSkipped holds off the next one for the TTL.
+func CheckUpdate(key string, check func(ctx context.Context) (bool, string, error)) (bool, string, error) {
+	type answer struct {
+		update bool
+		remote string
+		err    error
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkBudget)
+	defer cancel()
+	done := make(chan answer, 1)
+	go func() {
+		update, remote, err := check(ctx)
+		done <- answer{update, remote, err}
+	}()
+	var a answer
+	select {
+	case a = <-done:
+	case <-ctx.Done():
+		a.err = fmt.Errorf("no answer within %s", checkBudget)
+	}
+	if !a.update {
+		result := checkResult{Checked: time.Now().UTC(), Remote: a.remote}
+		if a.err != nil {
+			result.Error = a.err.Error()
+		}
+		saveCheck(key, result)
+	}
+	return a.update, a.remote, a.err
+}
+
+func loadChecks() map[string]checkResult {
+	checks := map[string]checkResult{}
+	if data, err := os.ReadFile(ChecksPath); err == nil {
+		json.Unmarshal(data, &checks)
+	}
+	return checks
+}
+
+// saveCheck records a result. The cache is only an optimisation, so failures are ignored.
+func saveCheck(key string, result checkResult) {
+	if checkTTL == 0 {
+		return
+	}
+	checksMu.Lock()
+	defer checksMu.Unlock()
+	checks := loadChecks()
+	checks[key] = result
+	data, err := json.MarshalIndent(checks, "", "  ")
+	if err != nil || os.MkdirAll(filepath.Dir(ChecksPath), 0755) != nil {
+		return
+	}
+	tmp := fmt.Sprintf("%s.%d.tmp", ChecksPath, os.Getpid())
+	if os.WriteFile(tmp, append(data, '\n'), 0644) == nil {
+		os.Rename(tmp, ChecksPath)
+	}
+}
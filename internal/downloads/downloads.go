@@ -0,0 +1,309 @@
+// Package downloads queues downloads across all running yapl processes, so a background
+// update never starves a download the user is waiting for. Each download is a ticket file
+// in Dir; there is no daemon, every process schedules itself by reading the others' tickets.
+package downloads
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Dir holds one ticket per queued or running download, relative to the yapl root.
+const Dir = "state/downloads"
+
+// Priorities, highest first.
+const (
+	PriorityUser       = "user"
+	PriorityBackground = "background"
+)
+
+// Ticket states.
+const (
+	StateQueued = "queued"
+	StateActive = "active"
+)
+
+const pollInterval = 500 * time.Millisecond
+
+// Ticket describes a download in the queue.
+type Ticket struct {
+	ID       string    `json:"id"`
+	PID      int       `json:"pid"`
+	URL      string    `json:"url"`
+	Priority string    `json:"priority"`
+	State    string    `json:"state"`
+	Paused   bool      `json:"paused"`
+	Queued   time.Time `json:"queued"`
+	Bytes    int64     `json:"bytes"`
+	Total    int64     `json:"total"`
+}
+
+var (
+	priority      = PriorityUser
+	maxConcurrent = 2
+	sequence      int
+)
+
+// SetBackground marks this process's downloads as background downloads, which give way
+// to downloads the user is waiting for.
+func SetBackground(background bool) {
+	priority = PriorityUser
+	if background {
+		priority = PriorityBackground
+	}
+}
+
+// SetMaxConcurrent sets how many downloads may run at once across all yapl processes.
+// A limit below 1 keeps the default of 2.
+func SetMaxConcurrent(limit int) {
+	if limit > 0 {
+		maxConcurrent = limit
+	}
+}
+
+// Handle is a queued download owned by this process.
+type Handle struct {
+	ticket    Ticket
+	lastWrite time.Time
+	lastCheck time.Time
+}
+
+// Acquire queues a download of url and blocks until it may start: fewer than the
+// concurrency limit are active and no download of higher priority (or of equal priority
+// but queued earlier) is still waiting. Background downloads fail with ErrDeferred
+// outside the download schedule.
+func Acquire(url string) (*Handle, error) {
+	if priority == PriorityBackground {
+		if reason := Deferred(); reason != "" {
+			return nil, fmt.Errorf("%w: %s", ErrDeferred, reason)
+		}
+	}
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return nil, err
+	}
+	sequence++
+	h := &Handle{ticket: Ticket{
+		ID:       fmt.Sprintf("%d-%d", os.Getpid(), sequence),
+		PID:      os.Getpid(),
+		URL:      url,
+		Priority: priority,
+		State:    StateQueued,
+		Queued:   time.Now(),
+	}}
+	if err := h.save(); err != nil {
+		return nil, err
+	}
+
+	announced := false
+	for {
+		tickets, err := List()
+		if err != nil {
+			h.Release()
+			return nil, err
+		}
+		if h.mayStart(tickets) {
+			break
+		}
+		if !announced {
+			fmt.Printf("-> Download queued behind %d other download(s)...\n", len(tickets)-1)
+			announced = true
+		}
+		time.Sleep(pollInterval)
+	}
+	h.ticket.State = StateActive
+	return h, h.save()
+}
+
+func (h *Handle) mayStart(tickets []Ticket) bool {
+	active := 0
+	for _, t := range tickets {
+		if t.ID == h.ticket.ID {
+			continue
+		}
+		// Paused downloads, and background downloads when we are a user download, hold
+		// their connection but yield their slot.
+		yields := t.Priority == PriorityBackground && h.ticket.Priority == PriorityUser
+		if t.State == StateActive && !t.Paused && !yields {
+			active++
+		}
+		if t.State == StateQueued && !t.Paused && ahead(t, h.ticket) {
+			return false
+		}
+	}
+	return active < maxConcurrent
+}
+
+// ahead reports whether queued ticket a should start before b.
+func ahead(a, b Ticket) bool {
+	if a.Priority != b.Priority {
+		return a.Priority == PriorityUser
+	}
+	return a.Queued.Before(b.Queued)
+}
+
+// SetTotal records the download's size once the server has reported it.
+func (h *Handle) SetTotal(total int64) {
+	h.ticket.Total = total
+	h.save()
+}
+
+// Reader wraps the download's body: it records progress in the ticket, stops while the
+// ticket is paused and, for background downloads, while a user download is running.
+func (h *Handle) Reader(r io.Reader) io.Reader {
+	return &queuedReader{h: h, r: r}
+}
+
+type queuedReader struct {
+	h *Handle
+	r io.Reader
+}
+
+func (q *queuedReader) Read(p []byte) (int, error) {
+	q.h.waitWhileHeld()
+	n, err := q.r.Read(p)
+	q.h.ticket.Bytes += int64(n)
+	if time.Since(q.h.lastWrite) > time.Second {
+		q.h.save()
+	}
+	return n, err
+}
+
+// waitWhileHeld blocks while the download is paused or has to yield to a user download.
+func (h *Handle) waitWhileHeld() {
+	if time.Since(h.lastCheck) < time.Second {
+		return // Checked recently
+	}
+	h.lastCheck = time.Now()
+	announced := false
+	for {
+		held := h.paused()
+		if !held && h.ticket.Priority == PriorityBackground {
+			tickets, _ := List()
+			for _, t := range tickets {
+				if t.Priority == PriorityUser && !t.Paused {
+					held = true
+					break
+				}
+			}
+		}
+		if !held {
+			return
+		}
+		if !announced {
+			fmt.Println("-> Download paused...")
+			announced = true
+		}
+		h.save()
+		time.Sleep(pollInterval)
+	}
+}
+
+// paused reloads the pause flag, which 'yapl downloads pause' sets in the ticket file.
+func (h *Handle) paused() bool {
+	var t Ticket
+	if readTicket(h.path(), &t) == nil {
+		h.ticket.Paused = t.Paused
+	}
+	return h.ticket.Paused
+}
+
+// Release removes the download from the queue.
+func (h *Handle) Release() {
+	os.Remove(h.path())
+}
+
+func (h *Handle) path() string {
+	return filepath.Join(Dir, h.ticket.ID+".json")
+}
+
+func (h *Handle) save() error {
+	h.paused() // Keep a pause set since the last save
+	h.lastWrite = time.Now()
+	return writeTicket(h.path(), h.ticket)
+}
+
+// List returns the downloads of running yapl processes in queue order, removing
+// tickets left behind by processes that died.
+func List() ([]Ticket, error) {
+	files, err := filepath.Glob(filepath.Join(Dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var tickets []Ticket
+	for _, file := range files {
+		var t Ticket
+		if err := readTicket(file, &t); err != nil {
+			continue
+		}
+		if !alive(t.PID) {
+			os.Remove(file)
+			continue
+		}
+		tickets = append(tickets, t)
+	}
+	sort.SliceStable(tickets, func(i, j int) bool {
+		if tickets[i].State != tickets[j].State {
+			return tickets[i].State == StateActive
+		}
+		return ahead(tickets[i], tickets[j])
+	})
+	return tickets, nil
+}
+
+// SetPaused pauses or resumes the download with the given ID (or ID prefix), or all
+// downloads if id is "all". It returns how many downloads were changed.
+func SetPaused(id string, paused bool) (int, error) {
+	tickets, err := List()
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for _, t := range tickets {
+		if id != "all" && !strings.HasPrefix(t.ID, id) {
+			continue
+		}
+		t.Paused = paused
+		if err := writeTicket(filepath.Join(Dir, t.ID+".json"), t); err != nil {
+			return changed, err
+		}
+		changed++
+	}
+	if changed == 0 {
+		return 0, errors.New("no matching download")
+	}
+	return changed, nil
+}
+
+func alive(pid int) bool {
+	return pid > 0 && syscall.Kill(pid, 0) == nil
+}
+
+func readTicket(path string, t *Ticket) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, t)
+}
+
+// writeTicket replaces the ticket atomically so readers never see a partial file.
+func writeTicket(path string, t Ticket) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp" + strconv.Itoa(os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,73 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"yapl/internal/policy"
+)
+
+// ErrDeferred is returned for non-essential downloads that may not run right now.
+var ErrDeferred = errors.New("non-essential download deferred")
+
+var (
+	allowedHours   string
+	deferOnMetered = true
+	deferReason    *string
+)
+
+// SetSchedule restricts non-essential downloads (background downloads and update checks)
+// to the daily window allowedHours ("HH:MM-HH:MM", empty for any time) and, if
+// deferMetered is set, to connections NetworkManager does not consider metered.
+func SetSchedule(hours string, deferMetered bool) error {
+	if hours != "" {
+		if _, _, err := policy.ParseWindow(hours, time.Now()); err != nil {
+			return err
+		}
+	}
+	allowedHours = hours
+	deferOnMetered = deferMetered
+	deferReason = nil
+	return nil
+}
+
+// Deferred returns why non-essential downloads should wait, or "" if they may run now.
+// The answer is worked out once per process.
+func Deferred() string {
+	if deferReason != nil {
+		return *deferReason
+	}
+	reason := ""
+	if allowedHours != "" {
+		now := time.Now()
+		from, until, _ := policy.ParseWindow(allowedHours, now)
+		if now.Before(from) || !now.Before(until) {
+			reason = fmt.Sprintf("downloads are only scheduled between %s", allowedHours)
+		}
+	}
+	if reason == "" && deferOnMetered && Metered() {
+		reason = "the network connection is metered"
+	}
+	deferReason = &reason
+	return reason
+}
+
+// Metered reports whether NetworkManager considers the primary connection metered,
+// either because the user said so or because it guessed (e.g. a phone hotspot).
+// Without NetworkManager the connection is assumed not to be metered.
+func Metered() bool {
+	out, err := exec.Command("busctl", "get-property", "org.freedesktop.NetworkManager",
+		"/org/freedesktop/NetworkManager", "org.freedesktop.NetworkManager", "Metered").Output()
+	if err != nil {
+		return false
+	}
+	// NMMetered: 0 unknown, 1 yes, 2 no, 3 guess yes, 4 guess no.
+	switch strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(out)), "u")) {
+	case "1", "3":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+// Package events writes machine-readable progress and results for frontends (--json).
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	mu  sync.Mutex
+	out io.Writer
+)
+
+// Enable turns on JSON output: every event becomes one JSON object per line on w.
+func Enable(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	out = w
+}
+
+// Enabled reports whether events are being written.
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return out != nil
+}
+
+// Emit writes an event with the given fields. It does nothing unless JSON output is enabled.
+func Emit(event string, fields map[string]interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	if out == nil {
+		return
+	}
+	record := make(map[string]interface{}, len(fields)+2)
+	for k, v := range fields {
+		record[k] = v
+	}
+	record["event"] = event
+	record["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	out.Write(append(data, '\n'))
+}
+
+// LogWriter passes log lines through to w and turns warnings and errors into
+// "warning" and "error" events.
+func LogWriter(w io.Writer) io.Writer {
+	return logWriter{w}
+}
+
+type logWriter struct {
+	w io.Writer
+}
+
+func (l logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(string(bytes.TrimRight(p, "\n")), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "❌"):
+			Emit("error", map[string]interface{}{"message": strings.TrimSpace(strings.TrimPrefix(trimmed, "❌"))})
+		case strings.HasPrefix(trimmed, "⚠️"):
+			Emit("warning", map[string]interface{}{"message": strings.TrimSpace(strings.TrimPrefix(trimmed, "⚠️"))})
+		}
+	}
+	return l.w.Write(p)
+}
+
+// Progress counts bytes read through it and emits throttled progress events.
+type Progress struct {
+	R     io.Reader
+	Event string
+	Name  string
+	Total int64 // -1 if unknown
+
+	read int64
+	last time.Time
+}
+
+func (p *Progress) Read(b []byte) (int, error) {
+	n, err := p.R.Read(b)
+	p.read += int64(n)
+	if err == io.EOF || time.Since(p.last) >= 500*time.Millisecond {
+		p.last = time.Now()
+		Emit(p.Event, map[string]interface{}{"source": p.Name, "bytes": p.read, "total": p.Total})
+	}
+	return n, err
+}
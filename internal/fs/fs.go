@@ -35,6 +35,8 @@ func DirExistsAndIsNotEmpty(path string) bool {
 	return err == nil
 }
 
+// CopyFile copies src to dst with src's permissions. Where the filesystem supports it
+// the copy is a reflink, which is instant and takes no space until either file changes.
 func CopyFile(src, dst string) error {
 	in, err := os.Open(src)
 	if err != nil {
@@ -48,8 +50,10 @@ func CopyFile(src, dst string) error {
 	}
 	defer out.Close()
 
-	if _, err := io.Copy(out, in); err != nil {
-		return err
+	if err := reflink(out, in); err != nil {
+		if _, err := io.Copy(out, in); err != nil {
+			return err
+		}
 	}
 
 	info, err := os.Stat(src)
@@ -59,6 +63,7 @@ func CopyFile(src, dst string) error {
 	return os.Chmod(dst, info.Mode())
 }
 
+// CopyDir copies the tree at src to dst, reflinking files where possible (see CopyFile).
 func CopyDir(src, dst string) error {
 	if err := os.MkdirAll(dst, 0755); err != nil {
 		return err
@@ -72,8 +77,18 @@ func CopyDir(src, dst string) error {
 			return err
 		}
 		dstPath := filepath.Join(dst, relPath)
-		if info.IsDir() {
+		switch {
+		case info.IsDir():
 			return os.MkdirAll(dstPath, info.Mode())
+		case info.Mode()&os.ModeSymlink != 0:
+			// Recreate symlinks (e.g. a prefix's pfx and dosdevices links) instead of following them.
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, dstPath)
+		case !info.Mode().IsRegular():
+			return nil // Skip sockets, pipes and devices
 		}
 		return CopyFile(path, dstPath)
 	})
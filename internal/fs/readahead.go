@@ -0,0 +1,92 @@
+package fs
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Readahead reads the files below dirs so the kernel keeps them in its page cache, and
+// a program started next loads them from memory instead of the disk. Directories are read
+// in order until budget bytes have been read; the rest is left out. Missing directories
+// are skipped. It returns how many bytes and files were read.
+func Readahead(dirs []string, budget int64) (int64, int, error) {
+	var files []string
+	remaining := budget
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		err := Walk(context.Background(), dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil // Unreadable entries are simply not cached
+			}
+			if remaining <= 0 {
+				return filepath.SkipAll
+			}
+			if info.Mode().IsRegular() && info.Size() > 0 {
+				files = append(files, path)
+				remaining -= info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	var read atomic.Int64
+	var count atomic.Int32
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				f, err := os.Open(path)
+				if err != nil {
+					continue
+				}
+				n, _ := io.Copy(io.Discard, f)
+				f.Close()
+				read.Add(n)
+				count.Add(1)
+			}
+		}()
+	}
+	for _, path := range files {
+		paths <- path
+	}
+	close(paths)
+	wg.Wait()
+	return read.Load(), int(count.Load()), nil
+}
+
+// MemAvailable returns the memory the kernel can give programs without swapping, which
+// includes the page cache it would drop for them; 0 if /proc/meminfo cannot be read.
+func MemAvailable() int64 {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rest, ok := strings.CutPrefix(scanner.Text(), "MemAvailable:"); ok {
+			kb, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(rest), " kB"), 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kb << 10
+		}
+	}
+	return 0
+}
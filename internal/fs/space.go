@@ -0,0 +1,25 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Free returns the space available to unprivileged users on the filesystem that holds
+// path. path need not exist yet; its nearest existing parent is asked.
+func Free(path string) (int64, error) {
+	path = MustGetAbsolutePath(path)
+	for {
+		var st syscall.Statfs_t
+		err := syscall.Statfs(path, &st)
+		if err == nil {
+			return int64(st.Bavail) * int64(st.Bsize), nil
+		}
+		parent := filepath.Dir(path)
+		if !os.IsNotExist(err) || parent == path {
+			return 0, err
+		}
+		path = parent
+	}
+}
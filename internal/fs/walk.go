@@ -0,0 +1,145 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// walkers is how many directories Walk lists at once. Listing is mostly waiting on the
+	// disk or the network, so it pays to have more going than there are CPUs.
+	walkers = 8
+	// walkAhead caps the listings read ahead of the caller, so a slow caller (e.g. one
+	// compressing every file) does not hold a whole tree in memory.
+	walkAhead = 256
+)
+
+// entry is a file or directory found by Walk.
+type entry struct {
+	path string
+	info os.FileInfo
+	err  error
+}
+
+// listing is a directory's entries, read in the background.
+type listing struct {
+	entries []entry
+	err     error
+	done    chan struct{}
+}
+
+// Walk calls fn for root and every file and directory below it, like filepath.Walk, but
+// lists and lstats directories on several goroutines, which pays off on slow disks and
+// network filesystems where every lstat waits on the device. fn is called from one
+// goroutine at a time and in filepath.Walk's lexical order, so it may write to a tar
+// stream without locking. Symlinks are not followed. Returning filepath.SkipDir from fn
+// skips a directory; any other error from fn, or ctx being done, stops the walk.
+func Walk(ctx context.Context, root string, fn filepath.WalkFunc) error {
+	w := &walker{ctx: ctx, sem: make(chan struct{}, walkers), ahead: make(chan struct{}, walkAhead)}
+	info, err := os.Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = w.walk(root, info, nil, fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+type walker struct {
+	ctx   context.Context
+	sem   chan struct{} // Limits the listings running at once
+	ahead chan struct{} // Limits the listings read but not yet walked
+}
+
+// list reads a directory's entries in the background if the read-ahead limit allows,
+// returning nil otherwise.
+func (w *walker) list(dir string) *listing {
+	select {
+	case w.ahead <- struct{}{}:
+	default:
+		return nil
+	}
+	l := &listing{done: make(chan struct{})}
+	go func() {
+		defer close(l.done)
+		select {
+		case w.sem <- struct{}{}:
+		case <-w.ctx.Done():
+			l.err = w.ctx.Err()
+			return
+		}
+		defer func() { <-w.sem }()
+		l.entries, l.err = readDir(dir)
+	}()
+	return l
+}
+
+// wait returns the entries of a directory listed by list, or lists it now.
+func (w *walker) wait(dir string, l *listing) ([]entry, error) {
+	if l == nil {
+		return readDir(dir)
+	}
+	<-l.done
+	<-w.ahead
+	return l.entries, l.err
+}
+
+func (w *walker) walk(path string, info os.FileInfo, l *listing, fn filepath.WalkFunc) error {
+	if err := w.ctx.Err(); err != nil {
+		w.discard(l)
+		return err
+	}
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+	entries, err := w.wait(path, l)
+	if err1 := fn(path, info, err); err != nil || err1 != nil {
+		return err1
+	}
+
+	// Start listing the subdirectories while the entries before them are walked.
+	listings := make([]*listing, len(entries))
+	for i, e := range entries {
+		if e.err == nil && e.info.IsDir() {
+			listings[i] = w.list(e.path)
+		}
+	}
+	for i, e := range entries {
+		if e.err != nil {
+			err = fn(e.path, nil, e.err)
+		} else {
+			err = w.walk(e.path, e.info, listings[i], fn)
+		}
+		if err != nil && !(err == filepath.SkipDir && (e.err != nil || e.info.IsDir())) {
+			for _, rest := range listings[i+1:] {
+				w.discard(rest)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// discard waits for a listing that will not be walked, so it no longer counts as read ahead.
+func (w *walker) discard(l *listing) {
+	if l != nil {
+		<-l.done
+		<-w.ahead
+	}
+}
+
+// readDir returns a directory's entries in lexical order, with their lstat results.
+func readDir(dir string) ([]entry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	entries := make([]entry, 0, len(dirEntries))
+	for _, d := range dirEntries {
+		path := filepath.Join(dir, d.Name())
+		info, err := os.Lstat(path)
+		entries = append(entries, entry{path, info, err})
+	}
+	return entries, err
+}
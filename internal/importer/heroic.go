@@ -0,0 +1,161 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"yapl/internal/config"
+)
+
+type heroicGameConfig struct {
+	WinePrefix  string `json:"winePrefix"`
+	WineVersion struct {
+		Bin  string `json:"bin"`
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"wineVersion"`
+	LauncherArgs       string `json:"launcherArgs"`
+	EnvironmentOptions []struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	} `json:"enviromentOptions"` // Sic, Heroic's spelling
+}
+
+type legendaryInstall struct {
+	Title       string `json:"title"`
+	InstallPath string `json:"install_path"`
+	Executable  string `json:"executable"`
+}
+
+// HeroicConfigDirs are the places Heroic keeps its configuration (native and Flatpak).
+func HeroicConfigDirs() []string {
+	home, _ := os.UserHomeDir()
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	return []string{
+		filepath.Join(configHome, "heroic"),
+		filepath.Join(home, ".var", "app", "com.heroicgameslauncher.hgl", "config", "heroic"),
+	}
+}
+
+// Heroic reads Heroic's per-game configs and returns one result per Windows game.
+// When appNames is empty every configured game is imported.
+func Heroic(appNames []string) ([]Result, error) {
+	var configDir string
+	for _, dir := range HeroicConfigDirs() {
+		if _, err := os.Stat(filepath.Join(dir, "GamesConfig")); err == nil {
+			configDir = dir
+			break
+		}
+	}
+	if configDir == "" {
+		return nil, fmt.Errorf("no Heroic configuration found in %s", strings.Join(HeroicConfigDirs(), " or "))
+	}
+	installs := readLegendaryInstalls(filepath.Dir(configDir))
+
+	files, err := filepath.Glob(filepath.Join(configDir, "GamesConfig", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	wanted := make(map[string]bool, len(appNames))
+	for _, name := range appNames {
+		wanted[name] = true
+	}
+
+	var results []Result
+	for _, file := range files {
+		appName := strings.TrimSuffix(filepath.Base(file), ".json")
+		if len(wanted) > 0 && !wanted[appName] {
+			continue
+		}
+		result, ok, err := heroicGame(file, appName, installs[appName])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if ok {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+func heroicGame(file, appName string, install legendaryInstall) (Result, bool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Result{}, false, err
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return Result{}, false, err
+	}
+	raw, ok := doc[appName]
+	if !ok {
+		return Result{}, false, nil
+	}
+	var cfg heroicGameConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return Result{}, false, err
+	}
+	if cfg.WinePrefix == "" {
+		return Result{}, false, nil // Native Linux game
+	}
+
+	name := appName
+	if install.Title != "" {
+		name = strings.ReplaceAll(install.Title, "/", "-")
+	}
+
+	appCfg := config.App{
+		LaunchMethod: "direct",
+		Executable:   "drive_c/windows/explorer.exe",
+		LaunchArgs:   splitArgs(cfg.LauncherArgs),
+	}
+	// The game lives outside the prefix, so reach it through Wine's Z: drive.
+	if install.InstallPath != "" && install.Executable != "" {
+		appCfg.Executable = filepath.Join("dosdevices", "z:", install.InstallPath, install.Executable)
+	}
+	if len(cfg.EnvironmentOptions) > 0 {
+		appCfg.EnvironmentVars = make(map[string]string)
+		for _, opt := range cfg.EnvironmentOptions {
+			appCfg.EnvironmentVars[opt.Key] = opt.Value
+		}
+	}
+
+	result := Result{Name: name, App: appCfg, PrefixLink: heroicPrefix(cfg.WinePrefix)}
+	if cfg.WineVersion.Bin != "" {
+		// Proton builds point at their 'proton' script, Wine builds at bin/wine.
+		protonDir := filepath.Dir(cfg.WineVersion.Bin)
+		if cfg.WineVersion.Type != "proton" {
+			protonDir = filepath.Dir(protonDir)
+		}
+		result.App.ProtonVersion = filepath.Base(protonDir)
+		result.Proton = config.VersionInfo{Path: protonDir}
+	}
+	return result, true, nil
+}
+
+// heroicPrefix returns the actual Wine prefix, which Proton keeps in a 'pfx' subdirectory.
+func heroicPrefix(winePrefix string) string {
+	if _, err := os.Stat(filepath.Join(winePrefix, "pfx", "system.reg")); err == nil {
+		return filepath.Join(winePrefix, "pfx")
+	}
+	return winePrefix
+}
+
+func readLegendaryInstalls(configHome string) map[string]legendaryInstall {
+	installs := make(map[string]legendaryInstall)
+	data, err := os.ReadFile(filepath.Join(configHome, "legendary", "installed.json"))
+	if err != nil {
+		return installs
+	}
+	json.Unmarshal(data, &installs)
+	return installs
+}
@@ -0,0 +1,96 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"yapl/internal/config"
+	"yapl/internal/fs"
+)
+
+// Result is an imported game, ready to be written as a yapl config.
+type Result struct {
+	Name string
+	App  config.App
+	// Proton is registered in runner.json under App.ProtonVersion unless that version already exists.
+	Proton config.VersionInfo
+	// PrefixLink, if set, is an existing Wine prefix that the game's prefix links to.
+	PrefixLink string
+	// CopyPrefix copies PrefixLink instead of linking it.
+	CopyPrefix bool
+}
+
+// Save writes the game's config and registers its Proton version in runner.json.
+func (r Result) Save(appType, runnerPath string) error {
+	if r.Name == "" || r.Name != filepath.Base(r.Name) {
+		return fmt.Errorf("invalid game name '%s'", r.Name)
+	}
+	if _, err := os.Stat(config.ConfigPath(appType, r.Name)); err == nil {
+		return fmt.Errorf("'%s' already has a config", filepath.Join(appType, r.Name))
+	}
+
+	if r.App.ProtonVersion != "" {
+		err := config.UpdateGlobal(runnerPath, func(g *config.Global) {
+			if _, ok := g.ProtonVersions[r.App.ProtonVersion]; ok {
+				return
+			}
+			if g.ProtonVersions == nil {
+				g.ProtonVersions = make(map[string]config.VersionInfo)
+			}
+			g.ProtonVersions[r.App.ProtonVersion] = r.Proton
+			fmt.Printf("-> Added Proton version '%s' to %s.\n", r.App.ProtonVersion, runnerPath)
+		})
+		if err != nil {
+			return fmt.Errorf("could not update %s: %w", runnerPath, err)
+		}
+	}
+	if err := config.SaveApp(appType, r.Name, r.App); err != nil {
+		return err
+	}
+	prefixPath := filepath.Join(appType, r.Name, "prefix")
+	if r.PrefixLink != "" && r.CopyPrefix {
+		fmt.Printf("-> Copying prefix '%s'...\n", r.PrefixLink)
+		if err := fs.CopyDir(r.PrefixLink, prefixPath); err != nil {
+			return fmt.Errorf("could not copy prefix: %w", err)
+		}
+	} else if r.PrefixLink != "" {
+		if err := os.Symlink(r.PrefixLink, prefixPath); err != nil {
+			return fmt.Errorf("could not link prefix: %w", err)
+		}
+	}
+	return nil
+}
+
+// splitArgs splits a command line into arguments, honouring single and double quotes.
+func splitArgs(s string) []string {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
@@ -0,0 +1,181 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"yapl/internal/config"
+)
+
+const lutrisAPI = "https://lutris.net/api/installers/"
+
+// Lutris translates a Lutris install script, given as a YAML/JSON file or a lutris.net
+// installer slug, into a yapl game config.
+func Lutris(source string) (Result, error) {
+	doc, err := loadLutrisScript(source)
+	if err != nil {
+		return Result{}, err
+	}
+
+	name := stringAt(doc, "name")
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	}
+	// Installers from lutris.net wrap the actual script in a "script" key.
+	script, ok := doc["script"].(map[string]interface{})
+	if !ok {
+		script = doc
+	}
+	if runner := stringAt(doc, "runner"); runner != "" && runner != "wine" {
+		return Result{}, fmt.Errorf("only wine installers can be imported, this one uses the '%s' runner", runner)
+	}
+
+	game := mapAt(script, "game")
+	wine := mapAt(script, "wine")
+	system := mapAt(script, "system")
+
+	appCfg := config.App{
+		ProtonVersion:   stringAt(wine, "version"),
+		LaunchMethod:    "direct",
+		Executable:      lutrisExecutable(stringAt(game, "exe"), stringAt(game, "prefix")),
+		WineArch:        stringAt(game, "arch"),
+		LaunchArgs:      splitArgs(stringAt(game, "args")),
+		Winetricks:      lutrisWinetricks(script),
+		DLLOverrides:    stringMap(mapAt(wine, "overrides")),
+		EnvironmentVars: stringMap(mapAt(system, "env")),
+	}
+	if appCfg.Executable == "" {
+		appCfg.Executable = "drive_c/windows/explorer.exe"
+	}
+
+	result := Result{Name: name, App: appCfg}
+	if appCfg.ProtonVersion != "" {
+		result.Proton = lutrisWineVersion(appCfg.ProtonVersion)
+	}
+	return result, nil
+}
+
+func loadLutrisScript(source string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(source)
+	if os.IsNotExist(err) && !strings.ContainsAny(source, "/.") {
+		return fetchLutrisInstaller(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read install script: %w", err)
+	}
+
+	var doc map[string]interface{}
+	if json.Unmarshal(data, &doc) == nil {
+		return doc, nil
+	}
+	parsed, err := parseYAML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse install script: %w", err)
+	}
+	doc, ok := parsed.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("install script is not a mapping")
+	}
+	return doc, nil
+}
+
+// fetchLutrisInstaller downloads the first installer published for a slug on lutris.net.
+func fetchLutrisInstaller(slug string) (map[string]interface{}, error) {
+	fmt.Printf("-> Fetching Lutris installer '%s'...\n", slug)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(lutrisAPI + slug)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach lutris.net: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("lutris.net returned %s for '%s'", resp.Status, slug)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var listing struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &listing); err == nil && len(listing.Results) > 0 {
+		return listing.Results[0], nil
+	}
+	var single map[string]interface{}
+	if err := json.Unmarshal(body, &single); err != nil || single["script"] == nil {
+		return nil, fmt.Errorf("no installer found for '%s'", slug)
+	}
+	return single, nil
+}
+
+// lutrisExecutable makes the exe path relative to the Wine prefix.
+func lutrisExecutable(exe, prefix string) string {
+	if exe == "" {
+		return ""
+	}
+	if prefix != "" && strings.HasPrefix(exe, prefix+"/") {
+		return strings.TrimPrefix(exe, prefix+"/")
+	}
+	return strings.TrimPrefix(exe, "$GAMEDIR/")
+}
+
+func lutrisWinetricks(script map[string]interface{}) []string {
+	var verbs []string
+	tasks, _ := script["installer"].([]interface{})
+	for _, step := range tasks {
+		task := mapAt(asMap(step), "task")
+		if stringAt(task, "name") == "winetricks" {
+			verbs = append(verbs, strings.Fields(stringAt(task, "app"))...)
+		}
+	}
+	return verbs
+}
+
+// lutrisWineVersion points at the Lutris-managed runner when it is installed locally.
+func lutrisWineVersion(version string) config.VersionInfo {
+	if home, err := os.UserHomeDir(); err == nil {
+		runnerPath := filepath.Join(home, ".local", "share", "lutris", "runners", "wine", version)
+		if _, err := os.Stat(runnerPath); err == nil {
+			return config.VersionInfo{Path: runnerPath}
+		}
+	}
+	return config.VersionInfo{URL: "URL_TO_PROTON_TAR"}
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func mapAt(m map[string]interface{}, key string) map[string]interface{} {
+	return asMap(m[key])
+}
+
+func stringAt(m map[string]interface{}, key string) string {
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func stringMap(m map[string]interface{}) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(m))
+	for k := range m {
+		result[k] = stringAt(m, k)
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"yapl/internal/config"
+)
+
+// Prefix adopts an existing Wine prefix, e.g. one created by Lutris or plain wine. Proton's
+// compatdata directories (with a 'pfx' subdirectory) are accepted as well.
+func Prefix(path string, copyPrefix bool) (Result, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return Result{}, err
+	}
+	prefix := heroicPrefix(path)
+	if _, err := os.Stat(filepath.Join(prefix, "system.reg")); err != nil {
+		return Result{}, fmt.Errorf("'%s' is not a Wine prefix (no system.reg)", path)
+	}
+
+	name := filepath.Base(prefix)
+	if name == "pfx" {
+		name = filepath.Base(filepath.Dir(prefix))
+	}
+
+	arch, err := prefixArch(filepath.Join(prefix, "system.reg"))
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{
+		Name: name,
+		App: config.App{
+			LaunchMethod: "direct",
+			Executable:   "drive_c/windows/explorer.exe",
+			WineArch:     arch,
+		},
+		PrefixLink: prefix,
+		CopyPrefix: copyPrefix,
+	}
+	if version := prefixProtonVersion(prefix); version != "" {
+		result.App.ProtonVersion = version
+		result.Proton = protonToolInfo(version)
+	}
+	return result, nil
+}
+
+// prefixArch reads the architecture wine recorded when it created the prefix.
+func prefixArch(systemReg string) (string, error) {
+	f, err := os.Open(systemReg)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < 10 && scanner.Scan(); i++ {
+		if arch, ok := strings.CutPrefix(scanner.Text(), "#arch="); ok {
+			return arch, nil
+		}
+	}
+	return "win64", scanner.Err()
+}
+
+// prefixProtonVersion returns the Proton version that last used a compatdata prefix, from
+// the 'config_info' or 'version' files Proton writes next to 'pfx'. Plain wine prefixes
+// record no version.
+func prefixProtonVersion(prefix string) string {
+	if filepath.Base(prefix) != "pfx" {
+		return ""
+	}
+	dir := filepath.Dir(prefix)
+	for _, file := range []string{"config_info", "version"} {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		line := strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+		// 'version' holds "<build id> <version>", config_info starts with the version.
+		if fields := strings.Fields(line); len(fields) > 0 {
+			return fields[len(fields)-1]
+		}
+	}
+	return ""
+}
+
+// protonToolInfo points at a Steam compatibility tool of that name when one is installed.
+func protonToolInfo(version string) config.VersionInfo {
+	home, _ := os.UserHomeDir()
+	for _, dir := range []string{
+		filepath.Join(home, ".steam", "root", "compatibilitytools.d", version),
+		filepath.Join(home, ".local", "share", "Steam", "compatibilitytools.d", version),
+	} {
+		if _, err := os.Stat(filepath.Join(dir, "proton")); err == nil {
+			return config.VersionInfo{Path: dir}
+		}
+	}
+	return config.VersionInfo{URL: "URL_TO_PROTON_TAR"}
+}
@@ -0,0 +1,211 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseYAML understands the subset of YAML used by Lutris install scripts: nested
+// mappings, block and flow sequences, quoted scalars, comments and block scalars (| and >).
+// Scalars are always returned as strings.
+func parseYAML(data string) (interface{}, error) {
+	var lines []yamlLine
+	for _, raw := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		text := strings.TrimRight(raw, " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "---" {
+			continue
+		}
+		lines = append(lines, yamlLine{indent: len(text) - len(trimmed), text: trimmed, raw: text})
+	}
+	p := &yamlParser{lines: lines}
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return map[string]interface{}{}, nil
+	}
+	return p.parseBlock(p.lines[p.pos].indent)
+}
+
+type yamlLine struct {
+	indent int
+	text   string
+	raw    string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) {
+		text := p.lines[p.pos].text
+		if text != "" && !strings.HasPrefix(text, "#") {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
+	line := p.lines[p.pos]
+	if line.text == "-" || strings.HasPrefix(line.text, "- ") {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	var items []interface{}
+	for p.skipBlank(); p.pos < len(p.lines); p.skipBlank() {
+		line := p.lines[p.pos]
+		if line.indent != indent || !(line.text == "-" || strings.HasPrefix(line.text, "- ")) {
+			break
+		}
+		item := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		switch {
+		case item == "":
+			p.pos++
+			value, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		case isMappingEntry(item):
+			// "- key: value" starts a mapping indented past the dash.
+			childIndent := indent + (len(line.text) - len(item))
+			p.lines[p.pos] = yamlLine{indent: childIndent, text: item, raw: strings.Repeat(" ", childIndent) + item}
+			value, err := p.parseMapping(childIndent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		default:
+			p.pos++
+			items = append(items, parseScalar(item))
+		}
+	}
+	return items, nil
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	result := make(map[string]interface{})
+	for p.skipBlank(); p.pos < len(p.lines); p.skipBlank() {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("yaml line %d: unexpected indentation", p.pos+1)
+		}
+		if !isMappingEntry(line.text) {
+			return nil, fmt.Errorf("yaml line %d: expected 'key: value'", p.pos+1)
+		}
+		key, rest := splitMappingEntry(line.text)
+		p.pos++
+
+		switch {
+		case rest == "":
+			// A sequence may sit at the same indentation as its key.
+			p.skipBlank()
+			if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && strings.HasPrefix(p.lines[p.pos].text, "-") {
+				value, err := p.parseSequence(indent)
+				if err != nil {
+					return nil, err
+				}
+				result[key] = value
+				continue
+			}
+			value, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+		case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			result[key] = p.parseBlockScalar(indent, rest[0] == '>')
+		default:
+			result[key] = parseScalar(rest)
+		}
+	}
+	return result, nil
+}
+
+// parseNested parses the block indented deeper than parentIndent, or returns an empty string.
+func (p *yamlParser) parseNested(parentIndent int) (interface{}, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= parentIndent {
+		return "", nil
+	}
+	return p.parseBlock(p.lines[p.pos].indent)
+}
+
+func (p *yamlParser) parseBlockScalar(parentIndent int, folded bool) string {
+	var parts []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.text != "" && line.indent <= parentIndent {
+			break
+		}
+		if blockIndent < 0 && line.text != "" {
+			blockIndent = line.indent
+		}
+		if len(line.raw) >= blockIndent && blockIndent >= 0 {
+			parts = append(parts, line.raw[blockIndent:])
+		} else {
+			parts = append(parts, "")
+		}
+		p.pos++
+	}
+	sep := "\n"
+	if folded {
+		sep = " "
+	}
+	return strings.TrimRight(strings.Join(parts, sep), " \n") + "\n"
+}
+
+func isMappingEntry(text string) bool {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") || strings.HasPrefix(text, "[") {
+		return false
+	}
+	return strings.HasSuffix(text, ":") || strings.Contains(text, ": ")
+}
+
+func splitMappingEntry(text string) (string, string) {
+	if key, ok := strings.CutSuffix(text, ":"); ok && !strings.Contains(key, ": ") {
+		return strings.TrimSpace(key), ""
+	}
+	key, rest, _ := strings.Cut(text, ": ")
+	return strings.TrimSpace(key), strings.TrimSpace(stripComment(rest))
+}
+
+func parseScalar(s string) interface{} {
+	s = strings.TrimSpace(stripComment(s))
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		unquoted := s[1 : len(s)-1]
+		unquoted = strings.ReplaceAll(unquoted, `\"`, `"`)
+		return strings.ReplaceAll(unquoted, `\\`, `\`)
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	case len(s) >= 2 && s[0] == '[' && s[len(s)-1] == ']':
+		var items []interface{}
+		for _, item := range strings.Split(s[1:len(s)-1], ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, parseScalar(item))
+			}
+		}
+		return items
+	}
+	return s
+}
+
+func stripComment(s string) string {
+	if strings.HasPrefix(s, "\"") || strings.HasPrefix(s, "'") {
+		return s
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,96 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// DefaultPath is where the session journal is kept, relative to the yapl root.
+const DefaultPath = "state/sessions.jsonl"
+
+// Session describes a single launch of a game or application.
+type Session struct {
+	User           string    `json:"user"`
+	Type           string    `json:"type"`
+	Name           string    `json:"name"`
+	Start          time.Time `json:"start"`
+	Duration       float64   `json:"duration_seconds"`
+	ExitCode       int       `json:"exit_code"`
+	LaunchMethod   string    `json:"launch_method,omitempty"`
+	ProtonVersion  string    `json:"proton_version,omitempty"`
+	RuntimeVersion string    `json:"runtime_version,omitempty"`
+}
+
+// Filter narrows down the sessions returned by Query. Empty fields match everything.
+type Filter struct {
+	User  string
+	Name  string
+	Since time.Time
+}
+
+// CurrentUser returns the name of the user running yapl.
+func CurrentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// Append adds a session to the journal file, creating it if necessary.
+func Append(path string, s Session) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create journal directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open journal: %w", err)
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshal session: %w", err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// Query reads the journal and returns all sessions matching the filter, oldest first.
+func Query(path string, filter Filter) ([]Session, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open journal: %w", err)
+	}
+	defer f.Close()
+
+	var sessions []Session
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s Session
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			continue // Skip partially written or corrupt lines
+		}
+		if filter.User != "" && s.User != filter.User {
+			continue
+		}
+		if filter.Name != "" && s.Name != filter.Name {
+			continue
+		}
+		if !filter.Since.IsZero() && s.Start.Before(filter.Since) {
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, scanner.Err()
+}
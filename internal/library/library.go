@@ -0,0 +1,125 @@
+package library
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"yapl/internal/archive"
+	"yapl/internal/fs"
+)
+
+// Entry is a packaged game or app available in the shared library.
+type Entry struct {
+	Type      string
+	Name      string
+	Archive   string
+	Installed bool
+}
+
+// List returns every bundle found in the library's games/ and apps/ directories.
+func List(libPath string) ([]Entry, error) {
+	if _, err := os.Stat(libPath); err != nil {
+		return nil, fmt.Errorf("library path is not accessible: %w", err)
+	}
+
+	var entries []Entry
+	for _, targetType := range []string{"games", "apps"} {
+		files, err := os.ReadDir(filepath.Join(libPath, targetType))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read library %s: %w", targetType, err)
+		}
+		for _, file := range files {
+			name, ok := archive.TrimArchiveSuffix(file.Name())
+			if file.IsDir() || !ok {
+				continue
+			}
+			entries = append(entries, Entry{
+				Type:      targetType,
+				Name:      name,
+				Archive:   filepath.Join(libPath, targetType, file.Name()),
+				Installed: fs.DirExistsAndIsNotEmpty(filepath.Join(targetType, name)),
+			})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Type != entries[j].Type {
+			return entries[i].Type < entries[j].Type
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// Sync materializes the named bundles locally, or every bundle when names is empty.
+// Bundles that are already installed are left untouched.
+func Sync(libPath string, names []string) error {
+	entries, err := List(libPath)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	synced := 0
+	for _, entry := range entries {
+		if len(wanted) > 0 && !wanted[entry.Name] {
+			continue
+		}
+		delete(wanted, entry.Name)
+		if entry.Installed {
+			fmt.Printf("-> '%s' is already installed.\n", entry.Name)
+			continue
+		}
+		installed, err := install(entry)
+		if err != nil {
+			return err
+		}
+		if installed {
+			synced++
+		}
+	}
+	for name := range wanted {
+		return fmt.Errorf("'%s' was not found in the library", name)
+	}
+	fmt.Printf("✅ Library sync complete, %d bundle(s) installed.\n", synced)
+	return nil
+}
+
+// Materialize installs a single game or app from the library if it is available there
+// and not present locally. It reports whether anything was installed.
+func Materialize(libPath, targetType, name string) (bool, error) {
+	if fs.DirExistsAndIsNotEmpty(filepath.Join(targetType, name)) {
+		return false, nil
+	}
+	entries, err := List(libPath)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.Type == targetType && entry.Name == name {
+			fmt.Printf("-> '%s' is not installed locally, fetching it from the library...\n", name)
+			return install(entry)
+		}
+	}
+	return false, nil
+}
+
+// install unpacks the entry's bundle and reports whether it is installed afterwards.
+// Unpackage only warns when it skips a bundle, e.g. a patch or an existing destination.
+func install(entry Entry) (bool, error) {
+	if err := fs.MustCreateDirectory(entry.Type); err != nil {
+		return false, err
+	}
+	if err := archive.Unpackage(entry.Type, []string{entry.Archive}); err != nil {
+		return false, err
+	}
+	return fs.DirExistsAndIsNotEmpty(filepath.Join(entry.Type, entry.Name)), nil
+}
@@ -0,0 +1,122 @@
+// Package ntfs checks Windows partitions that games are shared from in dual-boot setups.
+package ntfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Mount describes the filesystem a path lives on.
+type Mount struct {
+	Point   string
+	FSType  string
+	Source  string
+	Options map[string]bool
+}
+
+// Driver names the NTFS driver of the mount, or "" if it is not NTFS.
+func (m Mount) Driver() string {
+	switch m.FSType {
+	case "ntfs3":
+		return "ntfs3"
+	case "ntfs":
+		return "ntfs (legacy, read-only)"
+	case "fuseblk", "fuse.ntfs-3g", "fuse.ntfs", "ntfs-3g":
+		return "ntfs-3g"
+	}
+	return ""
+}
+
+// FindMount returns the mount containing path, from /proc/self/mountinfo.
+func FindMount(path string) (Mount, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Mount{}, err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return Mount{}, err
+	}
+	defer f.Close()
+
+	var best Mount
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// id parent major:minor root mountpoint options [optional...] - fstype source superoptions
+		left, right, ok := strings.Cut(scanner.Text(), " - ")
+		if !ok {
+			continue
+		}
+		fields, tail := strings.Fields(left), strings.Fields(right)
+		if len(fields) < 6 || len(tail) < 2 {
+			continue
+		}
+		point := unescape(fields[4])
+		if abs != point && !strings.HasPrefix(abs, strings.TrimSuffix(point, "/")+"/") {
+			continue
+		}
+		if len(point) < len(best.Point) {
+			continue
+		}
+		options := make(map[string]bool)
+		for _, opt := range strings.Split(fields[5], ",") {
+			options[opt] = true
+		}
+		if len(tail) > 2 {
+			for _, opt := range strings.Split(tail[2], ",") {
+				options[opt] = true
+			}
+		}
+		best = Mount{Point: point, FSType: tail[0], Source: unescape(tail[1]), Options: options}
+	}
+	if best.Point == "" {
+		return Mount{}, fmt.Errorf("no mount found for '%s'", path)
+	}
+	return best, scanner.Err()
+}
+
+// Check returns the problems that break Wine games on this mount.
+func Check(m Mount, dir string) []string {
+	var problems []string
+	if m.Options["ro"] {
+		problems = append(problems, "the partition is mounted read-only, usually because Windows was hibernated by Fast Startup; disable Fast Startup in Windows and remount")
+	}
+	if m.Options["noexec"] {
+		problems = append(problems, "the partition is mounted with 'noexec', which stops Wine from loading the game's DLLs; remount with 'exec'")
+	}
+	if syscall.Access(dir, 0x2 /* W_OK */) != nil && !m.Options["ro"] {
+		problems = append(problems, fmt.Sprintf("'%s' is not writable by you; mount with 'uid=%d,gid=%d' so saves and updates work", dir, os.Getuid(), os.Getgid()))
+	}
+	if m.Driver() == "ntfs-3g" && !m.Options["windows_names"] {
+		problems = append(problems, "ntfs-3g is not mounted with 'windows_names', so Linux programs can create files Windows cannot open")
+	}
+	return problems
+}
+
+// unescape decodes the octal escapes (\040 for space) used in mountinfo.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			var c byte
+			if _, err := fmt.Sscanf(s[i+1:i+4], "%03o", &c); err == nil {
+				b.WriteByte(c)
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
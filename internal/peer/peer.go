@@ -0,0 +1,357 @@
+package peer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"yapl/internal/archive"
+	"yapl/internal/config"
+	"yapl/internal/fs"
+)
+
+// DefaultPort is used for both UDP discovery and the HTTP file server.
+const DefaultPort = 47625
+
+// ArchiveDir keeps the downloaded archives of components whose entry has a sha256, by
+// checksum, so they can be seeded to peers.
+const ArchiveDir = "dependencies/archives"
+
+const (
+	discoverMessage = "YAPL-DISCOVER"
+	replyPrefix     = "YAPL-PEER "
+	discoverTimeout = 1500 * time.Millisecond
+)
+
+// Port returns the configured peer port, falling back to DefaultPort.
+func Port(cfg *config.LANPeers) int {
+	if cfg != nil && cfg.Port != 0 {
+		return cfg.Port
+	}
+	return DefaultPort
+}
+
+// Serve answers discovery broadcasts and serves the kept component archives (GET
+// /archives/<sha256>) and games and apps as tar streams (GET /games/<name>.tar) until
+// the process is stopped. Only the addresses of the allow list are answered.
+func Serve(globalCfg config.Global) error {
+	cfg := globalCfg.LANPeers
+	if cfg == nil {
+		cfg = &config.LANPeers{}
+	}
+	allowed, err := allowList(cfg.Allow)
+	if err != nil {
+		return err
+	}
+	port := Port(cfg)
+	udpConn, err := net.ListenPacket("udp4", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("could not listen for discovery: %w", err)
+	}
+	defer udpConn.Close()
+	go answerDiscovery(udpConn, port, allowed)
+
+	fmt.Printf("🌐 Seeding to LAN peers on port %d. Press Ctrl+C to stop.\n", port)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		if !isAllowed(allowed, net.ParseIP(host)) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		serve(w, r, globalCfg.Defaults)
+	}
+	return http.ListenAndServe(net.JoinHostPort(cfg.Listen, strconv.Itoa(port)), http.HandlerFunc(handler))
+}
+
+// Discover broadcasts on the LAN and returns the HTTP base URLs of all peers that answered.
+func Discover(port int) []string {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	broadcast := &net.UDPAddr{IP: net.IPv4bcast, Port: port}
+	if _, err := conn.WriteTo([]byte(discoverMessage), broadcast); err != nil {
+		return nil
+	}
+
+	var peers []string
+	seen := make(map[string]bool)
+	conn.SetReadDeadline(time.Now().Add(discoverTimeout))
+	buf := make([]byte, 256)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return peers // Deadline reached
+		}
+		msg := string(buf[:n])
+		if !strings.HasPrefix(msg, replyPrefix) {
+			continue
+		}
+		host, _, _ := net.SplitHostPort(addr.String())
+		url := fmt.Sprintf("http://%s", net.JoinHostPort(host, strings.TrimPrefix(msg, replyPrefix)))
+		if !seen[url] && !isLocal(host) {
+			seen[url] = true
+			peers = append(peers, url)
+		}
+	}
+}
+
+// Source returns where to install a component from. With peers enabled, a component whose
+// entry has a sha256 is first downloaded into ArchiveDir, from a peer that has its archive
+// or else from its URL, and installed from there, so this machine can seed it in turn.
+// Whatever a peer sends must match the sha256 before it is used. Components without one
+// are never taken from peers, since they are programs the machine will run, and are
+// installed from their URL.
+func Source(cfg *config.LANPeers, vinfo config.VersionInfo) (string, error) {
+	if cfg == nil || !cfg.Enabled || vinfo.SHA256 == "" || !strings.HasPrefix(vinfo.URL, "http") {
+		return vinfo.URL, nil
+	}
+	dest, err := archivePath(vinfo)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(dest); err == nil {
+		fmt.Printf("-> Using the archive kept in '%s'.\n", filepath.Dir(dest))
+		return dest, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+
+	fmt.Println("-> Looking for LAN peers...")
+	client := &http.Client{Timeout: 3 * time.Second}
+	for _, peerURL := range Discover(Port(cfg)) {
+		archiveURL := peerURL + "/archives/" + vinfo.SHA256
+		resp, err := client.Head(archiveURL)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			continue
+		}
+		fmt.Printf("-> Fetching '%s' from peer %s...\n", filepath.Base(dest), peerURL)
+		if err := archive.Download(archiveURL, dest, vinfo.SHA256); err != nil {
+			log.Printf("⚠️  Fetching from peer %s failed, trying elsewhere: %v", peerURL, err)
+			continue
+		}
+		return dest, nil
+	}
+	if err := archive.Download(vinfo.URL, dest, vinfo.SHA256); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// FetchApp copies the game or app stored at relDir (e.g. "games/Game") from a LAN peer.
+// It reports whether a peer provided it.
+func FetchApp(port int, relDir string) bool {
+	client := &http.Client{Timeout: 3 * time.Second}
+	for _, peerURL := range Discover(port) {
+		url := peerURL + "/" + filepath.ToSlash(relDir) + ".tar"
+		resp, err := client.Head(url)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			continue
+		}
+
+		fmt.Printf("-> Fetching '%s' from peer %s...\n", relDir, peerURL)
+		partial := relDir + ".partial"
+		os.RemoveAll(partial)
+		ar := &archive.Archive{Source: url}
+		if err := ar.Extract(partial, true); err != nil {
+			log.Printf("⚠️  Fetching from peer %s failed, trying elsewhere: %v", peerURL, err)
+			os.RemoveAll(partial)
+			continue
+		}
+		if err := os.Rename(partial, relDir); err != nil {
+			log.Printf("⚠️  Could not move '%s' into place: %v", relDir, err)
+			os.RemoveAll(partial)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// archivePath returns where the archive of a component is kept, named like its download.
+func archivePath(vinfo config.VersionInfo) (string, error) {
+	if _, err := hex.DecodeString(vinfo.SHA256); err != nil || len(vinfo.SHA256) != 64 {
+		return "", fmt.Errorf("'%s' is not a SHA-256 checksum", vinfo.SHA256)
+	}
+	u, err := url.Parse(vinfo.URL)
+	if err != nil {
+		return "", err
+	}
+	name := path.Base(u.Path)
+	if _, ok := archive.TrimArchiveSuffix(name); !ok && !strings.HasSuffix(name, ".tar") {
+		return "", fmt.Errorf("'%s' is not a tar archive", vinfo.URL)
+	}
+	return filepath.Join(ArchiveDir, strings.ToLower(vinfo.SHA256), name), nil
+}
+
+func answerDiscovery(conn net.PacketConn, port int, allowed []*net.IPNet) {
+	buf := make([]byte, 256)
+	reply := []byte(replyPrefix + strconv.Itoa(port))
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if string(buf[:n]) == discoverMessage && ok && isAllowed(allowed, udpAddr.IP) {
+			conn.WriteTo(reply, addr)
+		}
+	}
+}
+
+func serve(w http.ResponseWriter, r *http.Request, d *config.Defaults) {
+	relPath := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if sum, ok := strings.CutPrefix(relPath, "archives/"); ok {
+		serveArchive(w, r, sum)
+		return
+	}
+	relDir, ok := strings.CutSuffix(relPath, ".tar")
+	if !ok || !isServed(relDir) || !fs.DirExistsAndIsNotEmpty(relDir) {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.Split(relDir, "/")
+	appCfg, err := config.LoadApp(parts[0], parts[1], d)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	// The same files as in a package of it, without logs, caches and crash dumps.
+	exclude := archive.DefaultExclude
+	if appCfg.Package != nil && appCfg.Package.Exclude != nil {
+		exclude = appCfg.Package.Exclude
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	if r.Method == http.MethodHead {
+		return
+	}
+	fmt.Printf("-> Sending '%s' to %s\n", relDir, r.RemoteAddr)
+	if err := archive.WriteTar(w, filepath.FromSlash(relDir), exclude); err != nil {
+		log.Printf("⚠️  Sending '%s' failed: %v", relDir, err)
+	}
+}
+
+// serveArchive sends the kept archive with the given checksum.
+func serveArchive(w http.ResponseWriter, r *http.Request, sum string) {
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != 64 {
+		http.NotFound(w, r)
+		return
+	}
+	dir := filepath.Join(ArchiveDir, strings.ToLower(sum))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), ".partial") {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			break
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			break
+		}
+		if r.Method != http.MethodHead {
+			fmt.Printf("-> Sending '%s' to %s\n", entry.Name(), r.RemoteAddr)
+		}
+		http.ServeContent(w, r, entry.Name(), info.ModTime(), f)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// isServed checks that relDir is a game or app directory.
+func isServed(relDir string) bool {
+	parts := strings.Split(relDir, "/")
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return len(parts) == 2 && (parts[0] == "games" || parts[0] == "apps")
+}
+
+// allowList parses the addresses and CIDR ranges that may use the seed. Without any, the
+// subnets of the machine's own network interfaces are allowed.
+func allowList(allow []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range allow {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("lan_peers.allow: '%s' is not an address or CIDR range", entry)
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("lan_peers.allow: %w", err)
+		}
+		nets = append(nets, ipNet)
+	}
+	if len(allow) > 0 {
+		return nets, nil
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("could not list the network interfaces: %w", err)
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			nets = append(nets, &net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask})
+		}
+	}
+	return nets, nil
+}
+
+func isAllowed(allowed []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range allowed {
+		if ip != nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLocal(host string) bool {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.String() == host {
+			return true
+		}
+	}
+	return false
+}
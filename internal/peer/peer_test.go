@@ -0,0 +1,160 @@
+package peer
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"yapl/internal/config"
+)
+
+func TestAllowList(t *testing.T) {
+	allowed, err := allowList([]string{"192.168.1.0/24", "10.0.0.5", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]bool{
+		"192.168.1.7": true, "192.168.2.7": false, "10.0.0.5": true, "10.0.0.6": false,
+		"::ffff:10.0.0.5": true, "fd12::1": true, "fe80::1": false,
+	} {
+		if got := isAllowed(allowed, net.ParseIP(ip)); got != want {
+			t.Errorf("%s: allowed %v, want %v", ip, got, want)
+		}
+	}
+	if isAllowed(allowed, nil) {
+		t.Error("an unparsable address was allowed")
+	}
+	for _, bad := range []string{"example.com", "10.0.0.0/33"} {
+		if _, err := allowList([]string{bad}); err == nil {
+			t.Errorf("'%s' was accepted", bad)
+		}
+	}
+}
+
+func TestIsServed(t *testing.T) {
+	for relDir, want := range map[string]bool{
+		"games/G": true, "apps/A": true,
+		"proton/GE-Proton9-1": false, "dependencies/dxvk/2.7": false, "state": false,
+		"games/G/prefix": false, "games/..": false, "games": false, "runner.json": false,
+	} {
+		if got := isServed(relDir); got != want {
+			t.Errorf("%s: served %v, want %v", relDir, got, want)
+		}
+	}
+}
+
+func TestArchivePath(t *testing.T) {
+	sum := "AB0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcd"
+	got, err := archivePath(config.VersionInfo{URL: "https://example.com/dl/GE-Proton9-1.tar.gz?x=1", SHA256: sum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(ArchiveDir, "ab0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcd", "GE-Proton9-1.tar.gz"); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	for _, vinfo := range []config.VersionInfo{
+		{URL: "https://example.com/a.tar.gz", SHA256: "../../etc"},
+		{URL: "https://example.com/a.tar.gz", SHA256: "abcd"},
+		{URL: "https://example.com/a.zip", SHA256: sum},
+	} {
+		if _, err := archivePath(vinfo); err == nil {
+			t.Errorf("%+v was accepted", vinfo)
+		}
+	}
+}
+
+func TestServeArchive(t *testing.T) {
+	t.Chdir(t.TempDir())
+	data := []byte("archive contents")
+	h := sha256.Sum256(data)
+	sum := hex.EncodeToString(h[:])
+	dir := filepath.Join(ArchiveDir, sum)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dxvk-2.7.tar.gz"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll("games/G/logs", 0755)
+	os.WriteFile("games/G/game.json", []byte("{}"), 0644)
+	os.WriteFile("runner.json", []byte("{}"), 0644)
+	server := newServer(t)
+
+	resp, err := http.Get(server.URL + "/archives/" + sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != string(data) {
+		t.Fatalf("got %s %q", resp.Status, body)
+	}
+	for _, path := range []string{
+		"/archives/" + sum[:60], "/archives/../runner.json", "/runner.json", "/proton/GE-Proton9-1.tar",
+		"/dependencies/archives.tar", "/games/G/logs.tar", "/games/missing.tar",
+	} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: got %s, want 404", path, resp.Status)
+		}
+	}
+}
+
+// newServer serves the current directory to the test without runner.json defaults.
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, nil)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServeGameLeavesOutExcludes(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.MkdirAll("games/G/logs", 0755)
+	os.WriteFile("games/G/logs/run.log", []byte("log"), 0644)
+	os.WriteFile("games/G/game.json", []byte(`{"executable": "g.exe"}`), 0644)
+	os.WriteFile("games/G/game.local.json", []byte("{}"), 0644)
+	os.WriteFile("games/G/g.exe", []byte("exe"), 0644)
+	server := newServer(t)
+
+	resp, err := http.Get(server.URL + "/games/G.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var names []string
+	tr := tar.NewReader(resp.Body)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if !slices.Contains(names, "G/g.exe") {
+		t.Fatalf("the game's files are missing: %v", names)
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, "G/logs") || name == "G/game.local.json" {
+			t.Errorf("'%s' was sent", name)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Package plugin runs yapl-<name> executables from the root's plugins directory or PATH,
+// like git and kubectl plugins: as extra commands ('yapl <name>') and at hook points
+// enabled in runner.json.
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"yapl/internal/config"
+)
+
+// Hook is a point at which yapl calls plugins.
+type Hook string
+
+const (
+	PreRun         Hook = "pre-run"         // Before the game starts; a failure stops the launch
+	PostSetup      Hook = "post-setup"      // After 'setup' succeeded
+	ResolveVersion Hook = "resolve-version" // For a version that runner.json does not define
+)
+
+// Dir holds plugins that come with the storage root rather than being installed on PATH.
+const Dir = "plugins"
+
+// prefix starts the file name of every plugin.
+const prefix = "yapl-"
+
+// CheckHooks reports a hook point in runner.json's "plugins" block (plugin name to hook
+// names) that yapl does not call.
+func CheckHooks(plugins map[string][]string) error {
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names) // Report the same plugin every time
+	for _, name := range names {
+		for _, h := range plugins[name] {
+			switch Hook(h) {
+			case PreRun, PostSetup, ResolveVersion:
+			default:
+				return fmt.Errorf("unknown hook '%s' for plugin '%s'. Use 'pre-run', 'post-setup', or 'resolve-version'", h, name)
+			}
+		}
+	}
+	return nil
+}
+
+// enabled returns the plugins that the "plugins" block enables for hook, in name order
+// so that they run in a stable order.
+func enabled(plugins map[string][]string, hook Hook) []string {
+	var names []string
+	for name, hooks := range plugins {
+		if slices.Contains(hooks, string(hook)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Path returns the executable of a plugin: plugins/yapl-<name> in the root, else
+// yapl-<name> on PATH.
+func Path(name string) (string, bool) {
+	if name == "" || strings.ContainsRune(name, os.PathSeparator) {
+		return "", false
+	}
+	if path, err := filepath.Abs(filepath.Join(Dir, prefix+name)); err == nil && executable(path) {
+		return path, true
+	}
+	path, err := exec.LookPath(prefix + name)
+	return path, err == nil
+}
+
+func executable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode()&0111 != 0
+}
+
+// Plugin is an installed plugin.
+type Plugin struct {
+	Name  string
+	Path  string
+	Hooks []Hook // The hook points runner.json enables it for
+}
+
+// List returns the installed plugins by name, with the hook points that runner.json's
+// "plugins" block enables them for. A plugin in the plugins directory hides one of the
+// same name on PATH, as Path does.
+func List(plugins map[string][]string) []Plugin {
+	found := map[string]string{}
+	dirs := append([]string{Dir}, filepath.SplitList(os.Getenv("PATH"))...)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), prefix)
+			if !ok || name == "" {
+				continue
+			}
+			if _, seen := found[name]; seen {
+				continue
+			}
+			path, err := filepath.Abs(filepath.Join(dir, entry.Name()))
+			if err == nil && executable(path) {
+				found[name] = path
+			}
+		}
+	}
+	list := make([]Plugin, 0, len(found))
+	for name, path := range found {
+		p := Plugin{Name: name, Path: path}
+		for _, h := range []Hook{PreRun, PostSetup, ResolveVersion} {
+			if slices.Contains(plugins[name], string(h)) {
+				p.Hooks = append(p.Hooks, h)
+			}
+		}
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Exec runs a plugin as a command with the user's arguments and terminal, and returns its
+// exit code.
+func Exec(name string, args []string) (int, error) {
+	path, ok := Path(name)
+	if !ok {
+		return 0, fmt.Errorf("plugin 'yapl-%s' not found in %s/ or on PATH", name, Dir)
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = pluginEnv("")
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// Output is what a plugin wrote to stdout for a hook.
+type Output struct {
+	Plugin string
+	Stdout []byte
+}
+
+// Run calls every plugin that runner.json's "plugins" block enables for the hook as
+// 'yapl-<name> hook <hook>' with payload as JSON on stdin. Their stderr goes to the user.
+// It stops at the first plugin that fails.
+func Run(plugins map[string][]string, hook Hook, payload interface{}) ([]Output, error) {
+	var outputs []Output
+	for _, name := range enabled(plugins, hook) {
+		out, err := call(name, hook, payload)
+		if err != nil {
+			return outputs, err
+		}
+		outputs = append(outputs, Output{Plugin: name, Stdout: out})
+	}
+	return outputs, nil
+}
+
+func call(name string, hook Hook, payload interface{}) ([]byte, error) {
+	path, ok := Path(name)
+	if !ok {
+		return nil, fmt.Errorf("plugin 'yapl-%s' not found in %s/ or on PATH", name, Dir)
+	}
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path, "hook", string(hook))
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	cmd.Env = pluginEnv(hook)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("plugin '%s' failed at %s: %w", name, hook, err)
+	}
+	return out, nil
+}
+
+// pluginEnv tells plugins how to call back into yapl and where its files are.
+func pluginEnv(hook Hook) []string {
+	env := os.Environ()
+	if exe, err := os.Executable(); err == nil {
+		env = append(env, "YAPL_BIN="+exe)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		env = append(env, "YAPL_ROOT="+wd)
+	}
+	if hook != "" {
+		env = append(env, "YAPL_HOOK="+string(hook))
+	}
+	return env
+}
+
+// ResolveVersions asks the resolve-version plugins for every version the app uses that
+// runner.json does not define, and adds their answers (a runner.json version entry, e.g.
+// {"url": "..."}) to globalCfg for this run.
+func ResolveVersions(globalCfg *config.Global, appCfg config.App) error {
+	resolvers := enabled(globalCfg.Plugins, ResolveVersion)
+	if len(resolvers) == 0 {
+		return nil
+	}
+	if globalCfg.ProtonVersions == nil {
+		globalCfg.ProtonVersions = map[string]config.VersionInfo{}
+	}
+	if globalCfg.RuntimeVersions == nil {
+		globalCfg.RuntimeVersions = map[string]config.VersionInfo{}
+	}
+	if globalCfg.DependencyVersions == nil {
+		globalCfg.DependencyVersions = map[string]map[string]config.VersionInfo{}
+	}
+
+	resolve := func(component, version string, known map[string]config.VersionInfo) error {
+		if version == "" || version == "system" {
+			return nil
+		}
+		if _, ok := known[version]; ok {
+			return nil
+		}
+		payload := map[string]string{"component": component, "version": version}
+		for _, name := range resolvers {
+			out, err := call(name, ResolveVersion, payload)
+			if err != nil {
+				return err
+			}
+			if len(bytes.TrimSpace(out)) == 0 {
+				continue // The plugin does not know this version
+			}
+			var vinfo config.VersionInfo
+			if err := json.Unmarshal(out, &vinfo); err != nil {
+				return fmt.Errorf("plugin '%s' returned an invalid version for %s '%s': %w", name, component, version, err)
+			}
+			if vinfo.URL == "" && vinfo.Path == "" {
+				continue
+			}
+			fmt.Printf("-> Plugin '%s' resolved %s '%s'.\n", name, component, version)
+			known[version] = vinfo
+			return nil
+		}
+		return nil
+	}
+
+	if err := resolve("proton", appCfg.ProtonVersion, globalCfg.ProtonVersions); err != nil {
+		return err
+	}
+	if err := resolve("runtime", appCfg.RuntimeVersion, globalCfg.RuntimeVersions); err != nil {
+		return err
+	}
+	deps := map[string]string{
+		"dxvk":  appCfg.Dependencies.DXVKVersion,
+		"vkd3d": appCfg.Dependencies.VKD3DVersion,
+	}
+	if !appCfg.UMUOptions.UseSystemBinary {
+		deps["umu-launcher"] = appCfg.UMUOptions.Version
+	}
+	for _, component := range []string{"dxvk", "vkd3d", "umu-launcher"} {
+		if deps[component] == "" {
+			continue
+		}
+		if globalCfg.DependencyVersions[component] == nil {
+			globalCfg.DependencyVersions[component] = map[string]config.VersionInfo{}
+		}
+		if err := resolve(component, deps[component], globalCfg.DependencyVersions[component]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"yapl/internal/config"
+)
+
+// writePlugin writes an executable shell script named yapl-<name> to dir.
+func writePlugin(t *testing.T, dir, name, script string) string {
+	t.Helper()
+	os.MkdirAll(dir, 0755)
+	path, _ := filepath.Abs(filepath.Join(dir, prefix+name))
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLookupOrder(t *testing.T) {
+	t.Chdir(t.TempDir())
+	bin := t.TempDir()
+	t.Setenv("PATH", bin)
+	local := writePlugin(t, Dir, "x", "")
+	writePlugin(t, bin, "x", "")
+	onPath := writePlugin(t, bin, "y", "")
+	os.WriteFile(filepath.Join(bin, prefix+"plain"), []byte("not executable"), 0644)
+
+	tests := []struct {
+		name string
+		want string // Empty if the plugin is not found
+	}{
+		{"x", local},
+		{"y", onPath},
+		{"plain", ""},
+		{"missing", ""},
+		{"../x", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		path, ok := Path(tt.name)
+		if path != tt.want || ok != (tt.want != "") {
+			t.Errorf("Path(%q) = %s, %v, want %s", tt.name, path, ok, tt.want)
+		}
+	}
+
+	list := List(map[string][]string{"x": {"resolve-version", "pre-run"}})
+	if len(list) != 2 || list[0].Path != local || list[1].Path != onPath {
+		t.Fatalf("listed %+v", list)
+	}
+	if len(list[0].Hooks) != 2 || list[0].Hooks[0] != PreRun || list[0].Hooks[1] != ResolveVersion || len(list[1].Hooks) != 0 {
+		t.Errorf("hooks: %v and %v", list[0].Hooks, list[1].Hooks)
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	// Each plugin prints its arguments, hook and payload, and fails for a payload asking it to.
+	echo := `read -r payload
+echo "$* $YAPL_HOOK $payload"
+[ "$payload" != '{"fail":true}' ]
+`
+	writePlugin(t, Dir, "a", echo)
+	writePlugin(t, Dir, "b", echo)
+	plugins := map[string][]string{"b": {"pre-run"}, "a": {"pre-run", "post-setup"}}
+
+	outputs, err := Run(plugins, PreRun, map[string]string{"game": "Doom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 2 || outputs[0].Plugin != "a" || outputs[1].Plugin != "b" {
+		t.Fatalf("got %+v", outputs)
+	}
+	if got := string(outputs[0].Stdout); got != "hook pre-run pre-run {\"game\":\"Doom\"}\n" {
+		t.Errorf("plugin a wrote %q", got)
+	}
+	if outputs, err := Run(plugins, PostSetup, nil); err != nil || len(outputs) != 1 {
+		t.Errorf("post-setup ran %+v, %v", outputs, err)
+	}
+	if outputs, err := Run(nil, PreRun, nil); err != nil || len(outputs) != 0 {
+		t.Errorf("ran %+v, %v without enabled plugins", outputs, err)
+	}
+
+	// The first failure stops the hook.
+	outputs, err = Run(plugins, PreRun, map[string]bool{"fail": true})
+	if err == nil || !strings.Contains(err.Error(), "plugin 'a' failed at pre-run") || len(outputs) != 0 {
+		t.Fatalf("got %+v, %v", outputs, err)
+	}
+	if _, err := Run(map[string][]string{"gone": {"pre-run"}}, PreRun, nil); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("got %v for a missing plugin", err)
+	}
+}
+
+func TestResolveVersions(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+	writePlugin(t, Dir, "mirror", `read -r payload
+case "$payload" in
+*'"version":"GE-Custom"'*) echo '{"url": "https://mirror.example/GE-Custom.tar.gz"}' ;;
+esac
+`)
+	global := &config.Global{
+		Plugins:        map[string][]string{"mirror": {"resolve-version"}},
+		ProtonVersions: map[string]config.VersionInfo{"GE-Known": {URL: "https://example.com/known.tar.gz"}},
+	}
+	app := config.App{ProtonVersion: "GE-Custom", RuntimeVersion: "sniper"}
+	if err := ResolveVersions(global, app); err != nil {
+		t.Fatal(err)
+	}
+	if got := global.ProtonVersions["GE-Custom"].URL; got != "https://mirror.example/GE-Custom.tar.gz" {
+		t.Errorf("GE-Custom resolved to %q", got)
+	}
+	if _, ok := global.RuntimeVersions["sniper"]; ok {
+		t.Error("a version the plugin does not know was added")
+	}
+
+	if err := CheckHooks(map[string][]string{"mirror": {"resolve-version"}, "other": {"post-run"}}); err == nil || !strings.Contains(err.Error(), "unknown hook 'post-run'") {
+		t.Errorf("got %v for an unknown hook", err)
+	}
+}
@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Admin PINs are stored in admin_pin as "pbkdf2-sha256$<iterations>$<salt>$<key>", with
+// the salt and key in unpadded base64. runner.json is readable by the restricted users,
+// so the hash is salted and slow to guess.
+const (
+	pinScheme     = "pbkdf2-sha256"
+	pinIterations = 600000
+	// pinMaxIterations bounds the count read from runner.json, so a mistyped hash cannot
+	// make every launch derive keys for minutes.
+	pinMaxIterations = 10 * pinIterations
+	pinSaltSize      = 16
+	pinKeySize       = 32
+)
+
+// HashPIN returns the admin_pin value for pin, with a new random salt.
+func HashPIN(pin string) (string, error) {
+	if pin == "" {
+		return "", errors.New("the PIN is empty")
+	}
+	salt := make([]byte, pinSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, pin, salt, pinIterations, pinKeySize)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("%s$%d$%s$%s", pinScheme, pinIterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+// CheckPIN reports whether pin matches the configured admin PIN hash.
+func CheckPIN(pc ParentalControls, pin string) bool {
+	if pc.AdminPIN == "" || pin == "" {
+		return false
+	}
+	iterations, salt, want, err := parsePINHash(pc.AdminPIN)
+	if err != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, pin, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(key, want) == 1
+}
+
+// ParsePINHash checks that hash is an admin_pin value HashPIN could have written.
+func ParsePINHash(hash string) error {
+	_, _, _, err := parsePINHash(hash)
+	return err
+}
+
+func parsePINHash(hash string) (int, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != pinScheme {
+		return 0, nil, nil, fmt.Errorf("not a %s$<iterations>$<salt>$<key> hash", pinScheme)
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 || iterations > pinMaxIterations {
+		return 0, nil, nil, fmt.Errorf("invalid iteration count '%s'", parts[1])
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil || len(salt) < 8 {
+		return 0, nil, nil, errors.New("invalid salt")
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) < 16 {
+		return 0, nil, nil, errors.New("invalid key")
+	}
+	return iterations, salt, key, nil
+}
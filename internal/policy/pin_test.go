@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashPIN(t *testing.T) {
+	hash, err := HashPIN("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ParsePINHash(hash); err != nil {
+		t.Fatalf("%s: %v", hash, err)
+	}
+	pc := ParentalControls{AdminPIN: hash}
+	if !CheckPIN(pc, "1234") {
+		t.Error("the PIN was rejected")
+	}
+	for _, pin := range []string{"", "1235", "12345", "123"} {
+		if CheckPIN(pc, pin) {
+			t.Errorf("%q was accepted", pin)
+		}
+	}
+	if again, _ := HashPIN("1234"); again == hash {
+		t.Error("two hashes of the same PIN share a salt")
+	}
+	if _, err := HashPIN(""); err == nil {
+		t.Error("an empty PIN was hashed")
+	}
+}
+
+func TestCheckPIN(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+		pin  string
+		want bool
+	}{
+		// The example of the README, the PIN 1234.
+		{"hash", "pbkdf2-sha256$600000$cEmP6Yd0cFKmGH6gH0DGTQ$9mBJKYS31bCvSrysJemXL9ucJJy8Y56LYTQbosNT/nc", "1234", true},
+		{"wrong PIN", "pbkdf2-sha256$600000$cEmP6Yd0cFKmGH6gH0DGTQ$9mBJKYS31bCvSrysJemXL9ucJJy8Y56LYTQbosNT/nc", "4321", false},
+		{"fewer iterations", "pbkdf2-sha256$1000$c2FsdHNhbHQ$5H+PyfD+NrL3f3614/3BySuQSLtJDQtIzZAv5KnpEKU", "1234", true},
+		{"changed iterations", "pbkdf2-sha256$1001$c2FsdHNhbHQ$5H+PyfD+NrL3f3614/3BySuQSLtJDQtIzZAv5KnpEKU", "1234", false},
+		{"no PIN set", "", "1234", false},
+		{"unsalted SHA-256", "03ac674216f3e15c761ee1a5e255f067953623c8b388b4459e13f978d7c846f4", "1234", false},
+		{"plain PIN", "1234", "1234", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckPIN(ParentalControls{AdminPIN: tt.hash}, tt.pin); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePINHash(t *testing.T) {
+	tests := []struct {
+		hash string
+		want string
+	}{
+		{"sha256$1$c2FsdHNhbHQ$5H+PyfD+NrL3f3614/3BySuQSLtJDQtIzZAv5KnpEKU", "not a pbkdf2-sha256"},
+		{"pbkdf2-sha256$600000$c2FsdHNhbHQ", "not a pbkdf2-sha256"},
+		{"pbkdf2-sha256$0$c2FsdHNhbHQ$5H+PyfD+NrL3f3614/3BySuQSLtJDQtIzZAv5KnpEKU", "invalid iteration count"},
+		{"pbkdf2-sha256$6000001$c2FsdHNhbHQ$5H+PyfD+NrL3f3614/3BySuQSLtJDQtIzZAv5KnpEKU", "invalid iteration count"},
+		{"pbkdf2-sha256$1000$c2FsdA$5H+PyfD+NrL3f3614/3BySuQSLtJDQtIzZAv5KnpEKU", "invalid salt"},
+		{"pbkdf2-sha256$1000$c2FsdHNhbHQ$!!", "invalid key"},
+	}
+	for _, tt := range tests {
+		if err := ParsePINHash(tt.hash); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.hash, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"yapl/internal/journal"
+)
+
+// ParentalControls limits when and for how long games may be played.
+type ParentalControls struct {
+	AdminPIN    string         `json:"admin_pin,omitempty"` // Hash from 'yapl parental hash-pin'
+	WarnMinutes int            `json:"warn_minutes,omitempty"`
+	Rules       []PlaytimeRule `json:"rules"`
+}
+
+// PlaytimeRule applies to sessions of a user and/or game. Empty User or Game match everyone/everything.
+type PlaytimeRule struct {
+	User         string `json:"user,omitempty"`
+	Game         string `json:"game,omitempty"`
+	DailyMinutes int    `json:"daily_minutes,omitempty"`
+	AllowedHours string `json:"allowed_hours,omitempty"`
+}
+
+// Decision is the outcome of evaluating the parental controls for a launch.
+type Decision struct {
+	Allowed bool
+	Reason  string
+	// Remaining is how long the session may last. Zero means unlimited.
+	Remaining time.Duration
+}
+
+// Evaluate checks every rule that applies to the user and game and returns the most restrictive result.
+func Evaluate(pc ParentalControls, user, game string, now time.Time, sessions []journal.Session) (Decision, error) {
+	decision := Decision{Allowed: true}
+	for _, rule := range pc.Rules {
+		if (rule.User != "" && rule.User != user) || (rule.Game != "" && rule.Game != game) {
+			continue
+		}
+
+		if rule.AllowedHours != "" {
+			from, until, err := ParseWindow(rule.AllowedHours, now)
+			if err != nil {
+				return Decision{}, err
+			}
+			if now.Before(from) || !now.Before(until) {
+				return Decision{Reason: fmt.Sprintf("playing is only allowed between %s", rule.AllowedHours)}, nil
+			}
+			decision.restrict(until.Sub(now))
+		}
+
+		if rule.DailyMinutes > 0 {
+			budget := time.Duration(rule.DailyMinutes) * time.Minute
+			left := budget - usedToday(rule, now, sessions)
+			if left <= 0 {
+				return Decision{Reason: fmt.Sprintf("the daily budget of %d minutes has been used up", rule.DailyMinutes)}, nil
+			}
+			decision.restrict(left)
+		}
+	}
+	return decision, nil
+}
+
+func (d *Decision) restrict(limit time.Duration) {
+	if d.Remaining == 0 || limit < d.Remaining {
+		d.Remaining = limit
+	}
+}
+
+func usedToday(rule PlaytimeRule, now time.Time, sessions []journal.Session) time.Duration {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var used time.Duration
+	for _, s := range sessions {
+		if (rule.User != "" && s.User != rule.User) || (rule.Game != "" && s.Name != rule.Game) {
+			continue
+		}
+		if s.Start.Before(midnight) {
+			continue
+		}
+		used += time.Duration(s.Duration * float64(time.Second))
+	}
+	return used
+}
+
+// ParseWindow turns "HH:MM-HH:MM" into today's start and end times.
+func ParseWindow(window string, now time.Time) (time.Time, time.Time, error) {
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid allowed_hours '%s', expected HH:MM-HH:MM", window)
+	}
+	var bounds [2]time.Time
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid allowed_hours '%s': %w", window, err)
+		}
+		bounds[i] = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	}
+	// Windows like "20:00-02:00" wrap around midnight.
+	if !bounds[1].After(bounds[0]) {
+		if now.Before(bounds[1]) {
+			bounds[0] = bounds[0].AddDate(0, 0, -1)
+		} else {
+			bounds[1] = bounds[1].AddDate(0, 0, 1)
+		}
+	}
+	return bounds[0], bounds[1], nil
+}
@@ -0,0 +1,199 @@
+// Package prefix records the state of a Wine prefix and finds what changed since, so the
+// changes an installer or tweak made can be reviewed or exported as a recipe and applied
+// to fresh prefixes elsewhere.
+package prefix
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"yapl/internal/registry"
+)
+
+// Snapshot is the state of a prefix: its files by size and modification time, and every
+// registry value.
+type Snapshot struct {
+	Created  time.Time                    `json:"created"`
+	Files    map[string]FileState         `json:"files"`
+	Registry map[string]map[string]string `json:"registry"` // Full key path to value name to data
+}
+
+// FileState identifies a version of a file cheaply, like rsync does.
+type FileState struct {
+	Size    int64 `json:"size"`
+	ModTime int64 `json:"mtime"`
+}
+
+// Diff lists what changed in a prefix since a snapshot. Paths are relative to the prefix.
+type Diff struct {
+	Added    []string         `json:"added,omitempty"`
+	Changed  []string         `json:"changed,omitempty"`
+	Removed  []string         `json:"removed,omitempty"`
+	Registry []RegistryChange `json:"registry,omitempty"`
+}
+
+// RegistryChange is a value that was set or removed. Data is in Wine's notation (see
+// registry.Value).
+type RegistryChange struct {
+	Key     string `json:"key"` // e.g. `HKCU\Software\Wine\DllOverrides`
+	Name    string `json:"name"`
+	Data    string `json:"data,omitempty"`
+	Removed bool   `json:"removed,omitempty"`
+}
+
+// hives maps the hive files to the roots their keys are shown under.
+var hives = map[string]string{registry.SystemFile: "HKLM", registry.UserFile: "HKCU"}
+
+// skipFiles are Wine's own bookkeeping, tracked through the registry or rewritten on
+// every start.
+var skipFiles = []string{registry.SystemFile, registry.UserFile, "userdef.reg", ".update-timestamp", "dosdevices", "shadercache", "pfx"}
+
+// skipKeys describe this machine's hardware or change on every start, so they do not
+// belong in a recipe.
+var skipKeys = []string{
+	`HKLM\Hardware`,
+	`HKLM\System\CurrentControlSet\Enum`,
+	`HKLM\System\CurrentControlSet\Control\Class`,
+	`HKLM\System\CurrentControlSet\Control\Video`,
+	`HKLM\Software\Wine\Ports`,
+	`HKCU\Volatile Environment`,
+}
+
+// Take records the current state of the prefix at dir.
+func Take(dir string) (*Snapshot, error) {
+	s := &Snapshot{Created: time.Now().UTC(), Files: map[string]FileState{}, Registry: map[string]map[string]string{}}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		rel = filepath.ToSlash(rel)
+		if rel != "." && !strings.Contains(rel, "/") && slices.Contains(skipFiles, rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		s.Files[rel] = FileState{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for file, root := range hives {
+		hive, err := registry.Load(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range hive.Keys {
+			full := root + `\` + key.Name
+			if skippedKey(full) {
+				continue
+			}
+			values := map[string]string{}
+			for _, v := range key.Values {
+				values[v.Name] = v.Data
+			}
+			s.Registry[full] = values
+		}
+	}
+	return s, nil
+}
+
+// Load reads a snapshot saved with Save.
+func Load(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Save writes the snapshot to path, creating its directory.
+func (s *Snapshot) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Compare returns what changed from the snapshot before to now.
+func Compare(before, now *Snapshot) Diff {
+	var d Diff
+	for path, state := range now.Files {
+		old, ok := before.Files[path]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, path)
+		case old != state:
+			d.Changed = append(d.Changed, path)
+		}
+	}
+	for path := range before.Files {
+		if _, ok := now.Files[path]; !ok {
+			d.Removed = append(d.Removed, path)
+		}
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Changed)
+	sort.Strings(d.Removed)
+
+	for key, values := range now.Registry {
+		for name, data := range values {
+			if old, ok := before.Registry[key][name]; !ok || old != data {
+				d.Registry = append(d.Registry, RegistryChange{Key: key, Name: name, Data: data})
+			}
+		}
+	}
+	for key, values := range before.Registry {
+		for name := range values {
+			if _, ok := now.Registry[key][name]; !ok {
+				d.Registry = append(d.Registry, RegistryChange{Key: key, Name: name, Removed: true})
+			}
+		}
+	}
+	sort.Slice(d.Registry, func(i, j int) bool {
+		a, b := d.Registry[i], d.Registry[j]
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Name < b.Name
+	})
+	return d
+}
+
+// Empty reports whether nothing changed.
+func (d Diff) Empty() bool {
+	return len(d.Added)+len(d.Changed)+len(d.Removed)+len(d.Registry) == 0
+}
+
+func skippedKey(key string) bool {
+	for _, skip := range skipKeys {
+		if strings.EqualFold(key, skip) || (len(key) > len(skip) && key[len(skip)] == '\\' && strings.EqualFold(key[:len(skip)], skip)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package prefix
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"yapl/internal/registry"
+)
+
+// uninstallKeys are where installers register programs for Windows' "Apps & features",
+// relative to the hive root; the second one holds 32-bit programs in a 64-bit prefix.
+var uninstallKeys = []string{
+	`Software\Microsoft\Windows\CurrentVersion\Uninstall`,
+	`Software\Wow6432Node\Microsoft\Windows\CurrentVersion\Uninstall`,
+}
+
+// Program is a Windows program installed in a prefix, as registered for uninstalling.
+type Program struct {
+	Key             string `json:"key"` // Full registry key, e.g. `HKLM\Software\...\Uninstall\{GUID}`
+	Name            string `json:"name"`
+	Version         string `json:"version,omitempty"`
+	Publisher       string `json:"publisher,omitempty"`
+	InstallLocation string `json:"install_location,omitempty"`
+	UninstallString string `json:"uninstall_string,omitempty"`
+	// QuietUninstallString is the command some installers register to uninstall without
+	// asking anything.
+	QuietUninstallString string `json:"quiet_uninstall_string,omitempty"`
+}
+
+// Programs lists the programs installed in the prefix at dir by name, leaving out those
+// Windows hides too: system components and updates of other programs.
+func Programs(dir string) ([]Program, error) {
+	var programs []Program
+	for _, file := range []string{registry.SystemFile, registry.UserFile} {
+		hive, err := registry.Load(filepath.Join(dir, file))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, parent := range uninstallKeys {
+			for _, key := range hive.Subkeys(parent) {
+				name, _ := key.String("DisplayName")
+				if name == "" {
+					continue
+				}
+				if hidden, _ := key.DWORD("SystemComponent"); hidden == 1 {
+					continue
+				}
+				if _, update := key.String("ParentKeyName"); update {
+					continue
+				}
+				p := Program{Key: hives[file] + `\` + key.Name, Name: name}
+				p.Version, _ = key.String("DisplayVersion")
+				p.Publisher, _ = key.String("Publisher")
+				p.InstallLocation, _ = key.String("InstallLocation")
+				p.UninstallString, _ = key.String("UninstallString")
+				p.QuietUninstallString, _ = key.String("QuietUninstallString")
+				programs = append(programs, p)
+			}
+		}
+	}
+	sort.Slice(programs, func(i, j int) bool { return strings.ToLower(programs[i].Name) < strings.ToLower(programs[j].Name) })
+	return programs, nil
+}
+
+// FindProgram returns the program named name, compared case-insensitively, or else the
+// only one whose name contains it.
+func FindProgram(programs []Program, name string) (Program, error) {
+	var matches []Program
+	for _, p := range programs {
+		if strings.EqualFold(p.Name, name) {
+			return p, nil
+		}
+		if strings.Contains(strings.ToLower(p.Name), strings.ToLower(name)) {
+			matches = append(matches, p)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Program{}, fmt.Errorf("no installed program matches '%s'; see 'prefix programs'", name)
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, p := range matches {
+		names[i] = "'" + p.Name + "'"
+	}
+	return Program{}, fmt.Errorf("'%s' matches %s; give the full name", name, strings.Join(names, ", "))
+}
+
+// SilentUninstall returns the command line that uninstalls the program without asking,
+// split into arguments for wine. silent is false if the uninstaller is of no kind known to
+// have a silent mode, so it may show its dialogs.
+func (p Program) SilentUninstall() (args []string, silent bool, err error) {
+	if p.QuietUninstallString != "" {
+		return splitCommandLine(p.QuietUninstallString), true, nil
+	}
+	if p.UninstallString == "" {
+		return nil, false, fmt.Errorf("'%s' has no uninstall command", p.Name)
+	}
+	args = splitCommandLine(p.UninstallString)
+	exe := strings.ToLower(args[0])
+	exe = exe[strings.LastIndexAny(exe, `\/`)+1:]
+	switch {
+	case exe == "msiexec" || exe == "msiexec.exe":
+		// Registered as "MsiExec.exe /I{GUID}", which opens the maintenance dialog.
+		for i, a := range args[1:] {
+			code, ok := cutOption(a, "i", "x")
+			if !ok && (strings.EqualFold(a, "/i") || strings.EqualFold(a, "/x")) && i+2 < len(args) {
+				code, ok = args[i+2], true
+			}
+			if ok {
+				return []string{"msiexec", "/x", code, "/qn", "/norestart"}, true, nil
+			}
+		}
+	case innoUninstaller(exe):
+		return append(args, "/VERYSILENT", "/SUPPRESSMSGBOXES", "/NORESTART"), true, nil
+	case strings.HasPrefix(exe, "uninst"):
+		// NSIS: uninst.exe or uninstall.exe
+		return append(args, "/S"), true, nil
+	}
+	return args, false, nil
+}
+
+// innoUninstaller reports whether exe is the name of an Inno Setup uninstaller, e.g.
+// unins000.exe.
+func innoUninstaller(exe string) bool {
+	digits, ok := strings.CutPrefix(strings.TrimSuffix(exe, ".exe"), "unins")
+	return ok && len(digits) == 3 && strings.Trim(digits, "0123456789") == ""
+}
+
+// cutOption returns the value of an MSI option such as "/I{GUID}" or "/x {GUID}" given as
+// a single argument.
+func cutOption(arg string, names ...string) (string, bool) {
+	if len(arg) < 3 || (arg[0] != '/' && arg[0] != '-') {
+		return "", false
+	}
+	for _, n := range names {
+		if strings.EqualFold(arg[1:2], n) {
+			return strings.TrimSpace(arg[2:]), strings.TrimSpace(arg[2:]) != ""
+		}
+	}
+	return "", false
+}
+
+// splitCommandLine splits a Windows command line into arguments. Quotes group words, and
+// an unquoted program path with spaces, which many installers register, extends to the
+// first word ending in ".exe".
+func splitCommandLine(s string) []string {
+	var args []string
+	var cur strings.Builder
+	quoted, inArg := false, false
+	for _, r := range strings.TrimSpace(s) {
+		switch {
+		case r == '"':
+			quoted, inArg = !quoted, true
+		case (r == ' ' || r == '\t') && !quoted:
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	if len(args) == 0 {
+		return []string{""}
+	}
+	if !strings.HasPrefix(strings.TrimSpace(s), `"`) && !strings.HasSuffix(strings.ToLower(args[0]), ".exe") {
+		for i := 1; i < len(args); i++ {
+			if strings.HasSuffix(strings.ToLower(args[i]), ".exe") {
+				return append([]string{strings.Join(args[:i+1], " ")}, args[i+1:]...)
+			}
+		}
+	}
+	return args
+}
@@ -0,0 +1,177 @@
+package prefix
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"yapl/internal/fs"
+	"yapl/internal/registry"
+)
+
+// RecipeFile describes a recipe; the files it adds or replaces are kept next to it in
+// the recipe's "files" directory, at their path in the prefix.
+const RecipeFile = "recipe.json"
+
+// Recipe is a set of prefix changes that can be applied to another prefix.
+type Recipe struct {
+	Files      []string         `json:"files,omitempty"`
+	Removed    []string         `json:"removed,omitempty"`
+	Registry   []RegistryChange `json:"registry,omitempty"`
+	Winetricks []string         `json:"winetricks,omitempty"` // Verbs that make the same changes
+}
+
+// verbFiles maps files that winetricks verbs install (lower case, without directory) to
+// the verb, for recognizing the verbs behind a set of changes.
+var verbFiles = map[string]string{
+	"d3dcompiler_43.dll": "d3dcompiler_43",
+	"d3dcompiler_47.dll": "d3dcompiler_47",
+	"xinput1_3.dll":      "xinput",
+	"xaudio2_7.dll":      "xact",
+	"physxloader.dll":    "physx",
+	"msvcp90.dll":        "vcrun2008",
+	"msvcp100.dll":       "vcrun2010",
+	"msvcp110.dll":       "vcrun2012",
+	"msvcp120.dll":       "vcrun2013",
+	"msvcp140.dll":       "vcrun2022",
+	"vcruntime140.dll":   "vcrun2022",
+	"arial.ttf":          "corefonts",
+	"times.ttf":          "corefonts",
+}
+
+// verbPatterns are matched against the file name when verbFiles has no entry.
+var verbPatterns = map[string]string{
+	"d3dx9_*.dll":  "d3dx9",
+	"d3dx10_*.dll": "d3dx10",
+	"d3dx11_*.dll": "d3dx11_43",
+}
+
+// Verbs returns the winetricks verbs whose files were added or changed.
+func (d Diff) Verbs() []string {
+	found := map[string]bool{}
+	for _, p := range append(append([]string{}, d.Added...), d.Changed...) {
+		lower := strings.ToLower(p)
+		if strings.HasPrefix(lower, "drive_c/windows/microsoft.net/framework/v4.0.30319/") {
+			found["dotnet48"] = true
+			continue
+		}
+		if !strings.HasPrefix(lower, "drive_c/windows/system32/") && !strings.HasPrefix(lower, "drive_c/windows/syswow64/") && !strings.HasPrefix(lower, "drive_c/windows/fonts/") {
+			continue
+		}
+		name := path.Base(lower)
+		if verb, ok := verbFiles[name]; ok {
+			found[verb] = true
+			continue
+		}
+		for pattern, verb := range verbPatterns {
+			if ok, _ := path.Match(pattern, name); ok {
+				found[verb] = true
+			}
+		}
+	}
+	verbs := make([]string, 0, len(found))
+	for verb := range found {
+		verbs = append(verbs, verb)
+	}
+	sort.Strings(verbs)
+	return verbs
+}
+
+// Export writes the changes in the prefix at dir as a recipe in dest, copying the added
+// and changed files.
+func Export(dir string, d Diff, dest string) (Recipe, error) {
+	recipe := Recipe{
+		Files:      append(append([]string{}, d.Added...), d.Changed...),
+		Removed:    d.Removed,
+		Registry:   d.Registry,
+		Winetricks: d.Verbs(),
+	}
+	sort.Strings(recipe.Files)
+	if err := os.MkdirAll(filepath.Join(dest, "files"), 0755); err != nil {
+		return recipe, err
+	}
+	for _, f := range recipe.Files {
+		target := filepath.Join(dest, "files", filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return recipe, err
+		}
+		if err := fs.CopyFile(filepath.Join(dir, filepath.FromSlash(f)), target); err != nil {
+			return recipe, err
+		}
+	}
+	data, err := json.MarshalIndent(recipe, "", "  ")
+	if err != nil {
+		return recipe, err
+	}
+	return recipe, os.WriteFile(filepath.Join(dest, RecipeFile), append(data, '\n'), 0644)
+}
+
+// LoadRecipe reads the recipe in dir.
+func LoadRecipe(dir string) (Recipe, error) {
+	var recipe Recipe
+	data, err := os.ReadFile(filepath.Join(dir, RecipeFile))
+	if err != nil {
+		return recipe, err
+	}
+	if err := json.Unmarshal(data, &recipe); err != nil {
+		return recipe, fmt.Errorf("%s: %w", RecipeFile, err)
+	}
+	for _, f := range append(append([]string{}, recipe.Files...), recipe.Removed...) {
+		if clean := path.Clean(f); path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return recipe, fmt.Errorf("%s: path '%s' leaves the prefix", RecipeFile, f)
+		}
+	}
+	return recipe, nil
+}
+
+// Apply makes the recipe in recipeDir's changes to the prefix at dir: it copies its
+// files, removes the files it removed and sets its registry values. Wine must not be
+// running in the prefix.
+func Apply(recipeDir string, recipe Recipe, dir string) error {
+	for _, f := range recipe.Files {
+		target := filepath.Join(dir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := fs.CopyFile(filepath.Join(recipeDir, "files", filepath.FromSlash(f)), target); err != nil {
+			return err
+		}
+	}
+	for _, f := range recipe.Removed {
+		if err := os.Remove(filepath.Join(dir, filepath.FromSlash(f))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	loaded := map[string]*registry.Hive{}
+	for _, change := range recipe.Registry {
+		file, name, err := registry.SplitPath(change.Key)
+		if err != nil {
+			return err
+		}
+		hive := loaded[file]
+		if hive == nil {
+			if hive, err = registry.Load(filepath.Join(dir, file)); err != nil {
+				return err
+			}
+			loaded[file] = hive
+		}
+		if change.Removed {
+			if key := hive.Key(name); key != nil {
+				key.Delete(change.Name)
+			}
+			continue
+		}
+		hive.CreateKey(name).Set(change.Name, change.Data)
+	}
+	for file, hive := range loaded {
+		if err := hive.Save(filepath.Join(dir, file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
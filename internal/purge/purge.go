@@ -0,0 +1,94 @@
+// Package purge finds and removes the data yapl manages, grouped into categories that
+// can be removed separately.
+package purge
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"yapl/internal/fs"
+)
+
+// Category is a kind of yapl data.
+type Category struct {
+	Name        string
+	Description string
+	Paths       []string // Existing files and directories, relative to the yapl root
+	Size        int64
+}
+
+// Categories lists what is installed, in the order it should be removed: caches first
+// and the configs that describe everything else last.
+func Categories() []Category {
+	cats := []Category{
+		{
+			Name:        "caches",
+			Description: "download queue, runtime download cache, archives kept for LAN peers and game logs",
+			Paths:       existing(append([]string{"state/downloads", "dependencies/runtime-cache", "dependencies/archives"}, glob("games/*/logs", "apps/*/logs")...)),
+		},
+		{
+			Name:        "prefixes",
+			Description: "Wine prefixes, including installed games and their saves",
+			Paths:       existing(glob("games/*/prefix", "apps/*/prefix")),
+		},
+		{
+			Name:        "dependencies",
+			Description: "Proton builds, DXVK, VKD3D, umu-launcher, Steam runtimes and the shared store",
+			Paths:       existing([]string{"proton", "dependencies", "store"}),
+		},
+		{
+			Name:        "configs",
+			Description: "runner.json, game and app directories with their configs and save backups, hook scripts and session history",
+			Paths:       existing([]string{"runner.json", "games", "apps", "hooks", "state"}),
+		},
+	}
+	for i := range cats {
+		for _, p := range cats[i].Paths {
+			cats[i].Size += size(p)
+		}
+	}
+	return cats
+}
+
+// Remove deletes a path, calling removed with it and its size once it is gone. Symlinks
+// (such as linked prefixes) are removed, never what they point to.
+func Remove(path string, removed func(path string, size int64)) error {
+	n := size(path)
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	removed(path, n)
+	return nil
+}
+
+func glob(patterns ...string) []string {
+	var paths []string
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		paths = append(paths, matches...)
+	}
+	return paths
+}
+
+func existing(paths []string) []string {
+	var found []string
+	for _, p := range paths {
+		if _, err := os.Lstat(p); err == nil {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// size adds up the regular files under path without following symlinks.
+func size(path string) int64 {
+	var total int64
+	fs.Walk(context.Background(), path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
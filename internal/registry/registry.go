@@ -0,0 +1,502 @@
+// Package registry reads and edits the registry hives Wine keeps in a prefix (system.reg,
+// user.reg and userdef.reg) as plain files, without starting Wine. Hives must only be
+// written while no wineserver is using the prefix: it keeps the registry in memory and
+// overwrites the files when it exits.
+package registry
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Hive is a parsed .reg file. Keys keep the order they had in the file and the lines of
+// values that are not changed are written back exactly as they were read.
+type Hive struct {
+	Header []string // Lines before the first key, e.g. "WINE REGISTRY Version 2" and "#arch=win64"
+	Keys   []*Key
+}
+
+// Key is a registry key. Name is relative to the hive's root, with single backslashes
+// between its parts (e.g. `Software\Wine\DllOverrides`).
+type Key struct {
+	Name     string
+	Modified time.Time
+	Meta     []string // Lines starting with '#' after the key, e.g. "#time=..." or "#class=..."
+	Values   []*Value
+}
+
+// Value is a named value of a key; the default value has an empty name. Data is its text
+// after '=' as Wine writes it, e.g. `"text"`, `dword:00000001` or `hex:01,02`.
+type Value struct {
+	Name string
+	Data string
+
+	raw string // Source lines, including continuations; empty once the value changed
+}
+
+// Hive files in a prefix.
+const (
+	SystemFile = "system.reg" // HKEY_LOCAL_MACHINE
+	UserFile   = "user.reg"   // HKEY_CURRENT_USER
+)
+
+// SplitPath splits a full key path such as `HKCU\Software\Wine` into the hive file that
+// holds it and the key's name within that file.
+func SplitPath(path string) (string, string, error) {
+	root, name, _ := strings.Cut(cleanName(path), `\`)
+	switch strings.ToUpper(root) {
+	case "HKCU", "HKEY_CURRENT_USER":
+		return UserFile, name, nil
+	case "HKLM", "HKEY_LOCAL_MACHINE":
+		return SystemFile, name, nil
+	}
+	return "", "", fmt.Errorf("'%s' is not under HKCU or HKLM", path)
+}
+
+// Load parses the hive at path.
+func Load(path string) (*Hive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return h, nil
+}
+
+// Parse reads a hive in Wine's .reg format.
+func Parse(r io.Reader) (*Hive, error) {
+	h := &Hive{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	var key *Key
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if key == nil && !strings.HasPrefix(line, "[") {
+			if lineNo == 1 && !strings.HasPrefix(line, "WINE REGISTRY Version") {
+				return nil, fmt.Errorf("not a Wine registry file")
+			}
+			h.Header = append(h.Header, line)
+			continue
+		}
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "["):
+			k, err := parseKeyLine(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			key = k
+			h.Keys = append(h.Keys, key)
+		case strings.HasPrefix(line, "#"):
+			key.Meta = append(key.Meta, line)
+		case strings.HasPrefix(line, ";"):
+		default:
+			raw := line
+			// Long hex values are wrapped, each line but the last ending in a backslash.
+			for strings.HasSuffix(line, "\\") && scanner.Scan() {
+				lineNo++
+				raw += "\n" + scanner.Text()
+				line = line[:len(line)-1] + strings.TrimLeft(scanner.Text(), " \t")
+			}
+			v, err := parseValueLine(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			v.raw = raw
+			key.Values = append(key.Values, v)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(h.Header) == 0 {
+		return nil, fmt.Errorf("not a Wine registry file")
+	}
+	return h, nil
+}
+
+func parseKeyLine(line string) (*Key, error) {
+	name, end, err := unescape(line, 1, ']')
+	if err != nil {
+		return nil, err
+	}
+	key := &Key{Name: name}
+	if stamp := strings.TrimSpace(line[end+1:]); stamp != "" {
+		sec, err := strconv.ParseInt(stamp, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp '%s'", stamp)
+		}
+		key.Modified = time.Unix(sec, 0)
+	}
+	return key, nil
+}
+
+func parseValueLine(line string) (*Value, error) {
+	v := &Value{}
+	rest := line
+	switch {
+	case strings.HasPrefix(line, "@="):
+		rest = line[2:]
+	case strings.HasPrefix(line, "\""):
+		name, end, err := unescape(line, 1, '"')
+		if err != nil {
+			return nil, err
+		}
+		if end+1 >= len(line) || line[end+1] != '=' {
+			return nil, fmt.Errorf("expected '=' after value name")
+		}
+		v.Name, rest = name, line[end+2:]
+	default:
+		return nil, fmt.Errorf("unexpected line '%s'", line)
+	}
+	v.Data = rest
+	return v, nil
+}
+
+// Key returns the key with the given name, compared case-insensitively like Windows does,
+// or nil.
+func (h *Hive) Key(name string) *Key {
+	name = cleanName(name)
+	for _, k := range h.Keys {
+		if strings.EqualFold(k.Name, name) {
+			return k
+		}
+	}
+	return nil
+}
+
+// CreateKey returns the named key, adding it to the end of the hive if it is missing.
+// Wine creates missing parent keys itself when it loads the hive.
+func (h *Hive) CreateKey(name string) *Key {
+	if k := h.Key(name); k != nil {
+		return k
+	}
+	k := &Key{Name: cleanName(name)}
+	k.touch()
+	h.Keys = append(h.Keys, k)
+	return k
+}
+
+// DeleteKey removes a key and everything below it, reporting whether anything was removed.
+func (h *Hive) DeleteKey(name string) bool {
+	name = cleanName(name)
+	kept := h.Keys[:0]
+	for _, k := range h.Keys {
+		if !strings.EqualFold(k.Name, name) && !isBelow(k.Name, name) {
+			kept = append(kept, k)
+		}
+	}
+	removed := len(kept) != len(h.Keys)
+	h.Keys = kept
+	return removed
+}
+
+// Subkeys returns the keys directly below name, e.g. every installed program for
+// `Software\Microsoft\Windows\CurrentVersion\Uninstall`.
+func (h *Hive) Subkeys(name string) []*Key {
+	name = cleanName(name)
+	var keys []*Key
+	for _, k := range h.Keys {
+		if isBelow(k.Name, name) && !strings.Contains(k.Name[len(name)+1:], `\`) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Arch returns the architecture recorded in the header ("win32" or "win64"), or "" if
+// the hive has none.
+func (h *Hive) Arch() string {
+	for _, line := range h.Header {
+		if arch, ok := strings.CutPrefix(line, "#arch="); ok {
+			return arch
+		}
+	}
+	return ""
+}
+
+// WriteTo writes the hive in Wine's format.
+func (h *Hive) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	header := h.Header
+	for len(header) > 0 && header[len(header)-1] == "" {
+		header = header[:len(header)-1]
+	}
+	for _, line := range header {
+		b.WriteString(line + "\n")
+	}
+	for _, k := range h.Keys {
+		b.WriteString("\n[" + escape(k.Name, "[]") + "]")
+		if !k.Modified.IsZero() {
+			fmt.Fprintf(&b, " %d", k.Modified.Unix())
+		}
+		b.WriteByte('\n')
+		for _, line := range k.Meta {
+			b.WriteString(line + "\n")
+		}
+		for _, v := range k.Values {
+			if v.raw != "" {
+				b.WriteString(v.raw + "\n")
+			} else if v.Name == "" {
+				b.WriteString("@=" + v.Data + "\n")
+			} else {
+				b.WriteString("\"" + escape(v.Name, "\"") + "\"=" + v.Data + "\n")
+			}
+		}
+	}
+	n, err := w.Write(b.Bytes())
+	return int64(n), err
+}
+
+// Save writes the hive to path, replacing the file atomically.
+func (h *Hive) Save(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".yapl-reg-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := h.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		os.Chmod(tmp.Name(), info.Mode().Perm())
+	} else {
+		os.Chmod(tmp.Name(), 0644)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Value returns the named value; "" or "@" is the default value.
+func (k *Key) Value(name string) *Value {
+	if name == "@" {
+		name = ""
+	}
+	for _, v := range k.Values {
+		if strings.EqualFold(v.Name, name) {
+			return v
+		}
+	}
+	return nil
+}
+
+// String returns a string value (REG_SZ or REG_EXPAND_SZ).
+func (k *Key) String(name string) (string, bool) {
+	v := k.Value(name)
+	if v == nil {
+		return "", false
+	}
+	return v.String()
+}
+
+// DWORD returns a REG_DWORD value.
+func (k *Key) DWORD(name string) (uint32, bool) {
+	v := k.Value(name)
+	if v == nil {
+		return 0, false
+	}
+	return v.DWORD()
+}
+
+// Set stores raw value data in Wine's notation, replacing an existing value of that name.
+func (k *Key) Set(name, data string) {
+	if name == "@" {
+		name = ""
+	}
+	k.touch()
+	if v := k.Value(name); v != nil {
+		v.Data, v.raw = data, ""
+		return
+	}
+	k.Values = append(k.Values, &Value{Name: name, Data: data})
+}
+
+// SetString stores a REG_SZ value.
+func (k *Key) SetString(name, s string) {
+	k.Set(name, Quote(s))
+}
+
+// Quote returns s as REG_SZ value data.
+func Quote(s string) string {
+	return "\"" + escape(s, "\"") + "\""
+}
+
+// SetDWORD stores a REG_DWORD value.
+func (k *Key) SetDWORD(name string, n uint32) {
+	k.Set(name, fmt.Sprintf("dword:%08x", n))
+}
+
+// Delete removes a value, reporting whether it existed.
+func (k *Key) Delete(name string) bool {
+	if name == "@" {
+		name = ""
+	}
+	for i, v := range k.Values {
+		if strings.EqualFold(v.Name, name) {
+			k.Values = append(k.Values[:i], k.Values[i+1:]...)
+			k.touch()
+			return true
+		}
+	}
+	return false
+}
+
+// touch records a modification now, in the key line and in the "#time=" line Wine keeps
+// with 100ns precision.
+func (k *Key) touch() {
+	now := time.Now()
+	k.Modified = time.Unix(now.Unix(), 0)
+	filetime := fmt.Sprintf("#time=%x", (now.UnixNano()/100)+116444736000000000)
+	for i, line := range k.Meta {
+		if strings.HasPrefix(line, "#time=") {
+			k.Meta[i] = filetime
+			return
+		}
+	}
+	k.Meta = append([]string{filetime}, k.Meta...)
+}
+
+// String decodes a REG_SZ (`"..."`) or REG_EXPAND_SZ (`str(2):"..."`) value.
+func (v *Value) String() (string, bool) {
+	data := strings.TrimPrefix(v.Data, "str(2):")
+	if !strings.HasPrefix(data, "\"") {
+		return "", false
+	}
+	s, end, err := unescape(data, 1, '"')
+	if err != nil || end != len(data)-1 {
+		return "", false
+	}
+	return s, true
+}
+
+// DWORD decodes a REG_DWORD (`dword:0000001e`) value.
+func (v *Value) DWORD() (uint32, bool) {
+	hex, ok := strings.CutPrefix(v.Data, "dword:")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	return uint32(n), err == nil
+}
+
+func cleanName(name string) string {
+	return strings.Trim(strings.ReplaceAll(name, "/", `\`), `\`)
+}
+
+func isBelow(name, parent string) bool {
+	return len(name) > len(parent)+1 && name[len(parent)] == '\\' && strings.EqualFold(name[:len(parent)], parent)
+}
+
+// unescape decodes the escaped text starting at s[i] up to the unescaped delimiter and
+// returns it with the delimiter's index. Wine escapes backslashes, delimiters, control
+// characters and non-ASCII UTF-16 code units (as \x followed by up to four hex digits).
+func unescape(s string, i int, delim byte) (string, int, error) {
+	var units []uint16
+	for i < len(s) {
+		c := s[i]
+		if c == delim {
+			return string(utf16.Decode(units)), i, nil
+		}
+		if c != '\\' {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			units = utf16.AppendRune(units, r)
+			i += size
+			continue
+		}
+		i++
+		if i >= len(s) {
+			break
+		}
+		c = s[i]
+		i++
+		switch c {
+		case 'a':
+			units = append(units, '\a')
+		case 'b':
+			units = append(units, '\b')
+		case 'e':
+			units = append(units, 0x1b)
+		case 'f':
+			units = append(units, '\f')
+		case 'n':
+			units = append(units, '\n')
+		case 'r':
+			units = append(units, '\r')
+		case 't':
+			units = append(units, '\t')
+		case 'v':
+			units = append(units, '\v')
+		case 'x':
+			j := i
+			for j < len(s) && j < i+4 && isHex(s[j]) {
+				j++
+			}
+			n, _ := strconv.ParseUint(s[i:j], 16, 16)
+			units = append(units, uint16(n))
+			i = j
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			j := i - 1
+			for j < len(s) && j < i+2 && s[j] >= '0' && s[j] <= '7' {
+				j++
+			}
+			n, _ := strconv.ParseUint(s[i-1:j], 8, 16)
+			units = append(units, uint16(n))
+			i = j
+		default:
+			units = append(units, uint16(c))
+		}
+	}
+	return "", 0, fmt.Errorf("missing closing '%c'", delim)
+}
+
+// escape encodes s the way Wine writes names and strings, also escaping the characters in
+// special.
+func escape(s, special string) string {
+	var b strings.Builder
+	units := utf16.Encode([]rune(s))
+	for i, u := range units {
+		switch {
+		case u == '\\' || (u < 128 && strings.ContainsRune(special, rune(u))):
+			b.WriteByte('\\')
+			b.WriteByte(byte(u))
+		case u == '\n':
+			b.WriteString(`\n`)
+		case u == '\r':
+			b.WriteString(`\r`)
+		case u == '\t':
+			b.WriteString(`\t`)
+		case u < 32 || u > 126:
+			// Pad to four digits when a hex digit follows, so it is not read as part of this one.
+			if i+1 < len(units) && units[i+1] < 128 && isHex(byte(units[i+1])) {
+				fmt.Fprintf(&b, `\x%04x`, u)
+			} else {
+				fmt.Fprintf(&b, `\x%x`, u)
+			}
+		default:
+			b.WriteByte(byte(u))
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,183 @@
+package registry
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadFixture(t *testing.T) (*Hive, []byte) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "user.reg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, data
+}
+
+func TestParseFixture(t *testing.T) {
+	h, _ := loadFixture(t)
+	if h.Arch() != "win64" {
+		t.Errorf("arch = %q", h.Arch())
+	}
+	if len(h.Keys) != 5 {
+		t.Fatalf("parsed %d keys, want 5", len(h.Keys))
+	}
+	desktop := h.Key(`control panel\desktop`)
+	if desktop == nil || !desktop.Modified.Equal(time.Unix(1700000000, 0)) || desktop.Meta[0] != "#time=1da1c2b3d4e5f60" {
+		t.Fatalf("Control Panel\\Desktop: %+v", desktop)
+	}
+	if h.Key(`Software\Weird[Key]`) == nil {
+		t.Error("the key with escaped brackets was not found")
+	}
+	if got := h.Subkeys(`Software\Wine`); len(got) != 3 {
+		t.Errorf("Software\\Wine has %d subkeys, want 3", len(got))
+	}
+
+	strs := h.Key(`Software\Wine\Strings`)
+	for name, want := range map[string]string{
+		"@":              "default value",
+		`Quoted "name"`:  `say "hi"`,
+		"path":           `C:\Program Files\Game\`,
+		"Accents":        "Café naïve 日本",
+		"Padded":         "éa",
+		"Lines":          "one\ntwo\tthree",
+		"Expand":         `%SystemRoot%\system32`,
+		"Brackets [x]":   "][",
+		"missing":        "",
+		"DragFullWindow": "",
+	} {
+		got, ok := strs.String(name)
+		if got != want || ok != (want != "") {
+			t.Errorf("%s: got %q, %v, want %q", name, got, ok, want)
+		}
+	}
+	if got, ok := h.Key(`Software\Weird[Key]`).String("Empty"); !ok || got != "" {
+		t.Errorf("Empty: got %q, %v", got, ok)
+	}
+
+	bin := h.Key(`Software\Wine\Binary`)
+	for name, want := range map[string]uint32{"Zero": 0, "Max": 0xffffffff} {
+		if got, ok := bin.DWORD(name); !ok || got != want {
+			t.Errorf("%s: got %#x, %v, want %#x", name, got, ok, want)
+		}
+	}
+	if got, ok := desktop.DWORD("FontSmoothingGamma"); !ok || got != 0x578 {
+		t.Errorf("FontSmoothingGamma: got %#x, %v", got, ok)
+	}
+	if _, ok := desktop.DWORD("UserPreferencesMask"); ok {
+		t.Error("a hex value was read as a dword")
+	}
+	if _, ok := bin.String("Zero"); ok {
+		t.Error("a dword was read as a string")
+	}
+	if got := bin.Value("Multi").Data; got != "hex(7):41,00,00,00,42,00,43,00,00,00,00,00" {
+		t.Errorf("Multi: %q", got)
+	}
+	// Wrapped values are joined without the backslashes and indentation.
+	long := bin.Value("Long").Data
+	if !strings.HasPrefix(long, "hex:00,01,") || !strings.HasSuffix(long, ",2e,2f,30") || strings.ContainsAny(long, "\\ \n") {
+		t.Errorf("Long: %q", long)
+	}
+	if len(strings.Split(strings.TrimPrefix(long, "hex:"), ",")) != 0x31 {
+		t.Errorf("Long has %d bytes, want %d", len(strings.Split(strings.TrimPrefix(long, "hex:"), ",")), 0x31)
+	}
+	if bin.Meta[1] != `#class="Binary"` {
+		t.Errorf("meta lines: %q", bin.Meta)
+	}
+}
+
+func TestWriteUnchangedFixture(t *testing.T) {
+	h, data := loadFixture(t)
+	var out bytes.Buffer
+	if _, err := h.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != string(data) {
+		t.Fatalf("writing the hive changed it:\n%s", out.String())
+	}
+}
+
+func TestEditFixture(t *testing.T) {
+	h, _ := loadFixture(t)
+	strs := h.Key(`Software\Wine\Strings`)
+	strs.SetString("Path", `D:\Games\`)
+	strs.SetString("New", "Ünïcode \"quoted\"\n1")
+	h.Key(`Software\Wine\Binary`).SetDWORD("Max", 7)
+	h.CreateKey(`Software/Wine/Created/`).SetString("", "x")
+	if !h.DeleteKey(`software\wine\dlloverrides`) || h.Key(`Software\Wine\DllOverrides`) != nil {
+		t.Fatal("DllOverrides was not deleted")
+	}
+
+	var out bytes.Buffer
+	if _, err := h.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"Path"="D:\\Games\\"`,
+		`"New"="\xdcn\x00efcode \"quoted\"\n1"`, // Padded before the hex digit c
+		`"Max"=dword:00000007`,
+		"[Software\\\\Wine\\\\Created] ",
+		`@="x"`,
+		// Untouched wrapped values keep their lines.
+		"\"Long\"=hex:00,01,02,03,04,05,06,07,08,09,0a,0b,0c,0d,0e,0f,10,11,12,13,14,15,\\\n  16,",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("the written hive lacks %s", want)
+		}
+	}
+
+	reread, err := Parse(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reread.Key(`Software\Wine\Strings`).String("New"); got != "Ünïcode \"quoted\"\n1" {
+		t.Errorf("New reads back as %q", got)
+	}
+	if got, _ := reread.Key(`Software\Wine\Strings`).String("Accents"); got != "Café naïve 日本" {
+		t.Errorf("Accents reads back as %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, src := range map[string]string{
+		"no header":            "[Software] 1\n",
+		"other file":           "REGEDIT4\n\n[HKEY_CURRENT_USER]\n",
+		"empty":                "",
+		"unterminated key":     "WINE REGISTRY Version 2\n\n[Software\n",
+		"bad timestamp":        "WINE REGISTRY Version 2\n\n[Software] soon\n",
+		"unterminated name":    "WINE REGISTRY Version 2\n\n[Software] 1\n\"name=dword:1\n",
+		"name without =":       "WINE REGISTRY Version 2\n\n[Software] 1\n\"name\" dword:1\n",
+		"value without a name": "WINE REGISTRY Version 2\n\n[Software] 1\nname=dword:1\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(strings.NewReader(src)); err == nil {
+				t.Fatal("parsed without an error")
+			}
+		})
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	for path, want := range map[string][2]string{
+		`HKCU\Software\Wine`:                      {UserFile, `Software\Wine`},
+		`HKEY_LOCAL_MACHINE/Software/Wine/`:       {SystemFile, `Software\Wine`},
+		`hklm\System\CurrentControlSet\Services`:  {SystemFile, `System\CurrentControlSet\Services`},
+		`HKEY_CURRENT_USER\Control Panel\Desktop`: {UserFile, `Control Panel\Desktop`},
+	} {
+		file, name, err := SplitPath(path)
+		if err != nil || file != want[0] || name != want[1] {
+			t.Errorf("%s: got %s %s %v", path, file, name, err)
+		}
+	}
+	if _, _, err := SplitPath(`HKCR\.exe`); err == nil {
+		t.Error("HKCR was accepted")
+	}
+}
@@ -0,0 +1,176 @@
+// Package repo publishes directories of bundles as signed indexes and installs bundles
+// from the indexes of subscribed sources.
+package repo
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"yapl/internal/archive"
+)
+
+// IndexFile and SignatureFile are written into the published directory. The signature is
+// the base64 Ed25519 signature of the index file's bytes.
+const (
+	IndexFile     = "index.json"
+	SignatureFile = IndexFile + ".sig"
+)
+
+// KeyPath holds the private key indexes are signed with. It is created by the first
+// 'repo index' and must be kept to publish updates clients accept.
+const KeyPath = "state/repo.key"
+
+// Index lists the bundles of a repository.
+type Index struct {
+	Generated time.Time `json:"generated"`
+	Bundles   []Bundle  `json:"bundles"`
+}
+
+// Bundle is a packaged game or app in an index. URL may be relative to the index.
+type Bundle struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"` // "game" or "app"
+	Version string `json:"version,omitempty"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+	URL     string `json:"url"`
+}
+
+// Generate indexes the bundles in dir and below it, and writes the signed index into dir.
+// Bundles below an 'apps' directory are apps; the rest are games. It returns the index and
+// the public key clients verify it with.
+func Generate(dir string) (*Index, string, error) {
+	key, err := loadKey()
+	if err != nil {
+		return nil, "", err
+	}
+	index := &Index{Generated: time.Now().UTC().Truncate(time.Second), Bundles: []Bundle{}}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		name, ok := archive.TrimArchiveSuffix(info.Name())
+		if !ok || strings.HasSuffix(name, archive.PatchSuffix) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if _, i, split := archive.SplitVolume(info.Name()); split {
+			if i == 1 {
+				log.Printf("⚠️  Skipping '%s': split bundles cannot be installed from a URL.", rel)
+			}
+			return nil
+		}
+		fmt.Printf("-> Indexing '%s'...\n", rel)
+		b := Bundle{Name: name, Type: "game", Size: info.Size(), URL: filepath.ToSlash(rel)}
+		if strings.HasPrefix(filepath.ToSlash(rel), "apps/") || strings.Contains(filepath.ToSlash(rel), "/apps/") {
+			b.Type = "app"
+		}
+		if !strings.HasSuffix(info.Name(), archive.EncryptedSuffix) {
+			if m, err := archive.ReadManifest(path); err != nil {
+				log.Printf("⚠️  Could not read the manifest of '%s': %v", rel, err)
+			} else if m != nil {
+				b.Version = m.Version
+			}
+		}
+		if b.SHA256, err = hashFile(path); err != nil {
+			return err
+		}
+		index.Bundles = append(index.Bundles, b)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(index.Bundles, func(i, j int) bool { return index.Bundles[i].URL < index.Bundles[j].URL })
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+	data = append(data, '\n')
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)) + "\n"
+	if err := writeFile(filepath.Join(dir, IndexFile), data); err != nil {
+		return nil, "", err
+	}
+	if err := writeFile(filepath.Join(dir, SignatureFile), []byte(sig)); err != nil {
+		return nil, "", err
+	}
+	return index, publicKey(key), nil
+}
+
+// PublicKey returns the public key of the signing key, creating the key if needed.
+func PublicKey() (string, error) {
+	key, err := loadKey()
+	if err != nil {
+		return "", err
+	}
+	return publicKey(key), nil
+}
+
+func publicKey(key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+func loadKey() (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(KeyPath)
+	if os.IsNotExist(err) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(KeyPath), 0755); err != nil {
+			return nil, err
+		}
+		seed := base64.StdEncoding.EncodeToString(key.Seed()) + "\n"
+		if err := os.WriteFile(KeyPath, []byte(seed), 0600); err != nil {
+			return nil, err
+		}
+		fmt.Printf("-> Created the signing key %s; back it up, clients only accept indexes it signs.\n", KeyPath)
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s is not a valid signing key", KeyPath)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// verify checks the index data against its signature and a source's public key.
+func verify(data, sig []byte, key string) error {
+	pub, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return errors.New("the source's public_key is not a valid Ed25519 key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil || !ed25519.Verify(ed25519.PublicKey(pub), data, raw) {
+		return errors.New("the index signature does not match the source's public key")
+	}
+	return nil
+}
+
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,200 @@
+package repo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"yapl/internal/archive"
+	"yapl/internal/config"
+)
+
+// writeBundle packages a game directory with one file as name.tar.gz in dir.
+func writeBundle(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), name)
+	os.MkdirAll(src, 0755)
+	os.WriteFile(filepath.Join(src, "game.json"), []byte(content), 0644)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := archive.WriteTar(gz, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	gz.Close()
+	path := filepath.Join(dir, name+".tar.gz")
+	os.MkdirAll(dir, 0755)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// publish writes a repository with a game and an app and returns its directory and key.
+func publish(t *testing.T) (string, string) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	dir := "published"
+	writeBundle(t, dir, "Doom", `{"executable": "doom.exe"}`)
+	writeBundle(t, filepath.Join(dir, "apps"), "Editor", `{}`)
+	index, key, err := Generate(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Bundles) != 2 || index.Bundles[0].URL != "Doom.tar.gz" || index.Bundles[1].Type != "app" {
+		t.Fatalf("indexed %+v", index.Bundles)
+	}
+	return dir, key
+}
+
+func TestGenerateKeepsKey(t *testing.T) {
+	dir, key := publish(t)
+	if _, again, err := Generate(dir); err != nil || again != key {
+		t.Fatalf("the second index was signed with %s, %v, want %s", again, err, key)
+	}
+	if pub, err := PublicKey(); err != nil || pub != key {
+		t.Fatalf("PublicKey returned %s, %v", pub, err)
+	}
+	if info, err := os.Stat(KeyPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("the key file: %v, %v", info, err)
+	}
+
+	os.WriteFile(KeyPath, []byte("not a key\n"), 0600)
+	if _, _, err := Generate(dir); err == nil || !strings.Contains(err.Error(), "not a valid signing key") {
+		t.Fatalf("got %v for a broken key file", err)
+	}
+}
+
+func TestFetchVerifiesSignature(t *testing.T) {
+	dir, key := publish(t)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	indexPath := filepath.Join(dir, IndexFile)
+	original, _ := os.ReadFile(indexPath)
+	sig, _ := os.ReadFile(indexPath + ".sig")
+
+	tests := []struct {
+		name  string
+		key   string
+		index string // Replaces index.json unless empty
+		sig   string // Replaces index.json.sig unless empty; "-" removes it
+		want  string // Error, or empty for success
+	}{
+		{"valid", key, "", "", ""},
+		{"key with whitespace", " " + key + "\n", "", "", ""},
+		{"other key", publicKey(otherKey), "", "", "does not match the source's public key"},
+		{"key not base64", "not a key", "", "", "not a valid Ed25519 key"},
+		{"short key", base64.StdEncoding.EncodeToString([]byte("short")), "", "", "not a valid Ed25519 key"},
+		{"changed checksum", key, strings.Replace(string(original), `"sha256": "`, `"sha256": "0`, 1), "", "does not match"},
+		{"added whitespace", key, string(original) + "\n", "", "does not match"},
+		{"signed by another key", key, "", base64.StdEncoding.EncodeToString(ed25519.Sign(otherKey, original)), "does not match"},
+		{"signature not base64", key, "", "!!", "does not match"},
+		{"truncated signature", key, "", string(sig[:20]), "does not match"},
+		{"unsigned", key, "", "-", "the index is not signed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.WriteFile(indexPath, original, 0644)
+			os.WriteFile(indexPath+".sig", sig, 0644)
+			if tt.index != "" {
+				os.WriteFile(indexPath, []byte(tt.index), 0644)
+			}
+			switch tt.sig {
+			case "":
+			case "-":
+				os.Remove(indexPath + ".sig")
+			default:
+				os.WriteFile(indexPath+".sig", []byte(tt.sig), 0644)
+			}
+
+			index, location, err := Fetch(config.BundleSource{Name: "test", URL: dir, PublicKey: tt.key})
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if location != filepath.Join(dir, IndexFile) || len(index.Bundles) != 2 {
+					t.Fatalf("read %d bundles from %s", len(index.Bundles), location)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstallFromServer(t *testing.T) {
+	dir, key := publish(t)
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+	sources := []config.BundleSource{
+		{Name: "broken", URL: server.URL + "/missing", PublicKey: key},
+		{Name: "lan", URL: server.URL + "/", PublicKey: key},
+	}
+
+	b, source, err := Find(sources, "game", "Doom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != server.URL+"/Doom.tar.gz" {
+		t.Fatalf("resolved to %s", source)
+	}
+	if _, _, err := Find(sources, "game", "Editor"); err == nil {
+		t.Error("an app was found as a game")
+	}
+	if results := Search(sources, "EDIT"); len(results) != 1 || results[0].Source != "lan" || results[0].Name != "Editor" {
+		t.Errorf("search found %+v", results)
+	}
+
+	if err := Install(b, source, "games"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile("games/Doom/game.json"); string(data) != `{"executable": "doom.exe"}` {
+		t.Fatalf("installed game.json: %q", data)
+	}
+	if err := Install(b, source, "games"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("got %v installing over the game", err)
+	}
+
+	// A bundle that was replaced after the index was signed is not installed.
+	writeBundle(t, dir, "Doom", `{"executable": "evil.exe"}`)
+	if err := Install(b, source, "other"); err == nil || !strings.Contains(err.Error(), "does not match the index") {
+		t.Fatalf("got %v for a replaced bundle", err)
+	}
+	if entries, _ := os.ReadDir("other"); len(entries) != 0 {
+		t.Fatalf("the replaced bundle left %v behind", entries)
+	}
+}
+
+func TestInstallRejectsNames(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for _, name := range []string{"", "..", "../escape", "a/b", "/abs"} {
+		if err := Install(&Bundle{Name: name}, "x.tar.gz", "games"); err == nil || !strings.Contains(err.Error(), "invalid name") {
+			t.Errorf("%q: got %v", name, err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		location, ref, want string
+	}{
+		{"https://example.com/repo/index.json", "Doom.tar.gz", "https://example.com/repo/Doom.tar.gz"},
+		{"https://example.com/repo/index.json", "../other/Doom.tar.gz", "https://example.com/other/Doom.tar.gz"},
+		{"https://example.com/repo/index.json", "http://mirror/Doom.tar.gz", "http://mirror/Doom.tar.gz"},
+		{"/mnt/nas/repo/index.json", "apps/Editor.tar.gz", "/mnt/nas/repo/apps/Editor.tar.gz"},
+		{"/mnt/nas/repo/index.json", "/srv/Doom.tar.gz", "/srv/Doom.tar.gz"},
+	}
+	for _, tt := range tests {
+		if got := resolve(tt.location, tt.ref); got != tt.want {
+			t.Errorf("resolve(%s, %s) = %s, want %s", tt.location, tt.ref, got, tt.want)
+		}
+	}
+}
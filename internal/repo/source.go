@@ -0,0 +1,162 @@
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"yapl/internal/archive"
+	"yapl/internal/config"
+)
+
+// Fetch downloads a source's index and checks its signature. It returns the index and
+// where it was read from, which relative bundle URLs are resolved against.
+func Fetch(src config.BundleSource) (*Index, string, error) {
+	location := src.URL
+	if !strings.HasSuffix(location, ".json") {
+		location = strings.TrimSuffix(location, "/") + "/" + IndexFile
+	}
+	data, err := read(location)
+	if err != nil {
+		return nil, "", err
+	}
+	sig, err := read(location + ".sig")
+	if err != nil {
+		return nil, "", fmt.Errorf("the index is not signed: %w", err)
+	}
+	if err := verify(data, sig, src.PublicKey); err != nil {
+		return nil, "", err
+	}
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, "", fmt.Errorf("unreadable index: %w", err)
+	}
+	return &index, location, nil
+}
+
+// Find looks for a bundle in the sources' indexes, in order, and returns it with its
+// resolved URL. Sources that cannot be read are skipped with a warning. An empty typ
+// matches games and apps.
+func Find(sources []config.BundleSource, typ, name string) (*Bundle, string, error) {
+	for _, src := range sources {
+		index, location, err := Fetch(src)
+		if err != nil {
+			log.Printf("⚠️  Skipping source '%s': %v", src.Name, err)
+			continue
+		}
+		for _, b := range index.Bundles {
+			if b.Name == name && (b.Type == "game" || b.Type == "app") && (typ == "" || b.Type == typ) {
+				fmt.Printf("-> Found '%s' in source '%s'.\n", name, src.Name)
+				return &b, resolve(location, b.URL), nil
+			}
+		}
+	}
+	return nil, "", fmt.Errorf("'%s' was not found in any bundle source", name)
+}
+
+// Result is a bundle found by Search.
+type Result struct {
+	Source string
+	Bundle
+}
+
+// Search returns the bundles of every source whose name contains term, ignoring case.
+// Sources that cannot be read are skipped with a warning.
+func Search(sources []config.BundleSource, term string) []Result {
+	term = strings.ToLower(term)
+	var results []Result
+	for _, src := range sources {
+		index, _, err := Fetch(src)
+		if err != nil {
+			log.Printf("⚠️  Skipping source '%s': %v", src.Name, err)
+			continue
+		}
+		for _, b := range index.Bundles {
+			if strings.Contains(strings.ToLower(b.Name), term) {
+				results = append(results, Result{Source: src.Name, Bundle: b})
+			}
+		}
+	}
+	return results
+}
+
+// Install extracts a bundle from source into targetDir and checks it against the index's
+// checksum. It is assembled under a temporary name, so a failed or tampered download never
+// looks installed.
+func Install(b *Bundle, source, targetDir string) error {
+	if b.Name == "" || filepath.Base(b.Name) != b.Name || !filepath.IsLocal(b.Name) {
+		return fmt.Errorf("the index lists an invalid name '%s'", b.Name)
+	}
+	dest := filepath.Join(targetDir, b.Name)
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("'%s' already exists", dest)
+	}
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+	partial := dest + ".partial"
+	if err := os.RemoveAll(partial); err != nil {
+		return err
+	}
+	ar := &archive.Archive{Source: source}
+	if err := ar.Extract(partial, true); err != nil {
+		os.RemoveAll(partial)
+		return err
+	}
+	if ar.SHA256 != b.SHA256 {
+		os.RemoveAll(partial)
+		return fmt.Errorf("the bundle's checksum %s does not match the index (%s)", ar.SHA256, b.SHA256)
+	}
+	return os.Rename(partial, dest)
+}
+
+// resolve returns a bundle URL relative to the index as an absolute URL or path.
+func resolve(location, ref string) string {
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") || filepath.IsAbs(ref) {
+		return ref
+	}
+	if base, err := url.Parse(location); err == nil && (base.Scheme == "http" || base.Scheme == "https") {
+		if u, err := url.Parse(ref); err == nil {
+			return base.ResolveReference(u).String()
+		}
+	}
+	return filepath.Join(filepath.Dir(location), filepath.FromSlash(ref))
+}
+
+func read(location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return os.ReadFile(location)
+	}
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download failed: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
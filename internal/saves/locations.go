@@ -0,0 +1,155 @@
+package saves
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DatabasePath is the local save location database. Its entries are added to the
+// built-in ones, replacing those of the same app.
+const DatabasePath = "state/save-locations.json"
+
+// Location is a save location in the form Steam Cloud's auto-cloud settings use: a root
+// folder and a path below it, which may contain glob patterns.
+type Location struct {
+	Root string `json:"root"`
+	Path string `json:"path"`
+}
+
+// Entry lists a game's save locations.
+type Entry struct {
+	Name      string     `json:"name,omitempty"`
+	Locations []Location `json:"locations"`
+}
+
+// roots maps the Windows roots of Steam Cloud to their place in a Proton prefix.
+var roots = map[string]string{
+	"WinMyDocuments":     "drive_c/users/steamuser/Documents",
+	"WinAppDataLocal":    "drive_c/users/steamuser/AppData/Local",
+	"WinAppDataLocalLow": "drive_c/users/steamuser/AppData/LocalLow",
+	"WinAppDataRoaming":  "drive_c/users/steamuser/AppData/Roaming",
+	"WinSavedGames":      "drive_c/users/steamuser/Saved Games",
+	"WinProgramData":     "drive_c/ProgramData",
+	"WinUserProfile":     "drive_c/users/steamuser", // Not a Steam Cloud root; for ludusavi's <home>
+}
+
+// steamTokens are the per-account placeholders Steam Cloud paths may contain. Every
+// account's saves are backed up.
+var steamTokens = regexp.MustCompile(`\{(64BitSteamID|Steam3AccountID|SteamID)\}`)
+
+//go:embed locations.json
+var builtin []byte
+
+// LoadDatabase returns the built-in save locations merged with the local database, by
+// Steam AppID.
+func LoadDatabase() (map[string]Entry, error) {
+	db, err := parseDatabase(builtin)
+	if err != nil {
+		return nil, fmt.Errorf("built-in save locations: %w", err)
+	}
+	data, err := os.ReadFile(DatabasePath)
+	if os.IsNotExist(err) {
+		return db, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	local, err := parseDatabase(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", DatabasePath, err)
+	}
+	for id, entry := range local {
+		db[id] = entry
+	}
+	return db, nil
+}
+
+func parseDatabase(data []byte) (map[string]Entry, error) {
+	var db map[string]Entry
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, err
+	}
+	for id, entry := range db {
+		for _, loc := range entry.Locations {
+			if _, ok := roots[loc.Root]; !ok {
+				return nil, fmt.Errorf("app %s: unsupported root '%s'", id, loc.Root)
+			}
+			if clean := path.Clean("/" + loc.Path); loc.Path == "" || strings.Contains(loc.Path, "..") || clean == "/" {
+				return nil, fmt.Errorf("app %s: invalid path '%s'", id, loc.Path)
+			}
+		}
+	}
+	return db, nil
+}
+
+// Lookup returns the save paths, relative to the prefix, that the database knows for a
+// Steam AppID, and the game's name.
+func Lookup(appID string) ([]string, string, error) {
+	db, err := LoadDatabase()
+	if err != nil {
+		return nil, "", err
+	}
+	entry, ok := db[appID]
+	if !ok {
+		return nil, "", nil
+	}
+	paths := make([]string, 0, len(entry.Locations))
+	for _, loc := range entry.Locations {
+		rel := steamTokens.ReplaceAllString(strings.Trim(loc.Path, "/"), "*")
+		paths = append(paths, roots[loc.Root]+"/"+rel)
+	}
+	return paths, entry.Name, nil
+}
+
+// UpdateDatabase replaces the local database with the one at source, a URL or a file,
+// after checking that it parses. Source may also be ludusavi's manifest (YAML), whose
+// Windows save paths of Steam games are converted. It returns how many games it lists.
+func UpdateDatabase(source string) (int, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		client := &http.Client{Timeout: 60 * time.Second}
+		resp, err := client.Get(source)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return 0, fmt.Errorf("download failed: %s", resp.Status)
+		}
+		if data, err = io.ReadAll(io.LimitReader(resp.Body, 64<<20)); err != nil {
+			return 0, err
+		}
+	} else if data, err = os.ReadFile(source); err != nil {
+		return 0, err
+	}
+
+	parse := parseDatabase
+	if !json.Valid(data) {
+		parse = parseLudusavi
+	}
+	db, err := parse(data)
+	if err != nil {
+		return 0, err
+	}
+	if data, err = json.MarshalIndent(db, "", "  "); err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(DatabasePath), 0755); err != nil {
+		return 0, err
+	}
+	tmp := DatabasePath + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return 0, err
+	}
+	return len(db), os.Rename(tmp, DatabasePath)
+}
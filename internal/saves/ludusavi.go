@@ -0,0 +1,265 @@
+package saves
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ludusaviRoots maps the placeholders that start the paths of ludusavi's manifest
+// (https://github.com/mtkennerly/ludusavi-manifest) to save location roots. Paths below
+// the install directory (<base>, <root>, <game>) or outside the prefix are not used.
+var ludusaviRoots = map[string]string{
+	"<winAppData>":         "WinAppDataRoaming",
+	"<winLocalAppData>":    "WinAppDataLocal",
+	"<winLocalAppDataLow>": "WinAppDataLocalLow",
+	"<winDocuments>":       "WinMyDocuments",
+	"<winProgramData>":     "WinProgramData",
+	"<home>":               "WinUserProfile",
+}
+
+// parseLudusavi converts ludusavi's manifest into a save location database, keeping the
+// Windows save files of the games that have a Steam AppID.
+func parseLudusavi(data []byte) (map[string]Entry, error) {
+	doc, err := parseYAML(string(data))
+	if err != nil {
+		return nil, err
+	}
+	games, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not a ludusavi manifest")
+	}
+	db := map[string]Entry{}
+	for name, v := range games {
+		game, _ := v.(map[string]interface{})
+		steam, _ := game["steam"].(map[string]interface{})
+		id, _ := steam["id"].(string)
+		files, _ := game["files"].(map[string]interface{})
+		if id == "" || len(files) == 0 {
+			continue
+		}
+		var locations []Location
+		for p, v := range files {
+			spec, _ := v.(map[string]interface{})
+			if !ludusaviSave(spec) {
+				continue
+			}
+			if loc, ok := ludusaviLocation(p); ok {
+				locations = append(locations, loc)
+			}
+		}
+		if len(locations) > 0 {
+			slices.SortFunc(locations, func(a, b Location) int { return strings.Compare(a.Root+a.Path, b.Root+b.Path) })
+			db[id] = Entry{Name: name, Locations: locations}
+		}
+	}
+	return db, nil
+}
+
+// ludusaviSave reports whether a file entry holds saves on Windows builds of the game.
+func ludusaviSave(spec map[string]interface{}) bool {
+	if tags, ok := spec["tags"].([]interface{}); ok && !slices.Contains(tags, interface{}("save")) {
+		return false
+	}
+	when, _ := spec["when"].([]interface{})
+	if len(when) == 0 {
+		return true
+	}
+	for _, c := range when {
+		cond, _ := c.(map[string]interface{})
+		os, _ := cond["os"].(string)
+		store, _ := cond["store"].(string)
+		if (os == "" || os == "windows") && (store == "" || store == "steam") {
+			return true
+		}
+	}
+	return false
+}
+
+func ludusaviLocation(p string) (Location, bool) {
+	placeholder, rest, _ := strings.Cut(p, "/")
+	root, ok := ludusaviRoots[placeholder]
+	if !ok || rest == "" {
+		return Location{}, false
+	}
+	rest = strings.ReplaceAll(rest, "<storeUserId>", "{SteamID}")
+	rest = strings.ReplaceAll(rest, "<osUserName>", "steamuser")
+	if strings.ContainsAny(rest, "<>") || strings.Contains(rest, "..") {
+		return Location{}, false // Other placeholders, e.g. <storeGameId>
+	}
+	return Location{Root: root, Path: rest}, true
+}
+
+// parseYAML reads the block mappings, block sequences and plain, quoted and empty flow
+// scalars ludusavi's manifest is written in. Scalars are returned as strings.
+func parseYAML(text string) (interface{}, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(text, "\n") {
+		trimmed := strings.TrimLeft(raw, " ")
+		content := strings.TrimRight(trimmed, " \r\t")
+		if content == "" || strings.HasPrefix(content, "#") || content == "---" {
+			continue
+		}
+		lines = append(lines, yamlLine{number: i + 1, indent: len(raw) - len(trimmed), text: content})
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	v, next, err := parseYAMLBlock(lines, 0, lines[0].indent)
+	if err == nil && next < len(lines) {
+		err = fmt.Errorf("line %d: unexpected indentation", lines[next].number)
+	}
+	return v, err
+}
+
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func parseYAMLBlock(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	if isSequenceItem(lines[i].text) {
+		return parseYAMLSequence(lines, i, indent)
+	}
+	return parseYAMLMapping(lines, i, indent)
+}
+
+func parseYAMLSequence(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	var items []interface{}
+	for i < len(lines) && lines[i].indent == indent && isSequenceItem(lines[i].text) {
+		rest := strings.TrimLeft(strings.TrimPrefix(lines[i].text, "-"), " ")
+		switch {
+		case rest == "":
+			if i+1 < len(lines) && lines[i+1].indent > indent {
+				item, next, err := parseYAMLBlock(lines, i+1, lines[i+1].indent)
+				if err != nil {
+					return nil, 0, err
+				}
+				items, i = append(items, item), next
+				continue
+			}
+			items, i = append(items, nil), i+1
+		case isSequenceItem(rest) || yamlKey(rest) != "":
+			// The item is a collection starting on the dash's line; the rest of it is
+			// indented to where its first entry starts.
+			lines[i] = yamlLine{number: lines[i].number, indent: indent + len(lines[i].text) - len(rest), text: rest}
+			item, next, err := parseYAMLBlock(lines, i, lines[i].indent)
+			if err != nil {
+				return nil, 0, err
+			}
+			items, i = append(items, item), next
+		default:
+			scalar, err := yamlScalar(rest, lines[i].number)
+			if err != nil {
+				return nil, 0, err
+			}
+			items, i = append(items, scalar), i+1
+		}
+	}
+	return items, i, nil
+}
+
+func parseYAMLMapping(lines []yamlLine, i, indent int) (interface{}, int, error) {
+	m := map[string]interface{}{}
+	for i < len(lines) && lines[i].indent == indent && !isSequenceItem(lines[i].text) {
+		line := lines[i]
+		raw := yamlKey(line.text)
+		if raw == "" {
+			return nil, 0, fmt.Errorf("line %d: expected 'key: value'", line.number)
+		}
+		key, err := yamlScalar(raw, line.number)
+		if err != nil {
+			return nil, 0, err
+		}
+		value := strings.TrimSpace(line.text[len(raw)+1:])
+		i++
+		switch {
+		case value != "":
+			if m[key.(string)], err = yamlScalar(value, line.number); err != nil {
+				return nil, 0, err
+			}
+		case i < len(lines) && (lines[i].indent > indent || lines[i].indent == indent && isSequenceItem(lines[i].text)):
+			if m[key.(string)], i, err = parseYAMLBlock(lines, i, lines[i].indent); err != nil {
+				return nil, 0, err
+			}
+		default:
+			m[key.(string)] = nil
+		}
+	}
+	return m, i, nil
+}
+
+// yamlKey returns the key of a 'key: value' line as written, or "" if the line is not one.
+func yamlKey(text string) string {
+	end := 0
+	if text[0] == '"' || text[0] == '\'' {
+		end = 1
+		for end < len(text) {
+			if text[end] == '\\' && text[0] == '"' {
+				end += 2
+				continue
+			}
+			if text[end] == text[0] {
+				if text[0] == '\'' && end+1 < len(text) && text[end+1] == '\'' {
+					end += 2
+					continue
+				}
+				break
+			}
+			end++
+		}
+		end++
+		if end > len(text) {
+			return ""
+		}
+	} else {
+		end = strings.Index(text, ": ")
+		if end < 0 {
+			end = len(text) - 1
+		}
+	}
+	if end >= len(text) || text[end] != ':' || (end+1 < len(text) && text[end+1] != ' ') {
+		return ""
+	}
+	return text[:end]
+}
+
+func yamlScalar(s string, number int) (interface{}, error) {
+	switch {
+	case s == "{}":
+		return map[string]interface{}{}, nil
+	case s == "[]":
+		return []interface{}{}, nil
+	case strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]"):
+		var items []interface{}
+		for _, item := range strings.Split(s[1:len(s)-1], ",") {
+			v, err := yamlScalar(strings.TrimSpace(item), number)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case strings.HasPrefix(s, "\""):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("line %d: unterminated string", number)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s, nil
+}
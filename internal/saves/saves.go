@@ -0,0 +1,83 @@
+package saves
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"yapl/internal/archive"
+	"yapl/internal/fs"
+)
+
+const backupFormat = "gz"
+
+// ErrNoSavePaths is returned by Backup when a game has no save paths.
+var ErrNoSavePaths = errors.New("no 'save_paths' configured in game.json")
+
+// Backup writes the configured save paths of a prefix into a timestamped tarball in backupDir.
+func Backup(prefixPath, backupDir, name string, savePaths []string) (string, error) {
+	if len(savePaths) == 0 {
+		return "", ErrNoSavePaths
+	}
+	if err := fs.MustCreateDirectory(backupDir); err != nil {
+		return "", err
+	}
+
+	bundleName := filepath.Join(backupDir, fmt.Sprintf("%s-%s.tar.gz", name, time.Now().Format("20060102-150405")))
+	fmt.Printf("-> Backing up saves to '%s'...\n", bundleName)
+	added, err := archive.PackagePaths(bundleName, prefixPath, savePaths, backupFormat)
+	if err != nil {
+		os.Remove(bundleName)
+		return "", fmt.Errorf("failed to back up saves: %w", err)
+	}
+	if added == 0 {
+		os.Remove(bundleName)
+		return "", errors.New("none of the configured save paths exist in the prefix")
+	}
+	return bundleName, nil
+}
+
+// Restore extracts a save backup over the prefix. An empty archivePath restores the latest backup.
+func Restore(prefixPath, backupDir, archivePath string) (string, error) {
+	if archivePath == "" {
+		backups, err := List(backupDir)
+		if err != nil {
+			return "", err
+		}
+		if len(backups) == 0 {
+			return "", fmt.Errorf("no save backups found in '%s'", backupDir)
+		}
+		archivePath = backups[len(backups)-1]
+	}
+
+	fmt.Printf("-> Restoring saves from '%s'...\n", archivePath)
+	ar := &archive.Archive{Source: archivePath}
+	if err := ar.Extract(fs.MustGetAbsolutePath(prefixPath), false); err != nil {
+		return "", fmt.Errorf("failed to restore saves: %w", err)
+	}
+	return archivePath, nil
+}
+
+// List returns the save backups in backupDir, oldest first.
+func List(backupDir string) ([]string, error) {
+	entries, err := os.ReadDir(backupDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read backup directory: %w", err)
+	}
+	var backups []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".tar.gz") {
+			backups = append(backups, filepath.Join(backupDir, entry.Name()))
+		}
+	}
+	// Timestamps in the file names make lexical order chronological.
+	sort.Strings(backups)
+	return backups, nil
+}
@@ -0,0 +1,148 @@
+// Package sbom describes the software a game bundle runs on as a CycloneDX software bill
+// of materials.
+package sbom
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"yapl/internal/config"
+	"yapl/internal/dependency"
+)
+
+// FileName is where the SBOM is stored inside the game or app directory.
+const FileName = "sbom.cdx.json"
+
+// BOM is the subset of the CycloneDX 1.5 JSON format yapl writes.
+type BOM struct {
+	BOMFormat   string      `json:"bomFormat"`
+	SpecVersion string      `json:"specVersion"`
+	Version     int         `json:"version"`
+	Metadata    Metadata    `json:"metadata"`
+	Components  []Component `json:"components"`
+}
+
+type Metadata struct {
+	Timestamp string    `json:"timestamp"`
+	Tools     Tools     `json:"tools"`
+	Component Component `json:"component"`
+}
+
+type Tools struct {
+	Components []Component `json:"components"`
+}
+
+type Component struct {
+	Type               string              `json:"type"`
+	Name               string              `json:"name"`
+	Version            string              `json:"version,omitempty"`
+	Hashes             []Hash              `json:"hashes,omitempty"`
+	ExternalReferences []ExternalReference `json:"externalReferences,omitempty"`
+	Properties         []Property          `json:"properties,omitempty"`
+}
+
+type Hash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type ExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type Property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Generate builds the SBOM of a game or app: yapl itself, and the Proton build, runtime
+// and dependencies its config uses. Hashes, download URLs and license files come from the
+// provenance yapl recorded when it installed each component.
+func Generate(name string, appCfg config.App, globalCfg config.Global, timestamp time.Time) ([]byte, error) {
+	yapl := Component{Type: "application", Name: "yapl", Version: YaplVersion()}
+	bom := BOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata: Metadata{
+			Timestamp: timestamp.Format(time.RFC3339),
+			Tools:     Tools{Components: []Component{yapl}},
+			Component: Component{Type: "application", Name: name},
+		},
+		Components: []Component{yapl},
+	}
+
+	if appCfg.ProtonVersion != "" {
+		dir := filepath.Join("proton", appCfg.ProtonVersion)
+		if vinfo := globalCfg.ProtonVersions[appCfg.ProtonVersion]; vinfo.Path != "" {
+			dir = vinfo.Path
+		}
+		bom.Components = append(bom.Components, component("framework", "proton", appCfg.ProtonVersion, dir))
+	}
+	if appCfg.RuntimeVersion != "" && appCfg.LaunchMethod == "container" {
+		runtimeDir := config.RuntimeDir(appCfg)
+		if resolved, err := filepath.EvalSymlinks(runtimeDir); err == nil {
+			runtimeDir = resolved
+		}
+		c := component("platform", "steam-linux-runtime-"+appCfg.RuntimeVersion, appCfg.RuntimeVersion, runtimeDir)
+		if p, ok := dependency.ReadProvenance(runtimeDir); ok {
+			c.Version = p.Version // The BUILD_ID
+		}
+		bom.Components = append(bom.Components, c)
+	}
+	deps := []struct{ name, version, kind string }{
+		{"dxvk", appCfg.Dependencies.DXVKVersion, "library"},
+		{"vkd3d", appCfg.Dependencies.VKD3DVersion, "library"},
+	}
+	if appCfg.LaunchMethod == "umu" && !appCfg.UMUOptions.UseSystemBinary {
+		deps = append(deps, struct{ name, version, kind string }{"umu-launcher", appCfg.UMUOptions.Version, "application"})
+	}
+	for _, d := range deps {
+		if d.version != "" {
+			bom.Components = append(bom.Components, component(d.kind, d.name, d.version, filepath.Join("dependencies", d.name, d.version)))
+		}
+	}
+	return json.MarshalIndent(bom, "", "  ")
+}
+
+// component describes an installed component, with whatever provenance it has.
+func component(kind, name, version, dir string) Component {
+	c := Component{Type: kind, Name: name, Version: version}
+	p, ok := dependency.ReadProvenance(dir)
+	if !ok {
+		return c
+	}
+	if p.SHA256 != "" {
+		c.Hashes = []Hash{{Alg: "SHA-256", Content: p.SHA256}}
+	}
+	if p.Source != "" {
+		c.ExternalReferences = append(c.ExternalReferences, ExternalReference{Type: "distribution", URL: p.Source})
+	}
+	if p.Project != "" {
+		c.ExternalReferences = append(c.ExternalReferences, ExternalReference{Type: "website", URL: p.Project})
+	}
+	for _, l := range p.Licenses {
+		c.Properties = append(c.Properties, Property{Name: "yapl:license_file", Value: l})
+	}
+	return c
+}
+
+// YaplVersion is the module version, or the VCS revision for development builds.
+func YaplVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,637 @@
+// Package script runs the small hook scripts users can drop into a game's hooks/
+// directory to adjust a launch without writing a plugin, e.g.:
+//
+//	# hooks/pre-run.yapl
+//	if on_battery {
+//	    append launch_args "-lowpower"
+//	    set env.DXVK_FRAME_RATE = "30"
+//	} else if hostname == "desktop" {
+//	    set gpu = "discrete"
+//	}
+//
+// A script is a list of statements: set, append, if/else, print and fail. Expressions
+// have strings, numbers, booleans and lists, the operators == != < <= > >= && || ! +
+// and the functions contains, exists, lower and upper.
+package script
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Env is what a script can read and change. Scripts only see the variables it defines,
+// plus env.NAME for environment variables.
+type Env struct {
+	Vars map[string]Value
+	// Settable lists the variables a script may assign to (in addition to env.*).
+	Settable map[string]bool
+	// Environment receives env.NAME assignments; reads fall back to the process environment.
+	Environment map[string]string
+	Output      func(string)
+}
+
+// Value is a string, float64, bool or []string.
+type Value interface{}
+
+// ErrFailed is returned (wrapped) when a script stops the launch with 'fail'.
+var ErrFailed = errors.New("script failed")
+
+// Run parses and runs a script.
+func Run(name, source string, env *Env) error {
+	toks, err := lex(source)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	p := &parser{toks: toks}
+	stmts, err := p.block(false)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if err := execute(stmts, env); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// --- Lexer ---
+
+type tokKind int
+
+const (
+	tEOF tokKind = iota
+	tNewline
+	tIdent
+	tString
+	tNumber
+	tOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	line int
+}
+
+var operators = strings.Fields("== != <= >= && || < > ! + ( ) , { } = [ ]")
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '\n':
+			toks = append(toks, token{tNewline, "\n", line})
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == ';':
+			if c == ';' {
+				toks = append(toks, token{tNewline, ";", line})
+			}
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				if j < len(src) && src[j] == '\n' {
+					return nil, fmt.Errorf("line %d: unterminated string", line)
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("line %d: unterminated string", line)
+			}
+			s, err := strconv.Unquote(src[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid string %s", line, src[i:j+1])
+			}
+			toks = append(toks, token{tString, s, line})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{tNumber, src[i:j], line})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			toks = append(toks, token{tIdent, src[i:j], line})
+			i = j
+		default:
+			op := string(c)
+			if i+1 < len(src) {
+				switch two := src[i : i+2]; two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if !slices.Contains(operators, op) {
+				return nil, fmt.Errorf("line %d: unexpected character %q", line, c)
+			}
+			toks = append(toks, token{tOp, op, line})
+			i += len(op)
+		}
+	}
+	return append(toks, token{tEOF, "", line}), nil
+}
+
+// --- Parser ---
+
+type stmt struct {
+	kind   string // "set", "append", "if", "print", "fail"
+	line   int
+	target string
+	expr   expr
+	then   []stmt
+	els    []stmt
+}
+
+type expr interface{}
+
+type (
+	literal  struct{ v Value }
+	variable struct {
+		name string
+		line int
+	}
+	unary struct {
+		op string
+		x  expr
+	}
+	binary struct {
+		op   string
+		x, y expr
+		line int
+	}
+	call struct {
+		fn   string
+		args []expr
+		line int
+	}
+	list struct{ items []expr }
+)
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(text string) error {
+	if t := p.next(); t.text != text || (t.kind != tOp && t.kind != tIdent) {
+		return fmt.Errorf("line %d: expected '%s', found '%s'", t.line, text, t.text)
+	}
+	return nil
+}
+
+func (p *parser) skipNewlines() {
+	for p.peek().kind == tNewline {
+		p.next()
+	}
+}
+
+// block parses statements up to the end of the script, or up to '}' when nested.
+func (p *parser) block(nested bool) ([]stmt, error) {
+	var stmts []stmt
+	for {
+		p.skipNewlines()
+		t := p.peek()
+		if t.kind == tEOF {
+			if nested {
+				return nil, fmt.Errorf("line %d: missing '}'", t.line)
+			}
+			return stmts, nil
+		}
+		if nested && t.kind == tOp && t.text == "}" {
+			p.next()
+			return stmts, nil
+		}
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s)
+	}
+}
+
+func (p *parser) statement() (stmt, error) {
+	t := p.next()
+	if t.kind != tIdent {
+		return stmt{}, fmt.Errorf("line %d: expected a statement, found '%s'", t.line, t.text)
+	}
+	s := stmt{kind: t.text, line: t.line}
+	var err error
+	switch t.text {
+	case "set":
+		target := p.next()
+		if target.kind != tIdent {
+			return s, fmt.Errorf("line %d: expected a variable after 'set'", target.line)
+		}
+		s.target = target.text
+		if err := p.expect("="); err != nil {
+			return s, err
+		}
+		s.expr, err = p.expression()
+	case "append":
+		target := p.next()
+		if target.kind != tIdent {
+			return s, fmt.Errorf("line %d: expected a variable after 'append'", target.line)
+		}
+		s.target = target.text
+		s.expr, err = p.expression()
+	case "print", "fail":
+		s.expr, err = p.expression()
+	case "if":
+		if s.expr, err = p.expression(); err != nil {
+			return s, err
+		}
+		if err := p.expect("{"); err != nil {
+			return s, err
+		}
+		if s.then, err = p.block(true); err != nil {
+			return s, err
+		}
+		if p.peek().kind == tIdent && p.peek().text == "else" {
+			p.next()
+			if p.peek().kind == tIdent && p.peek().text == "if" {
+				elseIf, err := p.statement()
+				if err != nil {
+					return s, err
+				}
+				s.els = []stmt{elseIf}
+				return s, nil
+			}
+			if err := p.expect("{"); err != nil {
+				return s, err
+			}
+			s.els, err = p.block(true)
+		}
+		return s, err
+	default:
+		return s, fmt.Errorf("line %d: unknown statement '%s'", t.line, t.text)
+	}
+	if err != nil {
+		return s, err
+	}
+	if end := p.peek(); end.kind != tNewline && end.kind != tEOF && !(end.kind == tOp && end.text == "}") {
+		return s, fmt.Errorf("line %d: unexpected '%s'", end.line, end.text)
+	}
+	return s, nil
+}
+
+var precedence = map[string]int{
+	"||": 1, "&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4,
+}
+
+func (p *parser) expression() (expr, error) { return p.binary(1) }
+
+func (p *parser) binary(minPrec int) (expr, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		prec, ok := precedence[t.text]
+		if t.kind != tOp || !ok || prec < minPrec {
+			return x, nil
+		}
+		p.next()
+		y, err := p.binary(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = binary{op: t.text, x: x, y: y, line: t.line}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	if t := p.peek(); t.kind == tOp && t.text == "!" {
+		p.next()
+		x, err := p.unary()
+		return unary{op: "!", x: x}, err
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tString:
+		return literal{t.text}, nil
+	case tNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid number '%s'", t.line, t.text)
+		}
+		return literal{n}, nil
+	case tIdent:
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		}
+		if p.peek().kind == tOp && p.peek().text == "(" {
+			p.next()
+			c := call{fn: t.text, line: t.line}
+			for !(p.peek().kind == tOp && p.peek().text == ")") {
+				arg, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+				c.args = append(c.args, arg)
+				if p.peek().kind == tOp && p.peek().text == "," {
+					p.next()
+				} else if !(p.peek().kind == tOp && p.peek().text == ")") {
+					return nil, fmt.Errorf("line %d: expected ',' or ')'", p.peek().line)
+				}
+			}
+			p.next()
+			return c, nil
+		}
+		return variable{name: t.text, line: t.line}, nil
+	case tOp:
+		switch t.text {
+		case "(":
+			x, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+		case "[":
+			var l list
+			for !(p.peek().kind == tOp && p.peek().text == "]") {
+				item, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+				l.items = append(l.items, item)
+				if p.peek().kind == tOp && p.peek().text == "," {
+					p.next()
+				} else if !(p.peek().kind == tOp && p.peek().text == "]") {
+					return nil, fmt.Errorf("line %d: expected ',' or ']'", p.peek().line)
+				}
+			}
+			p.next()
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("line %d: unexpected '%s'", t.line, t.text)
+}
+
+// --- Evaluation ---
+
+func execute(stmts []stmt, env *Env) error {
+	for _, s := range stmts {
+		switch s.kind {
+		case "if":
+			cond, err := eval(s.expr, env)
+			if err != nil {
+				return err
+			}
+			branch := s.els
+			if truthy(cond) {
+				branch = s.then
+			}
+			if err := execute(branch, env); err != nil {
+				return err
+			}
+			continue
+		}
+
+		v, err := eval(s.expr, env)
+		if err != nil {
+			return err
+		}
+		switch s.kind {
+		case "print":
+			if env.Output != nil {
+				env.Output(toString(v))
+			}
+		case "fail":
+			return fmt.Errorf("%w: %s", ErrFailed, toString(v))
+		case "set":
+			if err := assign(env, s.target, v, s.line); err != nil {
+				return err
+			}
+		case "append":
+			current, ok := env.Vars[s.target].([]string)
+			if !ok || !env.Settable[s.target] {
+				return fmt.Errorf("line %d: cannot append to '%s'", s.line, s.target)
+			}
+			current = append(append([]string(nil), current...), toList(v)...)
+			env.Vars[s.target] = current
+		}
+	}
+	return nil
+}
+
+func assign(env *Env, target string, v Value, line int) error {
+	if name, ok := strings.CutPrefix(target, "env."); ok && name != "" {
+		env.Environment[name] = toString(v)
+		return nil
+	}
+	if !env.Settable[target] {
+		return fmt.Errorf("line %d: '%s' cannot be set", line, target)
+	}
+	switch env.Vars[target].(type) {
+	case []string:
+		env.Vars[target] = toList(v)
+	case bool:
+		env.Vars[target] = truthy(v)
+	default:
+		env.Vars[target] = toString(v)
+	}
+	return nil
+}
+
+func eval(e expr, env *Env) (Value, error) {
+	switch e := e.(type) {
+	case literal:
+		return e.v, nil
+	case list:
+		var items []string
+		for _, item := range e.items {
+			v, err := eval(item, env)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, toList(v)...)
+		}
+		return items, nil
+	case variable:
+		if name, ok := strings.CutPrefix(e.name, "env."); ok {
+			if v, ok := env.Environment[name]; ok {
+				return v, nil
+			}
+			return os.Getenv(name), nil
+		}
+		v, ok := env.Vars[e.name]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown variable '%s'", e.line, e.name)
+		}
+		return v, nil
+	case unary:
+		x, err := eval(e.x, env)
+		return !truthy(x), err
+	case binary:
+		x, err := eval(e.x, env)
+		if err != nil {
+			return nil, err
+		}
+		// && and || only evaluate their right side when needed.
+		if e.op == "&&" && !truthy(x) || e.op == "||" && truthy(x) {
+			return truthy(x), nil
+		}
+		y, err := eval(e.y, env)
+		if err != nil {
+			return nil, err
+		}
+		return binaryOp(e.op, x, y, e.line)
+	case call:
+		var args []Value
+		for _, a := range e.args {
+			v, err := eval(a, env)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, v)
+		}
+		return callFunction(e.fn, args, e.line)
+	}
+	return nil, fmt.Errorf("invalid expression")
+}
+
+func binaryOp(op string, x, y Value, line int) (Value, error) {
+	switch op {
+	case "&&", "||":
+		return truthy(y), nil
+	case "==":
+		return toString(x) == toString(y), nil
+	case "!=":
+		return toString(x) != toString(y), nil
+	case "+":
+		if xs, ok := x.([]string); ok {
+			return append(append([]string(nil), xs...), toList(y)...), nil
+		}
+		xn, xok := x.(float64)
+		yn, yok := y.(float64)
+		if xok && yok {
+			return xn + yn, nil
+		}
+		return toString(x) + toString(y), nil
+	}
+	xn, xerr := toNumber(x)
+	yn, yerr := toNumber(y)
+	if xerr != nil || yerr != nil {
+		return nil, fmt.Errorf("line %d: '%s' needs numbers", line, op)
+	}
+	switch op {
+	case "<":
+		return xn < yn, nil
+	case "<=":
+		return xn <= yn, nil
+	case ">":
+		return xn > yn, nil
+	default:
+		return xn >= yn, nil
+	}
+}
+
+func callFunction(fn string, args []Value, line int) (Value, error) {
+	want := map[string]int{"contains": 2, "exists": 1, "lower": 1, "upper": 1}
+	n, ok := want[fn]
+	if !ok {
+		return nil, fmt.Errorf("line %d: unknown function '%s'", line, fn)
+	}
+	if len(args) != n {
+		return nil, fmt.Errorf("line %d: %s takes %d argument(s)", line, fn, n)
+	}
+	switch fn {
+	case "contains":
+		if items, ok := args[0].([]string); ok {
+			for _, item := range items {
+				if item == toString(args[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return strings.Contains(toString(args[0]), toString(args[1])), nil
+	case "exists":
+		_, err := os.Stat(toString(args[0]))
+		return err == nil, nil
+	case "lower":
+		return strings.ToLower(toString(args[0])), nil
+	default:
+		return strings.ToUpper(toString(args[0])), nil
+	}
+}
+
+func truthy(v Value) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	case []string:
+		return len(v) > 0
+	}
+	return false
+}
+
+func toString(v Value) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		return strings.Join(v, " ")
+	}
+	return ""
+}
+
+func toList(v Value) []string {
+	if l, ok := v.([]string); ok {
+		return l
+	}
+	return []string{toString(v)}
+}
+
+func toNumber(v Value) (float64, error) {
+	if n, ok := v.(float64); ok {
+		return n, nil
+	}
+	return strconv.ParseFloat(toString(v), 64)
+}
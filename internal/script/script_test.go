@@ -0,0 +1,151 @@
+package script
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testEnv has one variable of each type, all settable except hostname.
+func testEnv() (*Env, *[]string) {
+	var output []string
+	return &Env{
+		Vars: map[string]Value{
+			"hostname":    "desktop",
+			"gpu":         "",
+			"on_battery":  false,
+			"launch_args": []string{"-dx11"},
+		},
+		Settable:    map[string]bool{"gpu": true, "on_battery": true, "launch_args": true},
+		Environment: map[string]string{},
+		Output:      func(s string) { output = append(output, s) },
+	}, &output
+}
+
+func TestExpressions(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`1 + 2`, "3"},
+		{`"a" + 1`, "a1"},
+		{`1 + 2 == 3`, "true"},
+		{`1 == 1 && 2 == 3`, "false"},
+		{`true || false && false`, "true"},    // && binds tighter than ||
+		{`(true || false) && false`, "false"}, // Unless grouped
+		{`!false && false`, "false"},          // ! binds tighter than &&
+		{`!(false && false)`, "true"},
+		{`1 < 2 == true`, "true"},
+		{`10 > 9`, "true"}, // Numbers, not strings
+		{`"10" >= 9`, "true"},
+		{`2 <= 1`, "false"},
+		{`[1, "b"] + "c"`, "1 b c"},
+		{`contains(launch_args, "-dx11")`, "true"},
+		{`contains(hostname, "desk")`, "true"},
+		{`lower("ABC") + upper("def")`, "abcDEF"},
+		{`false && missing`, "false"}, // The right side is not evaluated
+		{`true || missing`, "true"},
+		{`hostname != "laptop"`, "true"},
+		{`1.5 + 1`, "2.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			env, output := testEnv()
+			if err := Run("test", "print "+tt.expr, env); err != nil {
+				t.Fatal(err)
+			}
+			if len(*output) != 1 || (*output)[0] != tt.want {
+				t.Fatalf("printed %q, want %q", *output, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatements(t *testing.T) {
+	env, output := testEnv()
+	src := `
+# Comments and blank lines are ignored.
+if on_battery {
+    set gpu = "integrated"
+} else if hostname == "desktop" {
+    set gpu = "discrete"; append launch_args "-high"
+} else {
+    fail "unreachable"
+}
+set env.DXVK_HUD = 1
+set on_battery = "yes"
+append launch_args ["-a", "-b"]
+print env.DXVK_HUD
+`
+	if err := Run("test", src, env); err != nil {
+		t.Fatal(err)
+	}
+	if env.Vars["gpu"] != "discrete" {
+		t.Errorf("gpu = %q", env.Vars["gpu"])
+	}
+	if want := []string{"-dx11", "-high", "-a", "-b"}; !reflect.DeepEqual(env.Vars["launch_args"], want) {
+		t.Errorf("launch_args = %q, want %q", env.Vars["launch_args"], want)
+	}
+	if env.Vars["on_battery"] != true {
+		t.Errorf("on_battery = %v, want it to stay a bool", env.Vars["on_battery"])
+	}
+	if env.Environment["DXVK_HUD"] != "1" || !reflect.DeepEqual(*output, []string{"1"}) {
+		t.Errorf("DXVK_HUD = %q, printed %q", env.Environment["DXVK_HUD"], *output)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		// Lexer
+		{"single ampersand", `print true & false`, `unexpected character '&'`},
+		{"single bar", `print true | false`, `unexpected character '|'`},
+		{"unknown character", `print $x`, `unexpected character '$'`},
+		{"unterminated string", `print "abc`, "unterminated string"},
+		{"string across lines", "print \"a\nb\"", "unterminated string"},
+		// Parser
+		{"unknown statement", `launch now`, "unknown statement 'launch'"},
+		{"statement starting with a value", `"x"`, "expected a statement"},
+		{"set without a variable", `set = 1`, "expected a variable after 'set'"},
+		{"set without =", `set gpu "x"`, "expected '='"},
+		{"trailing tokens", `set gpu = "a" "b"`, "unexpected 'b'"},
+		{"missing }", "if true {\nprint 1", "line 2: missing '}'"},
+		{"missing {", `if true print 1`, "expected '{'"},
+		{"missing )", `print (1 + 2`, "expected ')'"},
+		{"arguments without a comma", `print lower("a" "b")`, "expected ',' or ')'"},
+		{"list without a comma", `print [1 2]`, "expected ',' or ']'"},
+		{"dangling operator", `print 1 +`, "unexpected ''"},
+		{"invalid number", `print 1.2.3`, "invalid number '1.2.3'"},
+		// Evaluation
+		{"undefined variable", `print missing`, "line 1: unknown variable 'missing'"},
+		{"undefined variable in a condition", "\n\nif missing == 1 {\n}", "line 3: unknown variable 'missing'"},
+		{"undefined variable after &&", `print true && missing`, "unknown variable 'missing'"},
+		{"read-only variable", `set hostname = "x"`, "'hostname' cannot be set"},
+		{"unknown variable set", `set other = "x"`, "'other' cannot be set"},
+		{"append to a string", `append gpu "x"`, "cannot append to 'gpu'"},
+		{"comparing strings", `print "a" < "b"`, "'<' needs numbers"},
+		{"unknown function", `print shell("ls")`, "unknown function 'shell'"},
+		{"wrong argument count", `print lower("a", "b")`, "lower takes 1 argument(s)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, _ := testEnv()
+			err := Run("test", tt.src, env)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFail(t *testing.T) {
+	env, _ := testEnv()
+	err := Run("pre-run.yapl", `if !on_battery { fail "plug in " + hostname }`, env)
+	if !errors.Is(err, ErrFailed) || !strings.Contains(err.Error(), "pre-run.yapl: script failed: plug in desktop") {
+		t.Fatalf("got %v", err)
+	}
+}
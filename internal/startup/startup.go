@@ -0,0 +1,150 @@
+// Package startup times the phases of a launch (--profile-startup) to find out why a game
+// takes long to appear: loading the config, checking dependencies, preparing the prefix,
+// building the command and, finally, the game opening its first window.
+package startup
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"yapl/internal/events"
+)
+
+// pollInterval is how often the X server's window list is read while waiting for the game.
+const pollInterval = 100 * time.Millisecond
+
+type phase struct {
+	name string
+	took time.Duration
+}
+
+var (
+	mu       sync.Mutex
+	enabled  bool
+	begin    time.Time
+	last     time.Time
+	phases   []phase
+	reported bool
+)
+
+// Enable starts profiling; start is when yapl was started.
+func Enable(start time.Time) {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled, begin, last = true, start, start
+}
+
+// Phase ends the current phase, naming it. It does nothing unless profiling is enabled.
+func Phase(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !enabled || reported {
+		return
+	}
+	now := time.Now()
+	phases = append(phases, phase{name, now.Sub(last)})
+	last = now
+}
+
+// Watch is called just before the game is executed. Until the returned function is called
+// when the game exits, it waits for a new window to appear on the X display and then
+// prints the breakdown. If no window is seen, the breakdown is printed on exit.
+func Watch() (stop func()) {
+	mu.Lock()
+	on := enabled
+	mu.Unlock()
+	if !on {
+		return func() {}
+	}
+	before, err := windows()
+	if err != nil {
+		fmt.Printf("-> Startup profile: the first window cannot be detected (%v); timing until exit instead.\n", err)
+		return func() {
+			Phase("exec to exit")
+			report()
+		}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			now, err := windows()
+			if err != nil {
+				continue
+			}
+			for id := range now {
+				if !before[id] {
+					Phase("exec to window")
+					report()
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+		Phase("exec to exit (no window seen)")
+		report()
+	}
+}
+
+// windows returns the IDs of the X display's top-level client windows.
+func windows() (map[string]bool, error) {
+	if os.Getenv("DISPLAY") == "" {
+		return nil, fmt.Errorf("no X display")
+	}
+	out, err := exec.Command("xprop", "-root", "_NET_CLIENT_LIST").Output()
+	if err != nil {
+		return nil, fmt.Errorf("xprop: %w", err)
+	}
+	ids := map[string]bool{}
+	if _, list, ok := strings.Cut(string(out), "#"); ok {
+		for _, id := range strings.Split(list, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids[id] = true
+			}
+		}
+	}
+	return ids, nil
+}
+
+// report prints the breakdown once.
+func report() {
+	mu.Lock()
+	defer mu.Unlock()
+	if reported {
+		return
+	}
+	reported = true
+	total := last.Sub(begin)
+	fmt.Println("⏱️  Startup profile:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	var timings []map[string]interface{}
+	for _, p := range phases {
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(p.took) / float64(total)
+		}
+		fmt.Fprintf(w, "   %s\t%s\t%.0f%%\n", p.name, p.took.Round(time.Millisecond), share)
+		timings = append(timings, map[string]interface{}{"phase": p.name, "ms": p.took.Milliseconds()})
+	}
+	fmt.Fprintf(w, "   total\t%s\n", total.Round(time.Millisecond))
+	w.Flush()
+	events.Emit("startup_profile", map[string]interface{}{"phases": timings, "total_ms": total.Milliseconds()})
+}
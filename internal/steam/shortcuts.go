@@ -0,0 +1,165 @@
+package steam
+
+import (
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"yapl/internal/fs"
+)
+
+// Shortcut is a non-Steam game entry in shortcuts.vdf.
+type Shortcut struct {
+	AppName       string
+	Exe           string
+	StartDir      string
+	LaunchOptions string
+	Icon          string
+}
+
+// artNames maps artwork in a game's art/ directory to Steam's grid file suffixes.
+var artNames = map[string]string{
+	"portrait": "p",
+	"wide":     "",
+	"hero":     "_hero",
+	"logo":     "_logo",
+	"icon":     "_icon",
+}
+
+// shortcutFlags are the defaults Steam itself writes for a new shortcut.
+var shortcutFlags = []struct {
+	key   string
+	value uint32
+}{
+	{"IsHidden", 0},
+	{"AllowDesktopConfig", 1},
+	{"AllowOverlay", 1},
+	{"OpenVR", 0},
+	{"Devkit", 0},
+	{"DevkitOverrideAppID", 0},
+	{"LastPlayTime", 0},
+}
+
+// UserDataDirs returns the userdata/<id> directories of all Steam accounts on this machine.
+func UserDataDirs() []string {
+	home, _ := os.UserHomeDir()
+	roots := []string{
+		filepath.Join(home, ".steam", "steam"),
+		filepath.Join(home, ".local", "share", "Steam"),
+		filepath.Join(home, ".var", "app", "com.valvesoftware.Steam", ".local", "share", "Steam"),
+	}
+
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		matches, _ := filepath.Glob(filepath.Join(root, "userdata", "*"))
+		for _, dir := range matches {
+			resolved, err := filepath.EvalSymlinks(dir)
+			if err != nil || seen[resolved] || filepath.Base(dir) == "0" {
+				continue
+			}
+			if _, err := strconv.ParseUint(filepath.Base(dir), 10, 32); err != nil {
+				continue
+			}
+			seen[resolved] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// AppID returns the ID Steam assigns to a non-Steam shortcut, which also names its grid artwork.
+func (s Shortcut) AppID() uint32 {
+	return crc32.ChecksumIEEE([]byte(s.Exe+s.AppName)) | 0x80000000
+}
+
+// AddShortcut inserts or updates the shortcut in userDir's shortcuts.vdf and installs any
+// artwork found in artDir into the account's grid directory.
+func AddShortcut(userDir string, s Shortcut, artDir string) error {
+	configDir := filepath.Join(userDir, "config")
+	if err := fs.MustCreateDirectory(configDir); err != nil {
+		return err
+	}
+	vdfPath := filepath.Join(configDir, "shortcuts.vdf")
+
+	root := NewNode()
+	if data, err := os.ReadFile(vdfPath); err == nil {
+		if root, err = ParseVDF(data); err != nil {
+			return fmt.Errorf("could not parse %s: %w", vdfPath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	shortcuts := root.Map("shortcuts")
+	if shortcuts == nil {
+		shortcuts = NewNode()
+		root.Set("shortcuts", shortcuts)
+	}
+
+	if installed := installArt(filepath.Join(configDir, "grid"), artDir, s.AppID()); installed["icon"] != "" && s.Icon == "" {
+		s.Icon = installed["icon"]
+	}
+
+	entry := findShortcut(shortcuts, s.AppName)
+	if entry == nil {
+		entry = NewNode()
+		shortcuts.Set(strconv.Itoa(len(shortcuts.Keys)), entry)
+	}
+	entry.Set("appid", s.AppID())
+	entry.Set("AppName", s.AppName)
+	entry.Set("Exe", s.Exe)
+	entry.Set("StartDir", s.StartDir)
+	entry.Set("icon", s.Icon)
+	entry.Set("LaunchOptions", s.LaunchOptions)
+	for _, key := range []string{"ShortcutPath", "DevkitGameID", "FlatpakAppID"} {
+		if entry.Get(key) == nil {
+			entry.Set(key, "")
+		}
+	}
+	for _, flag := range shortcutFlags {
+		if entry.Get(flag.key) == nil {
+			entry.Set(flag.key, flag.value)
+		}
+	}
+	if entry.Map("tags") == nil {
+		entry.Set("tags", NewNode())
+	}
+
+	return os.WriteFile(vdfPath, root.Bytes(), 0644)
+}
+
+// findShortcut returns the existing entry with the given name, matched case-sensitively
+// like Steam itself does.
+func findShortcut(shortcuts *Node, appName string) *Node {
+	for _, key := range shortcuts.Keys {
+		entry := shortcuts.Map(key)
+		if entry != nil && (entry.String("AppName") == appName || entry.String("appname") == appName) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// installArt copies portrait/wide/hero/logo/icon images from artDir into Steam's grid directory.
+func installArt(gridDir, artDir string, appID uint32) map[string]string {
+	installed := make(map[string]string)
+	for name, suffix := range artNames {
+		for _, ext := range []string{".png", ".jpg"} {
+			src := filepath.Join(artDir, name+ext)
+			if _, err := os.Stat(src); err != nil {
+				continue
+			}
+			if err := fs.MustCreateDirectory(gridDir); err != nil {
+				continue
+			}
+			dst := filepath.Join(gridDir, fmt.Sprintf("%d%s%s", appID, suffix, ext))
+			if err := fs.CopyFile(src, dst); err == nil {
+				installed[name] = dst
+			}
+			break
+		}
+	}
+	return installed
+}
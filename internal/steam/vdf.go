@@ -0,0 +1,143 @@
+package steam
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Binary VDF type markers as used by shortcuts.vdf.
+const (
+	typeMap    byte = 0x00
+	typeString byte = 0x01
+	typeInt    byte = 0x02
+	typeEnd    byte = 0x08
+)
+
+// Node is an ordered binary VDF map. Values are string, uint32 or *Node.
+type Node struct {
+	Keys   []string
+	Values map[string]interface{}
+}
+
+// NewNode returns an empty map node.
+func NewNode() *Node {
+	return &Node{Values: make(map[string]interface{})}
+}
+
+// Set stores a value, keeping the position of an existing key.
+func (n *Node) Set(key string, value interface{}) {
+	if _, ok := n.Values[key]; !ok {
+		n.Keys = append(n.Keys, key)
+	}
+	n.Values[key] = value
+}
+
+// Get returns the value stored under key, or nil.
+func (n *Node) Get(key string) interface{} {
+	return n.Values[key]
+}
+
+// Map returns the child map stored under key, or nil.
+func (n *Node) Map(key string) *Node {
+	child, _ := n.Values[key].(*Node)
+	return child
+}
+
+// String returns the string stored under key, or "".
+func (n *Node) String(key string) string {
+	s, _ := n.Values[key].(string)
+	return s
+}
+
+// ParseVDF decodes a binary VDF document such as shortcuts.vdf.
+func ParseVDF(data []byte) (*Node, error) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	root := NewNode()
+	if err := parseMap(r, root); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return root, nil
+}
+
+func parseMap(r *bufio.Reader, node *Node) error {
+	for {
+		kind, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if kind == typeEnd {
+			return nil
+		}
+		key, err := readCString(r)
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case typeMap:
+			child := NewNode()
+			if err := parseMap(r, child); err != nil {
+				return err
+			}
+			node.Set(key, child)
+		case typeString:
+			value, err := readCString(r)
+			if err != nil {
+				return err
+			}
+			node.Set(key, value)
+		case typeInt:
+			var value uint32
+			if err := binary.Read(r, binary.LittleEndian, &value); err != nil {
+				return err
+			}
+			node.Set(key, value)
+		default:
+			return fmt.Errorf("unsupported vdf type 0x%02x for key '%s'", kind, key)
+		}
+	}
+}
+
+func readCString(r *bufio.Reader) (string, error) {
+	s, err := r.ReadString(0)
+	if err != nil {
+		return "", err
+	}
+	return s[:len(s)-1], nil
+}
+
+// Bytes encodes the node as binary VDF.
+func (n *Node) Bytes() []byte {
+	var buf bytes.Buffer
+	n.write(&buf)
+	buf.WriteByte(typeEnd)
+	return buf.Bytes()
+}
+
+func (n *Node) write(buf *bytes.Buffer) {
+	for _, key := range n.Keys {
+		switch v := n.Values[key].(type) {
+		case *Node:
+			buf.WriteByte(typeMap)
+			writeCString(buf, key)
+			v.write(buf)
+			buf.WriteByte(typeEnd)
+		case string:
+			buf.WriteByte(typeString)
+			writeCString(buf, key)
+			writeCString(buf, v)
+		case uint32:
+			buf.WriteByte(typeInt)
+			writeCString(buf, key)
+			binary.Write(buf, binary.LittleEndian, v)
+		}
+	}
+}
+
+func writeCString(buf *bytes.Buffer, s string) {
+	buf.WriteString(s)
+	buf.WriteByte(0)
+}
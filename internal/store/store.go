@@ -0,0 +1,173 @@
+// Package store deduplicates the files of installed Proton builds and dependencies. Every
+// file is hardlinked to an object named after its content in store/objects, so identical
+// files in different versions share one copy on disk.
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Dir holds the objects, relative to the yapl root.
+const Dir = "store/objects"
+
+// Stats summarizes a Dedup run.
+type Stats struct {
+	Files  int   // Regular files looked at
+	Linked int   // Files replaced by a link to an existing object
+	Saved  int64 // Bytes freed by those links
+}
+
+var enabled bool
+
+// SetEnabled makes Proton and dependency installs deduplicate themselves automatically.
+func SetEnabled(e bool) {
+	enabled = e
+}
+
+// Enabled reports whether new installs are deduplicated.
+func Enabled() bool { return enabled }
+
+// Dedup hashes every regular file under dir and hardlinks it to the store: to the
+// existing object with the same content and permissions, or as a new object.
+func Dedup(dir string) (Stats, error) {
+	var stats Stats
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".yapl-") {
+			return err // yapl's own metadata files are rewritten in place, so never shared
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() == 0 {
+			return err
+		}
+		stats.Files++
+		linked, err := dedupFile(path, info)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if linked {
+			stats.Linked++
+			stats.Saved += info.Size()
+		}
+		return nil
+	})
+	return stats, err
+}
+
+func dedupFile(path string, info fs.FileInfo) (bool, error) {
+	sum, err := hashFile(path)
+	if err != nil {
+		return false, err
+	}
+	// Links share their permissions, so files that differ only in mode are kept apart.
+	object := filepath.Join(Dir, sum[:2], fmt.Sprintf("%s-%o", sum[2:], info.Mode().Perm()))
+	objInfo, err := os.Stat(object)
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(object), 0755); err != nil {
+			return false, err
+		}
+		return false, os.Link(path, object)
+	}
+	if err != nil {
+		return false, err
+	}
+	if os.SameFile(info, objInfo) {
+		return false, nil
+	}
+	tmp := path + ".yapl-link"
+	if err := os.Link(object, tmp); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
+
+// LinkTree recreates src at dst with every regular file hardlinked instead of copied. The
+// caller must replace, not rewrite, any file it changes in dst afterwards.
+func LinkTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return os.Link(path, target)
+		}
+		return nil // Skip sockets, pipes and devices
+	})
+}
+
+// GC removes objects that no installed file links to any more and returns how many it
+// removed and the bytes that freed.
+func GC() (int, int64, error) {
+	removed, freed := 0, int64(0)
+	err := filepath.WalkDir(Dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Nlink == 1 {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			os.Remove(filepath.Dir(path)) // Only succeeds once the directory is empty
+			removed++
+			freed += info.Size()
+		}
+		return nil
+	})
+	return removed, freed, err
+}
+
+// CrossDevice reports whether err means the store and the files are on different
+// filesystems, where hardlinks are impossible.
+func CrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
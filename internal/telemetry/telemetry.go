@@ -0,0 +1,191 @@
+// Package telemetry keeps the anonymous usage statistics a user may choose to send to
+// the maintainers. Nothing is collected or sent unless runner.json enables it, and the
+// report is built from local files that `yapl telemetry show` prints in full.
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"yapl/internal/config"
+	"yapl/internal/journal"
+	"yapl/internal/sbom"
+)
+
+// statePath holds the failure counts collected since the last submission.
+const statePath = "state/telemetry.json"
+
+// Failure categories, deliberately coarse: no names, paths or messages are kept.
+const (
+	FailureDependencies = "dependencies" // Proton, DXVK, VKD3D or umu could not be installed
+	FailureRuntime      = "runtime"      // The Steam Linux Runtime could not be installed
+	FailurePrefix       = "prefix"       // The Wine prefix could not be created or updated
+	FailureHook         = "hook"         // A pre-run script or plugin stopped the launch
+	FailureLaunch       = "launch"       // The game could not be started
+)
+
+var settings config.Telemetry
+
+// Set applies runner.json's telemetry settings for this process.
+func Set(t *config.Telemetry) {
+	settings = config.Telemetry{}
+	if t != nil {
+		settings = *t
+	}
+}
+
+// Enabled reports whether the user opted in.
+func Enabled() bool { return settings.Enabled }
+
+// Report is everything a submission contains.
+type Report struct {
+	YaplVersion   string         `json:"yapl_version"`
+	Distro        string         `json:"distro"`
+	Arch          string         `json:"arch"`
+	Since         *time.Time     `json:"since,omitempty"`
+	LaunchMethods map[string]int `json:"launch_methods"`
+	ExitCodes     map[string]int `json:"exit_codes"` // "zero", "nonzero" or "signal"
+	Failures      map[string]int `json:"failures"`
+}
+
+type state struct {
+	LastSubmitted time.Time      `json:"last_submitted,omitempty"`
+	Failures      map[string]int `json:"failures,omitempty"`
+}
+
+// RecordFailure counts a failed launch in the given category. It does nothing unless
+// telemetry is enabled.
+func RecordFailure(category string) {
+	if !settings.Enabled {
+		return
+	}
+	st := readState()
+	if st.Failures == nil {
+		st.Failures = map[string]int{}
+	}
+	st.Failures[category]++
+	writeState(st)
+}
+
+// Build assembles the report from the session journal and the recorded failures, covering
+// the time since the last submission.
+func Build() (Report, error) {
+	st := readState()
+	r := Report{
+		YaplVersion:   sbom.YaplVersion(),
+		Distro:        distro(),
+		Arch:          runtime.GOARCH,
+		LaunchMethods: map[string]int{},
+		ExitCodes:     map[string]int{},
+		Failures:      map[string]int{},
+	}
+	if !st.LastSubmitted.IsZero() {
+		r.Since = &st.LastSubmitted
+	}
+	for k, v := range st.Failures {
+		r.Failures[k] = v
+	}
+	sessions, err := journal.Query(journal.DefaultPath, journal.Filter{Since: st.LastSubmitted})
+	if err != nil {
+		return r, err
+	}
+	for _, s := range sessions {
+		method := s.LaunchMethod
+		if method == "" {
+			method = "container"
+		}
+		r.LaunchMethods[method]++
+		switch {
+		case s.ExitCode == 0:
+			r.ExitCodes["zero"]++
+		case s.ExitCode < 0:
+			r.ExitCodes["signal"]++
+		default:
+			r.ExitCodes["nonzero"]++
+		}
+	}
+	return r, nil
+}
+
+// Submit sends the report to the configured endpoint and starts a new collection period.
+func Submit() error {
+	if !settings.Enabled {
+		return fmt.Errorf("telemetry is disabled; run 'yapl telemetry enable' first")
+	}
+	if settings.Endpoint == "" {
+		return fmt.Errorf("no telemetry.endpoint is set in runner.json")
+	}
+	r, err := Build()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Post(settings.Endpoint, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("could not submit statistics: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("could not submit statistics: %s", resp.Status)
+	}
+	writeState(state{LastSubmitted: time.Now().UTC()})
+	return nil
+}
+
+// Reset discards the failures recorded so far.
+func Reset() error {
+	st := readState()
+	st.Failures = nil
+	return writeState(st)
+}
+
+func readState() state {
+	var st state
+	if data, err := os.ReadFile(statePath); err == nil {
+		json.Unmarshal(data, &st)
+	}
+	return st
+}
+
+func writeState(st state) error {
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(statePath, data, 0644)
+}
+
+// distro returns the ID and VERSION_ID from os-release, e.g. "fedora 41".
+func distro() string {
+	f, err := os.Open("/etc/os-release")
+	if err != nil {
+		return "unknown"
+	}
+	defer f.Close()
+	fields := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if k, v, ok := strings.Cut(scanner.Text(), "="); ok {
+			fields[k] = strings.Trim(v, `"'`)
+		}
+	}
+	if fields["ID"] == "" {
+		return "unknown"
+	}
+	return strings.TrimSpace(fields["ID"] + " " + fields["VERSION_ID"])
+}
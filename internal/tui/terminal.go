@@ -0,0 +1,82 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// terminal switches stdin between cooked and raw mode.
+type terminal struct {
+	fd    uintptr
+	saved syscall.Termios
+}
+
+func ioctl(fd, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// IsTerminal reports whether f is connected to a terminal.
+func IsTerminal(f *os.File) bool {
+	var t syscall.Termios
+	return ioctl(f.Fd(), syscall.TCGETS, unsafe.Pointer(&t)) == nil
+}
+
+// makeRaw disables line buffering and echo so single key presses can be read.
+func makeRaw(f *os.File) (*terminal, error) {
+	term := &terminal{fd: f.Fd()}
+	if err := ioctl(term.fd, syscall.TCGETS, unsafe.Pointer(&term.saved)); err != nil {
+		return nil, err
+	}
+	raw := term.saved
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.ISIG | syscall.IEXTEN
+	raw.Iflag &^= syscall.IXON | syscall.ICRNL
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(term.fd, syscall.TCSETS, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return term, nil
+}
+
+// ReadPassword prints prompt to stderr and reads a line from stdin, a terminal, without
+// echoing it.
+func ReadPassword(prompt string) (string, error) {
+	term := &terminal{fd: os.Stdin.Fd()}
+	if err := ioctl(term.fd, syscall.TCGETS, unsafe.Pointer(&term.saved)); err != nil {
+		return "", err
+	}
+	quiet := term.saved
+	quiet.Lflag &^= syscall.ECHO
+	if err := ioctl(term.fd, syscall.TCSETS, unsafe.Pointer(&quiet)); err != nil {
+		return "", err
+	}
+	defer term.restore()
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// restore returns the terminal to the mode it was in before makeRaw.
+func (t *terminal) restore() {
+	ioctl(t.fd, syscall.TCSETS, unsafe.Pointer(&t.saved))
+}
+
+// size returns the terminal's rows and columns, falling back to 24x80.
+func size(f *os.File) (int, int) {
+	var ws struct{ Row, Col, X, Y uint16 }
+	if err := ioctl(f.Fd(), syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil || ws.Row == 0 {
+		return 24, 80
+	}
+	return int(ws.Row), int(ws.Col)
+}
@@ -0,0 +1,174 @@
+// Package tui is a full-screen terminal launcher for the local games and apps.
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Item is a game or app shown in the library list.
+type Item struct {
+	Type          string // "games" or "apps"
+	Name          string
+	ProtonVersion string
+	Initialized   bool
+	LastPlayed    time.Time
+}
+
+// Action is something the user can do with the selected item.
+type Action struct {
+	Key   byte
+	Name  string
+	Label string
+}
+
+// Actions are offered for every item; Enter runs the first one.
+var Actions = []Action{
+	{'r', "run", "run"},
+	{'s', "setup", "setup"},
+	{'p', "package", "package"},
+	{'b', "backup", "backup saves"},
+	{'k', "kill", "kill"},
+}
+
+const (
+	clearScreen = "\x1b[H\x1b[2J"
+	altScreen   = "\x1b[?1049h\x1b[?25l"
+	mainScreen  = "\x1b[?25h\x1b[?1049l"
+	reverse     = "\x1b[7m"
+	dim         = "\x1b[2m"
+	reset       = "\x1b[0m"
+)
+
+// Run shows the library until the user quits. load is called again after every action
+// so the status columns stay current; perform runs an action on the normal screen.
+func Run(load func() ([]Item, error), perform func(action string, item Item) error) error {
+	if !IsTerminal(os.Stdin) || !IsTerminal(os.Stdout) {
+		return errors.New("the TUI needs an interactive terminal")
+	}
+	items, err := load()
+	if err != nil {
+		return err
+	}
+
+	selected, status := 0, ""
+	for {
+		action, quit, err := browse(items, &selected, status)
+		if err != nil || quit {
+			return err
+		}
+
+		item := items[selected]
+		fmt.Printf("-> %s %s '%s'\n\n", strings.ToUpper(action.Name[:1])+action.Name[1:], strings.TrimSuffix(item.Type, "s"), item.Name)
+		if err := perform(action.Name, item); err != nil {
+			fmt.Printf("\n❌ %s failed: %v\n", action.Label, err)
+			status = fmt.Sprintf("%s '%s' failed: %v", action.Label, item.Name, err)
+		} else {
+			status = fmt.Sprintf("%s '%s' finished", action.Label, item.Name)
+		}
+		fmt.Print("\nPress Enter to return to the library...")
+		bufio.NewReader(os.Stdin).ReadString('\n')
+
+		if items, err = load(); err != nil {
+			return err
+		}
+		selected = min(selected, max(len(items)-1, 0))
+	}
+}
+
+// browse draws the list in raw mode and returns the action chosen for items[*selected].
+func browse(items []Item, selected *int, status string) (Action, bool, error) {
+	term, err := makeRaw(os.Stdin)
+	if err != nil {
+		return Action{}, false, err
+	}
+	fmt.Print(altScreen)
+	defer func() {
+		fmt.Print(mainScreen)
+		term.restore()
+	}()
+
+	buf := make([]byte, 8)
+	for {
+		draw(items, *selected, status)
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return Action{}, false, err
+		}
+		key := string(buf[:n])
+		switch key {
+		case "q", "\x1b", "\x03": // q, Esc, Ctrl-C
+			return Action{}, true, nil
+		case "\x1b[A", "\x1bOA":
+			*selected = max(*selected-1, 0)
+			continue
+		case "\x1b[B", "\x1bOB":
+			*selected = min(*selected+1, len(items)-1)
+			continue
+		case "\r", "\n":
+			key = string(Actions[0].Key)
+		}
+		if len(items) == 0 || len(key) != 1 {
+			continue
+		}
+		for _, action := range Actions {
+			if action.Key == key[0] {
+				return action, false, nil
+			}
+		}
+		status = fmt.Sprintf("unknown key '%s'", key)
+	}
+}
+
+func draw(items []Item, selected int, status string) {
+	rows, cols := size(os.Stdout)
+	var b strings.Builder
+	b.WriteString(clearScreen)
+	b.WriteString(fit("yapl library", cols) + "\r\n\r\n")
+	b.WriteString(dim + fit(fmt.Sprintf("  %-5s %-30s %-20s %-8s %s", "TYPE", "NAME", "PROTON", "PREFIX", "LAST PLAYED"), cols) + reset + "\r\n")
+
+	if len(items) == 0 {
+		b.WriteString("  No games or apps found. Create one with 'yapl --game <name> setup'.\r\n")
+	}
+	// Scroll so the selection stays visible between the header and the footer.
+	visible := max(rows-6, 1)
+	first := max(selected-visible+1, 0)
+	for i := first; i < len(items) && i < first+visible; i++ {
+		item := items[i]
+		prefix := "-"
+		if item.Initialized {
+			prefix = "ready"
+		}
+		played := "never"
+		if !item.LastPlayed.IsZero() {
+			played = item.LastPlayed.Local().Format("2006-01-02 15:04")
+		}
+		line := fit(fmt.Sprintf("  %-5s %-30s %-20s %-8s %s", strings.TrimSuffix(item.Type, "s"), item.Name, item.ProtonVersion, prefix, played), cols)
+		if i == selected {
+			line = reverse + line + reset
+		}
+		b.WriteString(line + "\r\n")
+	}
+
+	var help []string
+	for _, action := range Actions {
+		help = append(help, fmt.Sprintf("%c %s", action.Key, action.Label))
+	}
+	fmt.Fprintf(&b, "\x1b[%d;1H", rows-1)
+	b.WriteString(fit(status, cols) + "\r\n")
+	b.WriteString(dim + fit("↑/↓ select  enter run  "+strings.Join(help, "  ")+"  q quit", cols) + reset)
+	os.Stdout.WriteString(b.String())
+}
+
+// fit cuts s to the terminal width.
+func fit(s string, cols int) string {
+	r := []rune(s)
+	if len(r) > cols {
+		return string(r[:cols])
+	}
+	return s
+}
@@ -0,0 +1,115 @@
+// Package warn logs warnings that can repeat once per file, e.g. per copied DLL or
+// extracted entry, collapsing the repeats into a count so they don't flood the output.
+package warn
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
+
+// shown is how many warnings of one kind are logged before the rest are only counted.
+const shown = 3
+
+type kind struct {
+	count int
+	last  string // The last warning that was not shown
+}
+
+var (
+	mu     sync.Mutex
+	kinds  = map[string]*kind{}
+	order  []string
+	total  int // Warning and error lines logged
+	strict bool
+)
+
+// Printf logs a warning like log.Printf, with the ⚠️ prefix added. Warnings are grouped by
+// format; once a group has been logged a few times, further ones are counted and reported
+// by Summary.
+func Printf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	mu.Lock()
+	k, ok := kinds[format]
+	if !ok {
+		k = &kind{}
+		kinds[format] = k
+		order = append(order, format)
+	}
+	k.count++
+	if k.count > shown {
+		k.last = msg
+		mu.Unlock()
+		return
+	}
+	mu.Unlock()
+	log.Printf("⚠️  %s", msg)
+}
+
+// Writer passes log output through to w, counting the lines that are warnings (⚠️) or
+// errors (❌) for Count.
+func Writer(w io.Writer) io.Writer {
+	return countingWriter{w}
+}
+
+type countingWriter struct {
+	w io.Writer
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(string(bytes.TrimRight(p, "\n")), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "⚠️") || strings.HasPrefix(trimmed, "❌") {
+			mu.Lock()
+			total++
+			mu.Unlock()
+		}
+	}
+	return c.w.Write(p)
+}
+
+// SetStrict makes Check fail the command if anything was counted.
+func SetStrict(on bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	strict = on
+}
+
+// Check ends the command with an error in strict mode if it logged any warning or error,
+// after Summary has reported the warnings that were not shown.
+func Check() {
+	mu.Lock()
+	n, on := total, strict
+	mu.Unlock()
+	if on && n > 0 {
+		log.Fatalf("❌ Failing because of --strict: %d warning(s) or error(s) were logged.", n)
+	}
+}
+
+// Count returns how many warning and error lines were logged through Writer. Warnings
+// Printf did not show are not counted, but Summary's line for them is.
+func Count() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return total
+}
+
+// Summary logs how many warnings of each kind were not shown, and starts counting anew.
+// Count keeps its total.
+func Summary() {
+	mu.Lock()
+	var lines []string
+	for _, format := range order {
+		if k := kinds[format]; k.count > shown {
+			lines = append(lines, fmt.Sprintf("⚠️  %d more warning(s) like this were not shown: %s", k.count-shown, k.last))
+		}
+	}
+	kinds, order = map[string]*kind{}, nil
+	mu.Unlock()
+	for _, line := range lines {
+		log.Print(line)
+	}
+}
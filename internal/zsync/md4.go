@@ -0,0 +1,69 @@
+package zsync
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// md4 computes the MD4 digest (RFC 1320), which zsync uses as its strong block checksum.
+// MD4 is broken as a cryptographic hash; the whole file is verified with SHA-1 afterwards.
+func md4(data []byte) [16]byte {
+	s := [4]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476}
+
+	length := len(data)
+	tail := make([]byte, 0, 128)
+	full := length &^ 63
+	tail = append(tail, data[full:]...)
+	tail = append(tail, 0x80)
+	for len(tail)%64 != 56 {
+		tail = append(tail, 0)
+	}
+	tail = binary.LittleEndian.AppendUint64(tail, uint64(length)*8)
+
+	for off := 0; off < full; off += 64 {
+		md4Block(&s, data[off:off+64])
+	}
+	for off := 0; off < len(tail); off += 64 {
+		md4Block(&s, tail[off:off+64])
+	}
+
+	var sum [16]byte
+	for i, v := range s {
+		binary.LittleEndian.PutUint32(sum[i*4:], v)
+	}
+	return sum
+}
+
+var (
+	md4Order2 = [16]int{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15}
+	md4Order3 = [16]int{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15}
+	md4Shift1 = [4]int{3, 7, 11, 19}
+	md4Shift2 = [4]int{3, 5, 9, 13}
+	md4Shift3 = [4]int{3, 9, 11, 15}
+)
+
+func md4Block(s *[4]uint32, block []byte) {
+	var x [16]uint32
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(block[i*4:])
+	}
+	a, b, c, d := s[0], s[1], s[2], s[3]
+
+	for i := 0; i < 16; i++ {
+		f := (b & c) | (^b & d)
+		a, b, c, d = d, bits.RotateLeft32(a+f+x[i], md4Shift1[i%4]), b, c
+	}
+	for i := 0; i < 16; i++ {
+		g := (b & c) | (b & d) | (c & d)
+		a, b, c, d = d, bits.RotateLeft32(a+g+x[md4Order2[i]]+0x5a827999, md4Shift2[i%4]), b, c
+	}
+	for i := 0; i < 16; i++ {
+		h := b ^ c ^ d
+		a, b, c, d = d, bits.RotateLeft32(a+h+x[md4Order3[i]]+0x6ed9eba1, md4Shift3[i%4]), b, c
+	}
+
+	s[0] += a
+	s[1] += b
+	s[2] += c
+	s[3] += d
+}
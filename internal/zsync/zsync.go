@@ -0,0 +1,343 @@
+// Package zsync updates a local copy of a large download by fetching only the blocks
+// that changed, as described by the .zsync control file published next to it.
+package zsync
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"yapl/internal/downloads"
+)
+
+// ErrUnavailable means the server publishes no usable .zsync file for the download.
+var ErrUnavailable = errors.New("no zsync control file available")
+
+// Stats describes how a file was assembled.
+type Stats struct {
+	Reused     int64 // Bytes copied from the previous version
+	Downloaded int64 // Bytes fetched from the server
+}
+
+// control is a parsed .zsync file.
+type control struct {
+	url           string
+	blockSize     int
+	length        int64
+	rsumBytes     int
+	checksumBytes int
+	sha1          string
+	blocks        []blockSum
+}
+
+type blockSum struct {
+	a, b     uint16
+	checksum []byte
+}
+
+// Sync brings the file at path up to date with the download described by controlURL,
+// reusing every block it already contains. If path does not exist yet, the whole file is
+// downloaded. The result is verified against the control file's SHA-1 before it replaces
+// path.
+func Sync(controlURL, path string) (Stats, error) {
+	ctl, err := fetchControl(controlURL)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	tmp := path + ".part"
+	out, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer os.Remove(tmp)
+	defer out.Close()
+	if err := out.Truncate(ctl.length); err != nil {
+		return Stats{}, err
+	}
+
+	have := make([]bool, len(ctl.blocks))
+	var stats Stats
+	if stats.Reused, err = reuseBlocks(ctl, path, out, have); err != nil {
+		return Stats{}, fmt.Errorf("reading previous version: %w", err)
+	}
+	if stats.Downloaded, err = fetchMissing(ctl, out, have); err != nil {
+		return Stats{}, err
+	}
+
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return Stats{}, err
+	}
+	hash := sha1.New()
+	if _, err := io.Copy(hash, io.LimitReader(out, ctl.length)); err != nil {
+		return Stats{}, err
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); ctl.sha1 != "" && sum != ctl.sha1 {
+		return Stats{}, fmt.Errorf("checksum mismatch after update: got %s, want %s", sum, ctl.sha1)
+	}
+	if err := out.Close(); err != nil {
+		return Stats{}, err
+	}
+	return stats, os.Rename(tmp, path)
+}
+
+func fetchControl(controlURL string) (*control, error) {
+	resp, err := http.Get(controlURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", controlURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrUnavailable
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", controlURL, resp.Status)
+	}
+	return parseControl(bufio.NewReader(resp.Body), controlURL)
+}
+
+// parseControl reads the "Key: value" header and the per-block checksums that follow it.
+func parseControl(r *bufio.Reader, controlURL string) (*control, error) {
+	ctl := &control{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("reading zsync header: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		key, value, _ := strings.Cut(line, ": ")
+		switch key {
+		case "Blocksize":
+			ctl.blockSize, err = strconv.Atoi(value)
+		case "Length":
+			ctl.length, err = strconv.ParseInt(value, 10, 64)
+		case "Hash-Lengths":
+			parts := strings.Split(value, ",")
+			if len(parts) != 3 {
+				return nil, fmt.Errorf("invalid Hash-Lengths '%s'", value)
+			}
+			if ctl.rsumBytes, err = strconv.Atoi(parts[1]); err == nil {
+				ctl.checksumBytes, err = strconv.Atoi(parts[2])
+			}
+		case "URL":
+			if ctl.url == "" {
+				ctl.url, err = resolve(controlURL, value)
+			}
+		case "SHA-1":
+			ctl.sha1 = strings.ToLower(value)
+		case "Z-URL", "Z-Map2":
+			// Blocks of a file that was compressed after zsyncmake ran; not supported.
+			return nil, ErrUnavailable
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid zsync header '%s': %w", line, err)
+		}
+	}
+	if ctl.blockSize <= 0 || ctl.length <= 0 || ctl.url == "" ||
+		ctl.rsumBytes < 1 || ctl.rsumBytes > 4 || ctl.checksumBytes < 1 || ctl.checksumBytes > 16 {
+		return nil, errors.New("incomplete zsync header")
+	}
+
+	count := int((ctl.length + int64(ctl.blockSize) - 1) / int64(ctl.blockSize))
+	ctl.blocks = make([]blockSum, count)
+	record := make([]byte, ctl.rsumBytes+ctl.checksumBytes)
+	for i := range ctl.blocks {
+		if _, err := io.ReadFull(r, record); err != nil {
+			return nil, fmt.Errorf("reading block checksums: %w", err)
+		}
+		// The weak checksum is stored big-endian as a then b, truncated from the front.
+		var rsum [4]byte
+		copy(rsum[4-ctl.rsumBytes:], record[:ctl.rsumBytes])
+		ctl.blocks[i] = blockSum{
+			a:        uint16(rsum[0])<<8 | uint16(rsum[1]),
+			b:        uint16(rsum[2])<<8 | uint16(rsum[3]),
+			checksum: bytes.Clone(record[ctl.rsumBytes:]),
+		}
+	}
+	return ctl, nil
+}
+
+func resolve(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return b.ResolveReference(r).String(), nil
+}
+
+// reuseBlocks slides zsync's rolling checksum over the previous version and copies every
+// block of the new file found in it, marking it in have. It returns the bytes reused.
+func reuseBlocks(ctl *control, path string, out *os.File, have []bool) (int64, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	bs := ctl.blockSize
+	if info.Size() < int64(bs) {
+		return 0, nil
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return 0, err
+	}
+	defer syscall.Munmap(data)
+
+	// Only the low byte of a is stored with 3 rsum bytes, and none of it with fewer.
+	aMask := uint16(0xffff)
+	switch {
+	case ctl.rsumBytes < 3:
+		aMask = 0
+	case ctl.rsumBytes == 3:
+		aMask = 0xff
+	}
+	key := func(a, b uint16) uint32 { return uint32(a&aMask)<<16 | uint32(b) }
+
+	index := make(map[uint32][]int, len(ctl.blocks))
+	// A bitmap in front of the map skips the lookup for almost all offsets.
+	const filterBits = 1 << 24
+	filter := make([]uint64, filterBits/64)
+	hashKey := func(k uint32) uint32 { return (k * 2654435761) >> 8 }
+	for i, blk := range ctl.blocks {
+		k := key(blk.a, blk.b)
+		index[k] = append(index[k], i)
+		h := hashKey(k)
+		filter[h/64] |= 1 << (h % 64)
+	}
+
+	var reused int64
+	n := len(data)
+	a, b := rsum(data[:bs])
+	for pos := 0; ; {
+		k := key(a, b)
+		h := hashKey(k)
+		matched := false
+		if filter[h/64]&(1<<(h%64)) != 0 {
+			if ids, ok := index[k]; ok {
+				sum := md4(data[pos : pos+bs])
+				for _, id := range ids {
+					if have[id] || !bytes.Equal(sum[:ctl.checksumBytes], ctl.blocks[id].checksum) {
+						continue
+					}
+					size := int64(bs)
+					if remaining := ctl.length - int64(id)*int64(bs); remaining < size {
+						size = remaining
+					}
+					if _, err := out.WriteAt(data[pos:pos+int(size)], int64(id)*int64(bs)); err != nil {
+						return reused, err
+					}
+					have[id] = true
+					reused += size
+					matched = true
+				}
+			}
+		}
+
+		if matched && pos+2*bs <= n {
+			pos += bs
+			a, b = rsum(data[pos : pos+bs])
+			continue
+		}
+		if pos+bs >= n {
+			return reused, nil
+		}
+		// Roll the window one byte forward.
+		oc, nc := uint16(data[pos]), uint16(data[pos+bs])
+		a += nc - oc
+		b += a - oc*uint16(bs)
+		pos++
+	}
+}
+
+// rsum is zsync's weak checksum of a block: a is the byte sum and b weights each byte by
+// its distance from the end of the block.
+func rsum(block []byte) (a, b uint16) {
+	n := len(block)
+	for i, c := range block {
+		a += uint16(c)
+		b += uint16(n-i) * uint16(c)
+	}
+	return a, b
+}
+
+// fetchMissing downloads the blocks not marked in have with HTTP range requests, merging
+// nearby runs to keep the number of requests down. It returns the bytes downloaded.
+func fetchMissing(ctl *control, out *os.File, have []bool) (int64, error) {
+	bs := int64(ctl.blockSize)
+	maxGap := max(1, 65536/ctl.blockSize)
+
+	type span struct{ first, last int }
+	var spans []span
+	for i := 0; i < len(have); i++ {
+		if have[i] {
+			continue
+		}
+		if len(spans) > 0 && i-spans[len(spans)-1].last <= maxGap {
+			spans[len(spans)-1].last = i
+		} else {
+			spans = append(spans, span{i, i})
+		}
+	}
+	if len(spans) == 0 {
+		return 0, nil
+	}
+
+	queued, err := downloads.Acquire(ctl.url)
+	if err != nil {
+		return 0, fmt.Errorf("download queue: %w", err)
+	}
+	defer queued.Release()
+
+	var downloaded int64
+	for _, s := range spans {
+		start := int64(s.first) * bs
+		end := min(int64(s.last+1)*bs, ctl.length) - 1
+		req, err := http.NewRequest(http.MethodGet, ctl.url, nil)
+		if err != nil {
+			return downloaded, err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return downloaded, fmt.Errorf("fetching blocks: %w", err)
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			return downloaded, fmt.Errorf("server answered range request with %s", resp.Status)
+		}
+		n, err := io.Copy(io.NewOffsetWriter(out, start), io.LimitReader(queued.Reader(resp.Body), end-start+1))
+		resp.Body.Close()
+		downloaded += n
+		if err != nil {
+			return downloaded, fmt.Errorf("fetching blocks: %w", err)
+		}
+		if n != end-start+1 {
+			return downloaded, fmt.Errorf("fetching blocks: short read at %d", start+n)
+		}
+	}
+	return downloaded, nil
+}
@@ -0,0 +1,177 @@
+package zsync
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMD4(t *testing.T) {
+	// The test suite of RFC 1320, appendix A.5.
+	vectors := map[string]string{
+		"":                           "31d6cfe0d16ae931b73c59d7e0c089c0",
+		"a":                          "bde52cb31de33e46245e05fbdbd6fb24",
+		"abc":                        "a448017aaf21d8525fc10ae87aa6729d",
+		"message digest":             "d9130a8164549fe818874806e1c7014b",
+		"abcdefghijklmnopqrstuvwxyz": "d79e1c308aa5bbcdeea8ed63df412da9",
+		"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789":                   "043f8582f241db351ce627e153e7f0e4",
+		"12345678901234567890123456789012345678901234567890123456789012345678901234567890": "e33b4ddc9c38f2199c3e7b164fcc0536",
+	}
+	for input, want := range vectors {
+		if sum := md4([]byte(input)); hex.EncodeToString(sum[:]) != want {
+			t.Errorf("md4(%q) = %x, want %s", input, sum, want)
+		}
+	}
+}
+
+// makeControl writes a .zsync file for data the way zsyncmake does, with the last block
+// padded with zeros and the checksums truncated to the given lengths.
+func makeControl(data []byte, blockSize, rsumBytes, checksumBytes int, url, sha string) []byte {
+	if sha == "" {
+		sum := sha1.Sum(data)
+		sha = hex.EncodeToString(sum[:])
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "zsync: 0.6.2\nFilename: file\nBlocksize: %d\nLength: %d\nHash-Lengths: 1,%d,%d\nURL: %s\nSHA-1: %s\n\n",
+		blockSize, len(data), rsumBytes, checksumBytes, url, sha)
+	for off := 0; off < len(data); off += blockSize {
+		block := make([]byte, blockSize)
+		copy(block, data[off:])
+		a, b := rsum(block)
+		var weak [4]byte
+		binary.BigEndian.PutUint16(weak[:], a)
+		binary.BigEndian.PutUint16(weak[2:], b)
+		strong := md4(block)
+		buf.Write(weak[4-rsumBytes:])
+		buf.Write(strong[:checksumBytes])
+	}
+	return buf.Bytes()
+}
+
+// serve publishes data at /file and its control file at /file.zsync, counting the bytes
+// of file requested.
+func serve(t *testing.T, data, ctl []byte, served *int64) string {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/file", func(w http.ResponseWriter, r *http.Request) {
+		cw := &countingWriter{ResponseWriter: w, n: served}
+		http.ServeContent(cw, r, "file", time.Time{}, bytes.NewReader(data))
+	})
+	mux.HandleFunc("/file.zsync", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(ctl)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n *int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c.n += int64(len(p))
+	return c.ResponseWriter.Write(p)
+}
+
+func TestSyncRoundTrip(t *testing.T) {
+	const blockSize = 1024
+	rng := rand.New(rand.NewSource(1))
+	// More blocks than the gap over which missing runs are fetched in one request, so
+	// only the changed and the partial last block are downloaded.
+	const blocks = 200
+	newData := make([]byte, blocks*blockSize+300)
+	rng.Read(newData)
+
+	// The previous version has 77 bytes in front, so every block is at a shifted offset,
+	// and one changed block in the middle.
+	old := append(make([]byte, 77), newData...)
+	copy(old[77+10*blockSize:], bytes.Repeat([]byte{0xEE}, blockSize))
+
+	for _, lengths := range [][2]int{{4, 16}, {3, 8}, {2, 4}} {
+		t.Run(fmt.Sprintf("%d,%d", lengths[0], lengths[1]), func(t *testing.T) {
+			t.Chdir(t.TempDir()) // For the download queue
+			var served int64
+			base := serve(t, newData, makeControl(newData, blockSize, lengths[0], lengths[1], "file", ""), &served)
+			path := filepath.Join(t.TempDir(), "file")
+			if err := os.WriteFile(path, old, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			stats, err := Sync(base+"/file.zsync", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := os.ReadFile(path)
+			if !bytes.Equal(got, newData) {
+				t.Fatal("the synced file differs from the download")
+			}
+			if stats.Reused != (blocks-1)*blockSize {
+				t.Errorf("reused %d bytes, want the %d unchanged full blocks", stats.Reused, blocks-1)
+			}
+			if stats.Reused+stats.Downloaded != int64(len(newData)) {
+				t.Errorf("reused %d and downloaded %d bytes of %d", stats.Reused, stats.Downloaded, len(newData))
+			}
+			if served != stats.Downloaded || served != blockSize+300 {
+				t.Errorf("the server sent %d bytes, the stats say %d", served, stats.Downloaded)
+			}
+		})
+	}
+}
+
+func TestSyncWithoutPreviousVersion(t *testing.T) {
+	t.Chdir(t.TempDir())
+	data := bytes.Repeat([]byte("yapl runtime "), 500)
+	var served int64
+	base := serve(t, data, makeControl(data, 512, 4, 16, "file", ""), &served)
+	path := filepath.Join(t.TempDir(), "file")
+
+	stats, err := Sync(base+"/file.zsync", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, data) {
+		t.Fatal("the downloaded file differs")
+	}
+	if stats.Reused != 0 || stats.Downloaded != int64(len(data)) {
+		t.Fatalf("got %+v, want the whole file downloaded", stats)
+	}
+}
+
+func TestSyncKeepsFileOnChecksumMismatch(t *testing.T) {
+	t.Chdir(t.TempDir())
+	data := bytes.Repeat([]byte("new version "), 1000)
+	var served int64
+	base := serve(t, data, makeControl(data, 512, 4, 16, "file", strings.Repeat("0", 40)), &served)
+	path := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(path, []byte("old version"), 0644)
+
+	if _, err := Sync(base+"/file.zsync", path); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("got %v, want a checksum mismatch", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "old version" {
+		t.Fatal("the previous version was replaced")
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Fatal("the partial file was left behind")
+	}
+}
+
+func TestSyncUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	if _, err := Sync(server.URL+"/file.zsync", filepath.Join(t.TempDir(), "file")); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got %v, want ErrUnavailable", err)
+	}
+}
@@ -0,0 +1,157 @@
+package yapl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"yapl/internal/app"
+	"yapl/internal/archive"
+	"yapl/internal/command"
+	"yapl/internal/dependency"
+)
+
+// Options change how a game is set up and launched, like the command's flags.
+type Options struct {
+	Upgrade  bool   // Download the Proton build again (--upgrade-proton)
+	Debug    bool   // Verbose Proton logging (--debug)
+	Steam    bool   // Run the Steam client in the prefix instead of the executable (--steam)
+	AdminPIN string // Bypasses parental controls (--pin)
+}
+
+// PackageOptions choose what Package puts in the bundle, like the package command's flags.
+type PackageOptions = app.PackageOptions
+
+// Game is a game or app of the storage root.
+type Game struct {
+	app *app.App
+}
+
+// Open loads a game or app, kind being Games or Apps, with the root's runner.json.
+func Open(kind, name string, opts Options) (*Game, error) {
+	if kind != Games && kind != Apps {
+		return nil, fmt.Errorf("unknown kind '%s'. Use '%s' or '%s'", kind, Games, Apps)
+	}
+	globalCfg, err := LoadGlobalConfig()
+	if err != nil {
+		return nil, fmt.Errorf("could not load global config: %w", err)
+	}
+	appCfg, err := LoadAppConfig(kind, name, &globalCfg)
+	if err != nil {
+		return nil, err
+	}
+	a := app.New(kind, name, opts.Upgrade, opts.Debug, opts.Steam, globalCfg, appCfg)
+	a.AdminPIN = opts.AdminPIN
+	return &Game{app: a}, nil
+}
+
+// Name returns the game's directory name.
+func (g *Game) Name() string { return g.app.Name }
+
+// Dir returns the game's directory, relative to the root.
+func (g *Game) Dir() string { return g.app.AppDir }
+
+// Config returns the game's config, with the templates and defaults it builds on applied.
+func (g *Game) Config() AppConfig { return g.app.AppConfig }
+
+// check catches the config problems that the command reports by exiting.
+func (g *Game) check() error {
+	if _, ok := g.app.GlobalConfig.ProtonVersions[g.app.AppConfig.ProtonVersion]; !ok {
+		return fmt.Errorf("proton version '%s' not defined in runner.json", g.app.AppConfig.ProtonVersion)
+	}
+	return nil
+}
+
+// locked runs fn while holding the game's lock, as the command does, so a program and
+// the command do not change the game at the same time.
+func (g *Game) locked(operation string, fn func() error) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	unlock, err := g.app.Lock(operation, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
+
+// EnsureDependencies installs the Proton build, runtime and dependencies the game uses,
+// if they are not installed yet.
+func (g *Game) EnsureDependencies() error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	if err := dependency.EnsureAll(g.app.AppConfig, g.app.ForceUpgrade, g.app.GlobalConfig); err != nil {
+		return err
+	}
+	return dependency.EnsureRuntime(g.app.AppConfig, g.app.GlobalConfig)
+}
+
+// Setup installs the game's dependencies and creates its prefix.
+func (g *Game) Setup() error {
+	return g.locked("setup", g.app.Setup)
+}
+
+// Run launches the game and waits for it to exit. A game that exits with an error is not
+// an error of Run; see ExitCode.
+func (g *Game) Run() error {
+	return g.locked("run", g.app.Run)
+}
+
+// Warm reads the game's files into memory and starts its wineserver ahead of a launch.
+func (g *Game) Warm() error {
+	return g.locked("warm", g.app.Warm)
+}
+
+// ExitCode returns the exit code of the last program Run launched.
+func (g *Game) ExitCode() int {
+	return command.LastExitCode()
+}
+
+// SetGameOutput sends the output of the programs Run launches to stdout and stderr
+// instead of the process's own, e.g. to show it apart from yapl's progress messages.
+func SetGameOutput(stdout, stderr io.Writer) {
+	command.SetGameOutput(stdout, stderr)
+}
+
+// Kill stops the game's wineserver, and with force any process still using its prefix.
+func (g *Game) Kill(force bool) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.app.Kill(force)
+}
+
+// Package writes a bundle of the game to the working directory, format being gz, xz or zst.
+func (g *Game) Package(format string, opts PackageOptions) error {
+	return g.locked("package", func() error {
+		return g.app.Package(format, opts)
+	})
+}
+
+// Unpackage extracts a bundle into games/ or apps/, kind being Games or Apps, installs
+// the components bundled with it, and returns the name of the game it holds.
+func Unpackage(kind, bundle string) (string, error) {
+	if kind != Games && kind != Apps {
+		return "", fmt.Errorf("unknown kind '%s'. Use '%s' or '%s'", kind, Games, Apps)
+	}
+	name, ok := archive.TrimArchiveSuffix(filepath.Base(bundle))
+	if !ok {
+		return "", fmt.Errorf("'%s' is not a bundle (<name>.tar.*)", bundle)
+	}
+	if _, err := LoadGlobalConfig(); err != nil {
+		return "", fmt.Errorf("could not load global config: %w", err)
+	}
+	if err := os.MkdirAll(kind, 0755); err != nil {
+		return "", err
+	}
+	if err := archive.UnpackageInto(kind, []string{bundle}, nil, "", false); err != nil {
+		return "", err
+	}
+	if _, err := dependency.InstallBundled(filepath.Join(kind, name)); err != nil {
+		return name, fmt.Errorf("could not install the components bundled with '%s': %w", name, err)
+	}
+	return name, nil
+}
@@ -0,0 +1,176 @@
+// Package yapl lets other programs, such as third-party launchers, use what the yapl
+// command does: load a storage root's configuration, install the Proton builds, runtimes
+// and dependencies games use, and set up, launch, stop and package games.
+//
+// Every path yapl keeps is relative to the storage root, so EnterRoot makes it the working
+// directory and a program works with one root at a time. Failures are returned as errors;
+// progress is printed to stdout as the command prints it.
+package yapl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"yapl/internal/archive"
+	"yapl/internal/config"
+	"yapl/internal/downloads"
+	"yapl/internal/library"
+	"yapl/internal/plugin"
+	"yapl/internal/store"
+	"yapl/internal/telemetry"
+)
+
+// GlobalConfig is the storage root's runner.json.
+type GlobalConfig = config.Global
+
+// AppConfig is a game's game.json or an app's app.json.
+type AppConfig = config.App
+
+// VersionInfo says where a Proton build, runtime or dependency version comes from.
+type VersionInfo = config.VersionInfo
+
+// Kinds of targets: games live in games/<name>, apps in apps/<name>.
+const (
+	Games = "games"
+	Apps  = "apps"
+)
+
+// EnterRoot makes the storage root the working directory, as every path yapl keeps is
+// relative to it. The root is --root, else $YAPL_HOME, else the 'root' set in the current
+// directory's runner.json, else the current directory.
+func EnterRoot(flagRoot string) error {
+	root := flagRoot
+	if root == "" {
+		root = os.Getenv("YAPL_HOME")
+	}
+	if root == "" {
+		var err error
+		if root, err = config.StorageRoot("runner.json"); err != nil {
+			return fmt.Errorf("could not read runner.json: %w", err)
+		}
+	}
+	if root == "" {
+		return nil
+	}
+	if strings.HasPrefix(root, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			root = filepath.Join(home, root[2:])
+		}
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("could not create root '%s': %w", root, err)
+	}
+	if err := os.Chdir(root); err != nil {
+		return fmt.Errorf("could not enter root '%s': %w", root, err)
+	}
+	return nil
+}
+
+// LoadGlobalConfig reads the root's runner.json, creating a default one if there is none,
+// and applies its process-wide settings: extraction, packaging, downloads and plugins.
+func LoadGlobalConfig() (config.Global, error) {
+	globalCfg, err := config.LoadOrCreateGlobal("runner.json")
+	if err != nil {
+		return config.Global{}, err
+	}
+
+	var extraction config.Extraction
+	if globalCfg.Extraction != nil {
+		extraction = *globalCfg.Extraction
+	}
+	if !archive.ValidModePolicy(extraction.ModePolicy) {
+		return config.Global{}, fmt.Errorf("unknown extraction.mode_policy '%s'. Use 'preserve', 'umask', or 'normalize'", extraction.ModePolicy)
+	}
+	umask, err := archive.ParseUmask(extraction.Umask)
+	if err != nil {
+		return config.Global{}, err
+	}
+	if extraction.CaseConflicts == "" {
+		extraction.CaseConflicts = archive.CaseWarn
+	}
+	if !archive.ValidCaseStrategy(extraction.CaseConflicts) {
+		return config.Global{}, fmt.Errorf("unknown extraction.case_conflicts '%s'. Use 'warn', 'merge', 'skip', or 'error'", extraction.CaseConflicts)
+	}
+	if extraction.InvalidNames == "" {
+		extraction.InvalidNames = archive.NamesWarn
+	}
+	if !archive.ValidNameStrategy(extraction.InvalidNames) {
+		return config.Global{}, fmt.Errorf("unknown extraction.invalid_names '%s'. Use 'keep', 'warn', or 'sanitize'", extraction.InvalidNames)
+	}
+	if extraction.Ownership == "" && extraction.Owner != "" {
+		extraction.Ownership = archive.OwnershipChown
+	}
+	if !archive.ValidOwnership(extraction.Ownership) {
+		return config.Global{}, fmt.Errorf("unknown extraction.ownership '%s'. Use 'none', 'chown', or 'manifest'", extraction.Ownership)
+	}
+	var owner *archive.Owner
+	if extraction.Owner != "" {
+		parsed, err := archive.ParseOwner(extraction.Owner)
+		if err != nil {
+			return config.Global{}, fmt.Errorf("extraction.owner: %w", err)
+		}
+		owner = &parsed
+	}
+	archive.SetExtractOptions(archive.ExtractOptions{
+		ModePolicy:    extraction.ModePolicy,
+		Umask:         umask,
+		CaseConflicts: extraction.CaseConflicts,
+		InvalidNames:  extraction.InvalidNames,
+		Ownership:     extraction.Ownership,
+		Owner:         owner,
+	})
+
+	if globalCfg.Packaging != nil && globalCfg.Packaging.Owner != "" {
+		packageOwner, err := archive.ParseOwner(globalCfg.Packaging.Owner)
+		if err != nil {
+			return config.Global{}, fmt.Errorf("packaging.owner: %w", err)
+		}
+		archive.SetPackageOwner(packageOwner)
+	}
+	if globalCfg.Packaging != nil {
+		for format, level := range globalCfg.Packaging.Levels {
+			if err := archive.SetLevel(format, level); err != nil {
+				return config.Global{}, fmt.Errorf("packaging.levels: %w", err)
+			}
+		}
+	}
+	telemetry.Set(globalCfg.Telemetry)
+	store.SetEnabled(globalCfg.Store != nil && globalCfg.Store.Enabled)
+	if err := plugin.CheckHooks(globalCfg.Plugins); err != nil {
+		return config.Global{}, fmt.Errorf("plugins: %w", err)
+	}
+	if dl := globalCfg.Downloads; dl != nil {
+		downloads.SetMaxConcurrent(dl.MaxConcurrent)
+		if dl.Metered != "" && dl.Metered != "defer" && dl.Metered != "allow" {
+			return config.Global{}, fmt.Errorf("unknown downloads.metered '%s'. Use 'defer' or 'allow'", dl.Metered)
+		}
+		if err := downloads.SetSchedule(dl.AllowedHours, dl.Metered != "allow"); err != nil {
+			return config.Global{}, fmt.Errorf("downloads.allowed_hours: %w", err)
+		}
+		if err := downloads.SetUpdateChecks(dl.UpdateCheckBudget, dl.UpdateCheckTTL); err != nil {
+			return config.Global{}, fmt.Errorf("downloads: %w", err)
+		}
+	}
+	return globalCfg, nil
+}
+
+// LoadAppConfig returns the config of a game or app, kind being Games or Apps. It is
+// installed from the shared library first if runner.json has one, and created with the
+// defaults if it does not exist yet. Versions that plugins resolve are added to global.
+func LoadAppConfig(kind, name string, global *GlobalConfig) (AppConfig, error) {
+	if global.Library != nil && global.Library.Path != "" {
+		if _, err := library.Materialize(global.Library.Path, kind, name); err != nil {
+			return AppConfig{}, fmt.Errorf("could not install from library: %w", err)
+		}
+	}
+	appCfg, err := config.LoadOrCreateApp(kind, name, *global)
+	if err != nil {
+		return AppConfig{}, fmt.Errorf("could not load or create app config: %w", err)
+	}
+	if err := plugin.ResolveVersions(global, appCfg); err != nil {
+		return AppConfig{}, fmt.Errorf("could not resolve versions: %w", err)
+	}
+	return appCfg, nil
+}